@@ -0,0 +1,162 @@
+package ft232h
+
+import (
+	"fmt"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// DGPIO stores interface configuration settings for the general-purpose pins
+// of MPSSE port "D" and provides methods for reading and writing to them.
+// The DGPIO interface is always initialized and available in any mode, but the
+// pins reserved by the active SPI or I²C interface (see Reserved) are protected
+// from being changed through the DGPIO interface.
+type DGPIO struct {
+	device *FT232H
+	config *GPIOConfig
+}
+
+func (gpio *DGPIO) String() string {
+	return fmt.Sprintf("{ FT232H: %p, Config: %q, Reserved: %08b }",
+		gpio.device, gpio.config, gpio.Reserved())
+}
+
+// DGPIOConfigDefault returns the default pin levels and directions for the
+// DGPIO interface. All pins are configured as inputs at logic level LOW by
+// default.
+func DGPIOConfigDefault() *GPIOConfig {
+	return GPIOConfigDefault()
+}
+
+// Reserved returns the bitmask of port "D" pins reserved by the active mode.
+// In SPI mode, these are SCLK (D0), MOSI (D1), MISO (D2), and the configured CS
+// pin if it is a DPin. In I²C mode, these are SCL (D0) and SDA (D1, D2), and
+// the adaptive clock input RTCK (D7) if clock stretching is enabled (see
+// I2COption). In JTAG mode, these are TCK (D0), TDI (D1), TDO (D2), and TMS
+// (D3). In UART mode, all pins are reserved. Otherwise, no pins are reserved.
+func (gpio *DGPIO) Reserved() uint8 {
+	const bus = 0x07 // D0-D2
+	switch gpio.device.mode {
+	case ModeSPI:
+		if cs := gpio.device.SPI.config.chipSelect; cs.IsMPSSE() {
+			return bus | cs.Mask()
+		}
+		return bus
+	case ModeI2C:
+		if gpio.device.I2C.config.stretch {
+			return bus | i2cRTCK
+		}
+		return bus
+	case ModeJTAG:
+		return jtagTCK | jtagTDI | jtagTDO | jtagTMS
+	case ModeUART:
+		return 0xFF
+	default:
+		return 0
+	}
+}
+
+// Init resets all unreserved DGPIO pin directions and values using the most
+// recently read or written configuration, returning a non-nil error if
+// unsuccessful.
+// Has no effect if the Port implements PortChannel, since the port "D" pins
+// are then managed entirely by the Port.
+func (gpio *DGPIO) Init() error {
+	if nil != gpio.device.info.engine.channel {
+		return nil
+	}
+	return gpio.Config(gpio.config)
+}
+
+// Config configures all unreserved DGPIO pin directions and values to the
+// settings defined in the given cfg, returning a non-nil error if unsuccessful.
+// The settings of reserved pins in cfg are ignored while they are reserved.
+func (gpio *DGPIO) Config(cfg *GPIOConfig) error {
+	gpio.config.Write(cfg.Dir, cfg.Val)
+	return gpio.Write(cfg.Val)
+}
+
+// ConfigPin configures the given DGPIO pin direction and value, returning a
+// non-nil error if the pin is reserved by the active mode.
+// The direction and value of all other pins is set based on the most recently
+// read or written configuration determined prior to this call, and are all
+// updated during this call.
+// If you need more fine-grained control, use Read()/Write() directly.
+func (gpio *DGPIO) ConfigPin(pin DPin, dir Dir, val bool) error {
+	if (gpio.Reserved() & pin.Mask()) > 0 {
+		return fmt.Errorf("pin reserved by %s mode: %s", gpio.device.mode, pin)
+	}
+	if !pin.Valid() {
+		return fmt.Errorf("invalid pin: %v", pin)
+	}
+	gpio.config.set(pin.Mask(), dir, val)
+	return gpio.Write(gpio.config.Val)
+}
+
+// Write sets the value of all unreserved output pins at once using the given
+// bitmask val, returning a non-nil error if unsuccessful.
+// The levels and directions of reserved pins are not changed.
+func (gpio *DGPIO) Write(val uint8) error {
+
+	val &= gpio.config.Dir // set only the pins configured as OUTPUT
+	_, err := gpio.device.info.engine.exec(gpio.command(val))
+	if nil != err {
+		return err
+	}
+	gpio.config.Val = val
+	return nil
+}
+
+// command returns the command setting the level of all unreserved output pins
+// using the given bitmask val, and the direction of all unreserved pins using
+// the current configuration.
+func (gpio *DGPIO) command(val uint8) mpsse.Command {
+	eng := gpio.device.info.engine
+	res, dir := gpio.Reserved(), gpio.config.Dir
+	return eng.setLow((eng.low&res)|(val&dir & ^res), (eng.lowDir&res)|(dir & ^res))
+}
+
+// output configures the given pin as output with the given val, returning the
+// command that applies the configuration of all unreserved pins, or a non-nil
+// error if the pin is reserved by the active mode.
+func (gpio *DGPIO) output(pin DPin, val bool) (mpsse.Command, error) {
+	if (gpio.Reserved() & pin.Mask()) > 0 {
+		return nil, fmt.Errorf("pin reserved by %s mode: %s", gpio.device.mode, pin)
+	}
+	gpio.config.set(pin.Mask(), Output, val)
+	return gpio.command(gpio.config.Val), nil
+}
+
+// Read returns the current value of all port "D" pins, including reserved pins,
+// returning 0 and a non-nil error if unsuccessful.
+func (gpio *DGPIO) Read() (uint8, error) {
+
+	val, err := gpio.device.info.engine.readLow()
+	if nil != err {
+		return 0, err
+	}
+	gpio.config.Val = val
+	return val, nil
+}
+
+// Set sets the given pin to output with the given val.
+// See ConfigPin() for other semantics.
+func (gpio *DGPIO) Set(pin DPin, val bool) error {
+	return gpio.ConfigPin(pin, Output, val)
+}
+
+// Get reads the current value of the given pin.
+func (gpio *DGPIO) Get(pin DPin) (bool, error) {
+	set, err := gpio.Read()
+	if nil != err {
+		return false, err
+	}
+	return (set & pin.Mask()) > 0, nil
+}
+
+// Chdir changes the DGPIO direction of the given pin.
+// Use ConfigPin() to change both direction and value, or Config() to change all
+// pin directions (and values).
+func (gpio *DGPIO) Chdir(pin DPin, dir Dir) error {
+	return gpio.ConfigPin(pin, dir, (gpio.config.Val&pin.Mask()) > 0)
+}
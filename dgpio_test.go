@@ -0,0 +1,93 @@
+package ft232h
+
+import (
+	"testing"
+)
+
+func TestDGPIO(t *testing.T) {
+
+	gpio := &simLoopGPIO{}
+	ft, err := OpenSim("dgpio", func(d *SimDevice) error {
+		d.AttachDGPIO(gpio)
+		return d.AttachSPI(D(3), &simRegSPI{})
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	t.Run("None", func(t *testing.T) {
+		if 0 != ft.DGPIO.Reserved() {
+			t.Fatalf("Reserved()={%08b}, expected={%08b}", ft.DGPIO.Reserved(), 0)
+		}
+		if err := ft.DGPIO.Config(&GPIOConfig{Dir: 0x0F, Val: 0x05}); nil != err {
+			t.Fatalf("DGPIO.Config(): %v", err)
+		}
+		if gpio.dir != 0x0F || gpio.val != 0x05 {
+			t.Fatalf("DGPIO dir={%08b} val={%08b}", gpio.dir, gpio.val)
+		}
+		val, err := ft.DGPIO.Read()
+		if nil != err {
+			t.Fatalf("DGPIO.Read(): %v", err)
+		}
+		if exp := uint8(0xF5); val != exp {
+			t.Fatalf("DGPIO.Read()={%08b}, expected={%08b}", val, exp)
+		}
+	})
+
+	t.Run("SPI", func(t *testing.T) {
+		if err := ft.DGPIO.Config(&GPIOConfig{Dir: 0x00, Val: 0x00}); nil != err {
+			t.Fatalf("DGPIO.Config(): %v", err)
+		}
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		if exp := uint8(0x0F); exp != ft.DGPIO.Reserved() {
+			t.Fatalf("Reserved()={%08b}, expected={%08b}", ft.DGPIO.Reserved(), exp)
+		}
+		for _, p := range []DPin{D(0), D(1), D(2), D(3)} {
+			if err := ft.DGPIO.Set(p, true); nil == err {
+				t.Fatalf("DGPIO.Set(%s): expected error for reserved pin", p)
+			}
+		}
+		if err := ft.DGPIO.Set(D(5), true); nil != err {
+			t.Fatalf("DGPIO.Set(): %v", err)
+		}
+		// writing all pins must not disturb SCLK/MOSI/MISO/CS
+		if err := ft.DGPIO.Config(&GPIOConfig{Dir: 0xFF, Val: 0x00}); nil != err {
+			t.Fatalf("DGPIO.Config(): %v", err)
+		}
+		if exp := uint8(0xFB); gpio.dir != exp || 0 == (gpio.val&D(3).Mask()) {
+			t.Fatalf("DGPIO dir={%08b} val={%08b}, expected dir={%08b} with CS HIGH",
+				gpio.dir, gpio.val, exp)
+		}
+		if err := ft.DGPIO.Set(D(7), true); nil != err {
+			t.Fatalf("DGPIO.Set(): %v", err)
+		}
+		if _, err := ft.SPI.Write([]uint8{0x00, 0xAA}, true, true); nil != err {
+			t.Fatalf("SPI.Write(): %v", err)
+		}
+		if set, err := ft.DGPIO.Get(D(7)); nil != err || !set {
+			t.Fatalf("DGPIO.Get(): %t, %v", set, err)
+		}
+	})
+
+	t.Run("I2C", func(t *testing.T) {
+		if err := ft.I2C.Init(); nil != err {
+			t.Fatalf("I2C.Init(): %v", err)
+		}
+		if exp := uint8(0x07); exp != ft.DGPIO.Reserved() {
+			t.Fatalf("Reserved()={%08b}, expected={%08b}", ft.DGPIO.Reserved(), exp)
+		}
+		// the pin configuration persists across mode changes
+		if set, err := ft.DGPIO.Get(D(7)); nil != err || !set {
+			t.Fatalf("DGPIO.Get(): %t, %v", set, err)
+		}
+		if err := ft.DGPIO.Set(D(3), false); nil != err {
+			t.Fatalf("DGPIO.Set(): %v", err)
+		}
+		if err := ft.DGPIO.Chdir(D(1), Input); nil == err {
+			t.Fatalf("DGPIO.Chdir(): expected error for reserved pin")
+		}
+	})
+}
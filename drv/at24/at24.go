@@ -0,0 +1,249 @@
+// Package at24 provides access to 24Cxx-family serial EEPROM devices (e.g.,
+// Microchip/Atmel AT24C, ST M24C, and compatible parts) on the I²C interface of
+// an FT232H.
+//
+// The geometry of each supported part (capacity, page size, and word address
+// width) is predefined by the Part variables. Parts larger than their word
+// address space (24C04-24C16 with 8-bit, and 24C1024/24C1025 with 16-bit word
+// addresses) select the upper bits of the memory address with the low bits of
+// the I²C slave address; each such block is accessed as a separate slave
+// transparently.
+//
+// EEPROM implements io.ReaderAt and io.WriterAt. WriteAt splits the data on
+// page boundaries, and after each page polls the device, which does not ACK
+// its address until its internal write cycle completes, e.g.:
+//
+//	e, err := at24.New(ft.I2C, at24.DefaultAddress, at24.Part24C256)
+//	...
+//	_, err = e.WriteAt([]byte("board rev C"), 0x100)
+package at24
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ardnew/ft232h"
+)
+
+// DefaultAddress is the slave address of a 24Cxx device with all address pins
+// (A2, A1, A0) tied LOW.
+const DefaultAddress uint = 0x50
+
+// Constants defining the time allowed for an internal write cycle to complete
+// (typically 5 ms, at most 10 ms on older parts), the delay between polls of
+// the device while waiting, and the maximum number of bytes read in a single
+// I²C transfer.
+const (
+	writeTimeout = 50 * time.Millisecond
+	pollInterval = 500 * time.Microsecond
+	readChunk    = 4096
+)
+
+// Part describes the geometry of a 24Cxx EEPROM part.
+type Part struct {
+	Name      string           // part number, e.g. "24C02"
+	Size      int64            // capacity (bytes)
+	PageSize  int              // page write buffer size (bytes)
+	AddrSpace ft232h.AddrSpace // word address width (Addr8Bit or Addr16Bit)
+	BlockBit  uint             // lowest slave address bit selecting the block
+}
+
+// Predefined parts of the 24Cxx family.
+var (
+	Part24C01   = Part{Name: "24C01", Size: 128, PageSize: 8, AddrSpace: ft232h.Addr8Bit}
+	Part24C02   = Part{Name: "24C02", Size: 256, PageSize: 8, AddrSpace: ft232h.Addr8Bit}
+	Part24C04   = Part{Name: "24C04", Size: 512, PageSize: 16, AddrSpace: ft232h.Addr8Bit}
+	Part24C08   = Part{Name: "24C08", Size: 1024, PageSize: 16, AddrSpace: ft232h.Addr8Bit}
+	Part24C16   = Part{Name: "24C16", Size: 2048, PageSize: 16, AddrSpace: ft232h.Addr8Bit}
+	Part24C32   = Part{Name: "24C32", Size: 4096, PageSize: 32, AddrSpace: ft232h.Addr16Bit}
+	Part24C64   = Part{Name: "24C64", Size: 8192, PageSize: 32, AddrSpace: ft232h.Addr16Bit}
+	Part24C128  = Part{Name: "24C128", Size: 16384, PageSize: 64, AddrSpace: ft232h.Addr16Bit}
+	Part24C256  = Part{Name: "24C256", Size: 32768, PageSize: 64, AddrSpace: ft232h.Addr16Bit}
+	Part24C512  = Part{Name: "24C512", Size: 65536, PageSize: 128, AddrSpace: ft232h.Addr16Bit}
+	Part24C1024 = Part{Name: "24C1024", Size: 131072, PageSize: 256, AddrSpace: ft232h.Addr16Bit}
+	Part24C1025 = Part{Name: "24C1025", Size: 131072, PageSize: 256, AddrSpace: ft232h.Addr16Bit, BlockBit: 2}
+)
+
+// Parts lists all of the predefined parts.
+var Parts = []Part{
+	Part24C01, Part24C02, Part24C04, Part24C08, Part24C16, Part24C32,
+	Part24C64, Part24C128, Part24C256, Part24C512, Part24C1024, Part24C1025,
+}
+
+// String returns a descriptive string of a Part.
+func (p Part) String() string {
+	return fmt.Sprintf("{ Name: %s, Size: %d, PageSize: %d, AddrSpace: %s }",
+		p.Name, p.Size, p.PageSize, p.AddrSpace)
+}
+
+// blockSize returns the number of bytes addressed by a single slave address.
+func (p Part) blockSize() int64 { return 1 << p.AddrSpace.Bits() }
+
+// blockMask returns the bits of the slave address selecting the block.
+func (p Part) blockMask() uint {
+	n := uint((p.Size + p.blockSize() - 1) / p.blockSize())
+	m := uint(0)
+	for b := uint(1); b < n; b <<= 1 {
+		m = m<<1 | 1
+	}
+	return m << p.BlockBit
+}
+
+// validate returns a non-nil error if the receiver is not a valid geometry.
+func (p Part) validate() error {
+	switch p.AddrSpace {
+	case ft232h.Addr8Bit, ft232h.Addr16Bit:
+	default:
+		return fmt.Errorf("invalid word address space: %s", p.AddrSpace)
+	}
+	if p.Size <= 0 || 0 != p.Size&(p.Size-1) {
+		return fmt.Errorf("invalid size (must be a power of 2): %d", p.Size)
+	}
+	if p.PageSize <= 0 || 0 != p.PageSize&(p.PageSize-1) ||
+		int64(p.PageSize) > p.Size || int64(p.PageSize) > p.blockSize() {
+		return fmt.Errorf("invalid page size: %d", p.PageSize)
+	}
+	if p.blockMask() > 0x07 {
+		return fmt.Errorf("too many block select bits: 0x%02X", p.blockMask())
+	}
+	return nil
+}
+
+// EEPROM is a 24Cxx serial EEPROM device.
+type EEPROM struct {
+	i2c  *ft232h.I2C
+	addr uint
+	part Part
+}
+
+// New returns a new EEPROM with the given geometry at the given 7-bit slave
+// address on the given I²C interface, which must already be initialized (see
+// ft232h.I2C.Init). The slave address bits used by the part to select a block
+// (see package documentation) must be 0.
+func New(i2c *ft232h.I2C, addr uint, part Part) (*EEPROM, error) {
+
+	if nil == i2c {
+		return nil, fmt.Errorf("nil I2C interface")
+	}
+	if addr < ft232h.I2CSlaveAddressMin || addr > ft232h.I2CSlaveAddressMax {
+		return nil, fmt.Errorf("invalid slave address: 0x%02X", addr)
+	}
+	if err := part.validate(); nil != err {
+		return nil, err
+	}
+	if 0 != addr&part.blockMask() {
+		return nil, fmt.Errorf("%s slave address 0x%02X overlaps block select bits 0x%02X",
+			part.Name, addr, part.blockMask())
+	}
+
+	return &EEPROM{i2c: i2c, addr: addr, part: part}, nil
+}
+
+// String returns a descriptive string of an EEPROM.
+func (e *EEPROM) String() string {
+	return fmt.Sprintf("{ Addr: 0x%02X, Part: %s }", e.addr, e.part)
+}
+
+// Addr returns the 7-bit slave address of the device.
+func (e *EEPROM) Addr() uint { return e.addr }
+
+// Part returns the geometry of the device.
+func (e *EEPROM) Part() Part { return e.part }
+
+// Size returns the capacity of the device in bytes.
+func (e *EEPROM) Size() int64 { return e.part.Size }
+
+// ReadAt reads len(p) bytes starting at byte offset off of the device into p,
+// using sequential reads. Implements io.ReaderAt.
+func (e *EEPROM) ReadAt(p []byte, off int64) (int, error) {
+
+	if off < 0 {
+		return 0, fmt.Errorf("invalid offset: %d", off)
+	}
+	n := 0
+	for n < len(p) {
+		if off >= e.part.Size {
+			return n, io.EOF
+		}
+		k := e.span(off, readChunk)
+		if k > int64(len(p)-n) {
+			k = int64(len(p) - n)
+		}
+		slave, word := e.locate(off)
+		rd, err := e.i2c.Tx(slave, word, uint(k))
+		if nil != err {
+			return n, err
+		}
+		n += copy(p[n:], rd)
+		off += k
+	}
+	return n, nil
+}
+
+// WriteAt writes len(p) bytes of p starting at byte offset off of the device,
+// one page at a time, waiting for the write cycle of each page to complete.
+// Implements io.WriterAt.
+func (e *EEPROM) WriteAt(p []byte, off int64) (int, error) {
+
+	if off < 0 || off+int64(len(p)) > e.part.Size {
+		return 0, fmt.Errorf("write out of range: %d bytes at offset %d (size %d)",
+			len(p), off, e.part.Size)
+	}
+	n := 0
+	for n < len(p) {
+		k := e.span(off, int64(e.part.PageSize)) // never cross a page boundary
+		if k > int64(len(p)-n) {
+			k = int64(len(p) - n)
+		}
+		slave, word := e.locate(off)
+		if _, err := e.i2c.Tx(slave, append(word, p[n:n+int(k)]...), 0); nil != err {
+			return n, err
+		}
+		if err := e.wait(slave); nil != err {
+			return n, err
+		}
+		n += int(k)
+		off += k
+	}
+	return n, nil
+}
+
+// span returns the number of bytes from byte offset off to the next multiple of
+// the given size, which must be a power of 2 no greater than the block size.
+func (e *EEPROM) span(off int64, size int64) int64 {
+	if size > e.part.blockSize() {
+		size = e.part.blockSize()
+	}
+	return size - off&(size-1)
+}
+
+// locate returns the slave address of the block containing the given byte
+// offset, and the word address of the byte offset within that block.
+func (e *EEPROM) locate(off int64) (uint, []uint8) {
+	bits := e.part.AddrSpace.Bits()
+	slave := e.addr | uint(off>>bits)<<e.part.BlockBit
+	return slave, ft232h.MSB.Bytes(e.part.AddrSpace.Bytes(), uint64(off))
+}
+
+// wait polls the given slave address until the device ACKs it, indicating its
+// internal write cycle has completed, or the write cycle timeout expires.
+func (e *EEPROM) wait(slave uint) error {
+	deadline := time.Now().Add(writeTimeout)
+	for {
+		_, err := e.i2c.Tx(slave, nil, 0)
+		var nack *ft232h.I2CAddrNACKError
+		if !errors.As(err, &nack) {
+			return err
+		}
+		remain := time.Until(deadline)
+		if remain <= 0 {
+			return fmt.Errorf("timeout waiting for write cycle (%s): %v", writeTimeout, err)
+		}
+		if remain > pollInterval {
+			remain = pollInterval
+		}
+		time.Sleep(remain)
+	}
+}
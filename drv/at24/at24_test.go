@@ -0,0 +1,176 @@
+package at24
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ardnew/ft232h"
+)
+
+// simEEPROM attaches a simulated 24Cxx with the given geometry at the given
+// slave address, returning the simulated device, an EEPROM controlling it, and
+// a function closing the simulated FT232H. The simulated device has an
+// internal write cycle during which it does not ACK its address for 3 address
+// phases. If channel is true, the simulated FT232H emulates the libMPSSE I²C
+// channel (see ft232h.PortChannel).
+func simEEPROM(t *testing.T, part Part, addr uint, channel bool) (*ft232h.SimI2CRegFile, *EEPROM, func()) {
+	t.Helper()
+
+	s := &ft232h.SimI2CRegFile{
+		AddrSize: part.AddrSpace.Bytes(),
+		AutoInc:  true,
+		Size:     uint(part.Size),
+		PageSize: uint(part.PageSize),
+		Cycle:    3,
+	}
+	ft, err := ft232h.OpenSim("at24", func(d *ft232h.SimDevice) error {
+		d.Channel = channel
+		for blk := int64(0); blk*part.blockSize() < part.Size; blk++ {
+			slave := addr | uint(blk)<<part.BlockBit
+			if err := d.AttachI2C(slave, s.Block(uint(blk*part.blockSize()))); nil != err {
+				return err
+			}
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	e, err := New(ft.I2C, addr, part)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	return s, e, func() { ft.Close() }
+}
+
+func TestEEPROM(t *testing.T) {
+
+	for _, tc := range []struct {
+		part Part
+		addr uint
+		off  int64
+	}{
+		{Part24C02, DefaultAddress, 0x7B},
+		{Part24C08, DefaultAddress, 0xF5},            // crosses block 0x50 → 0x51
+		{Part24C16, DefaultAddress | 0x08, 0x2EE},    // blocks 0x5A, 0x5B
+		{Part24C256, DefaultAddress | 0x07, 0x4321},  // A2-A0 pins HIGH
+		{Part24C1024, DefaultAddress, 0xFF70},        // crosses block 0x50 → 0x51
+		{Part24C1025, DefaultAddress | 0x03, 0xFF70}, // crosses block 0x53 → 0x57
+	} {
+		for _, channel := range []bool{false, true} {
+			name := tc.part.Name
+			if channel {
+				name += "/Channel"
+			}
+			t.Run(name, func(t *testing.T) {
+
+				s, e, done := simEEPROM(t, tc.part, tc.addr, channel)
+				defer done()
+				page := int64(tc.part.PageSize)
+
+				data := make([]uint8, 2*page+5)
+				for i := range data {
+					data[i] = uint8(i*7 + 3)
+				}
+				n, err := e.WriteAt(data, tc.off)
+				if nil != err || len(data) != n {
+					t.Fatalf("WriteAt()={%d}, expected={%d}: %v", n, len(data), err)
+				}
+				end := tc.off + int64(len(data))
+				if exp := int((end-1)/page - tc.off/page + 1); exp != s.Writes {
+					t.Fatalf("writes={%d}, expected={%d}", s.Writes, exp)
+				}
+				if exp := s.Cycle * s.Writes; exp != s.Polls {
+					t.Fatalf("polls={%d}, expected={%d}", s.Polls, exp)
+				}
+				if mem := s.Peek(uint(tc.off), uint(len(data))); !bytes.Equal(data, mem) {
+					t.Fatalf("mem={%X}, expected={%X}", mem, data)
+				}
+				if pre, post := s.Peek(uint(tc.off-1), 1), s.Peek(uint(end), 1); 0xFF != pre[0] || 0xFF != post[0] {
+					t.Fatalf("mem={%02X ... %02X}, expected={FF ... FF}", pre[0], post[0])
+				}
+
+				rd := make([]uint8, len(data))
+				if n, err := e.ReadAt(rd, tc.off); nil != err || len(rd) != n {
+					t.Fatalf("ReadAt()={%d}, expected={%d}: %v", n, len(rd), err)
+				}
+				if !bytes.Equal(data, rd) {
+					t.Fatalf("ReadAt()={%X}, expected={%X}", rd, data)
+				}
+
+				// reads spanning every block
+				all := make([]uint8, tc.part.Size+1)
+				if n, err = e.ReadAt(all, 0); io.EOF != err || int(tc.part.Size) != n {
+					t.Fatalf("ReadAt()={%d, %v}, expected={%d, %v}", n, err, tc.part.Size, io.EOF)
+				}
+				if mem := s.Peek(0, uint(tc.part.Size)); !bytes.Equal(mem, all[:n]) {
+					t.Fatalf("ReadAt()={%X}, expected={%X}", all[:n], mem)
+				}
+
+				if _, err := e.WriteAt(data, tc.part.Size-1); nil == err {
+					t.Fatalf("WriteAt(%d): expected error", tc.part.Size-1)
+				}
+			})
+		}
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+
+	for _, channel := range []bool{false, true} {
+		s, e, done := simEEPROM(t, Part24C32, DefaultAddress, channel)
+		s.Cycle = -1
+		if n, err := e.WriteAt([]uint8{1, 2, 3}, 0x1E); nil == err || 0 != n {
+			t.Fatalf("WriteAt()={%d, %v}, expected={%d, error}", n, err, 0)
+		}
+		if 1 != s.Writes {
+			t.Fatalf("writes={%d}, expected={%d}", s.Writes, 1)
+		}
+		done()
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	if _, err := New(nil, DefaultAddress, Part24C02); nil == err {
+		t.Fatalf("New(nil): expected error")
+	}
+	_, e, done := simEEPROM(t, Part24C02, DefaultAddress, false)
+	defer done()
+	i2c := e.i2c
+
+	for _, tc := range []struct {
+		addr uint
+		part Part
+		mask uint
+		err  bool
+	}{
+		{DefaultAddress | 0x07, Part24C01, 0x00, false},
+		{DefaultAddress | 0x06, Part24C04, 0x01, false},
+		{DefaultAddress | 0x01, Part24C04, 0x01, true},
+		{DefaultAddress | 0x04, Part24C08, 0x03, false},
+		{DefaultAddress | 0x02, Part24C08, 0x03, true},
+		{DefaultAddress, Part24C16, 0x07, false},
+		{DefaultAddress | 0x04, Part24C16, 0x07, true},
+		{DefaultAddress | 0x06, Part24C1024, 0x01, false},
+		{DefaultAddress | 0x03, Part24C1025, 0x04, false},
+		{DefaultAddress | 0x04, Part24C1025, 0x04, true},
+		{0x80, Part24C02, 0x00, true},
+		{DefaultAddress, Part{Size: 300, PageSize: 8, AddrSpace: ft232h.Addr8Bit}, 0x01, true},
+		{DefaultAddress, Part{Size: 256, PageSize: 8, AddrSpace: ft232h.Addr32Bit}, 0x00, true},
+		{DefaultAddress, Part{Size: 4096, PageSize: 16, AddrSpace: ft232h.Addr8Bit}, 0x0F, true},
+		{DefaultAddress, Part{Size: 256, PageSize: 512, AddrSpace: ft232h.Addr8Bit}, 0x00, true},
+	} {
+		if m := tc.part.blockMask(); tc.mask != m {
+			t.Fatalf("%s.blockMask()={%02X}, expected={%02X}", tc.part.Name, m, tc.mask)
+		}
+		if _, err := New(i2c, tc.addr, tc.part); tc.err != (nil != err) {
+			t.Fatalf("New(0x%02X, %s)={%v}, expected error={%t}", tc.addr, tc.part, err, tc.err)
+		}
+	}
+}
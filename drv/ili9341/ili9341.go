@@ -322,13 +322,39 @@ func (lcd *ILI9341) WriteData(data []uint8, start bool, stop bool) error {
 }
 
 func (lcd *ILI9341) SendCommandData(cmd uint8, data []uint8) error {
-	if err := lcd.SendCommand(cmd); nil != err {
-		return err
-	}
-	if err := lcd.SendData(data); nil != err {
-		return err
+	_, err := lcd.command(lcd.device.SPI.Tx(), cmd, data).Exec()
+	return err
+}
+
+// command queues the given command, followed by its data if non-nil, in the
+// given SPI transaction.
+func (lcd *ILI9341) command(tx *ft232h.SPITx, cmd uint8, data []uint8) *ft232h.SPITx {
+	// clear DC line to indicate command on MOSI, set DC to indicate data
+	tx.Pin(lcd.config.PinDC, false).Assert().Write([]uint8{cmd}).Deassert()
+	if nil != data {
+		tx.Pin(lcd.config.PinDC, true).Assert().Write(data).Deassert()
 	}
-	return nil
+	return tx
+}
+
+// frame queues the commands selecting the given (normalized) frame for writing
+// to display RAM in the given SPI transaction.
+func (lcd *ILI9341) frame(tx *ft232h.SPITx, frame Frame) *ft232h.SPITx {
+
+	// column-, row-address set, write to RAM
+	var caset, raset, ramwr uint8 = 0x2A, 0x2B, 0x2C
+
+	lcd.command(tx, caset, frame.colAddress())
+	lcd.command(tx, raset, frame.rowAddress())
+	return lcd.command(tx, ramwr, nil)
+}
+
+// writeFrame selects the given (normalized) frame and writes the given pixel
+// data to display RAM in a single SPI transaction.
+func (lcd *ILI9341) writeFrame(frame Frame, data []uint8) error {
+	tx := lcd.frame(lcd.device.SPI.Tx(), frame)
+	_, err := tx.Pin(lcd.config.PinDC, true).Assert().Write(data).Deassert().Exec()
+	return err
 }
 
 func (lcd *ILI9341) Init() error {
@@ -389,22 +415,8 @@ func (lcd *ILI9341) Init() error {
 }
 
 func (lcd *ILI9341) SetFrame(frame Frame) error {
-
-	// column-, row-address set, write to RAM
-	var caset, raset, ramwr uint8 = 0x2A, 0x2B, 0x2C
-
-	vis := lcd.Normalize(frame)
-
-	if err := lcd.SendCommandData(caset, vis.colAddress()); nil != err {
-		return err
-	}
-	if err := lcd.SendCommandData(raset, vis.rowAddress()); nil != err {
-		return err
-	}
-	if err := lcd.SendCommand(ramwr); nil != err {
-		return err
-	}
-	return nil
+	_, err := lcd.frame(lcd.device.SPI.Tx(), lcd.Normalize(frame)).Exec()
+	return err
 }
 
 func (lcd *ILI9341) SetFrameRect(x0 int, y0 int, x1 int, y1 int) error {
@@ -478,13 +490,8 @@ func (lcd *ILI9341) Normalize(f Frame) Frame {
 func (lcd *ILI9341) FillScreen(color RGB) error {
 
 	sz := lcd.config.Rotate.Size()
-	if err := lcd.SetFrame(MakeFrame(0, 0, sz.Width, sz.Height)); nil != err {
-		return err
-	}
-	if err := lcd.SendData(color.Buffer(NumPixels)); nil != err {
-		return err
-	}
-	return nil
+	fr := lcd.Normalize(MakeFrame(0, 0, sz.Width, sz.Height))
+	return lcd.writeFrame(fr, color.Buffer(NumPixels))
 }
 
 func (lcd *ILI9341) FillFrame(color RGB, frame Frame) error {
@@ -493,14 +500,8 @@ func (lcd *ILI9341) FillFrame(color RGB, frame Frame) error {
 	if 0 == fr.Size.Width || 0 == fr.Size.Height {
 		return nil
 	}
-	if err := lcd.SetFrame(fr); nil != err {
-		return err
-	}
 	px := fr.Size.Width * fr.Size.Height
-	if err := lcd.SendData(color.Buffer(uint(px))); nil != err {
-		return err
-	}
-	return nil
+	return lcd.writeFrame(fr, color.Buffer(uint(px)))
 }
 
 func (lcd *ILI9341) FillFrameRect(color RGB, x int, y int, w int, h int) error {
@@ -510,13 +511,8 @@ func (lcd *ILI9341) FillFrameRect(color RGB, x int, y int, w int, h int) error {
 func (lcd *ILI9341) DrawPixel(color RGB, x int, y int) error {
 
 	pt := lcd.Clip(MakePoint(x, y))
-	if err := lcd.SetFrame(MakeFrame(pt.X, pt.Y, 1, 1)); nil != err {
-		return err
-	}
-	if err := lcd.SendData(color.Buffer(1)); nil != err {
-		return err
-	}
-	return nil
+	fr := lcd.Normalize(MakeFrame(pt.X, pt.Y, 1, 1))
+	return lcd.writeFrame(fr, color.Buffer(1))
 }
 
 func (lcd *ILI9341) DrawBitmap1BPP(fg RGB, bg RGB, frame Frame, bmp []uint8) error {
@@ -551,13 +547,7 @@ func (lcd *ILI9341) DrawBitmap1BPP(fg RGB, bg RGB, frame Frame, bmp []uint8) err
 			n += 2
 		}
 	}
-	if err := lcd.SetFrame(fr); nil != err {
-		return err
-	}
-	if err := lcd.SendData(data); nil != err {
-		return err
-	}
-	return nil
+	return lcd.writeFrame(fr, data)
 }
 
 func (lcd *ILI9341) DrawBitmapRect1BPP(fg RGB, bg RGB, x int, y int, w int, h int, bmp []uint8) error {
@@ -585,13 +575,7 @@ func (lcd *ILI9341) DrawBitmap16BPP(frame Frame, bmp []uint16) error {
 		data[2*i+1] = uint8(rgb>>0) & 0xFF
 	}
 
-	if err := lcd.SetFrame(fr); nil != err {
-		return err
-	}
-	if err := lcd.SendData(data); nil != err {
-		return err
-	}
-	return nil
+	return lcd.writeFrame(fr, data)
 }
 
 func (lcd *ILI9341) DrawBitmapRect16BPP(x int, y int, w int, h int, bmp []uint16) error {
@@ -0,0 +1,147 @@
+package ili9341
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ardnew/ft232h"
+)
+
+// simLCD is a simulated ILI9341 that records each command it receives along
+// with the data bytes that follow it.
+type simLCD struct {
+	dc  ft232h.CPin
+	cmd bool // true when the DC line indicates command
+	log []simCmd
+}
+
+type simCmd struct {
+	cmd  uint8
+	data []uint8
+}
+
+func (s *simLCD) WriteGPIO(dir uint8, val uint8) {
+	s.cmd = 0 == (val & dir & s.dc.Mask())
+}
+
+func (s *simLCD) ReadGPIO() uint8 { return 0 }
+
+func (s *simLCD) Select(active bool) {}
+
+func (s *simLCD) Swap(mosi []uint8) []uint8 {
+	for _, b := range mosi {
+		if s.cmd {
+			s.log = append(s.log, simCmd{cmd: b})
+		} else if n := len(s.log); n > 0 {
+			s.log[n-1].data = append(s.log[n-1].data, b)
+		}
+	}
+	return make([]uint8, len(mosi))
+}
+
+func TestILI9341(t *testing.T) {
+
+	cfg := &Config{
+		PinCS:  ft232h.D(3),
+		PinDC:  ft232h.C(0),
+		PinRST: ft232h.C(1),
+		Rotate: RotDefault,
+	}
+
+	lcd := &simLCD{dc: cfg.PinDC}
+	ft, err := ft232h.OpenSim("ILI9341", func(d *ft232h.SimDevice) error {
+		d.AttachGPIO(lcd)
+		return d.AttachSPI(cfg.PinCS, lcd)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	disp, err := New(ft, cfg)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+
+	// software reset is the first command, and MADCTL the last
+	if len(lcd.log) < 2 || 0x01 != lcd.log[0].cmd {
+		t.Fatalf("expected software reset command first: %+v", lcd.log)
+	}
+	last := lcd.log[len(lcd.log)-1]
+	if 0x36 != last.cmd || !bytes.Equal(last.data, []uint8{RotDefault.MADCTL()}) {
+		t.Fatalf("expected MADCTL command last: %+v", last)
+	}
+
+	lcd.log = nil
+	if err := disp.FillFrameRect(Red, 10, 20, 2, 3); nil != err {
+		t.Fatalf("FillFrameRect(): %v", err)
+	}
+
+	pix := Red.Buffer(2 * 3)
+	for i, exp := range []simCmd{
+		{cmd: 0x2A, data: []uint8{0x00, 10, 0x00, 12}}, // CASET
+		{cmd: 0x2B, data: []uint8{0x00, 20, 0x00, 23}}, // RASET
+		{cmd: 0x2C, data: pix},                         // RAMWR
+	} {
+		if i >= len(lcd.log) {
+			t.Fatalf("missing command 0x%02X", exp.cmd)
+		}
+		if act := lcd.log[i]; exp.cmd != act.cmd || !bytes.Equal(exp.data, act.data) {
+			t.Fatalf("command[%d]={0x%02X % X}, expected={0x%02X % X}",
+				i, act.cmd, act.data, exp.cmd, exp.data)
+		}
+	}
+}
+
+func TestILI9341Channel(t *testing.T) {
+
+	cfg := &Config{
+		PinCS:  ft232h.D(3),
+		PinDC:  ft232h.C(0),
+		PinRST: ft232h.C(1),
+		Rotate: RotDefault,
+	}
+
+	// emulate the libMPSSE SPI channel of the D2XX/libMPSSE bridge
+	lcd := &simLCD{dc: cfg.PinDC}
+	ft, err := ft232h.OpenSim("ILI9341", func(d *ft232h.SimDevice) error {
+		d.Channel = true
+		d.AttachGPIO(lcd)
+		return d.AttachSPI(cfg.PinCS, lcd)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+	if err := ft.JTAG.Init(); !errors.Is(err, ft232h.ErrNoMPSSE) {
+		t.Fatalf("JTAG.Init()={%v}, expected={%v}", err, ft232h.ErrNoMPSSE)
+	}
+
+	disp, err := New(ft, cfg)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	if len(lcd.log) < 2 || 0x01 != lcd.log[0].cmd {
+		t.Fatalf("expected software reset command first: %+v", lcd.log)
+	}
+
+	lcd.log = nil
+	if err := disp.FillScreen(Blue); nil != err {
+		t.Fatalf("FillScreen(): %v", err)
+	}
+	sz := RotDefault.Size()
+	for i, exp := range []simCmd{
+		{cmd: 0x2A, data: []uint8{0x00, 0x00, uint8(sz.Width >> 8), uint8(sz.Width)}},   // CASET
+		{cmd: 0x2B, data: []uint8{0x00, 0x00, uint8(sz.Height >> 8), uint8(sz.Height)}}, // RASET
+		{cmd: 0x2C, data: Blue.Buffer(NumPixels)},                                       // RAMWR
+	} {
+		if i >= len(lcd.log) {
+			t.Fatalf("missing command 0x%02X", exp.cmd)
+		}
+		if act := lcd.log[i]; exp.cmd != act.cmd || !bytes.Equal(exp.data, act.data) {
+			t.Fatalf("command[%d]={0x%02X %d bytes}, expected={0x%02X %d bytes}",
+				i, act.cmd, len(act.data), exp.cmd, len(exp.data))
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package ina260 provides access to the registers of the Texas Instruments
+// INA260 precision digital current and power monitor on the I²C interface of
+// an FT232H.
+//
+// The register accessors (see Regs) are generated from the register map
+// ina260.json by the regmapgen command (see package
+// github.com/ardnew/ft232h/regmap).
+package ina260
+
+//go:generate go run github.com/ardnew/ft232h/regmap/regmapgen -in ina260.json
+
+// DefaultAddress is the unshifted I²C slave address of the INA260 with both
+// address pins A0 and A1 connected to GND.
+const DefaultAddress = 0x40
+
+// Constants defining the values of the Config register's MODE bitfield.
+const (
+	ModeShutdown    = 0x0 // power-down
+	ModeCurrentTrig = 0x1 // current, triggered
+	ModeVoltageTrig = 0x2 // bus voltage, triggered
+	ModeBothTrig    = 0x3 // current and bus voltage, triggered
+	ModeCurrentCont = 0x5 // current, continuous
+	ModeVoltageCont = 0x6 // bus voltage, continuous
+	ModeBothCont    = 0x7 // current and bus voltage, continuous (default)
+)
@@ -0,0 +1,392 @@
+// Code generated by regmapgen from ina260.json. DO NOT EDIT.
+
+package ina260
+
+import (
+	"fmt"
+
+	"github.com/ardnew/ft232h"
+)
+
+// Constants defining the register addresses of the INA260.
+const (
+	RegConfig         uint = 0x00
+	RegCurrent        uint = 0x01
+	RegVoltage        uint = 0x02
+	RegPower          uint = 0x03
+	RegMaskEnable     uint = 0x06
+	RegAlertLimit     uint = 0x07
+	RegManufacturerID uint = 0xFE
+	RegDieID          uint = 0xFF
+)
+
+// Constants defining the reset values of the INA260 registers.
+const (
+	ConfigReset         uint16 = 0x6127
+	MaskEnableReset     uint16 = 0x0000
+	AlertLimitReset     uint16 = 0x0000
+	ManufacturerIDReset uint16 = 0x5449
+	DieIDReset          uint16 = 0x2270
+)
+
+// Bitfields of the INA260 Config register.
+var (
+	ConfigRST    = ft232h.RegField{Name: "RST", Shift: 15, Width: 1}   // reset all registers to their default values
+	ConfigAVG    = ft232h.RegField{Name: "AVG", Shift: 9, Width: 3}    // number of samples averaged (1, 4, 16, 64, 128, 256, 512, 1024)
+	ConfigVBUSCT = ft232h.RegField{Name: "VBUSCT", Shift: 6, Width: 3} // bus voltage conversion time
+	ConfigISHCT  = ft232h.RegField{Name: "ISHCT", Shift: 3, Width: 3}  // shunt current conversion time
+	ConfigMODE   = ft232h.RegField{Name: "MODE", Shift: 0, Width: 3}   // operating mode (shutdown, triggered, or continuous)
+)
+
+// Bitfields of the INA260 MaskEnable register.
+var (
+	MaskEnableOCL  = ft232h.RegField{Name: "OCL", Shift: 15, Width: 1}  // alert on over current limit
+	MaskEnableUCL  = ft232h.RegField{Name: "UCL", Shift: 14, Width: 1}  // alert on under current limit
+	MaskEnableBOL  = ft232h.RegField{Name: "BOL", Shift: 13, Width: 1}  // alert on bus voltage over-voltage limit
+	MaskEnableBUL  = ft232h.RegField{Name: "BUL", Shift: 12, Width: 1}  // alert on bus voltage under-voltage limit
+	MaskEnablePOL  = ft232h.RegField{Name: "POL", Shift: 11, Width: 1}  // alert on power over-limit
+	MaskEnableCNVR = ft232h.RegField{Name: "CNVR", Shift: 10, Width: 1} // alert on conversion ready
+	MaskEnableAFF  = ft232h.RegField{Name: "AFF", Shift: 4, Width: 1}   // alert function flag
+	MaskEnableCVRF = ft232h.RegField{Name: "CVRF", Shift: 3, Width: 1}  // conversion ready flag
+	MaskEnableOVF  = ft232h.RegField{Name: "OVF", Shift: 2, Width: 1}   // math overflow flag
+	MaskEnableAPOL = ft232h.RegField{Name: "APOL", Shift: 1, Width: 1}  // alert pin polarity (active-high if set)
+	MaskEnableLEN  = ft232h.RegField{Name: "LEN", Shift: 0, Width: 1}   // alert latch enable
+)
+
+// Bitfields of the INA260 DieID register.
+var (
+	DieIDDevice   = ft232h.RegField{Name: "Device", Shift: 4, Width: 12}
+	DieIDRevision = ft232h.RegField{Name: "Revision", Shift: 0, Width: 4}
+)
+
+// Regs provides typed access to the registers of the INA260.
+type Regs struct {
+	i2c   *ft232h.I2C
+	slave uint
+}
+
+// NewRegs returns a new Regs for the INA260 with the given unshifted I²C
+// slave address on the given I²C interface.
+func NewRegs(i2c *ft232h.I2C, slave uint) *Regs {
+	return &Regs{i2c: i2c, slave: slave}
+}
+
+// reg returns the I2CReg of the register with the given address.
+func (r *Regs) reg(addr uint) *ft232h.I2CReg {
+	return r.i2c.Reg(r.slave, addr, ft232h.Addr8Bit, ft232h.MSB)
+}
+
+// read reads the register with the given address and size in bytes.
+func (r *Regs) read(addr uint, size uint) (uint64, error) {
+	return r.reg(addr).Read(size)
+}
+
+// write writes the given value to the register with the given address and size
+// in bytes.
+func (r *Regs) write(addr uint, size uint, value uint64) error {
+	return r.reg(addr).Write(size, value)
+}
+
+// setField performs a read-modify-write of the register with the given address
+// and size in bytes, setting the given bitfield to value.
+func (r *Regs) setField(addr uint, size uint, field ft232h.RegField, value uint64) error {
+	return r.reg(addr).SetField(size, field, value)
+}
+
+// String returns a descriptive string of a Regs.
+func (r *Regs) String() string {
+	return fmt.Sprintf("INA260 (I²C 0x%02X)", r.slave)
+}
+
+// Config reads the Config register.
+// Configuration register: averaging, conversion times, and operating mode.
+func (r *Regs) Config() (uint16, error) {
+	v, err := r.read(RegConfig, 2)
+	return uint16(v), err
+}
+
+// SetConfig writes the Config register.
+func (r *Regs) SetConfig(value uint16) error {
+	return r.write(RegConfig, 2, uint64(uint16(value)))
+}
+
+// ConfigRST reads the RST[15] bitfield of the Config register.
+func (r *Regs) ConfigRST() (uint64, error) {
+	v, err := r.read(RegConfig, 2)
+	return ConfigRST.Get(v), err
+}
+
+// SetConfigRST sets the RST[15] bitfield of the Config register,
+// leaving all other bits unchanged.
+func (r *Regs) SetConfigRST(value uint64) error {
+	return r.setField(RegConfig, 2, ConfigRST, value)
+}
+
+// ConfigAVG reads the AVG[11:9] bitfield of the Config register.
+func (r *Regs) ConfigAVG() (uint64, error) {
+	v, err := r.read(RegConfig, 2)
+	return ConfigAVG.Get(v), err
+}
+
+// SetConfigAVG sets the AVG[11:9] bitfield of the Config register,
+// leaving all other bits unchanged.
+func (r *Regs) SetConfigAVG(value uint64) error {
+	return r.setField(RegConfig, 2, ConfigAVG, value)
+}
+
+// ConfigVBUSCT reads the VBUSCT[8:6] bitfield of the Config register.
+func (r *Regs) ConfigVBUSCT() (uint64, error) {
+	v, err := r.read(RegConfig, 2)
+	return ConfigVBUSCT.Get(v), err
+}
+
+// SetConfigVBUSCT sets the VBUSCT[8:6] bitfield of the Config register,
+// leaving all other bits unchanged.
+func (r *Regs) SetConfigVBUSCT(value uint64) error {
+	return r.setField(RegConfig, 2, ConfigVBUSCT, value)
+}
+
+// ConfigISHCT reads the ISHCT[5:3] bitfield of the Config register.
+func (r *Regs) ConfigISHCT() (uint64, error) {
+	v, err := r.read(RegConfig, 2)
+	return ConfigISHCT.Get(v), err
+}
+
+// SetConfigISHCT sets the ISHCT[5:3] bitfield of the Config register,
+// leaving all other bits unchanged.
+func (r *Regs) SetConfigISHCT(value uint64) error {
+	return r.setField(RegConfig, 2, ConfigISHCT, value)
+}
+
+// ConfigMODE reads the MODE[2:0] bitfield of the Config register.
+func (r *Regs) ConfigMODE() (uint64, error) {
+	v, err := r.read(RegConfig, 2)
+	return ConfigMODE.Get(v), err
+}
+
+// SetConfigMODE sets the MODE[2:0] bitfield of the Config register,
+// leaving all other bits unchanged.
+func (r *Regs) SetConfigMODE(value uint64) error {
+	return r.setField(RegConfig, 2, ConfigMODE, value)
+}
+
+// Current reads the Current register.
+// Current through the internal shunt resistor.
+func (r *Regs) Current() (int16, error) {
+	v, err := r.read(RegCurrent, 2)
+	return int16(v), err
+}
+
+// CurrentScaled reads the Current register, returning its value in mA.
+func (r *Regs) CurrentScaled() (float64, error) {
+	v, err := r.Current()
+	return float64(v) * 1.25, err
+}
+
+// Voltage reads the Voltage register.
+// Bus voltage.
+func (r *Regs) Voltage() (uint16, error) {
+	v, err := r.read(RegVoltage, 2)
+	return uint16(v), err
+}
+
+// VoltageScaled reads the Voltage register, returning its value in mV.
+func (r *Regs) VoltageScaled() (float64, error) {
+	v, err := r.Voltage()
+	return float64(v) * 1.25, err
+}
+
+// Power reads the Power register.
+// Power, the product of current and bus voltage.
+func (r *Regs) Power() (uint16, error) {
+	v, err := r.read(RegPower, 2)
+	return uint16(v), err
+}
+
+// PowerScaled reads the Power register, returning its value in mW.
+func (r *Regs) PowerScaled() (float64, error) {
+	v, err := r.Power()
+	return float64(v) * 10, err
+}
+
+// MaskEnable reads the MaskEnable register.
+// Alert configuration and conversion ready flag.
+func (r *Regs) MaskEnable() (uint16, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return uint16(v), err
+}
+
+// SetMaskEnable writes the MaskEnable register.
+func (r *Regs) SetMaskEnable(value uint16) error {
+	return r.write(RegMaskEnable, 2, uint64(uint16(value)))
+}
+
+// MaskEnableOCL reads the OCL[15] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableOCL() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableOCL.Get(v), err
+}
+
+// SetMaskEnableOCL sets the OCL[15] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableOCL(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableOCL, value)
+}
+
+// MaskEnableUCL reads the UCL[14] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableUCL() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableUCL.Get(v), err
+}
+
+// SetMaskEnableUCL sets the UCL[14] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableUCL(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableUCL, value)
+}
+
+// MaskEnableBOL reads the BOL[13] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableBOL() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableBOL.Get(v), err
+}
+
+// SetMaskEnableBOL sets the BOL[13] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableBOL(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableBOL, value)
+}
+
+// MaskEnableBUL reads the BUL[12] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableBUL() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableBUL.Get(v), err
+}
+
+// SetMaskEnableBUL sets the BUL[12] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableBUL(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableBUL, value)
+}
+
+// MaskEnablePOL reads the POL[11] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnablePOL() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnablePOL.Get(v), err
+}
+
+// SetMaskEnablePOL sets the POL[11] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnablePOL(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnablePOL, value)
+}
+
+// MaskEnableCNVR reads the CNVR[10] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableCNVR() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableCNVR.Get(v), err
+}
+
+// SetMaskEnableCNVR sets the CNVR[10] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableCNVR(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableCNVR, value)
+}
+
+// MaskEnableAFF reads the AFF[4] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableAFF() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableAFF.Get(v), err
+}
+
+// SetMaskEnableAFF sets the AFF[4] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableAFF(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableAFF, value)
+}
+
+// MaskEnableCVRF reads the CVRF[3] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableCVRF() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableCVRF.Get(v), err
+}
+
+// SetMaskEnableCVRF sets the CVRF[3] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableCVRF(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableCVRF, value)
+}
+
+// MaskEnableOVF reads the OVF[2] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableOVF() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableOVF.Get(v), err
+}
+
+// SetMaskEnableOVF sets the OVF[2] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableOVF(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableOVF, value)
+}
+
+// MaskEnableAPOL reads the APOL[1] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableAPOL() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableAPOL.Get(v), err
+}
+
+// SetMaskEnableAPOL sets the APOL[1] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableAPOL(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableAPOL, value)
+}
+
+// MaskEnableLEN reads the LEN[0] bitfield of the MaskEnable register.
+func (r *Regs) MaskEnableLEN() (uint64, error) {
+	v, err := r.read(RegMaskEnable, 2)
+	return MaskEnableLEN.Get(v), err
+}
+
+// SetMaskEnableLEN sets the LEN[0] bitfield of the MaskEnable register,
+// leaving all other bits unchanged.
+func (r *Regs) SetMaskEnableLEN(value uint64) error {
+	return r.setField(RegMaskEnable, 2, MaskEnableLEN, value)
+}
+
+// AlertLimit reads the AlertLimit register.
+// Limit compared against the register selected by MaskEnable.
+func (r *Regs) AlertLimit() (uint16, error) {
+	v, err := r.read(RegAlertLimit, 2)
+	return uint16(v), err
+}
+
+// SetAlertLimit writes the AlertLimit register.
+func (r *Regs) SetAlertLimit(value uint16) error {
+	return r.write(RegAlertLimit, 2, uint64(uint16(value)))
+}
+
+// ManufacturerID reads the ManufacturerID register.
+// Manufacturer ID ("TI" in ASCII).
+func (r *Regs) ManufacturerID() (uint16, error) {
+	v, err := r.read(RegManufacturerID, 2)
+	return uint16(v), err
+}
+
+// DieID reads the DieID register.
+// Device ID and die revision.
+func (r *Regs) DieID() (uint16, error) {
+	v, err := r.read(RegDieID, 2)
+	return uint16(v), err
+}
+
+// DieIDDevice reads the Device[15:4] bitfield of the DieID register.
+func (r *Regs) DieIDDevice() (uint64, error) {
+	v, err := r.read(RegDieID, 2)
+	return DieIDDevice.Get(v), err
+}
+
+// DieIDRevision reads the Revision[3:0] bitfield of the DieID register.
+func (r *Regs) DieIDRevision() (uint64, error) {
+	v, err := r.read(RegDieID, 2)
+	return DieIDRevision.Get(v), err
+}
@@ -0,0 +1,76 @@
+package ina260
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ardnew/ft232h"
+)
+
+func TestRegs(t *testing.T) {
+
+	// 16-bit registers addressed by an 8-bit register pointer, MSB-first
+	reg16 := func(v uint16) []uint8 { return ft232h.MSB.Bytes(2, uint64(v)) }
+	ina := &ft232h.SimI2CRegFile{
+		Reg: map[uint][]uint8{
+			uint(RegConfig):         reg16(ConfigReset),
+			uint(RegCurrent):        reg16(0xFFF8), // -8·1.25 mA
+			uint(RegVoltage):        reg16(0x2580), // 9600·1.25 mV
+			uint(RegManufacturerID): reg16(ManufacturerIDReset),
+			uint(RegDieID):          reg16(DieIDReset),
+		},
+	}
+	ft, err := ft232h.OpenSim("ina260", func(d *ft232h.SimDevice) error {
+		return d.AttachI2C(DefaultAddress, ina)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	r := NewRegs(ft.I2C, DefaultAddress)
+
+	if id, err := r.ManufacturerID(); nil != err || ManufacturerIDReset != id {
+		t.Fatalf("ManufacturerID()={%04X}, expected={%04X}: %v", id, ManufacturerIDReset, err)
+	}
+	if d, err := r.DieIDDevice(); nil != err || 0x227 != d {
+		t.Fatalf("DieIDDevice()={%03X}, expected={%03X}: %v", d, 0x227, err)
+	}
+
+	if c, err := r.Current(); nil != err || -8 != c {
+		t.Fatalf("Current()={%d}, expected={%d}: %v", c, -8, err)
+	}
+	if c, err := r.CurrentScaled(); nil != err || -10.0 != c {
+		t.Fatalf("CurrentScaled()={%g}, expected={%g}: %v", c, -10.0, err)
+	}
+	if v, err := r.VoltageScaled(); nil != err || math.Abs(12000.0-v) > 1e-9 {
+		t.Fatalf("VoltageScaled()={%g}, expected={%g}: %v", v, 12000.0, err)
+	}
+
+	if m, err := r.ConfigMODE(); nil != err || ModeBothCont != m {
+		t.Fatalf("ConfigMODE()={%d}, expected={%d}: %v", m, ModeBothCont, err)
+	}
+	if err := r.SetConfigMODE(ModeVoltageTrig); nil != err {
+		t.Fatalf("SetConfigMODE(): %v", err)
+	}
+	if err := r.SetConfigAVG(4); nil != err {
+		t.Fatalf("SetConfigAVG(): %v", err)
+	}
+	if cfg, exp := ft232h.MSB.Uint(2, ina.Reg[uint(RegConfig)]), uint64(0x6922); exp != cfg {
+		t.Fatalf("Config={%04X}, expected={%04X}", cfg, exp)
+	}
+	if err := r.SetConfigAVG(8); nil == err {
+		t.Fatalf("SetConfigAVG(8): expected error")
+	}
+
+	if err := r.SetAlertLimit(0xABCD); nil != err {
+		t.Fatalf("SetAlertLimit(): %v", err)
+	}
+	if l, err := r.AlertLimit(); nil != err || 0xABCD != l {
+		t.Fatalf("AlertLimit()={%04X}, expected={%04X}: %v", l, 0xABCD, err)
+	}
+}
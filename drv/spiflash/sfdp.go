@@ -0,0 +1,172 @@
+package spiflash
+
+import (
+	"fmt"
+
+	"github.com/ardnew/ft232h"
+)
+
+// Constants related to the Serial Flash Discoverable Parameters (SFDP)
+// defined by JEDEC standard JESD216.
+const (
+	sfdpSignature = 0x50444653 // "SFDP", little-endian
+	sfdpBFPT      = 0xFF00     // parameter ID of the Basic Flash Parameter Table
+	sfdpMaxDWORDs = 64         // maximum length of the BFPT read (DWORDs)
+)
+
+// SFDP reads count bytes of the Serial Flash Discoverable Parameters, starting
+// at the given address.
+func (f *Flash) SFDP(addr uint32, count uint) ([]uint8, error) {
+	return f.read(opReadSFDP, ft232h.MSB.Bytes(3, uint64(addr)), 1, count)
+}
+
+// detect returns the geometry of the device with the given JEDEC ID, read from
+// the device's SFDP tables if available, or otherwise inferred from its JEDEC
+// ID.
+func (f *Flash) detect(id JEDECID) (*Info, error) {
+	bfpt, err := f.bfpt()
+	if nil != err {
+		return nil, err
+	}
+	if nil == bfpt {
+		return jedecInfo(id)
+	}
+	return bfptInfo(bfpt)
+}
+
+// bfpt returns the DWORDs of the device's Basic Flash Parameter Table, or nil
+// if the device does not support SFDP.
+func (f *Flash) bfpt() ([]uint32, error) {
+
+	hdr, err := f.SFDP(0, 8)
+	if nil != err {
+		return nil, err
+	}
+	if sfdpSignature != ft232h.LSB.Uint(4, hdr) {
+		return nil, nil
+	}
+
+	// use the BFPT with the greatest revision, which is always listed first
+	// (required) and may be superseded by later headers.
+	nph := uint(hdr[6]) + 1
+	ph, err := f.SFDP(8, 8*nph)
+	if nil != err {
+		return nil, err
+	}
+	ptp, size, rev := uint32(0), uint(0), -1
+	for i := uint(0); i < nph; i++ {
+		p := ph[8*i : 8*i+8]
+		id := uint(p[7])<<8 | uint(p[0])
+		if sfdpBFPT != id {
+			continue
+		}
+		if r := int(p[2])<<8 | int(p[1]); r > rev {
+			ptp = uint32(ft232h.LSB.Uint(3, p[4:7]))
+			size, rev = uint(p[3]), r
+		}
+	}
+	if rev < 0 || size < 9 {
+		return nil, fmt.Errorf("invalid SFDP basic flash parameter table")
+	}
+	if size > sfdpMaxDWORDs {
+		size = sfdpMaxDWORDs
+	}
+
+	b, err := f.SFDP(ptp, 4*size)
+	if nil != err {
+		return nil, err
+	}
+	dw := make([]uint32, size)
+	for i := range dw {
+		dw[i] = uint32(ft232h.LSB.Uint(4, b[4*i:]))
+	}
+	return dw, nil
+}
+
+// bfptInfo returns the geometry described by the given DWORDs of a Basic Flash
+// Parameter Table.
+func bfptInfo(dw []uint32) (*Info, error) {
+
+	// DWORD returns the n'th (1-based) DWORD, or 0 if not defined by the
+	// revision of the table.
+	dword := func(n int) uint32 {
+		if n > len(dw) {
+			return 0
+		}
+		return dw[n-1]
+	}
+
+	info := &Info{PageSize: PageSizeDefault}
+
+	// density (DWORD 2)
+	if d := dword(2); 0 != d&(1<<31) {
+		n := d &^ (1 << 31)
+		if n < 3 || n > 34 {
+			return nil, fmt.Errorf("invalid SFDP density: 2^%d bits", n)
+		}
+		info.Size = 1 << (n - 3)
+	} else {
+		info.Size = (int64(d) + 1) / 8
+	}
+
+	// erase types (DWORDs 8-9), or the 4 KiB erase (DWORD 1)
+	for _, d := range []uint32{dword(8), dword(9)} {
+		for _, e := range []uint32{d & 0xFFFF, d >> 16} {
+			if n := e & 0xFF; n > 0 && n < 32 {
+				info.Erase = append(info.Erase, EraseType{Size: 1 << n, Opcode: uint8(e >> 8)})
+			}
+		}
+	}
+	if 0 == len(info.Erase) && 0x1 == dword(1)&0x3 {
+		info.Erase = append(info.Erase, EraseType{Size: 4096, Opcode: uint8(dword(1) >> 8)})
+	}
+
+	// page size (DWORD 11)
+	if d := dword(11); 0 != d {
+		info.PageSize = 1 << ((d >> 4) & 0xF)
+	}
+
+	// address bytes (DWORD 1) and 4-byte address entry methods (DWORD 16)
+	d16 := dword(16) >> 24
+	switch {
+	case 0x2 == (dword(1)>>17)&0x3:
+		info.AddrMode = Addr4ByteOnly
+	case info.Size <= 1<<24:
+		info.AddrMode = Addr3Byte
+	case 0 != d16&0x20:
+		info.AddrMode = Addr4ByteOpcodes
+	case 0 != d16&0x01 || len(dw) < 16:
+		info.AddrMode = Addr4ByteMode
+	case 0 != d16&0x02:
+		info.AddrMode = Addr4ByteModeWREN
+	case 0 != d16&0x40:
+		info.AddrMode = Addr4ByteOnly
+	default:
+		return nil, fmt.Errorf("4-byte addressing method not supported: 0x%02X", d16)
+	}
+
+	return info, info.validate()
+}
+
+// jedecInfo returns the geometry of a device without SFDP, inferred from the
+// capacity code of its JEDEC ID (2^N bytes) and the erase commands common to
+// nearly all SPI NOR flash devices.
+func jedecInfo(id JEDECID) (*Info, error) {
+	n := id.Capacity()
+	if n < 0x10 || n > 0x1F {
+		return nil, fmt.Errorf("unknown capacity of device without SFDP: %s", id)
+	}
+	info := &Info{
+		Size:     1 << n,
+		PageSize: PageSizeDefault,
+		Erase: []EraseType{
+			{Size: 4096, Opcode: opErase4K},
+			{Size: 65536, Opcode: opErase64K},
+		},
+		AddrMode: Addr3Byte,
+	}
+	if info.Size > 1<<24 {
+		info.AddrMode = Addr4ByteMode
+	}
+	return info, info.validate()
+}
@@ -0,0 +1,497 @@
+// Package spiflash provides access to SPI NOR flash memory devices on the SPI
+// interface of an FT232H.
+//
+// The geometry of a device (capacity, page size, erase granularities, and the
+// method of addressing beyond 16 MiB) is discovered from its Serial Flash
+// Discoverable Parameters (SFDP, JEDEC standard JESD216), or inferred from its
+// JEDEC ID if the device does not support SFDP.
+//
+// Flash implements io.ReaderAt and io.WriterAt. Like all NOR flash programming,
+// WriteAt can only clear bits (1 to 0); the affected region must be erased (set
+// to all 1s) before writing new data, e.g.:
+//
+//	f, err := spiflash.New(ft.SPI, nil)
+//	...
+//	err = f.Erase(0, int64(len(image)))
+//	...
+//	_, err = f.WriteAt(image, 0)
+//
+// Each command is a single SPI transaction (see ft232h.SPITx) framed by the CS
+// line. If the FT232H was opened with a transport whose Port implements
+// ft232h.PortChannel, such as the D2XX/libMPSSE bridge, each transaction is
+// performed as a sequence of libMPSSE transfers holding CS asserted in between,
+// rather than a single MPSSE command buffer.
+package spiflash
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ardnew/ft232h"
+)
+
+// Constants defining the SPI NOR flash commands used by Flash.
+const (
+	opWriteStatus   uint8 = 0x01 // write status register
+	opPageProgram   uint8 = 0x02 // page program
+	opRead          uint8 = 0x03 // read data
+	opWriteDisable  uint8 = 0x04 // write disable
+	opReadStatus    uint8 = 0x05 // read status register
+	opWriteEnable   uint8 = 0x06 // write enable
+	opFastRead      uint8 = 0x0B // fast read (8 dummy clocks)
+	opFastRead4B    uint8 = 0x0C // fast read, 4-byte address
+	opPageProgram4B uint8 = 0x12 // page program, 4-byte address
+	opErase4K       uint8 = 0x20 // 4 KiB sector erase
+	opErase4K4B     uint8 = 0x21 // 4 KiB sector erase, 4-byte address
+	opErase32K      uint8 = 0x52 // 32 KiB block erase
+	opErase32K4B    uint8 = 0x5C // 32 KiB block erase, 4-byte address
+	opReadSFDP      uint8 = 0x5A // read SFDP (8 dummy clocks)
+	opReadJEDECID   uint8 = 0x9F // read JEDEC ID
+	opEnter4B       uint8 = 0xB7 // enter 4-byte address mode
+	opChipErase     uint8 = 0xC7 // chip erase
+	opErase64K      uint8 = 0xD8 // 64 KiB block erase
+	opErase64K4B    uint8 = 0xDC // 64 KiB block erase, 4-byte address
+	opExit4B        uint8 = 0xE9 // exit 4-byte address mode
+)
+
+// opErase4Byte maps erase commands to their 4-byte address equivalents.
+var opErase4Byte = map[uint8]uint8{
+	opErase4K:  opErase4K4B,
+	opErase32K: opErase32K4B,
+	opErase64K: opErase64K4B,
+}
+
+// Constants defining the time allowed for each type of operation to complete,
+// and the interval at which the status register is polled while waiting.
+const (
+	programTimeout   = time.Second
+	eraseTimeout     = 10 * time.Second
+	chipEraseTimeout = 10 * time.Minute
+	statusTimeout    = time.Second
+	erasePoll        = time.Millisecond
+)
+
+// readChunk is the maximum number of bytes read in a single SPI transaction.
+const readChunk = 65536
+
+// PageSizeDefault is the page program size of devices which do not specify it.
+const PageSizeDefault = 256
+
+// JEDECID is the manufacturer and device identification of a flash device.
+type JEDECID [3]uint8
+
+// Manufacturer returns the JEDEC manufacturer ID (e.g. 0xEF for Winbond).
+func (id JEDECID) Manufacturer() uint8 { return id[0] }
+
+// Type returns the manufacturer-specific memory type.
+func (id JEDECID) Type() uint8 { return id[1] }
+
+// Capacity returns the manufacturer-specific capacity code, which usually
+// indicates a capacity of 2^N bytes.
+func (id JEDECID) Capacity() uint8 { return id[2] }
+
+// String returns the JEDEC ID as a string of hexadecimal digits.
+func (id JEDECID) String() string {
+	return fmt.Sprintf("%02X%02X%02X", id[0], id[1], id[2])
+}
+
+// AddrMode represents the method used to address the memory of a device.
+type AddrMode uint8
+
+// Constants defining the addressing methods of a device.
+const (
+	Addr3Byte         AddrMode = iota // 3-byte addresses (up to 16 MiB)
+	Addr4ByteOpcodes                  // dedicated 4-byte address commands (0Ch, 12h, 21h, DCh, ...)
+	Addr4ByteMode                     // enter 4-byte address mode with B7h
+	Addr4ByteModeWREN                 // enter 4-byte address mode with 06h, B7h
+	Addr4ByteOnly                     // always in 4-byte address mode
+)
+
+// String returns a descriptive string of the addressing method.
+func (m AddrMode) String() string {
+	switch m {
+	case Addr3Byte:
+		return "3-byte"
+	case Addr4ByteOpcodes:
+		return "4-byte commands"
+	case Addr4ByteMode:
+		return "4-byte mode"
+	case Addr4ByteModeWREN:
+		return "4-byte mode (WREN)"
+	case Addr4ByteOnly:
+		return "4-byte only"
+	default:
+		return "(invalid address mode)"
+	}
+}
+
+// EraseType describes an erase command of a device.
+type EraseType struct {
+	Size   uint32 // size of the erased region (bytes), a power of 2
+	Opcode uint8  // command code, using 3-byte addresses
+}
+
+// Info describes the geometry of a flash device.
+type Info struct {
+	Size     int64       // capacity (bytes)
+	PageSize int         // page program size (bytes), a power of 2
+	Erase    []EraseType // erase commands, sorted by increasing size
+	AddrMode AddrMode    // method of addressing
+}
+
+// String returns a descriptive string of the device geometry.
+func (i *Info) String() string {
+	e := []string{}
+	for _, t := range i.Erase {
+		e = append(e, fmt.Sprintf("%d:%02Xh", t.Size, t.Opcode))
+	}
+	return fmt.Sprintf("{ Size: %d, PageSize: %d, Erase: [%s], AddrMode: %q }",
+		i.Size, i.PageSize, strings.Join(e, " "), i.AddrMode)
+}
+
+// validate verifies the receiver geometry, sorting its erase types by size
+// and removing the erase types unusable in its addressing method.
+func (i *Info) validate() error {
+	if i.Size <= 0 || (Addr3Byte == i.AddrMode && i.Size > 1<<24) || i.Size > 1<<32 {
+		return fmt.Errorf("invalid device size (%s addressing): %d", i.AddrMode, i.Size)
+	}
+	if i.PageSize <= 0 || 0 != i.PageSize&(i.PageSize-1) {
+		return fmt.Errorf("invalid page size: %d", i.PageSize)
+	}
+	erase := []EraseType{}
+	for _, e := range i.Erase {
+		if 0 == e.Size || 0 != e.Size&(e.Size-1) {
+			return fmt.Errorf("invalid erase size: %d", e.Size)
+		}
+		if _, ok := opErase4Byte[e.Opcode]; ok || Addr4ByteOpcodes != i.AddrMode {
+			erase = append(erase, e)
+		}
+	}
+	if 0 == len(erase) {
+		return fmt.Errorf("no erase commands supported")
+	}
+	sort.SliceStable(erase, func(a, b int) bool { return erase[a].Size < erase[b].Size })
+	i.Erase = erase
+	return nil
+}
+
+// Status represents the bits of the status register (SR1) of a device. Only
+// the BUSY and WEL bits are defined for all devices; the remaining bits are
+// usually used for write protection.
+type Status uint8
+
+// Constants defining the bits of the status register.
+const (
+	StatusBusy Status = 0x01 // write/erase in progress (WIP)
+	StatusWEL  Status = 0x02 // write enable latch
+	StatusBP   Status = 0x7C // block protection (BP0-BP2, and TB, BP3, or SEC)
+	StatusSRP  Status = 0x80 // status register protection (SRP0/SRWD)
+)
+
+// String returns a descriptive string of the set bits of the status register.
+func (s Status) String() string {
+	b := []string{}
+	if 0 != s&StatusBusy {
+		b = append(b, "BUSY")
+	}
+	if 0 != s&StatusWEL {
+		b = append(b, "WEL")
+	}
+	if 0 != s&StatusBP {
+		b = append(b, fmt.Sprintf("BP=%05b", (s&StatusBP)>>2))
+	}
+	if 0 != s&StatusSRP {
+		b = append(b, "SRP")
+	}
+	return "{ " + strings.Join(append(b, "}"), " ")
+}
+
+// Flash is a SPI NOR flash memory device.
+type Flash struct {
+	spi  *ft232h.SPI
+	id   JEDECID
+	info Info
+}
+
+// New returns a new Flash on the given SPI interface, which must already be
+// initialized (see ft232h.SPI.Init) in SPI mode 0 or 3 with an active-LOW CS.
+// The device geometry is discovered (see package documentation) if info is
+// nil, and the device is switched to 4-byte address mode if required.
+func New(spi *ft232h.SPI, info *Info) (*Flash, error) {
+
+	if nil == spi {
+		return nil, fmt.Errorf("nil SPI interface")
+	}
+
+	f := &Flash{spi: spi}
+
+	rd, err := f.read(opReadJEDECID, nil, 0, 3)
+	if nil != err {
+		return nil, err
+	}
+	copy(f.id[:], rd)
+	if (JEDECID{0x00, 0x00, 0x00}) == f.id || (JEDECID{0xFF, 0xFF, 0xFF}) == f.id {
+		return nil, fmt.Errorf("no flash device found (JEDEC ID %s)", f.id)
+	}
+
+	if nil == info {
+		if info, err = f.detect(f.id); nil != err {
+			return nil, err
+		}
+	} else {
+		c := *info
+		c.Erase = append([]EraseType{}, info.Erase...)
+		if err := c.validate(); nil != err {
+			return nil, err
+		}
+		info = &c
+	}
+	f.info = *info
+
+	switch f.info.AddrMode {
+	case Addr4ByteMode:
+		err = f.command(false, opEnter4B)
+	case Addr4ByteModeWREN:
+		err = f.command(true, opEnter4B)
+	}
+	if nil != err {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// String returns a descriptive string of a Flash.
+func (f *Flash) String() string {
+	return fmt.Sprintf("{ JEDEC ID: %s, Info: %s }", f.id, &f.info)
+}
+
+// ID returns the JEDEC ID of the device.
+func (f *Flash) ID() JEDECID { return f.id }
+
+// Info returns the geometry of the device.
+func (f *Flash) Info() Info {
+	info := f.info
+	info.Erase = append([]EraseType{}, f.info.Erase...)
+	return info
+}
+
+// Size returns the capacity of the device in bytes.
+func (f *Flash) Size() int64 { return f.info.Size }
+
+// Status reads the status register of the device.
+func (f *Flash) Status() (Status, error) {
+	rd, err := f.read(opReadStatus, nil, 0, 1)
+	if nil != err {
+		return 0, err
+	}
+	return Status(rd[0]), nil
+}
+
+// SetStatus writes the status register of the device. The BUSY and WEL bits
+// are read-only, and are ignored by the device.
+func (f *Flash) SetStatus(s Status) error {
+	if err := f.command(true, opWriteStatus, uint8(s)); nil != err {
+		return err
+	}
+	return f.wait(statusTimeout, 0)
+}
+
+// Unprotect clears the block protection bits of the status register (see
+// StatusBP), if any are set, so that the entire device can be programmed and
+// erased. Returns a non-nil error if the bits remain set, e.g. if the status
+// register is itself protected by the WP# pin.
+func (f *Flash) Unprotect() error {
+	s, err := f.Status()
+	if nil != err || 0 == s&StatusBP {
+		return err
+	}
+	if err := f.SetStatus(s &^ StatusBP); nil != err {
+		return err
+	}
+	if s, err = f.Status(); nil != err {
+		return err
+	}
+	if 0 != s&StatusBP {
+		return fmt.Errorf("block protection not cleared: %s", s)
+	}
+	return nil
+}
+
+// ReadAt reads len(p) bytes starting at byte offset off of the device into p,
+// using the fast read command. Implements io.ReaderAt.
+func (f *Flash) ReadAt(p []byte, off int64) (int, error) {
+
+	if off < 0 {
+		return 0, fmt.Errorf("invalid offset: %d", off)
+	}
+	n := 0
+	for n < len(p) {
+		if off >= f.info.Size {
+			return n, io.EOF
+		}
+		k := int64(len(p) - n)
+		if k > readChunk {
+			k = readChunk
+		}
+		if k > f.info.Size-off {
+			k = f.info.Size - off
+		}
+		op := opFastRead
+		if Addr4ByteOpcodes == f.info.AddrMode {
+			op = opFastRead4B
+		}
+		rd, err := f.read(op, f.addr(off), 1, uint(k))
+		if nil != err {
+			return n, err
+		}
+		n += copy(p[n:], rd)
+		off += k
+	}
+	return n, nil
+}
+
+// WriteAt programs len(p) bytes of p starting at byte offset off of the device,
+// one page at a time. The region written must already be erased. Implements
+// io.WriterAt.
+func (f *Flash) WriteAt(p []byte, off int64) (int, error) {
+
+	if off < 0 || off+int64(len(p)) > f.info.Size {
+		return 0, fmt.Errorf("write out of range: %d bytes at offset %d (size %d)",
+			len(p), off, f.info.Size)
+	}
+	op := opPageProgram
+	if Addr4ByteOpcodes == f.info.AddrMode {
+		op = opPageProgram4B
+	}
+	page := int64(f.info.PageSize)
+	n := 0
+	for n < len(p) {
+		k := page - off%page // never cross a page boundary
+		if k > int64(len(p)-n) {
+			k = int64(len(p) - n)
+		}
+		if err := f.command(true, op, append(f.addr(off), p[n:n+int(k)]...)...); nil != err {
+			return n, err
+		}
+		if err := f.wait(programTimeout, 0); nil != err {
+			return n, err
+		}
+		n += int(k)
+		off += k
+	}
+	return n, nil
+}
+
+// EraseSector erases the sector (smallest erase unit) at the given byte
+// offset, which must be aligned to the sector size.
+func (f *Flash) EraseSector(off int64) error {
+	return f.erase(f.info.Erase[0], off)
+}
+
+// EraseBlock erases the block (largest erase unit other than the chip) at the
+// given byte offset, which must be aligned to the block size.
+func (f *Flash) EraseBlock(off int64) error {
+	return f.erase(f.info.Erase[len(f.info.Erase)-1], off)
+}
+
+// Erase erases the given number of bytes starting at byte offset off, both of
+// which must be aligned to the sector size, using the largest erase units that
+// fit within the region.
+func (f *Flash) Erase(off int64, n int64) error {
+	sector := int64(f.info.Erase[0].Size)
+	if off < 0 || n < 0 || off+n > f.info.Size || 0 != off%sector || 0 != n%sector {
+		return fmt.Errorf("invalid erase region: %d bytes at offset %d (sector size %d)",
+			n, off, sector)
+	}
+	for end := off + n; off < end; {
+		e := f.info.Erase[0]
+		for _, t := range f.info.Erase[1:] {
+			if size := int64(t.Size); 0 == off%size && off+size <= end {
+				e = t
+			}
+		}
+		if err := f.erase(e, off); nil != err {
+			return err
+		}
+		off += int64(e.Size)
+	}
+	return nil
+}
+
+// EraseChip erases the entire device.
+func (f *Flash) EraseChip() error {
+	if err := f.command(true, opChipErase); nil != err {
+		return err
+	}
+	return f.wait(chipEraseTimeout, erasePoll)
+}
+
+// erase erases the region at the given byte offset with the given erase type.
+func (f *Flash) erase(e EraseType, off int64) error {
+	if off < 0 || off >= f.info.Size || 0 != off%int64(e.Size) {
+		return fmt.Errorf("invalid %d-byte erase offset: %d", e.Size, off)
+	}
+	op := e.Opcode
+	if Addr4ByteOpcodes == f.info.AddrMode {
+		op = opErase4Byte[op]
+	}
+	if err := f.command(true, op, f.addr(off)...); nil != err {
+		return err
+	}
+	return f.wait(eraseTimeout, erasePoll)
+}
+
+// addr returns the given byte offset formatted as a command address.
+func (f *Flash) addr(off int64) []uint8 {
+	if Addr3Byte == f.info.AddrMode {
+		return ft232h.MSB.Bytes(3, uint64(off))
+	}
+	return ft232h.MSB.Bytes(4, uint64(off))
+}
+
+// wait polls the status register at the given interval until the device is no
+// longer busy, or the given timeout expires.
+func (f *Flash) wait(timeout time.Duration, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		s, err := f.Status()
+		if nil != err {
+			return err
+		}
+		if 0 == s&StatusBusy {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for device ready (%s)", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// read executes the given command with the given address, followed by the
+// given number of dummy bytes, and then reads count bytes in a single SPI
+// transaction.
+func (f *Flash) read(op uint8, addr []uint8, dummy int, count uint) ([]uint8, error) {
+	cmd := append(append([]uint8{op}, addr...), make([]uint8, dummy)...)
+	rd, err := f.spi.Tx().Assert().Write(cmd).Read(count).Deassert().Exec()
+	if nil != err {
+		return nil, err
+	}
+	return rd[0], nil
+}
+
+// command executes the given command with the given address and data bytes,
+// preceded in the same SPI transaction by the write enable command if wren is
+// true.
+func (f *Flash) command(wren bool, op uint8, data ...uint8) error {
+	tx := f.spi.Tx()
+	if wren {
+		tx.Assert().Write([]uint8{opWriteEnable}).Deassert()
+	}
+	_, err := tx.Assert().Write(append([]uint8{op}, data...)).Deassert().Exec()
+	return err
+}
@@ -0,0 +1,441 @@
+package spiflash
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ardnew/ft232h"
+)
+
+// simFlash is a simulated SPI NOR flash device. Commands are decoded as the
+// bytes of each frame (CS asserted) are clocked, and program, erase, and
+// register writes are executed when CS is de-asserted, as on real devices.
+type simFlash struct {
+	id    JEDECID
+	sfdp  []uint8
+	size  uint32
+	mem   map[uint32]uint8 // erased bytes (0xFF) are not stored
+	sr    Status
+	addr4 bool
+	busy  int     // status reads remaining BUSY after a write
+	frame []uint8 // bytes received in the current frame
+	ops   []uint8 // executed write commands
+	err   []string
+}
+
+func (f *simFlash) Select(active bool) {
+	if active {
+		f.frame = f.frame[:0]
+		return
+	}
+	if len(f.frame) > 0 {
+		f.exec()
+	}
+}
+
+func (f *simFlash) Swap(mosi []uint8) []uint8 {
+	miso := make([]uint8, len(mosi))
+	for i, b := range mosi {
+		miso[i] = f.out(len(f.frame))
+		f.frame = append(f.frame, b)
+	}
+	return miso
+}
+
+// alen returns the number of address bytes of the given command.
+func (f *simFlash) alen(op uint8) int {
+	switch op {
+	case opReadSFDP:
+		return 3
+	case opFastRead4B, opPageProgram4B, opErase4K4B, opErase32K4B, opErase64K4B:
+		return 4
+	}
+	if f.addr4 {
+		return 4
+	}
+	return 3
+}
+
+// address returns the address of the current frame.
+func (f *simFlash) address() uint32 {
+	n := f.alen(f.frame[0])
+	return uint32(ft232h.MSB.Uint(uint(n), f.frame[1:1+n]))
+}
+
+// out returns the byte clocked out at the given position of the current frame.
+func (f *simFlash) out(pos int) uint8 {
+	if 0 == pos {
+		return 0xFF
+	}
+	switch op := f.frame[0]; op {
+	case opReadJEDECID:
+		if pos <= 3 {
+			return f.id[pos-1]
+		}
+	case opReadStatus:
+		if f.busy > 0 {
+			f.busy--
+			return uint8(f.sr | StatusBusy)
+		}
+		return uint8(f.sr)
+	case opReadSFDP, opFastRead, opFastRead4B, opRead:
+		skip := 1 + f.alen(op)
+		if opRead != op {
+			skip++ // dummy byte
+		}
+		if pos < skip {
+			break
+		}
+		a := f.address() + uint32(pos-skip)
+		if opReadSFDP == op {
+			if int(a) < len(f.sfdp) {
+				return f.sfdp[a]
+			}
+			break
+		}
+		if v, ok := f.mem[a%f.size]; ok {
+			return v
+		}
+	}
+	return 0xFF
+}
+
+// exec executes the write command of the completed frame.
+func (f *simFlash) exec() {
+	op := f.frame[0]
+	switch op {
+	case opWriteEnable:
+		f.sr |= StatusWEL
+		return
+	case opWriteDisable:
+		f.sr &^= StatusWEL
+		return
+	case opEnter4B:
+		f.addr4 = true
+		return
+	case opExit4B:
+		f.addr4 = false
+		return
+	case opReadJEDECID, opReadStatus, opReadSFDP, opFastRead, opFastRead4B, opRead:
+		return
+	}
+
+	if f.busy > 0 {
+		f.err = append(f.err, "command while busy")
+	}
+	if 0 == f.sr&StatusWEL {
+		f.err = append(f.err, "command without WEL")
+		return
+	}
+	f.sr &^= StatusWEL
+	f.ops = append(f.ops, op)
+	f.busy = 2
+
+	erase := func(size uint32) {
+		a := f.address()
+		if 0 != a%size {
+			f.err = append(f.err, "unaligned erase")
+		}
+		for i := a &^ (size - 1); i < a&^(size-1)+size; i++ {
+			delete(f.mem, i)
+		}
+	}
+	switch op {
+	case opWriteStatus:
+		f.sr = Status(f.frame[1]) &^ (StatusBusy | StatusWEL)
+	case opPageProgram, opPageProgram4B:
+		if 0 != f.sr&StatusBP {
+			f.err = append(f.err, "program while protected")
+			return
+		}
+		a := f.address()
+		data := f.frame[1+f.alen(op):]
+		if len(data) > PageSizeDefault {
+			f.err = append(f.err, "page overflow")
+		}
+		for i, b := range data {
+			// wraps within the page
+			p := a&^(PageSizeDefault-1) | (a+uint32(i))&(PageSizeDefault-1)
+			v, ok := f.mem[p]
+			if !ok {
+				v = 0xFF
+			}
+			f.mem[p] = v & b
+		}
+	case opErase4K, opErase4K4B:
+		erase(4096)
+	case opErase32K, opErase32K4B:
+		erase(32768)
+	case opErase64K, opErase64K4B:
+		erase(65536)
+	case opChipErase:
+		f.mem = map[uint32]uint8{}
+		f.busy = 5
+	default:
+		f.err = append(f.err, "unknown command")
+	}
+}
+
+// simSFDP returns an SFDP table with the given Basic Flash Parameter Table.
+func simSFDP(bfpt []uint32) []uint8 {
+	b := []uint8{
+		'S', 'F', 'D', 'P', 0x06, 0x01, 0x01, 0xFF, // 2 parameter headers
+		0x00, 0x00, 0x01, 0x09, 0x30, 0x00, 0x00, 0xFF, // BFPT 1.0 at 0x30, 9 DWORDs
+		0x00, 0x06, 0x01, uint8(len(bfpt)), 0x30, 0x00, 0x00, 0xFF, // BFPT 1.6 at 0x30
+	}
+	b = append(b, make([]uint8, 0x30-len(b))...)
+	for _, d := range bfpt {
+		b = append(b, ft232h.LSB.Bytes(4, uint64(d))...)
+	}
+	return b
+}
+
+// openFlash returns a Flash on a simulated FT232H with the given simulated
+// flash device attached. If channel is true, the simulated FT232H emulates the
+// libMPSSE SPI channel (see ft232h.PortChannel).
+func openFlash(t *testing.T, sf *simFlash, channel bool) (*Flash, func()) {
+	ft, err := ft232h.OpenSim("spiflash", func(d *ft232h.SimDevice) error {
+		d.Channel = channel
+		return d.AttachSPI(ft232h.D(3), sf)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	if err := ft.SPI.Config(&ft232h.SPIConfig{
+		SPIOption: &ft232h.SPIOption{CS: ft232h.D(3), ActiveLow: true, Mode: 0},
+	}); nil != err {
+		t.Fatalf("SPI.Config(): %v", err)
+	}
+	f, err := New(ft.SPI, nil)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	return f, func() { ft.Close() }
+}
+
+func TestFlash(t *testing.T) {
+
+	for _, tc := range []struct {
+		name string
+		sf   *simFlash
+		info Info
+	}{
+		{
+			name: "SFDP",
+			sf: &simFlash{
+				id:   JEDECID{0xEF, 0x40, 0x14}, // W25Q80
+				size: 1 << 20,
+				sfdp: simSFDP([]uint32{
+					0xFFF120E5, 0x007FFFFF, 0x6B08EB44, 0x3B42BB08, 0xFFFFFFFE,
+					0xFF00FFFF, 0xFF00FFFF, 0x520F200C, 0x0000D810,
+					0, 0x00000080, // 2^8-byte pages
+				}),
+			},
+			info: Info{
+				Size:     1 << 20,
+				PageSize: 256,
+				Erase:    []EraseType{{4096, 0x20}, {32768, 0x52}, {65536, 0xD8}},
+				AddrMode: Addr3Byte,
+			},
+		},
+		{
+			name: "SFDP-4B",
+			sf: &simFlash{
+				id:   JEDECID{0xC2, 0x20, 0x19}, // MX25L25645G
+				size: 1 << 25,
+				sfdp: simSFDP([]uint32{
+					0xFFF320E5, 0x0FFFFFFF, 0x6B08EB44, 0x3B04BB08, 0xFFFFFFFE,
+					0xFF00FFFF, 0xFF00FFFF, 0x520F200C, 0xFF00D810,
+					0, 0x00000080, 0, 0, 0, 0, 0xA1000000, // 4-byte commands or B7h
+				}),
+			},
+			info: Info{
+				Size:     1 << 25,
+				PageSize: 256,
+				Erase:    []EraseType{{4096, 0x20}, {32768, 0x52}, {65536, 0xD8}},
+				AddrMode: Addr4ByteOpcodes,
+			},
+		},
+		{
+			name: "JEDEC",
+			sf: &simFlash{
+				id:   JEDECID{0x20, 0xBA, 0x19}, // no SFDP
+				size: 1 << 25,
+			},
+			info: Info{
+				Size:     1 << 25,
+				PageSize: 256,
+				Erase:    []EraseType{{4096, 0x20}, {65536, 0xD8}},
+				AddrMode: Addr4ByteMode,
+			},
+		},
+	} {
+		for _, channel := range []bool{false, true} {
+			name := tc.name
+			if channel {
+				name += "/Channel"
+			}
+			t.Run(name, func(t *testing.T) {
+
+				sf := &simFlash{}
+				*sf = *tc.sf
+				sf.mem = map[uint32]uint8{}
+				f, done := openFlash(t, sf, channel)
+				defer done()
+
+				if sf.id != f.ID() {
+					t.Fatalf("ID()={%s}, expected={%s}", f.ID(), sf.id)
+				}
+				if info := f.Info(); info.String() != tc.info.String() {
+					t.Fatalf("Info()={%s}, expected={%s}", &info, &tc.info)
+				}
+				if (Addr4ByteMode == tc.info.AddrMode) != sf.addr4 {
+					t.Fatalf("New(): 4-byte address mode={%t}", sf.addr4)
+				}
+
+				// program across a page boundary, near the end of the device
+				off := f.Size() - 3*4096 - 100
+				data := make([]uint8, 400)
+				for i := range data {
+					data[i] = uint8(i*7 + 3)
+				}
+				if n, err := f.WriteAt(data, off); nil != err || len(data) != n {
+					t.Fatalf("WriteAt(): %d, %v", n, err)
+				}
+				if 3 != len(sf.ops) { // 100, 256, and 44 bytes
+					t.Fatalf("WriteAt(): %d page programs, expected %d", len(sf.ops), 3)
+				}
+				rd := make([]uint8, len(data)+2)
+				if n, err := f.ReadAt(rd, off-1); nil != err || len(rd) != n {
+					t.Fatalf("ReadAt(): %d, %v", n, err)
+				}
+				if !bytes.Equal(data, rd[1:len(rd)-1]) || 0xFF != rd[0] || 0xFF != rd[len(rd)-1] {
+					t.Fatalf("ReadAt()={% X}, expected={% X}", rd[1:9], data[:8])
+				}
+
+				// reads are clipped at the end of the device
+				tail := make([]uint8, 8)
+				if n, err := f.ReadAt(tail, f.Size()-4); io.EOF != err || 4 != n {
+					t.Fatalf("ReadAt(): %d, %v, expected %d, %v", n, err, 4, io.EOF)
+				}
+				if _, err := f.WriteAt(tail, f.Size()-4); nil == err {
+					t.Fatalf("WriteAt(): expected error beyond end of device")
+				}
+
+				// erase: sector, block, and a range of mixed sizes
+				sf.ops = sf.ops[:0]
+				if err := f.EraseSector(off &^ 4095); nil != err {
+					t.Fatalf("EraseSector(): %v", err)
+				}
+				if err := f.EraseBlock(0); nil != err {
+					t.Fatalf("EraseBlock(): %v", err)
+				}
+				if err := f.Erase(f.Size()-65536-2*4096, 65536+2*4096); nil != err {
+					t.Fatalf("Erase(): %v", err)
+				}
+				e4K, e64K := opErase4K, opErase64K
+				if Addr4ByteOpcodes == tc.info.AddrMode {
+					e4K, e64K = opErase4K4B, opErase64K4B
+				}
+				// the 8 KiB before the final 64 KiB block is not 32 KiB-aligned
+				exp := []uint8{e4K, e64K, e4K, e4K, e64K}
+				if !bytes.Equal(exp, sf.ops) {
+					t.Fatalf("Erase()={% X}, expected={% X}", sf.ops, exp)
+				}
+				if 0 != len(sf.mem) {
+					t.Fatalf("Erase(): %d bytes not erased", len(sf.mem))
+				}
+				for _, err := range []error{
+					f.EraseSector(100),
+					f.Erase(0, 100),
+					f.Erase(f.Size(), 4096),
+				} {
+					if nil == err {
+						t.Fatalf("Erase(): expected error for unaligned region")
+					}
+				}
+
+				if err := f.EraseChip(); nil != err {
+					t.Fatalf("EraseChip(): %v", err)
+				}
+				if 0 != len(sf.err) {
+					t.Fatalf("simulated flash errors: %v", sf.err)
+				}
+			})
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+
+	sf := &simFlash{
+		id:   JEDECID{0xEF, 0x40, 0x14},
+		size: 1 << 20,
+		mem:  map[uint32]uint8{},
+		sr:   0x1C | StatusSRP, // BP0-BP2 set
+	}
+	f, done := openFlash(t, sf, false)
+	defer done()
+
+	s, err := f.Status()
+	if nil != err || "{ BP=00111 SRP }" != s.String() {
+		t.Fatalf("Status()={%s}, expected={%s}: %v", s, "{ BP=00111 SRP }", err)
+	}
+	if _, err := f.WriteAt([]uint8{0x00}, 0); nil != err || 0 == len(sf.err) {
+		t.Fatalf("WriteAt(): %v, expected write to be rejected while protected", err)
+	}
+	sf.err = nil
+	if err := f.Unprotect(); nil != err {
+		t.Fatalf("Unprotect(): %v", err)
+	}
+	if s, err := f.Status(); nil != err || StatusSRP != s {
+		t.Fatalf("Status()={%s}, expected={%s}: %v", s, StatusSRP, err)
+	}
+	if err := f.SetStatus(0); nil != err || 0 != sf.sr || 0 != len(sf.err) {
+		t.Fatalf("SetStatus()={%s}: %v %v", sf.sr, err, sf.err)
+	}
+
+	// geometry given explicitly
+	info := Info{Size: 1 << 20, PageSize: 256, Erase: []EraseType{{65536, 0xD8}, {4096, 0x20}}}
+	ft, err := ft232h.OpenSim("spiflash", func(d *ft232h.SimDevice) error {
+		return d.AttachSPI(ft232h.D(3), sf)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+	if err := ft.SPI.Init(); nil != err {
+		t.Fatalf("SPI.Init(): %v", err)
+	}
+	g, err := New(ft.SPI, &info)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	if e := g.Info().Erase; 4096 != e[0].Size || 65536 != e[1].Size || 65536 != info.Erase[0].Size {
+		t.Fatalf("Info()={%s}, expected erase types sorted by size", g)
+	}
+	for _, bad := range []Info{
+		{Size: 1 << 25, PageSize: 256, Erase: info.Erase},
+		{Size: 1 << 20, PageSize: 100, Erase: info.Erase},
+		{Size: 1 << 20, PageSize: 256},
+		{Size: 1 << 25, PageSize: 256, Erase: []EraseType{{4096, 0x81}}, AddrMode: Addr4ByteOpcodes},
+	} {
+		if _, err := New(ft.SPI, &bad); nil == err {
+			t.Fatalf("New(%s): expected error", &bad)
+		}
+	}
+
+	// no device
+	ft, err = ft232h.OpenSim("spiflash", nil)
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+	if err := ft.SPI.Init(); nil != err {
+		t.Fatalf("SPI.Init(): %v", err)
+	}
+	if _, err := New(ft.SPI, nil); nil == err {
+		t.Fatalf("New(): expected error without device")
+	}
+}
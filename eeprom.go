@@ -0,0 +1,533 @@
+package ft232h
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// EEPROMSize is the size (bytes) of the FT232H configuration EEPROM (93C56).
+const EEPROMSize = 256
+
+// Constants defining the layout of the FT232H configuration EEPROM.
+const (
+	eepromChannel   = 0x00 // channel type and driver
+	eepromFT1248    = 0x01 // FT1248 options and power save
+	eepromVID       = 0x02 // USB vendor ID (LSB first)
+	eepromPID       = 0x04 // USB product ID (LSB first)
+	eepromRelease   = 0x06 // USB device release number (LSB first)
+	eepromAttrib    = 0x08 // USB configuration attributes
+	eepromMaxPower  = 0x09 // maximum bus current (2 mA units)
+	eepromChipCfg   = 0x0A // suspend pull-downs and serial number enable
+	eepromDriveD    = 0x0C // port "D" drive strength
+	eepromDriveC    = 0x0D // port "C" drive strength
+	eepromStrings   = 0x0E // offset and length of each string descriptor
+	eepromCBUS      = 0x18 // ACBUS pin functions (one nibble each)
+	eepromChip      = 0x1E // EEPROM chip type
+	eepromStrBase   = 0xA0 // first byte of the string descriptors
+	eepromChecksum  = EEPROMSize - 2
+	eepromChipType  = 0x56 // 93C56
+	eepromMaxStrLen = eepromChecksum - eepromStrBase
+)
+
+// EEPROMChannel represents the interface mode of the FT232H after reset.
+type EEPROMChannel uint8
+
+// Constants defining the supported channel types.
+const (
+	EEPROMChannelUART   EEPROMChannel = 0x00 // asynchronous serial UART
+	EEPROMChannelFIFO   EEPROMChannel = 0x01 // 245 FIFO
+	EEPROMChannelOpto   EEPROMChannel = 0x02 // fast opto-isolated serial
+	EEPROMChannelCPU    EEPROMChannel = 0x04 // CPU-style FIFO
+	EEPROMChannelFT1248 EEPROMChannel = 0x08 // FT1248
+)
+
+// String returns a string describing the channel type.
+func (c EEPROMChannel) String() string {
+	switch c {
+	case EEPROMChannelUART:
+		return "UART"
+	case EEPROMChannelFIFO:
+		return "245 FIFO"
+	case EEPROMChannelOpto:
+		return "fast serial"
+	case EEPROMChannelCPU:
+		return "CPU FIFO"
+	case EEPROMChannelFT1248:
+		return "FT1248"
+	default:
+		return "(invalid channel)"
+	}
+}
+
+// EEPROMDriver represents the host driver loaded for the FT232H.
+type EEPROMDriver uint8
+
+// Constants defining the supported host drivers.
+const (
+	EEPROMDriverD2XX EEPROMDriver = 0 // D2XX direct driver
+	EEPROMDriverVCP  EEPROMDriver = 1 // virtual COM port
+)
+
+// String returns a string describing the host driver.
+func (d EEPROMDriver) String() string {
+	switch d {
+	case EEPROMDriverD2XX:
+		return "D2XX"
+	case EEPROMDriverVCP:
+		return "VCP"
+	default:
+		return "(invalid driver)"
+	}
+}
+
+// EEPROMCBUS represents the function of a configurable ACBUS pin.
+type EEPROMCBUS uint8
+
+// Constants defining the supported ACBUS pin functions.
+const (
+	EEPROMCBUSTristate EEPROMCBUS = 0  // tristate (pulled up)
+	EEPROMCBUSTXLED    EEPROMCBUS = 1  // LED driven LOW on transmit
+	EEPROMCBUSRXLED    EEPROMCBUS = 2  // LED driven LOW on receive
+	EEPROMCBUSTXRXLED  EEPROMCBUS = 3  // LED driven LOW on transmit or receive
+	EEPROMCBUSPWREN    EEPROMCBUS = 4  // PWREN#, LOW once configured by USB
+	EEPROMCBUSSleep    EEPROMCBUS = 5  // SLEEP#, LOW in USB suspend
+	EEPROMCBUSDrive0   EEPROMCBUS = 6  // driven LOW
+	EEPROMCBUSDrive1   EEPROMCBUS = 7  // driven HIGH
+	EEPROMCBUSIOMode   EEPROMCBUS = 8  // GPIO in CBUS bit-bang mode
+	EEPROMCBUSTXDEN    EEPROMCBUS = 9  // transmit enable for RS485
+	EEPROMCBUSClock30  EEPROMCBUS = 10 // 30 MHz clock output
+	EEPROMCBUSClock15  EEPROMCBUS = 11 // 15 MHz clock output
+	EEPROMCBUSClock7_5 EEPROMCBUS = 12 // 7.5 MHz clock output
+)
+
+// String returns a string describing the ACBUS pin function.
+func (f EEPROMCBUS) String() string {
+	switch f {
+	case EEPROMCBUSTristate:
+		return "TRISTATE"
+	case EEPROMCBUSTXLED:
+		return "TXLED#"
+	case EEPROMCBUSRXLED:
+		return "RXLED#"
+	case EEPROMCBUSTXRXLED:
+		return "TX&RXLED#"
+	case EEPROMCBUSPWREN:
+		return "PWREN#"
+	case EEPROMCBUSSleep:
+		return "SLEEP#"
+	case EEPROMCBUSDrive0:
+		return "DRIVE0"
+	case EEPROMCBUSDrive1:
+		return "DRIVE1"
+	case EEPROMCBUSIOMode:
+		return "IOMODE"
+	case EEPROMCBUSTXDEN:
+		return "TXDEN"
+	case EEPROMCBUSClock30:
+		return "CLK30"
+	case EEPROMCBUSClock15:
+		return "CLK15"
+	case EEPROMCBUSClock7_5:
+		return "CLK7.5"
+	default:
+		return "(invalid function)"
+	}
+}
+
+// EEPROMDrive contains the output drive settings of a group of pins.
+type EEPROMDrive struct {
+	Current  uint // drive current (mA): 4, 8, 12, or 16
+	Schmitt  bool // Schmitt trigger inputs
+	SlowSlew bool // slow output slew rate
+}
+
+// String returns a descriptive string of the drive settings.
+func (d EEPROMDrive) String() string {
+	return fmt.Sprintf("{ Current: %dmA, Schmitt: %t, SlowSlew: %t }",
+		d.Current, d.Schmitt, d.SlowSlew)
+}
+
+// encode returns the drive settings encoded in the low nibble of an EEPROM
+// byte.
+func (d EEPROMDrive) encode() (uint8, error) {
+	if d.Current < 4 || d.Current > 16 || 0 != d.Current%4 {
+		return 0, fmt.Errorf("invalid drive current: %dmA", d.Current)
+	}
+	b := uint8(d.Current/4 - 1)
+	if d.SlowSlew {
+		b |= 0x04
+	}
+	if d.Schmitt {
+		b |= 0x08
+	}
+	return b, nil
+}
+
+// eepromDrive returns the drive settings encoded in the low nibble of the given
+// EEPROM byte.
+func eepromDrive(b uint8) EEPROMDrive {
+	return EEPROMDrive{
+		Current:  4 * uint(b&0x03+1),
+		SlowSlew: 0 != b&0x04,
+		Schmitt:  0 != b&0x08,
+	}
+}
+
+// EEPROMConfig contains the settings stored in the configuration EEPROM of an
+// FT232H, which take effect the next time the device is enumerated by USB.
+//
+// An EEPROMConfig decoded from an EEPROM image (see DecodeEEPROM) retains the
+// image, so that encoding it again preserves the contents of the EEPROM not
+// represented by the struct fields, such as the user area following the string
+// descriptors. Only the bytes of the existing string descriptors are cleared,
+// so strings longer than before overwrite the beginning of the user area.
+type EEPROMConfig struct {
+	VID          uint16 // USB vendor ID
+	PID          uint16 // USB product ID
+	Release      uint16 // USB device release number (BCD)
+	Manufacturer string // USB manufacturer string
+	Product      string // USB product description string
+	Serial       string // USB serial number string
+	SerialEnable bool   // report the serial number to the host
+
+	SelfPowered     bool // self-powered (otherwise bus-powered)
+	RemoteWakeup    bool // USB remote wakeup capable
+	MaxPower        uint // maximum bus current (mA), 0-500
+	SuspendPullDown bool // pull down I/O pins during USB suspend
+	PowerSave       bool // ACBUS7 is the power save input PWRSAV#
+
+	Channel           EEPROMChannel // interface mode after reset
+	Driver            EEPROMDriver  // host driver
+	FT1248ClockHigh   bool          // FT1248 clock idles HIGH
+	FT1248LSB         bool          // FT1248 data transferred LSB first
+	FT1248FlowControl bool          // FT1248 flow control enabled
+
+	DriveD EEPROMDrive    // port "D" (ADBUS) drive settings
+	DriveC EEPROMDrive    // port "C" (ACBUS) drive settings
+	CBUS   [10]EEPROMCBUS // functions of pins ACBUS0-ACBUS9
+
+	image []uint8 // decoded EEPROM image
+}
+
+// EEPROMConfigDefault returns the default configuration settings of an FT232H:
+// FTDI vendor ID and FT232H product ID, bus-powered drawing at most 100 mA,
+// UART channel with the VCP driver, 4 mA drive on all pins, and all ACBUS pins
+// tristated. The serial number is empty and not reported.
+func EEPROMConfigDefault() *EEPROMConfig {
+	return &EEPROMConfig{
+		VID:          0x0403,
+		PID:          0x6014,
+		Release:      0x0900,
+		Manufacturer: "FTDI",
+		Product:      "Single RS232-HS",
+		MaxPower:     100,
+		Channel:      EEPROMChannelUART,
+		Driver:       EEPROMDriverVCP,
+		DriveD:       EEPROMDrive{Current: 4},
+		DriveC:       EEPROMDrive{Current: 4},
+	}
+}
+
+// String returns a descriptive string of an EEPROM configuration.
+func (c *EEPROMConfig) String() string {
+	return fmt.Sprintf("{ VID: 0x%04X, PID: 0x%04X, Release: 0x%04X, "+
+		"Manufacturer: %q, Product: %q, Serial: %q, SerialEnable: %t, "+
+		"SelfPowered: %t, RemoteWakeup: %t, MaxPower: %dmA, SuspendPullDown: %t, PowerSave: %t, "+
+		"Channel: %s, Driver: %s, DriveD: %s, DriveC: %s, CBUS: %v }",
+		c.VID, c.PID, c.Release, c.Manufacturer, c.Product, c.Serial, c.SerialEnable,
+		c.SelfPowered, c.RemoteWakeup, c.MaxPower, c.SuspendPullDown, c.PowerSave,
+		c.Channel, c.Driver, c.DriveD, c.DriveC, c.CBUS)
+}
+
+// EEPROMChecksum returns the checksum of the given EEPROM image, computed over
+// all words except the last, where it is stored (LSB first).
+func EEPROMChecksum(image []uint8) uint16 {
+	sum := uint16(0xAAAA)
+	for i := 0; i+1 < eepromChecksum && i+1 < len(image); i += 2 {
+		sum ^= uint16(image[i]) | uint16(image[i+1])<<8
+		sum = sum<<1 | sum>>15
+	}
+	return sum
+}
+
+// DecodeEEPROM decodes the given FT232H configuration EEPROM image of
+// EEPROMSize bytes. Returns a non-nil error if the image is blank
+// (SEEPROMNotProgrammed), its checksum is invalid, or its string descriptors
+// are malformed.
+func DecodeEEPROM(image []uint8) (*EEPROMConfig, error) {
+
+	if EEPROMSize != len(image) {
+		return nil, fmt.Errorf("invalid EEPROM image size: %d (expected %d)",
+			len(image), EEPROMSize)
+	}
+	if bytes.Equal(image, bytes.Repeat([]uint8{0xFF}, EEPROMSize)) {
+		return nil, SEEPROMNotProgrammed
+	}
+	sum := EEPROMChecksum(image)
+	if stored := LSB.Uint(2, image[eepromChecksum:]); uint64(sum) != stored {
+		return nil, fmt.Errorf("invalid EEPROM checksum: 0x%04X (expected 0x%04X)",
+			stored, sum)
+	}
+
+	c := &EEPROMConfig{
+		VID:               uint16(LSB.Uint(2, image[eepromVID:])),
+		PID:               uint16(LSB.Uint(2, image[eepromPID:])),
+		Release:           uint16(LSB.Uint(2, image[eepromRelease:])),
+		SerialEnable:      0 != image[eepromChipCfg]&0x08,
+		SelfPowered:       0 != image[eepromAttrib]&0x40,
+		RemoteWakeup:      0 != image[eepromAttrib]&0x20,
+		MaxPower:          2 * uint(image[eepromMaxPower]),
+		SuspendPullDown:   0 != image[eepromChipCfg]&0x04,
+		PowerSave:         0 != image[eepromFT1248]&0x80,
+		Channel:           EEPROMChannel(image[eepromChannel] & 0x0F),
+		Driver:            EEPROMDriverD2XX,
+		FT1248ClockHigh:   0 != image[eepromFT1248]&0x01,
+		FT1248LSB:         0 != image[eepromFT1248]&0x02,
+		FT1248FlowControl: 0 != image[eepromFT1248]&0x04,
+		DriveD:            eepromDrive(image[eepromDriveD]),
+		DriveC:            eepromDrive(image[eepromDriveC]),
+		image:             append([]uint8{}, image...),
+	}
+	if 0 != image[eepromChannel]&0x10 {
+		c.Driver = EEPROMDriverVCP
+	}
+	for i := range c.CBUS {
+		c.CBUS[i] = EEPROMCBUS(image[eepromCBUS+i/2] >> (4 * uint(i%2)) & 0x0F)
+	}
+
+	var err error
+	for i, s := range []*string{&c.Manufacturer, &c.Product, &c.Serial} {
+		if *s, err = eepromString(image, eepromStrings+2*i); nil != err {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// eepromString decodes the USB string descriptor whose byte offset and length
+// are stored at the given position of the given EEPROM image.
+func eepromString(image []uint8, pos int) (string, error) {
+	off, n := int(image[pos]), int(image[pos+1])
+	if 0 == n {
+		return "", nil
+	}
+	if n < 2 || 0 != n%2 || off+n > eepromChecksum ||
+		n != int(image[off]) || 0x03 != image[off+1] {
+		return "", fmt.Errorf("invalid EEPROM string descriptor: %d bytes at offset 0x%02X",
+			n, off)
+	}
+	u := make([]uint16, (n-2)/2)
+	for i := range u {
+		u[i] = uint16(LSB.Uint(2, image[off+2+2*i:]))
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// Encode returns the FT232H configuration EEPROM image of the receiver, with a
+// valid checksum. Returns a non-nil error if any setting is invalid, or if the
+// strings do not fit in the EEPROM.
+func (c *EEPROMConfig) Encode() ([]uint8, error) {
+
+	if c.MaxPower > 500 {
+		return nil, fmt.Errorf("invalid maximum bus current: %dmA", c.MaxPower)
+	}
+	switch c.Channel {
+	case EEPROMChannelUART, EEPROMChannelFIFO, EEPROMChannelOpto,
+		EEPROMChannelCPU, EEPROMChannelFT1248:
+	default:
+		return nil, fmt.Errorf("invalid channel type: 0x%02X", uint8(c.Channel))
+	}
+	if c.Driver > EEPROMDriverVCP {
+		return nil, fmt.Errorf("invalid driver type: %d", c.Driver)
+	}
+	for i, f := range c.CBUS {
+		if f > EEPROMCBUSClock7_5 {
+			return nil, fmt.Errorf("invalid ACBUS%d function: %d", i, f)
+		}
+	}
+	driveD, err := c.DriveD.encode()
+	if nil != err {
+		return nil, err
+	}
+	driveC, err := c.DriveC.encode()
+	if nil != err {
+		return nil, err
+	}
+
+	b := make([]uint8, EEPROMSize)
+	if nil != c.image {
+		copy(b, c.image)
+	} else {
+		b[eepromChip] = eepromChipType
+	}
+
+	b[eepromChannel] = b[eepromChannel]&0xE0 | uint8(c.Channel)
+	eepromBit(b, eepromChannel, 0x10, EEPROMDriverVCP == c.Driver)
+	eepromBit(b, eepromFT1248, 0x01, c.FT1248ClockHigh)
+	eepromBit(b, eepromFT1248, 0x02, c.FT1248LSB)
+	eepromBit(b, eepromFT1248, 0x04, c.FT1248FlowControl)
+	eepromBit(b, eepromFT1248, 0x80, c.PowerSave)
+	copy(b[eepromVID:], LSB.Bytes(2, uint64(c.VID)))
+	copy(b[eepromPID:], LSB.Bytes(2, uint64(c.PID)))
+	copy(b[eepromRelease:], LSB.Bytes(2, uint64(c.Release)))
+	b[eepromAttrib] |= 0x80 // reserved, always set
+	eepromBit(b, eepromAttrib, 0x40, c.SelfPowered)
+	eepromBit(b, eepromAttrib, 0x20, c.RemoteWakeup)
+	b[eepromMaxPower] = uint8((c.MaxPower + 1) / 2)
+	eepromBit(b, eepromChipCfg, 0x04, c.SuspendPullDown)
+	eepromBit(b, eepromChipCfg, 0x08, c.SerialEnable)
+	b[eepromDriveD] = b[eepromDriveD]&0xF0 | driveD
+	b[eepromDriveC] = b[eepromDriveC]&0xF0 | driveC
+	for i, f := range c.CBUS {
+		shift := 4 * uint(i%2)
+		b[eepromCBUS+i/2] = b[eepromCBUS+i/2]&^(0x0F<<shift) | uint8(f)<<shift
+	}
+
+	// string descriptors are stored consecutively from the first byte, replacing
+	// the existing descriptors. The remaining bytes (the user area) are retained,
+	// except where overwritten by longer strings.
+	for i := 0; i < 3; i++ {
+		pos, n := int(b[eepromStrings+2*i]), int(b[eepromStrings+2*i+1])
+		if pos < eepromStrBase || pos+n > eepromChecksum {
+			continue
+		}
+		for j := pos; j < pos+n; j++ {
+			b[j] = 0
+		}
+	}
+	off := eepromStrBase
+	for i, s := range []string{c.Manufacturer, c.Product, c.Serial} {
+		u := utf16.Encode([]rune(s))
+		n := 2 + 2*len(u)
+		if 0 == len(u) {
+			b[eepromStrings+2*i], b[eepromStrings+2*i+1] = 0, 0
+			continue
+		}
+		if off+n > eepromChecksum {
+			return nil, fmt.Errorf("EEPROM strings too long (maximum %d bytes total, "+
+				"including 2 bytes each)", eepromMaxStrLen)
+		}
+		b[eepromStrings+2*i], b[eepromStrings+2*i+1] = uint8(off), uint8(n)
+		b[off], b[off+1] = uint8(n), 0x03
+		for j, r := range u {
+			copy(b[off+2+2*j:], LSB.Bytes(2, uint64(r)))
+		}
+		off += n
+	}
+
+	copy(b[eepromChecksum:], LSB.Bytes(2, uint64(EEPROMChecksum(b))))
+	return b, nil
+}
+
+// eepromBit sets (if on is true) or clears the given bits of the byte at the
+// given position of the given EEPROM image.
+func eepromBit(image []uint8, pos int, mask uint8, on bool) {
+	if on {
+		image[pos] |= mask
+	} else {
+		image[pos] &^= mask
+	}
+}
+
+// eeprom returns the receiver's Port as a PortEEPROM, and a non-nil error if
+// the Port cannot access the configuration EEPROM.
+func (m *FT232H) eeprom() (PortEEPROM, error) {
+	if nil == m.info || nil == m.info.port {
+		return nil, SDeviceNotOpened
+	}
+	if e, ok := m.info.port.(PortEEPROM); ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("configuration EEPROM not supported by transport")
+}
+
+// ReadEEPROM reads the entire configuration EEPROM image of EEPROMSize bytes.
+func (m *FT232H) ReadEEPROM() ([]uint8, error) {
+	e, err := m.eeprom()
+	if nil != err {
+		return nil, err
+	}
+	image := make([]uint8, EEPROMSize)
+	for i := 0; i < EEPROMSize; i += 2 {
+		w, err := e.ReadEEPROM(uint8(i / 2))
+		if nil != err {
+			return nil, err
+		}
+		copy(image[i:], LSB.Bytes(2, uint64(w)))
+	}
+	return image, nil
+}
+
+// WriteEEPROM writes the given configuration EEPROM image of EEPROMSize bytes.
+// Only the words differing from the current contents of the EEPROM are
+// written, and the entire EEPROM is then read back for verification. The image
+// is written as given; use EEPROMConfig.Encode to construct a valid image.
+// Returns an error wrapping SEEPROMWriteFailed if verification fails.
+func (m *FT232H) WriteEEPROM(image []uint8) error {
+	if EEPROMSize != len(image) {
+		return fmt.Errorf("invalid EEPROM image size: %d (expected %d)",
+			len(image), EEPROMSize)
+	}
+	e, err := m.eeprom()
+	if nil != err {
+		return err
+	}
+	curr, err := m.ReadEEPROM()
+	if nil != err {
+		return err
+	}
+	for i := 0; i < EEPROMSize; i += 2 {
+		if curr[i] != image[i] || curr[i+1] != image[i+1] {
+			if err := e.WriteEEPROM(uint8(i/2), uint16(LSB.Uint(2, image[i:]))); nil != err {
+				return err
+			}
+		}
+	}
+	if curr, err = m.ReadEEPROM(); nil != err {
+		return err
+	}
+	for i := range curr {
+		if curr[i] != image[i] {
+			return fmt.Errorf("%w: verify failed at offset 0x%02X: 0x%02X (expected 0x%02X)",
+				SEEPROMWriteFailed, i, curr[i], image[i])
+		}
+	}
+	return nil
+}
+
+// EraseEEPROM erases the entire configuration EEPROM. An FT232H with a blank
+// EEPROM uses its default configuration the next time it is enumerated by USB.
+func (m *FT232H) EraseEEPROM() error {
+	e, err := m.eeprom()
+	if nil != err {
+		return err
+	}
+	return e.EraseEEPROM()
+}
+
+// EEPROMConfig reads and decodes the configuration EEPROM (see DecodeEEPROM).
+func (m *FT232H) EEPROMConfig() (*EEPROMConfig, error) {
+	image, err := m.ReadEEPROM()
+	if nil != err {
+		return nil, err
+	}
+	return DecodeEEPROM(image)
+}
+
+// SetEEPROMConfig encodes and writes the given configuration to the
+// configuration EEPROM (see EEPROMConfig.Encode and WriteEEPROM). The new
+// settings take effect the next time the device is enumerated by USB.
+// To modify individual settings, read the current configuration with
+// EEPROMConfig, change its fields, and pass it to SetEEPROMConfig.
+func (m *FT232H) SetEEPROMConfig(cfg *EEPROMConfig) error {
+	if nil == cfg {
+		return fmt.Errorf("nil EEPROM configuration")
+	}
+	image, err := cfg.Encode()
+	if nil != err {
+		return err
+	}
+	return m.WriteEEPROM(image)
+}
@@ -0,0 +1,182 @@
+package ft232h
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEEPROMConfig(t *testing.T) {
+
+	cfg := EEPROMConfigDefault()
+	cfg.Serial, cfg.SerialEnable = "FT5X9K2A", true
+	cfg.SelfPowered, cfg.MaxPower = true, 90
+	cfg.Channel, cfg.Driver = EEPROMChannelFT1248, EEPROMDriverD2XX
+	cfg.FT1248LSB = true
+	cfg.DriveD = EEPROMDrive{Current: 16, Schmitt: true}
+	cfg.DriveC = EEPROMDrive{Current: 8, SlowSlew: true}
+	cfg.CBUS[0], cfg.CBUS[5], cfg.CBUS[9] = EEPROMCBUSTXLED, EEPROMCBUSClock7_5, EEPROMCBUSDrive1
+
+	image, err := cfg.Encode()
+	if nil != err {
+		t.Fatalf("Encode(): %v", err)
+	}
+	if EEPROMSize != len(image) {
+		t.Fatalf("len(Encode())={%d}, expected={%d}", len(image), EEPROMSize)
+	}
+	for _, b := range []struct {
+		pos int
+		exp []uint8
+	}{
+		{0x00, []uint8{0x08, 0x02, 0x03, 0x04, 0x14, 0x60, 0x00, 0x09, 0xC0, 0x2D, 0x08}},
+		{0x0C, []uint8{0x0B, 0x05}},
+		{0x0E, []uint8{0xA0, 0x0A, 0xAA, 0x20, 0xCA, 0x12}},
+		{0x18, []uint8{0x01, 0x00, 0xC0, 0x00, 0x70}},
+		{0x1E, []uint8{0x56}},
+		{0xA0, []uint8{0x0A, 0x03, 'F', 0, 'T', 0, 'D', 0, 'I', 0, 0x20, 0x03, 'S', 0}},
+		{0xCA, []uint8{0x12, 0x03, 'F', 0, 'T', 0, '5', 0}},
+	} {
+		if act := image[b.pos : b.pos+len(b.exp)]; !bytes.Equal(b.exp, act) {
+			t.Fatalf("Encode()[0x%02X:]={% 02X}, expected={% 02X}", b.pos, act, b.exp)
+		}
+	}
+	if sum := EEPROMChecksum(image); uint64(sum) != LSB.Uint(2, image[0xFE:]) {
+		t.Fatalf("checksum={%04X}, expected={%04X}", LSB.Uint(2, image[0xFE:]), sum)
+	}
+
+	dec, err := DecodeEEPROM(image)
+	if nil != err {
+		t.Fatalf("DecodeEEPROM(): %v", err)
+	}
+	dec.image = nil
+	if cfg.String() != dec.String() || cfg.FT1248LSB != dec.FT1248LSB {
+		t.Fatalf("DecodeEEPROM()={%s}, expected={%s}", dec, cfg)
+	}
+
+	// the user area is retained when modifying a decoded image
+	image[0x40] = 0x5A
+	user := []uint8{0xDE, 0xAD, 0xBE, 0xEF}
+	copy(image[0xF0:], user)
+	copy(image[0xFE:], LSB.Bytes(2, uint64(EEPROMChecksum(image))))
+	if dec, err = DecodeEEPROM(image); nil != err {
+		t.Fatalf("DecodeEEPROM(): %v", err)
+	}
+	dec.Product, dec.Serial = "Adapter", "X"
+	mod, err := dec.Encode()
+	if nil != err {
+		t.Fatalf("Encode(): %v", err)
+	}
+	if 0x5A != mod[0x40] || !bytes.Equal(image[:0x0E], mod[:0x0E]) {
+		t.Fatalf("Encode()={% 02X}, expected={% 02X}", mod[:0x41], image[:0x41])
+	}
+	if dec, err = DecodeEEPROM(mod); nil != err || "Adapter" != dec.Product || "X" != dec.Serial {
+		t.Fatalf("DecodeEEPROM()={%v}: %v", dec, err)
+	}
+	// the bytes of the old, longer strings are cleared
+	for i := 0xA0 + 10 + 16 + 4; i < 0xF0; i++ {
+		if 0 != mod[i] {
+			t.Fatalf("Encode()[0x%02X]={%02X}, expected={%02X}", i, mod[i], 0)
+		}
+	}
+	if !bytes.Equal(user, mod[0xF0:0xF4]) {
+		t.Fatalf("Encode()[0xF0:]={% 02X}, expected={% 02X}", mod[0xF0:0xF4], user)
+	}
+}
+
+func TestEEPROMConfigInvalid(t *testing.T) {
+
+	for _, set := range []func(c *EEPROMConfig){
+		func(c *EEPROMConfig) { c.MaxPower = 502 },
+		func(c *EEPROMConfig) { c.Channel = 0x03 },
+		func(c *EEPROMConfig) { c.Driver = 2 },
+		func(c *EEPROMConfig) { c.DriveD.Current = 0 },
+		func(c *EEPROMConfig) { c.DriveC.Current = 10 },
+		func(c *EEPROMConfig) { c.CBUS[7] = EEPROMCBUSClock7_5 + 1 },
+		func(c *EEPROMConfig) { c.Product = strings.Repeat("x", 42) },
+	} {
+		c := EEPROMConfigDefault()
+		set(c)
+		if _, err := c.Encode(); nil == err {
+			t.Fatalf("Encode(%s): expected error", c)
+		}
+	}
+
+	// strings of the maximum total length fit exactly
+	c := EEPROMConfigDefault()
+	c.Product = strings.Repeat("x", (eepromMaxStrLen-10-2-4)/2)
+	c.Serial = "y"
+	if _, err := c.Encode(); nil != err {
+		t.Fatalf("Encode(): %v", err)
+	}
+
+	if _, err := DecodeEEPROM(bytes.Repeat([]uint8{0xFF}, EEPROMSize)); !errors.Is(err, SEEPROMNotProgrammed) {
+		t.Fatalf("DecodeEEPROM(blank)={%v}, expected={%v}", err, SEEPROMNotProgrammed)
+	}
+	if _, err := DecodeEEPROM(make([]uint8, 128)); nil == err {
+		t.Fatalf("DecodeEEPROM(128): expected error")
+	}
+	image, _ := EEPROMConfigDefault().Encode()
+	image[0x02] ^= 0x01
+	if _, err := DecodeEEPROM(image); nil == err {
+		t.Fatalf("DecodeEEPROM(checksum): expected error")
+	}
+	image[0x02] ^= 0x01
+	image[0x0F] = 0x08 // length mismatch
+	copy(image[0xFE:], LSB.Bytes(2, uint64(EEPROMChecksum(image))))
+	if _, err := DecodeEEPROM(image); nil == err {
+		t.Fatalf("DecodeEEPROM(string): expected error")
+	}
+}
+
+func TestEEPROM(t *testing.T) {
+
+	var dev *SimDevice
+	ft, err := OpenSim("eeprom", func(d *SimDevice) error { dev = d; return nil })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if _, err := ft.EEPROMConfig(); !errors.Is(err, SEEPROMNotProgrammed) {
+		t.Fatalf("EEPROMConfig()={%v}, expected={%v}", err, SEEPROMNotProgrammed)
+	}
+
+	cfg := EEPROMConfigDefault()
+	cfg.Serial, cfg.SerialEnable = "FT000001", true
+	if err := ft.SetEEPROMConfig(cfg); nil != err {
+		t.Fatalf("SetEEPROMConfig(): %v", err)
+	}
+	image, _ := cfg.Encode()
+	for i, w := range dev.eeprom {
+		if exp := uint16(LSB.Uint(2, image[2*i:])); exp != w {
+			t.Fatalf("eeprom[0x%02X]={%04X}, expected={%04X}", i, w, exp)
+		}
+	}
+
+	// read, modify, write
+	c, err := ft.EEPROMConfig()
+	if nil != err {
+		t.Fatalf("EEPROMConfig(): %v", err)
+	}
+	c.Serial = "FT000002"
+	if err := ft.SetEEPROMConfig(c); nil != err {
+		t.Fatalf("SetEEPROMConfig(): %v", err)
+	}
+	if c, err = ft.EEPROMConfig(); nil != err || "FT000002" != c.Serial || "FTDI" != c.Manufacturer {
+		t.Fatalf("EEPROMConfig()={%v}: %v", c, err)
+	}
+
+	if err := ft.EraseEEPROM(); nil != err {
+		t.Fatalf("EraseEEPROM(): %v", err)
+	}
+	if image, err = ft.ReadEEPROM(); nil != err || !bytes.Equal(bytes.Repeat([]uint8{0xFF}, EEPROMSize), image) {
+		t.Fatalf("ReadEEPROM()={% 02X}, expected blank: %v", image, err)
+	}
+	if err := ft.WriteEEPROM(image[:10]); nil == err {
+		t.Fatalf("WriteEEPROM(10): expected error")
+	}
+	if err := ft.SetEEPROMConfig(nil); nil == err {
+		t.Fatalf("SetEEPROMConfig(nil): expected error")
+	}
+}
@@ -4,22 +4,7 @@ import (
 	"log"
 
 	"github.com/ardnew/ft232h"
-)
-
-const (
-	slave = 0x40       // default INA260 slave address
-	order = ft232h.MSB // byte order of INA260 data transfers
-
-	// register addresses
-	cAddr = 0x01 // voltage
-	vAddr = 0x02 // current
-	pAddr = 0x03 // power
-
-	// precision of the INA260 each register's LSB
-	cLSB = 1.25 // milliamps
-	vLSB = 1.25 // millivolts
-	pLSB = 10.0 // milliwatts
-
+	"github.com/ardnew/ft232h/drv/ina260"
 )
 
 func main() {
@@ -37,21 +22,15 @@ func main() {
 		log.Fatalf("I2C.Init(): %v", err)
 	}
 
-	// create a voltage register object
-	reg := ft.I2C.Reg(slave, vAddr, ft232h.Addr8Bit, order)
-
-	// initialize the voltage register pointer
-	voltage, e := reg.Reader(2)
-	if nil != e {
-		log.Fatalf("reg.Reader(): %v", e)
-	}
+	// create the INA260 register accessors
+	reg := ina260.NewRegs(ft.I2C, ina260.DefaultAddress)
 
 	// repeatedly dump the voltage register
 	for {
-		if v, e := voltage(false); nil != e {
-			log.Fatalf("voltage(): %v", e)
+		if v, e := reg.VoltageScaled(); nil != e {
+			log.Fatalf("VoltageScaled(): %v", e)
 		} else {
-			log.Printf("voltage = %.1f mV", float32(v)*vLSB)
+			log.Printf("voltage = %.1f mV", v)
 		}
 	}
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ardnew/ft232h"
+	"github.com/ardnew/ft232h/svf"
+)
+
+func main() {
+
+	if 2 != len(os.Args) {
+		log.Fatalf("usage: %s FILE.svf|FILE.xsvf", filepath.Base(os.Args[0]))
+	}
+
+	f, err := os.Open(os.Args[1])
+	if nil != err {
+		log.Fatalf("Open(): %v", err)
+	}
+	defer f.Close()
+
+	// open the FT232H
+	ft, err := ft232h.New()
+	if nil != err {
+		log.Fatalf("New(): %s", err)
+	}
+	defer ft.Close()
+	log.Printf("%s", ft)
+
+	// initialize FT232H in JTAG mode (TCK 1 MHz)
+	if err := ft.JTAG.Init(); nil != err {
+		log.Fatalf("JTAG.Init(): %v", err)
+	}
+
+	// list the devices on the scan chain
+	dev, err := ft.JTAG.Scan()
+	if nil != err {
+		log.Fatalf("JTAG.Scan(): %v", err)
+	}
+	for i, d := range dev {
+		log.Printf("TAP %d: %s", i, d)
+	}
+
+	p, err := svf.New(ft.JTAG)
+	if nil != err {
+		log.Fatalf("svf.New(): %v", err)
+	}
+	if strings.EqualFold(".xsvf", filepath.Ext(os.Args[1])) {
+		err = p.PlayXSVF(f)
+	} else {
+		err = p.Play(f)
+	}
+	if nil != err {
+		log.Fatalf("play: %v", err)
+	}
+	log.Printf("done")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/ardnew/ft232h"
+)
+
+func main() {
+
+	// open the FT232H
+	ft, err := ft232h.New()
+	if nil != err {
+		log.Fatalf("New(): %s", err)
+	}
+	defer ft.Close()
+	log.Printf("%s", ft)
+
+	// initialize FT232H in UART mode (115200 8N1)
+	if err := ft.UART.Init(); nil != err {
+		log.Fatalf("UART.Init(): %v", err)
+	}
+
+	// forward everything typed on stdin to the serial console
+	go func() {
+		if _, err := io.Copy(ft.UART, os.Stdin); nil != err {
+			log.Fatalf("write: %v", err)
+		}
+	}()
+
+	// print everything received from the serial console
+	if _, err := io.Copy(os.Stdout, ft.UART); nil != err {
+		log.Fatalf("read: %v", err)
+	}
+}
@@ -16,21 +16,56 @@ import (
 // the system, there are several constructor variations of form Open*() to help
 // distinguish which device to open. The default constructor New() will attempt
 // to parse command line flags to select a specific device.
-// The only interface that is initialized by default is GPIO. You must call an
-// initialization method of one of the other interfaces before using it.
+// The only interfaces initialized by default are GPIO and DGPIO. You must call
+// an initialization method of one of the other interfaces before using it.
 type FT232H struct {
-	info *deviceInfo
-	mode Mode
-	flag *Flag
-	I2C  *I2C
-	SPI  *SPI
-	GPIO *GPIO
+	info  *deviceInfo
+	mode  Mode
+	flag  *Flag
+	I2C   *I2C
+	SPI   *SPI
+	UART  *UART
+	JTAG  *JTAG
+	GPIO  *GPIO
+	DGPIO *DGPIO
+}
+
+// Mode represents the legacy protocol the MPSSE engine is configured for, or
+// the asynchronous serial (UART) mode with the MPSSE disabled.
+type Mode int
+
+// Constants defining the legacy protocols supported by MPSSE, and UART mode.
+const (
+	ModeNone Mode = 0
+	ModeSPI  Mode = 1
+	ModeI2C  Mode = 2
+	ModeUART Mode = 3
+	ModeJTAG Mode = 4
+)
+
+// String returns a string describing the legacy protocol supported by MPSSE.
+// Returns the string "unknown" if the mode is invalid.
+func (m Mode) String() string {
+	switch m {
+	case ModeNone:
+		return "(none)"
+	case ModeSPI:
+		return "SPI"
+	case ModeI2C:
+		return "I²C"
+	case ModeUART:
+		return "UART"
+	case ModeJTAG:
+		return "JTAG"
+	default:
+		return "(invalid mode)"
+	}
 }
 
 // String constructs a string representation of an FT232H device.
 func (m *FT232H) String() string {
-	return fmt.Sprintf("{ Index: %s, Mode: %q, Flag: %+v, I2C: %s, SPI: %+v, GPIO: %s }",
-		m.info, m.mode, m.flag, m.I2C, m.SPI, m.GPIO)
+	return fmt.Sprintf("{ Index: %s, Mode: %q, Flag: %+v, I2C: %s, SPI: %+v, UART: %s, JTAG: %s, GPIO: %s, DGPIO: %s }",
+		m.info, m.mode, m.flag, m.I2C, m.SPI, m.UART, m.JTAG, m.GPIO, m.DGPIO)
 }
 
 func (m *FT232H) Index() int {
@@ -84,12 +119,15 @@ func (m *FT232H) IsHiSpeed() bool {
 
 // Mask contains strings for each of the supported attributes used to
 // distinguish which FTDI device to open. See OpenMask for semantics.
+// The Transport used to enumerate and open devices may also be specified; if
+// nil, DefaultTransport is used.
 type Mask struct {
-	Index  string
-	VID    string
-	PID    string
-	Serial string
-	Desc   string
+	Index     string
+	VID       string
+	PID       string
+	Serial    string
+	Desc      string
+	Transport Transport
 }
 
 // Flag contains the attributes used to distinguish which FT232H device to
@@ -184,17 +222,26 @@ func OpenFlag(arg []string, fatal bool) (*FT232H, error) {
 // integer attributes can be expressed in any base recognized by the Go grammar
 // for numeric literals (e.g., "13", "0b1101", "0xD", and "D" are all valid and
 // equivalent).
+//
+// Devices are enumerated and opened using the Transport given in mask, or
+// DefaultTransport if mask is nil or its Transport is nil.
 func OpenMask(mask *Mask) (*FT232H, error) {
-	m := &FT232H{info: nil, mode: ModeNone, flag: nil, I2C: nil, SPI: nil, GPIO: nil}
+	m := &FT232H{info: nil, mode: ModeNone, flag: nil, I2C: nil, SPI: nil, UART: nil, JTAG: nil, GPIO: nil, DGPIO: nil}
 	if err := m.openDevice(mask); nil != err {
 		return nil, err
 	}
 	m.I2C = &I2C{device: m, config: i2cConfigDefault()}
 	m.SPI = &SPI{device: m, config: spiConfigDefault()}
+	m.UART = &UART{device: m, config: UARTConfigDefault()}
+	m.JTAG = &JTAG{device: m, config: JTAGConfigDefault(), state: JTAGTestLogicReset}
 	m.GPIO = &GPIO{device: m, config: GPIOConfigDefault()}
+	m.DGPIO = &DGPIO{device: m, config: DGPIOConfigDefault()}
 	if err := m.GPIO.Init(); nil != err {
 		return nil, err
 	}
+	if err := m.DGPIO.Init(); nil != err {
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -332,7 +379,20 @@ func (ft *FT232H) openDevice(mask *Mask) error {
 		return false
 	}
 
-	if dev, err = devices(); nil != err {
+	tpt, err := mask.transport()
+	if nil != err {
+		return err
+	}
+
+	// enumerate devices with the given USB IDs, if the transport only enumerates
+	// devices with particular USB IDs by default.
+	if t, ok := tpt.(TransportID); ok && nil != mask && ("" != mask.VID || "" != mask.PID) {
+		vid, _ := parseUint32(mask.VID)
+		pid, _ := parseUint32(mask.PID)
+		tpt = t.WithID(vid, pid)
+	}
+
+	if dev, err = devices(tpt); nil != err {
 		return err
 	}
 
@@ -392,7 +452,8 @@ func (m *FT232H) Close() error {
 }
 
 // deviceInfo contains the USB device descriptor and attributes for a device
-// managed by the D2XX driver.
+// enumerated by a Transport, along with the Port and MPSSE engine used to
+// communicate with the device once opened.
 type deviceInfo struct {
 	index     int
 	isOpen    bool
@@ -403,64 +464,87 @@ type deviceInfo struct {
 	locID     uint32
 	serial    string
 	desc      string
-	handle    Handle
+	transport Transport
+	port      Port
+	engine    *engine
 }
 
 // String constructs a readable string representation of the deviceInfo.
 func (dev *deviceInfo) String() string {
 	return fmt.Sprintf("%d:{ Open = %t, HiSpeed = %t, Chip = %q (0x%02X), "+
 		"VID = 0x%04X, PID = 0x%04X, Location = %04X, "+
-		"Serial = %q, Desc = %q, Handle = %p }",
+		"Serial = %q, Desc = %q, Port = %p }",
 		dev.index+1, dev.isOpen, dev.isHiSpeed, dev.chip, uint32(dev.chip),
-		dev.vid, dev.pid, dev.locID, dev.serial, dev.desc, dev.handle)
+		dev.vid, dev.pid, dev.locID, dev.serial, dev.desc, dev.port)
 }
 
-// open attempts to open a raw USB interface through the D2XX bridge, returning
-// a non-nil error if unsuccessful.
+// open attempts to open a raw USB interface through the device's Transport and
+// enable the MPSSE, returning a non-nil error if unsuccessful. The MPSSE is not
+// enabled if the Port implements PortChannel, which enables it on initializing
+// the SPI or I²C interface.
 func (dev *deviceInfo) open() error {
 	if ce := dev.close(); nil != ce {
 		return ce
 	}
-	if oe := _FT_Open(dev); nil != oe {
+	port, oe := dev.transport.Open(dev.index)
+	if nil != oe {
 		return oe
 	}
+	eng := newEngine(port)
+	if nil == eng.channel {
+		if ie := eng.init(engineLatencyDefault); nil != ie {
+			port.Close()
+			return ie
+		}
+	}
+	dev.port = port
+	dev.engine = eng
 	dev.isOpen = true
 	return nil
 }
 
-// close attempts to close a USB interface opened through the D2XX bridge,
-// Returns a non-nil error if unsuccessful.
+// close attempts to close a USB interface opened through the device's
+// Transport, Returns a non-nil error if unsuccessful.
 func (dev *deviceInfo) close() error {
-	if !dev.isOpen {
+	if !dev.isOpen || nil == dev.port {
 		return nil
 	}
-	if ce := _FT_Close(dev); nil != ce {
+	if ce := dev.port.Close(); nil != ce {
 		return ce
 	}
 	dev.isOpen = false
 	return nil
 }
 
-// devices queries all of the USB devices on the system using the D2XX bridge
-// and returns a slice of deviceInfo pointers for all MPSSE-capable devices.
+// devices queries all of the USB devices on the system using the given
+// Transport and returns a slice of deviceInfo pointers for all MPSSE-capable
+// devices.
 // Returns a nil slice and non-nil error if the driver failed to obtain device
 // information from the system.
 // Returns an empty slice and nil error if no MPSSE-capable devices were found
 // after successful communication with the system.
-func devices() ([]*deviceInfo, error) {
+func devices(tpt Transport) ([]*deviceInfo, error) {
 
-	n, ce := _FT_CreateDeviceInfoList()
-	if nil != ce {
-		return nil, ce
-	}
-
-	if 0 == n {
-		return []*deviceInfo{}, nil
+	list, err := tpt.Devices()
+	if nil != err {
+		return nil, err
 	}
 
-	info, de := _FT_GetDeviceInfoList(n)
-	if nil != de {
-		return nil, de
+	info := make([]*deviceInfo, len(list))
+	for i, d := range list {
+		info[i] = &deviceInfo{
+			index:     d.Index,
+			isOpen:    d.Open,
+			isHiSpeed: d.HiSpeed,
+			chip:      d.Chip,
+			vid:       d.VID,
+			pid:       d.PID,
+			locID:     d.LocID,
+			serial:    d.Serial,
+			desc:      d.Desc,
+			transport: tpt,
+			port:      nil,
+		}
 	}
 
 	return info, nil
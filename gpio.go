@@ -2,6 +2,8 @@ package ft232h
 
 import (
 	"fmt"
+
+	"github.com/ardnew/ft232h/mpsse"
 )
 
 // GPIO stores interface configuration settings for the GPIO ("C" port) and
@@ -68,18 +70,23 @@ func (cfg *GPIOConfig) Set(pin CPin, dir Dir, val bool) error {
 	if !pin.Valid() {
 		return fmt.Errorf("invalid pin: %v", pin)
 	}
+	cfg.set(pin.Mask(), dir, val)
+	return nil
+}
+
+// set changes the direction and value configuration of the pins in mask.
+func (cfg *GPIOConfig) set(mask uint8, dir Dir, val bool) {
 	switch dir {
 	case Output:
-		cfg.Dir |= pin.Mask()
+		cfg.Dir |= mask
 	case Input:
-		cfg.Dir &= ^pin.Mask()
+		cfg.Dir &= ^mask
 	}
 	if val {
-		cfg.Val |= pin.Mask()
+		cfg.Val |= mask
 	} else {
-		cfg.Val &= ^pin.Mask()
+		cfg.Val &= ^mask
 	}
-	return nil
 }
 
 // Init resets all GPIO pin directions and values using the most recently read
@@ -113,7 +120,7 @@ func (gpio *GPIO) Write(val uint8) error {
 
 	dir := gpio.config.Dir
 	val &= dir // set only the pins configured as OUTPUT
-	err := _FT_WriteGPIO(gpio, dir, val)
+	err := gpio.device.info.engine.writeHigh(dir, val)
 	if nil != err {
 		return err
 	}
@@ -125,7 +132,7 @@ func (gpio *GPIO) Write(val uint8) error {
 // error if unsuccessful.
 func (gpio *GPIO) Read() (uint8, error) {
 
-	val, err := _FT_ReadGPIO(gpio)
+	val, err := gpio.device.info.engine.readHigh()
 	if nil != err {
 		return 0, err
 	}
@@ -133,6 +140,14 @@ func (gpio *GPIO) Read() (uint8, error) {
 	return val, nil
 }
 
+// output configures the given pin as output with the given val, returning the
+// command that applies the configuration of all pins.
+func (gpio *GPIO) output(pin CPin, val bool) mpsse.Command {
+	gpio.config.set(pin.Mask(), Output, val)
+	return gpio.device.info.engine.setHigh(
+		gpio.config.Val&gpio.config.Dir, gpio.config.Dir)
+}
+
 // Set sets the given pin to output with the given val.
 // See ConfigPin() for other semantics.
 func (gpio *GPIO) Set(pin CPin, val bool) error {
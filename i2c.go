@@ -1,8 +1,12 @@
 package ft232h
 
 import (
+	"errors"
 	"fmt"
 	"math/bits"
+	"time"
+
+	"github.com/ardnew/ft232h/mpsse"
 )
 
 // I2C stores interface configuration settings for an I²C master and provides
@@ -46,6 +50,26 @@ const (
 	I2CSlaveAddressMax = 0x77
 )
 
+// Constants defining legal 10-bit I²C slave addresses
+const (
+	I2CSlaveAddress10BitMin = 0x000
+	I2CSlaveAddress10BitMax = 0x3FF
+)
+
+// validSlave returns a non-nil error if the given unshifted slave address is
+// outside the legal 7-bit address range, or the 10-bit range if ten is true.
+func validSlave(slave uint, ten bool) error {
+	min, max := uint(I2CSlaveAddressMin), uint(I2CSlaveAddressMax)
+	if ten {
+		min, max = I2CSlaveAddress10BitMin, I2CSlaveAddress10BitMax
+	}
+	if !(slave >= min && slave <= max) {
+		return fmt.Errorf("invalid slave address (0x%02X-0x%02X): 0x%02X",
+			min, max, slave)
+	}
+	return nil
+}
+
 // Constants related to I²C interface initialization.
 const (
 	I2CClockMaximum   I2CClockRate = I2CClockHighSpeedMode
@@ -62,13 +86,18 @@ type i2cConfig struct {
 	breakNACK bool
 	readNACK  bool
 	noDelay   bool
+	tenBit    bool
+	stretch   bool
+	timeout   time.Duration
 }
 
 // String returns a descriptive string of an i2cConfig.
 func (c i2cConfig) String() string {
 	return fmt.Sprintf("{ Clock: %q, Latency: \"%d ms\", Options: %s, "+
-		"BreakOnNACK: %t, NACKAfterRead: %t, NoUSBDelay: %t }",
-		c.clockRate, c.latency, c.options, c.breakNACK, c.readNACK, c.noDelay)
+		"BreakOnNACK: %t, NACKAfterRead: %t, NoUSBDelay: %t, TenBitAddress: %t, "+
+		"ClockStretch: %t, StretchTimeout: %q }",
+		c.clockRate, c.latency, c.options, c.breakNACK, c.readNACK, c.noDelay,
+		c.tenBit, c.stretch, c.timeout)
 }
 
 // i2cConfigDefault returns an i2cConfig struct stored in the private
@@ -82,6 +111,9 @@ func i2cConfigDefault() *i2cConfig {
 		breakNACK: i2cBreakNACKDefault,
 		readNACK:  i2cLastNACKDefault,
 		noDelay:   i2cNoDelayDefault,
+		tenBit:    i2cTenBitDefault,
+		stretch:   i2cStretchDefault,
+		timeout:   I2CStretchTimeoutDefault,
 	}
 }
 
@@ -90,9 +122,12 @@ func i2cConfigDefault() *i2cConfig {
 func (c *i2cConfig) I2CConfig() *I2CConfig {
 	return &I2CConfig{
 		I2COption: &I2COption{
-			BreakOnNACK:  c.breakNACK,
-			LastReadNACK: c.readNACK,
-			NoUSBDelay:   c.noDelay,
+			BreakOnNACK:    c.breakNACK,
+			LastReadNACK:   c.readNACK,
+			NoUSBDelay:     c.noDelay,
+			TenBitAddr:     c.tenBit,
+			ClockStretch:   c.stretch,
+			StretchTimeout: c.timeout,
 		},
 		Clock:        c.clockRate,
 		Latency:      c.latency,
@@ -135,8 +170,22 @@ type I2COption struct {
 	BreakOnNACK  bool // do not continue reading/writing stream on slave NACK
 	LastReadNACK bool // send NACK after last byte read from I²C slave
 	NoUSBDelay   bool // pack all I²C data into the fewest number of USB packets
+	TenBitAddr   bool // use 10-bit slave addresses (Read, Write, Tx, and Reg)
+
+	// ClockStretch enables adaptive clocking, which allows slaves to stretch the
+	// clock by holding SCL LOW: the MPSSE waits for the adaptive clock input RTCK
+	// (D7) to follow each edge of SCL before continuing. SCL (D0) must be
+	// connected to D7 externally, and D7 is reserved while enabled.
+	ClockStretch bool
+	// StretchTimeout is the maximum duration slaves may stretch the clock during
+	// each transfer, in addition to the transfer's nominal duration. If zero,
+	// I2CStretchTimeoutDefault is used.
+	StretchTimeout time.Duration
 }
 
+// I2CStretchTimeoutDefault is the default clock stretching timeout.
+const I2CStretchTimeoutDefault = 100 * time.Millisecond
+
 // i2cOption stores the various I²C configuration options as a 32-bit bitmap.
 type i2cOption uint32
 
@@ -178,6 +227,8 @@ const (
 	i2cBreakNACKDefault = false
 	i2cLastNACKDefault  = false
 	i2cNoDelayDefault   = true
+	i2cTenBitDefault    = false
+	i2cStretchDefault   = false
 )
 
 // Valid verifies the i2cOption receiver opt isnt equal to the sentinel value
@@ -227,6 +278,9 @@ const (
 	// is a special I²C frame that doesn't require an address
 	i2cNoAddress i2cXferOption = 0x00000040
 
+	// address the slave using a 10-bit address
+	i2cTenBitAddress i2cXferOption = 0x00000080
+
 	// default read/write options
 	i2cXferDefault i2cXferOption = 0x00000000
 
@@ -240,11 +294,37 @@ const (
 // Option changes the dynamic configuration parameters of the I²C interface.
 // It can be called while the I²C interface is open without having to first
 // close and reopen the device.
+// The configuration is left unchanged if an error is returned, such as an
+// error wrapping ErrNoMPSSE if clock stretching is requested and the Port
+// implements PortChannel.
 func (i2c *I2C) Option(opt *I2COption) error {
 
+	eng := i2c.device.info.engine
+	stretch := opt.ClockStretch != i2c.config.stretch
+
+	if opt.ClockStretch && nil != eng.channel {
+		return fmt.Errorf("clock stretching: %w", ErrNoMPSSE)
+	}
+	if stretch && ModeI2C == i2c.device.mode {
+		if _, err := eng.exec(mpsse.AdaptiveClock(opt.ClockStretch)); nil != err {
+			return err
+		}
+	}
+
 	i2c.config.breakNACK = opt.BreakOnNACK
 	i2c.config.readNACK = opt.LastReadNACK
 	i2c.config.noDelay = opt.NoUSBDelay
+	i2c.config.tenBit = opt.TenBitAddr
+
+	i2c.config.timeout = opt.StretchTimeout
+	if 0 == i2c.config.timeout {
+		i2c.config.timeout = I2CStretchTimeoutDefault
+	}
+
+	i2c.config.stretch = opt.ClockStretch
+	if stretch && ModeI2C == i2c.device.mode {
+		return i2c.device.DGPIO.Init() // protect or release RTCK
+	}
 
 	return nil
 }
@@ -303,13 +383,59 @@ func (i2c *I2C) Config(cfg *I2CConfig) error {
 // initializing the interface.
 func (i2c *I2C) Init() error {
 
-	if err := _I2C_InitChannel(i2c); nil != err {
+	eng := i2c.device.info.engine
+
+	if nil != eng.channel {
+		if i2c.config.stretch {
+			return fmt.Errorf("clock stretching: %w", ErrNoMPSSE)
+		}
+		if err := eng.channel.I2CInit(uint32(i2c.config.clockRate),
+			i2c.config.latency, uint32(i2c.config.options)); nil != err {
+			return err
+		}
+		i2c.device.mode = ModeI2C
+		return i2c.device.GPIO.Init() // reset GPIO
+	}
+
+	// 3-phase clocking holds SDA valid for an additional half-period, so the
+	// MPSSE clock must be 3/2 faster to produce the requested SCL frequency.
+	phase := i2c.config.options.clock3Phase()
+	rate := uint32(i2c.config.clockRate)
+	if phase {
+		rate = rate * 3 / 2
+	}
+
+	div, err := mpsse.Divisor(rate)
+	if nil != err {
+		return err
+	}
+
+	if err := eng.init(i2c.config.latency); nil != err {
+		return err
+	}
+
+	drive := mpsse.DriveZero{}
+	if i2c.config.options.lowDriveOnly() {
+		drive.Low = i2cSCL | i2cSDAOut
+	}
+
+	// idle with both SCL and SDA released HIGH
+	if _, err := eng.exec(
+		mpsse.AdaptiveClock(i2c.config.stretch),
+		mpsse.ThreePhase(phase),
+		mpsse.ClockDivisor(div),
+		drive,
+		i2c.pins(i2cSCL|i2cSDAOut, i2cSCL|i2cSDAOut),
+	); nil != err {
 		return err
 	}
 
 	i2c.device.mode = ModeI2C
 
-	return i2c.device.GPIO.Init() // reset GPIO
+	if err := i2c.device.GPIO.Init(); nil != err { // reset GPIO
+		return err
+	}
+	return i2c.device.DGPIO.Init() // reset unreserved port "D" pins
 }
 
 // Close closes both the I²C interface and the connection to the FT232H device.
@@ -317,21 +443,32 @@ func (i2c *I2C) Close() error {
 	return i2c.device.Close()
 }
 
+// xferDefault returns the transfer options common to all reads and writes with
+// the current configuration.
+func (i2c *I2C) xferDefault() i2cXferOption {
+	if i2c.config.tenBit {
+		return i2cXferDefault | i2cTenBitAddress
+	}
+	return i2cXferDefault
+}
+
 // Read reads the given count number of bytes from the I²C interface.
-// The given slave is the unshifted 7-bit I²C slave address to read from.
+// The given slave is the unshifted 7-bit (or 10-bit, see I2COption) I²C slave
+// address to read from.
 // There is no maximum length for the number of bytes to read.
 // If start is true, an I²C start condition is generated before transfer.
 // If stop is true, an I²C stop condition is generated after transfer.
+// If count is 0, only the slave address is written, e.g. to probe whether or
+// not the slave ACKs its address.
 // Returns the slice of bytes successfully read and a non-nil error if there was
 // an error.
 func (i2c *I2C) Read(slave uint, count uint, start bool, stop bool) ([]uint8, error) {
 
-	if !(slave >= I2CSlaveAddressMin && slave <= I2CSlaveAddressMax) {
-		return nil, fmt.Errorf("invalid slave address (0x%02X-0x%02X): 0x%02X",
-			I2CSlaveAddressMin, I2CSlaveAddressMax, slave)
+	if err := validSlave(slave, i2c.config.tenBit); nil != err {
+		return nil, err
 	}
 
-	opt := i2cXferDefault
+	opt := i2c.xferDefault()
 
 	if start {
 		opt |= i2cStartBit
@@ -345,35 +482,51 @@ func (i2c *I2C) Read(slave uint, count uint, start bool, stop bool) ([]uint8, er
 		opt |= i2cFastTransfer | i2cFastTransferBytes
 	}
 
-	// these flags are not supported when fast transfer (I2COption.NoUSBDelay)
-	// is enabled with start/stop condition generation
-	if !(i2c.config.noDelay && (start || stop)) {
-		if i2c.config.readNACK {
-			opt |= i2cLastReadNACK
-		}
-		if i2c.config.breakNACK {
-			opt |= i2cBreakOnNACK
-		}
+	// the last byte read before a stop condition is always NACKed, signaling the
+	// slave to release SDA so that the stop condition can be generated.
+	if stop || i2c.config.readNACK {
+		opt |= i2cLastReadNACK
+	}
+	if i2c.config.breakNACK {
+		opt |= i2cBreakOnNACK
 	}
 
-	return _I2C_Read(i2c, slave, count, opt)
+	return i2c.read(slave, count, opt)
 }
 
 // Write writes the given byte slice data to the I²C interface.
-// The given slave is the unshifted 7-bit I²C slave address to write to.
+// The given slave is the unshifted 7-bit (or 10-bit, see I2COption) I²C slave
+// address to write to.
 // There is no maximum length for the data slice.
 // If start is true, an I²C start condition is generated before transfer.
 // If stop is true, an I²C stop condition is generated after transfer.
-// Returns the slice of bytes successfully written and a non-nil error if there
-// was an error.
+// If data is empty, only the slave address is written, e.g. to probe whether
+// or not the slave ACKs its address.
+// Returns the number of bytes successfully written and a non-nil error if there
+// was an error (see I2CAddrNACKError, I2CDataNACKError, and I2CBusError).
 func (i2c *I2C) Write(slave uint, data []uint8, start bool, stop bool) (uint, error) {
+	return i2c.writeACK(slave, data, start, stop, nil)
+}
 
-	if !(slave >= I2CSlaveAddressMin && slave <= I2CSlaveAddressMax) {
-		return 0, fmt.Errorf("invalid slave address (0x%02X-0x%02X): 0x%02X",
-			I2CSlaveAddressMin, I2CSlaveAddressMax, slave)
+// WriteACK writes the given byte slice data to the I²C interface identical to
+// Write, but also returns whether or not the slave ACKed each byte of data.
+// Bytes not transferred are reported as not ACKed.
+func (i2c *I2C) WriteACK(slave uint, data []uint8, start bool, stop bool) (uint, []bool, error) {
+	ack := make([]bool, len(data))
+	n, err := i2c.writeACK(slave, data, start, stop, ack)
+	return n, ack, err
+}
+
+// writeACK performs the write of Write and WriteACK, storing whether or not the
+// slave ACKed each byte of data in the corresponding element of ack, if ack is
+// not nil.
+func (i2c *I2C) writeACK(slave uint, data []uint8, start bool, stop bool, ack []bool) (uint, error) {
+
+	if err := validSlave(slave, i2c.config.tenBit); nil != err {
+		return 0, err
 	}
 
-	opt := i2cXferDefault
+	opt := i2c.xferDefault()
 
 	if start {
 		opt |= i2cStartBit
@@ -387,21 +540,539 @@ func (i2c *I2C) Write(slave uint, data []uint8, start bool, stop bool) (uint, er
 		opt |= i2cFastTransfer | i2cFastTransferBytes
 	}
 
-	// this flag is not supported when fast transfer (I2COption.NoUSBDelay) is
-	// enabled with start/stop condition generation
-	if !(i2c.config.noDelay && (start || stop)) {
-		if i2c.config.breakNACK {
-			opt |= i2cBreakOnNACK
+	if i2c.config.breakNACK {
+		opt |= i2cBreakOnNACK
+	}
+
+	return i2c.write(slave, data, opt, ack)
+}
+
+// read performs an I²C read using the MPSSE engine with the given slave
+// address, number of bytes to read, and transfer options, returning a slice of
+// uint8 containing the bytes successfully read, and a non-nil error if there
+// was an error.
+// If the given count is greater than the MPSSE transfer limit (65536), multiple
+// read requests are performed with the MPSSE engine. In this case, if the I²C
+// start/stop bits are set, they are only generated on the first and last
+// transfer requests, respectively, and only the last byte of the last transfer
+// request is NACKed.
+// If the count is 0, a single request is performed with only the start
+// condition, address phase, and stop condition given in the transfer options.
+func (i2c *I2C) read(addr uint, count uint, opt i2cXferOption) ([]uint8, error) {
+
+	data := make([]uint8, count)
+
+	start := (opt & i2cStartBit) > 0
+	stop := (opt & i2cStopBit) > 0
+	nack := (opt & i2cLastReadNACK) > 0
+
+	for beg := uint(0); 0 == beg || beg < count; beg += maxTransferBytes {
+
+		end := beg + maxTransferBytes
+		if end > count {
+			end = count
+		}
+
+		if beg > 0 {
+			// TBD: don't readdress the slave (is this correct?)
+			opt |= i2cNoAddress
+			// dont send start if this isn't the first packet
+			if start {
+				opt &= ^i2cStartBit
+			}
+		}
+
+		// don't send stop if this isn't the last packet
+		if stop {
+			if end < count {
+				opt &= ^i2cStopBit
+			} else {
+				opt |= i2cStopBit
+			}
+		}
+
+		// don't NACK the last byte if this isn't the last packet
+		if nack {
+			if end < count {
+				opt &= ^i2cLastReadNACK
+			} else {
+				opt |= i2cLastReadNACK
+			}
+		}
+
+		sent, err := i2c.readChunk(addr, data[beg:end], opt)
+		if nil != err {
+			return data[:beg+sent], err
+		}
+
+	}
+
+	return data, nil
+}
+
+// write performs an I²C write using the MPSSE engine with the given slave
+// address, slice of uint8 data to send, and transfer options, returning the
+// total number of bytes successfully transferred, and a non-nil error if there
+// was an error. If ack is not nil, whether or not the slave ACKed each byte is
+// stored in the corresponding element of ack.
+// If the given data slice length is greater than the MPSSE transfer limit
+// (65536), multiple write requests are performed with the MPSSE engine. In this
+// case, if the I²C start/stop bits are set, they are only generated on the
+// first and last transfer requests, respectively.
+// If the data slice is empty, a single request is performed with only the
+// start condition, address phase, and stop condition given in the transfer
+// options, e.g. to probe whether or not a slave ACKs its address.
+func (i2c *I2C) write(addr uint, data []uint8, opt i2cXferOption, ack []bool) (uint, error) {
+
+	dataLen := uint(len(data))
+
+	start := (opt & i2cStartBit) > 0
+	stop := (opt & i2cStopBit) > 0
+
+	for beg := uint(0); 0 == beg || beg < dataLen; beg += maxTransferBytes {
+
+		end := beg + maxTransferBytes
+		if end > dataLen {
+			end = dataLen
 		}
+
+		if beg > 0 {
+			// TBD: don't readdress the slave (is this correct?)
+			opt |= i2cNoAddress
+			// dont send start if this isn't the first packet
+			if start {
+				opt &= ^i2cStartBit
+			}
+		}
+
+		// don't send stop if this isn't the last packet
+		if stop {
+			if end < dataLen {
+				opt &= ^i2cStopBit
+			} else {
+				opt |= i2cStopBit
+			}
+		}
+
+		var chunk []bool
+		if nil != ack {
+			chunk = ack[beg:end]
+		}
+
+		sent, err := i2c.writeChunk(addr, data[beg:end], opt, chunk)
+		if nil != err {
+			if e, ok := err.(*I2CDataNACKError); ok {
+				e.Index += beg
+			}
+			return beg + sent, err
+		}
+
 	}
 
-	return _I2C_Write(i2c, slave, data, opt)
+	return dataLen, nil
+}
+
+// Constants defining the port "D" pins used by the I²C interface. The SDA
+// output and input pins must be connected together externally.
+const (
+	i2cSCL    uint8 = 0x01 // D0 - SCL
+	i2cSDAOut uint8 = 0x02 // D1 - SDA (output)
+	i2cSDAIn  uint8 = 0x04 // D2 - SDA (input)
+	i2cRTCK   uint8 = 0x80 // D7 - RTCK (adaptive clock input, SCL)
+)
+
+// i2cHoldCount is the number of times the pin levels of each step in a start or
+// stop condition are repeated, which ensures the condition's setup and hold
+// times are satisfied at all supported clock rates.
+const i2cHoldCount = 10
+
+// pins returns the command setting the level val and direction dir of the I²C
+// pins on port "D". All other pins on port "D" are unchanged.
+func (i2c *I2C) pins(val uint8, dir uint8) mpsse.Command {
+	eng := i2c.device.info.engine
+	mask := i2cSCL | i2cSDAOut | i2cSDAIn
+	return eng.setLow((eng.low & ^mask)|(val&mask), (eng.lowDir & ^mask)|(dir&mask))
+}
+
+// hold returns the command setting the I²C pins to the given level val (both
+// pins driven as outputs) repeated i2cHoldCount times.
+func (i2c *I2C) hold(val uint8) []mpsse.Command {
+	cmd := make([]mpsse.Command, i2cHoldCount)
+	for i := range cmd {
+		cmd[i] = i2c.pins(val, i2cSCL|i2cSDAOut)
+	}
+	return cmd
+}
+
+// start returns the commands generating an I²C start condition, which may also
+// be used as a repeated start condition. SCL is left LOW.
+func (i2c *I2C) start() []mpsse.Command {
+	cmd := i2c.hold(i2cSCL | i2cSDAOut)               // SDA HIGH, SCL HIGH
+	cmd = append(cmd, i2c.hold(i2cSCL)...)            // SDA LOW, SCL HIGH
+	return append(cmd, i2c.pins(0, i2cSCL|i2cSDAOut)) // SDA LOW, SCL LOW
+}
+
+// stop returns the commands generating an I²C stop condition. Both SCL and SDA
+// are left released HIGH.
+func (i2c *I2C) stop() []mpsse.Command {
+	cmd := i2c.hold(0)                                // SDA LOW, SCL LOW
+	cmd = append(cmd, i2c.hold(i2cSCL)...)            // SDA LOW, SCL HIGH
+	cmd = append(cmd, i2c.hold(i2cSCL|i2cSDAOut)...)  // SDA HIGH, SCL HIGH
+	return append(cmd, i2c.pins(i2cSCL|i2cSDAOut, 0)) // release both
+}
+
+// i2cWriteResp is the number of bytes in the response to writeByte.
+const i2cWriteResp = 2
+
+// writeByte returns the commands clocking out the given byte b on SDA followed
+// by clocking in the slave's ACK bit. The response to the commands is two
+// bytes: the level of SDA sampled while clocking out each bit of b, used to
+// detect lost arbitration, followed by a byte with bit 0 clear if the slave
+// ACKed the byte (see written).
+func (i2c *I2C) writeByte(b uint8) []mpsse.Command {
+	return []mpsse.Command{
+		i2c.pins(0, i2cSCL|i2cSDAOut), // SCL LOW, drive SDA
+		mpsse.ClockData{Out: true, In: true, OutEdge: mpsse.Falling, InEdge: mpsse.Rising, Len: 1, Data: []uint8{b}},
+		i2c.pins(0, i2cSCL), // SCL LOW, release SDA
+		mpsse.ClockData{In: true, Bits: true, InEdge: mpsse.Rising, Len: 1},
+	}
+}
+
+// readByte returns the commands clocking in a byte on SDA followed by clocking
+// out an ACK bit if ack is true, otherwise a NACK bit. The response to the
+// commands is the byte read.
+func (i2c *I2C) readByte(ack bool) []mpsse.Command {
+	bit := uint8(0xFF)
+	if ack {
+		bit = 0x00
+	}
+	return []mpsse.Command{
+		i2c.pins(0, i2cSCL), // SCL LOW, release SDA
+		mpsse.ClockData{In: true, InEdge: mpsse.Rising, Len: 1},
+		i2c.pins(0, i2cSCL|i2cSDAOut), // SCL LOW, drive SDA
+		mpsse.ClockData{Out: true, Bits: true, OutEdge: mpsse.Falling, Len: 1, Data: []uint8{bit}},
+		i2c.pins(i2cSDAOut, i2cSCL), // SCL LOW, release SDA
+	}
+}
+
+// acked returns true if the given ACK bit response indicates the slave ACKed.
+func acked(resp uint8) bool { return 0 == (resp & 0x01) }
+
+// written verifies the given response to writeByte for the given byte b sent to
+// the given slave address, returning true if the slave ACKed the byte, and a
+// non-nil I2CBusError if arbitration was lost while clocking out b, i.e. SDA was
+// read LOW while the master released it HIGH.
+func written(addr uint, b uint8, resp []uint8) (bool, error) {
+	if 0 != (b & ^resp[0]) {
+		return false, &I2CBusError{Addr: addr, Cause: I2CArbitrationLost}
+	}
+	return acked(resp[1]), nil
+}
+
+// i2cCheck describes the response to the commands generated by address, used
+// to verify the bus was idle, arbitration was not lost, and the slave ACKed its
+// address.
+type i2cCheck struct {
+	addr uint    // unshifted slave address
+	idle bool    // response begins with the levels of the I²C pins
+	sent []uint8 // address bytes sent, each with response from writeByte
+}
+
+// len returns the number of bytes in the response described by the receiver.
+func (c i2cCheck) len() int {
+	n := i2cWriteResp * len(c.sent)
+	if c.idle {
+		n++
+	}
+	return n
+}
+
+// verify verifies the given response described by the receiver, returning a
+// non-nil I2CBusError if the bus was not idle or arbitration was lost, or a
+// non-nil I2CAddrNACKError if the slave did not ACK its address.
+func (c i2cCheck) verify(resp []uint8) error {
+	if c.idle {
+		scl, sda := (resp[0]&i2cSCL) > 0, (resp[0]&i2cSDAIn) > 0
+		if !scl || !sda {
+			return &I2CBusError{Addr: c.addr, Cause: I2CBusStuck, SCL: scl, SDA: sda}
+		}
+		resp = resp[1:]
+	}
+	for i, b := range c.sent {
+		ack, err := written(c.addr, b, resp[i*i2cWriteResp:])
+		if nil != err {
+			return err
+		}
+		if !ack {
+			return &I2CAddrNACKError{Addr: c.addr}
+		}
+	}
+	return nil
+}
+
+// address returns the commands generating the start condition and address phase
+// of an I²C transfer with the given slave address, read/write direction, and
+// transfer options, and a description of the response to the commands used to
+// verify the address phase (see i2cCheck).
+// If a start condition is generated while both SCL and SDA are HIGH, i.e. the
+// bus should be idle, the levels of SCL and SDA are first read to verify the
+// bus is not stuck.
+// A 10-bit address is sent as the header 11110 A9 A8 W followed by A7-A0, and
+// for reads, a repeated start condition and the header again with R.
+func (i2c *I2C) address(addr uint, read bool, opt i2cXferOption) ([]mpsse.Command, i2cCheck) {
+	cmd := []mpsse.Command{}
+	chk := i2cCheck{addr: addr}
+	if (opt & i2cStartBit) > 0 {
+		eng := i2c.device.info.engine
+		if idle := i2cSCL | i2cSDAOut; idle == (eng.low & idle) {
+			cmd = append(cmd, mpsse.GetLow{})
+			chk.idle = true
+		}
+		cmd = append(cmd, i2c.start()...)
+	}
+	if (opt & i2cNoAddress) > 0 {
+		return cmd, chk
+	}
+	rw := uint8(0)
+	if read {
+		rw = 1
+	}
+	if 0 == (opt & i2cTenBitAddress) {
+		chk.sent = []uint8{uint8(addr<<1) | rw}
+	} else {
+		hdr := uint8(0xF0 | ((addr >> 7) & 0x06))
+		chk.sent = []uint8{hdr, uint8(addr)}
+		if read {
+			chk.sent = append(chk.sent, hdr|rw)
+		}
+	}
+	for i, b := range chk.sent {
+		if 2 == i {
+			cmd = append(cmd, i2c.start()...)
+		}
+		cmd = append(cmd, i2c.writeByte(b)...)
+	}
+	return cmd, chk
+}
+
+// readChunk performs a single I²C read of at most 65536 bytes into the given
+// data slice using the MPSSE engine with the given slave address and
+// transfer options, returning the number of bytes successfully read, and a
+// non-nil error if there was an error.
+// Returns I2CAddrNACKError if the slave did not ACK its address, or
+// I2CBusError if the bus is stuck, arbitration was lost, or clock stretching
+// timed out.
+func (i2c *I2C) readChunk(addr uint, data []uint8, opt i2cXferOption) (uint, error) {
+
+	if ch := i2c.device.info.engine.channel; nil != ch {
+		if (opt & i2cTenBitAddress) > 0 {
+			return 0, fmt.Errorf("10-bit slave address: %w", ErrNoMPSSE)
+		}
+		n, err := ch.I2CRead(addr, data, uint32(opt))
+		return n, i2cChannelError(addr, n, err)
+	}
+
+	stop := (opt & i2cStopBit) > 0
+
+	cmd, chk := i2c.address(addr, true, opt)
+	if chk.len() > 0 && 0 == (opt&i2cFastTransfer) {
+		// verify the slave ACKs its address before reading any data
+		resp, err := i2c.exec(addr, cmd...)
+		if nil != err {
+			return 0, err
+		}
+		if err := chk.verify(resp); nil != err {
+			return 0, i2c.nack(stop, err)
+		}
+		cmd, chk = []mpsse.Command{}, i2cCheck{addr: addr}
+	}
+
+	for i := range data {
+		last := i == len(data)-1
+		cmd = append(cmd, i2c.readByte(!(last && (opt&i2cLastReadNACK) > 0))...)
+	}
+	if stop {
+		cmd = append(cmd, i2c.stop()...)
+	}
+
+	resp, err := i2c.exec(addr, cmd...)
+	if nil != err {
+		return 0, err
+	}
+	if err := chk.verify(resp); nil != err {
+		return 0, err
+	}
+	return uint(copy(data, resp[chk.len():])), nil
+}
+
+// writeChunk performs a single I²C write of at most 65536 bytes from the given
+// data slice using the MPSSE engine with the given slave address and transfer
+// options, returning the number of bytes successfully written, and a non-nil
+// error if there was an error. If ack is not nil, whether or not the slave
+// ACKed each byte is stored in the corresponding element of ack.
+// Returns I2CAddrNACKError if the slave did not ACK its address,
+// I2CDataNACKError if the slave did not ACK a data byte and the transfer
+// options request breaking on NACK, or I2CBusError if the bus is stuck,
+// arbitration was lost, or clock stretching timed out.
+// Unless fast transfer is requested, each byte is transferred and its ACK
+// checked before the next byte is transferred.
+func (i2c *I2C) writeChunk(addr uint, data []uint8, opt i2cXferOption, ack []bool) (uint, error) {
+
+	if ch := i2c.device.info.engine.channel; nil != ch {
+		if (opt & i2cTenBitAddress) > 0 {
+			return 0, fmt.Errorf("10-bit slave address: %w", ErrNoMPSSE)
+		}
+		n, err := ch.I2CWrite(addr, data, uint32(opt))
+		for i := uint(0); i < n && nil != ack; i++ {
+			ack[i] = true
+		}
+		return n, i2cChannelError(addr, n, err)
+	}
+
+	eng := i2c.device.info.engine
+	stop := (opt & i2cStopBit) > 0
+	brk := (opt & i2cBreakOnNACK) > 0
+
+	// check verifies the response to writeByte for data byte i.
+	check := func(i int, resp []uint8) error {
+		ok, err := written(addr, data[i], resp)
+		if nil != ack {
+			ack[i] = ok
+		}
+		if nil != err {
+			return err
+		}
+		if brk && !ok {
+			return &I2CDataNACKError{Addr: addr, Index: uint(i)}
+		}
+		return nil
+	}
+
+	cmd, chk := i2c.address(addr, false, opt)
+
+	if (opt & i2cFastTransfer) > 0 {
+		for _, b := range data {
+			cmd = append(cmd, i2c.writeByte(b)...)
+		}
+		if stop {
+			cmd = append(cmd, i2c.stop()...)
+		}
+		resp, err := i2c.exec(addr, cmd...)
+		if nil != err {
+			return 0, err
+		}
+		if err := chk.verify(resp); nil != err {
+			return 0, err
+		}
+		resp = resp[chk.len():]
+		for i := range data {
+			if err := check(i, resp[i*i2cWriteResp:]); nil != err {
+				return uint(i), err
+			}
+		}
+		return uint(len(data)), nil
+	}
+
+	if len(cmd) > 0 {
+		resp, err := i2c.exec(addr, cmd...)
+		if nil != err {
+			return 0, err
+		}
+		if err := chk.verify(resp); nil != err {
+			return 0, i2c.nack(stop, err)
+		}
+	}
+
+	for i, b := range data {
+		resp, err := i2c.exec(addr, i2c.writeByte(b)...)
+		if nil != err {
+			return uint(i), err
+		}
+		if err := check(i, resp); nil != err {
+			return uint(i), i2c.nack(stop, err)
+		}
+	}
+
+	if stop {
+		if _, err := eng.exec(i2c.stop()...); nil != err {
+			return uint(len(data)), err
+		}
+	}
+	return uint(len(data)), nil
+}
+
+// i2cChannelError returns the error corresponding to the given error err
+// returned by a PortChannel after transferring n data bytes with the given slave
+// address. The Status returned by libMPSSE when the slave does not ACK its
+// address or a data byte is replaced with I2CAddrNACKError or I2CDataNACKError,
+// respectively.
+func i2cChannelError(addr uint, n uint, err error) error {
+	switch {
+	case errors.Is(err, SDeviceNotFound):
+		return &I2CAddrNACKError{Addr: addr}
+	case errors.Is(err, SFailedToWriteDevice):
+		return &I2CDataNACKError{Addr: addr, Index: n}
+	default:
+		return err
+	}
+}
+
+// exec executes the given commands using the MPSSE engine, returning the
+// response and a non-nil error if unsuccessful.
+// If clock stretching is enabled, the device read timeout is first set to the
+// nominal duration of the commands plus the stretch timeout. If the response is
+// not received before the timeout, the slave with the given address is assumed
+// to be holding SCL LOW, and the MPSSE, which is still waiting on RTCK, is
+// recovered by reinitializing the interface before returning an I2CBusError.
+func (i2c *I2C) exec(addr uint, cmd ...mpsse.Command) ([]uint8, error) {
+
+	eng := i2c.device.info.engine
+	if !i2c.config.stretch {
+		return eng.exec(cmd...)
+	}
+
+	var bits time.Duration
+	for _, c := range cmd {
+		if d, ok := c.(mpsse.ClockData); ok {
+			if d.Bits {
+				bits += time.Duration(d.Len)
+			} else {
+				bits += time.Duration(d.Len) * 8
+			}
+		}
+	}
+	nominal := bits * time.Second / time.Duration(i2c.config.clockRate)
+
+	if err := eng.timeout(nominal + i2c.config.timeout); nil != err {
+		return nil, err
+	}
+	defer eng.timeout(0)
+
+	resp, err := eng.exec(cmd...)
+	if errors.Is(err, ErrReadTimeout) {
+		if err := i2c.Init(); nil != err {
+			return nil, err
+		}
+		return nil, &I2CBusError{Addr: addr, Cause: I2CStretchTimeout}
+	}
+	return resp, err
+}
+
+// nack generates a stop condition if stop is true, and then returns the given
+// error err, or the error generating the stop condition if unsuccessful.
+func (i2c *I2C) nack(stop bool, err error) error {
+	if stop {
+		if _, se := i2c.device.info.engine.exec(i2c.stop()...); nil != se {
+			return se
+		}
+	}
+	return err
 }
 
 // I2CReg represents a read-write register of an I²C slave device.
 type I2CReg struct {
 	i2c   *I2C      // the I²C interface to use
-	slave uint      // unshifted 7-bit I²C slave address
+	slave uint      // unshifted 7-bit (or 10-bit) I²C slave address
 	addr  uint      // register sub-address to read/write
 	space AddrSpace // sub-address space used to format register in data payload
 	order ByteOrder // byte order used to format register+data in data payload
@@ -410,6 +1081,9 @@ type I2CReg struct {
 // I2CRegReader represents a method for reading I²C slave device registers.
 type I2CRegReader func(rewrite bool) (uint64, error)
 
+// I2CRegWriter represents a method for writing I²C slave device registers.
+type I2CRegWriter func(value uint64) error
+
 // validate checks that fields of an I2CReg pass basic sanity requirements.
 // Returns the byte-ordered register sub-address to send in read/write payloads.
 func (reg *I2CReg) validate() ([]uint8, error) {
@@ -418,9 +1092,8 @@ func (reg *I2CReg) validate() ([]uint8, error) {
 		return nil, fmt.Errorf("invalid receiver (nil)")
 	}
 
-	if !(reg.slave >= I2CSlaveAddressMin && reg.slave <= I2CSlaveAddressMax) {
-		return nil, fmt.Errorf("invalid I²C slave address (0x%02X-0x%02X): 0x%02X",
-			I2CSlaveAddressMin, I2CSlaveAddressMax, reg.slave)
+	if err := validSlave(reg.slave, reg.i2c.config.tenBit); nil != err {
+		return nil, err
 	}
 
 	b := reg.space.Bytes()
@@ -477,17 +1150,127 @@ func (reg *I2CReg) Reader(size uint) (I2CRegReader, error) {
 
 	return func(rewrite bool) (uint64, error) {
 
+		var dat []uint8
+		var err error
 		if rewrite {
-			if _, err := reg.i2c.Write(reg.slave, addr, true, false); nil != err {
-				return 0, err
-			}
+			// reposition and read in a single combined transaction
+			dat, err = reg.i2c.Tx(reg.slave, addr, size)
+		} else {
+			dat, err = reg.i2c.Read(reg.slave, size, true, true)
 		}
-
-		if dat, err := reg.i2c.Read(reg.slave, size, true, true); nil != err {
+		if nil != err {
 			return 0, err
-		} else {
-			return reg.order.Uint(size, dat), nil
 		}
+		return reg.order.Uint(size, dat), nil
 
 	}, nil
 }
+
+// Writer returns a closure that can be used to repeatedly write to a register.
+// The size argument defines the number of bytes to write, i.e. the size of the
+// data written to the register, often 2 (16-bit); it is also used along with
+// the I2CReg byte order to format the value given to the closure.
+//
+// Each call to the returned closure writes the register sub-address followed
+// by the given value, enclosed by start and stop conditions.
+func (reg *I2CReg) Writer(size uint) (I2CRegWriter, error) {
+
+	addr, err := reg.validate()
+	if nil != err {
+		return nil, err
+	}
+
+	if size > 8 {
+		return nil, fmt.Errorf("invalid register size: %d (1-8)", size)
+	}
+
+	return func(value uint64) error {
+
+		data := append(append([]uint8{}, addr...), reg.order.Bytes(size, value)...)
+		_, err := reg.i2c.Write(reg.slave, data, true, true)
+		return err
+
+	}, nil
+}
+
+// Read reads a register of the given size (in bytes, see Reader) in a single
+// combined transaction, returning its value formatted with the I2CReg byte
+// order.
+func (reg *I2CReg) Read(size uint) (uint64, error) {
+
+	addr, err := reg.validate()
+	if nil != err {
+		return 0, err
+	}
+
+	dat, err := reg.i2c.Tx(reg.slave, addr, size)
+	if nil != err {
+		return 0, err
+	}
+
+	return reg.order.Uint(size, dat), nil
+}
+
+// Write writes the given value to a register of the given size (in bytes, see
+// Writer), formatted with the I2CReg byte order.
+func (reg *I2CReg) Write(size uint, value uint64) error {
+	wr, err := reg.Writer(size)
+	if nil != err {
+		return err
+	}
+	return wr(value)
+}
+
+// Update performs a read-modify-write of a register of the given size (in
+// bytes, see Reader): the bits set in mask are replaced with the corresponding
+// bits of value, and all other bits are unchanged.
+// The register is read in a single combined transaction, and then written.
+func (reg *I2CReg) Update(size uint, mask uint64, value uint64) error {
+
+	addr, err := reg.validate()
+	if nil != err {
+		return err
+	}
+
+	if size > 8 {
+		return fmt.Errorf("invalid register size: %d (1-8)", size)
+	}
+
+	dat, err := reg.i2c.Tx(reg.slave, addr, size)
+	if nil != err {
+		return err
+	}
+
+	curr := reg.order.Uint(size, dat)
+	data := append(append([]uint8{}, addr...),
+		reg.order.Bytes(size, (curr & ^mask)|(value&mask))...)
+	_, err = reg.i2c.Write(reg.slave, data, true, true)
+	return err
+}
+
+// Field reads a register of the given size (in bytes, see Reader), returning
+// the value of the given bitfield.
+func (reg *I2CReg) Field(size uint, field RegField) (uint64, error) {
+
+	v, err := reg.Read(size)
+	if nil != err {
+		return 0, err
+	}
+
+	return field.Get(v), nil
+}
+
+// SetField performs a read-modify-write of a register of the given size (in
+// bytes, see Reader), setting the given bitfield to value, and returns a
+// non-nil error if value does not fit in the bitfield.
+func (reg *I2CReg) SetField(size uint, field RegField, value uint64) error {
+	if value > field.Max() {
+		return fmt.Errorf("value exceeds bitfield %s: %d (max %d)",
+			field, value, field.Max())
+	}
+	return reg.Update(size, field.Mask(), value<<field.Shift)
+}
+
+// I2CRegField is the former name of RegField, which describes the bitfields of
+// both I²C and SPI slave device registers.
+type I2CRegField = RegField
@@ -0,0 +1,97 @@
+package ft232h
+
+import (
+	"fmt"
+)
+
+// I2CAddrNACKError is the error returned when an I²C slave does not ACK its
+// address. It wraps SDeviceNotFound, so that errors.Is(err, SDeviceNotFound)
+// reports true.
+type I2CAddrNACKError struct {
+	Addr uint // unshifted slave address
+}
+
+// Error implements the error interface.
+func (e *I2CAddrNACKError) Error() string {
+	return fmt.Sprintf("I²C slave 0x%02X: address NACK", e.Addr)
+}
+
+// Unwrap returns the Status wrapped by the error.
+func (e *I2CAddrNACKError) Unwrap() error { return SDeviceNotFound }
+
+// I2CDataNACKError is the error returned when an I²C slave does not ACK a data
+// byte written to it. It wraps SFailedToWriteDevice, so that
+// errors.Is(err, SFailedToWriteDevice) reports true.
+type I2CDataNACKError struct {
+	Addr  uint // unshifted slave address
+	Index uint // index of the data byte NACKed
+}
+
+// Error implements the error interface.
+func (e *I2CDataNACKError) Error() string {
+	return fmt.Sprintf("I²C slave 0x%02X: data NACK at byte %d", e.Addr, e.Index)
+}
+
+// Unwrap returns the Status wrapped by the error.
+func (e *I2CDataNACKError) Unwrap() error { return SFailedToWriteDevice }
+
+// I2CBusCause identifies the bus condition reported by an I2CBusError.
+type I2CBusCause int
+
+// Constants defining the bus conditions reported by an I2CBusError.
+const (
+	// SDA read LOW while the master released it HIGH to transmit a 1 bit, i.e.
+	// another master (or a misbehaving slave) is driving the bus.
+	I2CArbitrationLost I2CBusCause = iota
+	// SCL or SDA read LOW while the bus should have been idle, i.e. a slave is
+	// holding the bus LOW, or a pullup is missing.
+	I2CBusStuck
+	// A slave held SCL LOW longer than the clock stretching timeout (see
+	// I2COption).
+	I2CStretchTimeout
+)
+
+// String returns a descriptive string of an I2CBusCause.
+func (c I2CBusCause) String() string {
+	switch c {
+	case I2CArbitrationLost:
+		return "arbitration lost"
+	case I2CBusStuck:
+		return "bus stuck"
+	case I2CStretchTimeout:
+		return "clock stretching timeout"
+	default:
+		return fmt.Sprintf("unknown bus condition (%d)", int(c))
+	}
+}
+
+// I2CBusError is the error returned when an I²C transfer could not be carried
+// out due to the state of the bus. It wraps SIOError, so that
+// errors.Is(err, SIOError) reports true.
+type I2CBusError struct {
+	Addr  uint        // unshifted slave address
+	Cause I2CBusCause // bus condition detected
+	SCL   bool        // level of SCL when the bus was found stuck
+	SDA   bool        // level of SDA when the bus was found stuck
+}
+
+// Error implements the error interface.
+func (e *I2CBusError) Error() string {
+	if I2CBusStuck == e.Cause {
+		return fmt.Sprintf("I²C slave 0x%02X: %s (SCL=%s, SDA=%s)",
+			e.Addr, e.Cause, level(e.SCL), level(e.SDA))
+	}
+	return fmt.Sprintf("I²C slave 0x%02X: %s", e.Addr, e.Cause)
+}
+
+// Unwrap returns the Status wrapped by the error.
+func (e *I2CBusError) Unwrap() error { return SIOError }
+
+// level returns the string "HIGH" if the given pin level is true, otherwise
+// "LOW".
+func level(high bool) string {
+	if high {
+		return "HIGH"
+	}
+	return "LOW"
+}
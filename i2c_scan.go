@@ -0,0 +1,232 @@
+package ft232h
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// I2CProbe identifies the method used to probe an I²C slave address for the
+// presence of a device (see I2C.Scan).
+type I2CProbe int
+
+// Constants defining the I²C slave address probe methods, equivalent to those
+// of the Linux i2cdetect tool.
+const (
+	// Do not probe the address.
+	I2CProbeSkip I2CProbe = iota
+	// SMBus quick command: address with the W bit, followed by a stop condition.
+	// Fastest, but known to corrupt the contents of some EEPROMs and lock up
+	// some write-only devices.
+	I2CProbeQuick
+	// SMBus receive byte: address with the R bit and read a single byte. Safe
+	// for EEPROMs, but may lock up the bus of some write-only devices.
+	I2CProbeRead
+	// Address with the W bit and write a single zero byte, which selects the
+	// first register of most register-based devices.
+	I2CProbeWrite
+)
+
+// String returns a descriptive string of an I2CProbe.
+func (p I2CProbe) String() string {
+	switch p {
+	case I2CProbeSkip:
+		return "Skip"
+	case I2CProbeQuick:
+		return "Quick"
+	case I2CProbeRead:
+		return "Read"
+	case I2CProbeWrite:
+		return "Write"
+	default:
+		return fmt.Sprintf("Unknown probe (%d)", int(p))
+	}
+}
+
+// resp returns the number of bytes in the response to the commands generating
+// the receiver probe following the address phase.
+func (p I2CProbe) resp() int {
+	switch p {
+	case I2CProbeRead:
+		return 1 // data byte
+	case I2CProbeWrite:
+		return i2cWriteResp
+	}
+	return 0
+}
+
+// valid returns true if the receiver is a recognized probe method.
+func (p I2CProbe) valid() bool {
+	return p >= I2CProbeSkip && p <= I2CProbeWrite
+}
+
+// I2CScanRange assigns the probe method used for each 7-bit slave address in
+// the inclusive range First to Last.
+type I2CScanRange struct {
+	First uint
+	Last  uint
+	Probe I2CProbe
+}
+
+// I2CScanDefault returns the scan ranges used by the Linux i2cdetect tool in
+// its default (auto) mode: all non-reserved 7-bit addresses are probed with the
+// quick command, except for the ranges commonly used by EEPROMs, which are
+// probed by reading a byte.
+func I2CScanDefault() []I2CScanRange {
+	return []I2CScanRange{
+		{First: I2CSlaveAddressMin, Last: I2CSlaveAddressMax, Probe: I2CProbeQuick},
+		{First: 0x30, Last: 0x37, Probe: I2CProbeRead},
+		{First: 0x50, Last: 0x5F, Probe: I2CProbeRead},
+	}
+}
+
+// I2CScan contains the results of an I²C bus scan, indexed by 7-bit slave
+// address.
+type I2CScan struct {
+	Probe [0x80]I2CProbe // probe method used, I2CProbeSkip if not probed
+	ACK   [0x80]bool     // slave ACKed its address
+}
+
+// Found returns the slave addresses that ACKed, in ascending order.
+func (s *I2CScan) Found() []uint {
+	found := []uint{}
+	for a, ack := range s.ACK {
+		if ack {
+			found = append(found, uint(a))
+		}
+	}
+	return found
+}
+
+// String returns the scan results formatted as the 16x8 grid printed by the
+// Linux i2cdetect tool: each slave address that ACKed is printed in hex, each
+// address that did not ACK is printed as "--", and addresses not probed are
+// left blank.
+func (s *I2CScan) String() string {
+	var sb strings.Builder
+	sb.WriteString("     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f\n")
+	for row := 0; row < len(s.Probe); row += 0x10 {
+		fmt.Fprintf(&sb, "%02x: ", row)
+		for a := row; a < row+0x10; a++ {
+			switch {
+			case I2CProbeSkip == s.Probe[a]:
+				sb.WriteString("   ")
+			case s.ACK[a]:
+				fmt.Fprintf(&sb, "%02x ", a)
+			default:
+				sb.WriteString("-- ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Scan probes the I²C bus for slave devices using the given probe method for
+// each range of 7-bit slave addresses, returning the results and a non-nil
+// error if unsuccessful.
+// If a slave address is contained in more than one range, the last such range
+// is used; addresses not contained in any range are not probed. If no ranges
+// are given, the ranges returned by I2CScanDefault are used.
+// Each probe is enclosed by start and stop conditions, and all probes are
+// executed as a single MPSSE command buffer, or if the Port implements
+// PortChannel, as a separate read or write request per probe, with a slave
+// address NACK reported as not present.
+// Returns a non-nil I2CBusError if the bus is stuck, arbitration was lost, or
+// clock stretching timed out (with Addr 0, since the slave is unknown).
+func (i2c *I2C) Scan(rng ...I2CScanRange) (*I2CScan, error) {
+
+	if 0 == len(rng) {
+		rng = I2CScanDefault()
+	}
+
+	scan := &I2CScan{}
+	for _, r := range rng {
+		if r.First > r.Last || r.Last >= uint(len(scan.Probe)) {
+			return nil, fmt.Errorf("invalid scan range: 0x%02X-0x%02X", r.First, r.Last)
+		}
+		if !r.Probe.valid() {
+			return nil, fmt.Errorf("invalid probe method: %s", r.Probe)
+		}
+		for a := r.First; a <= r.Last; a++ {
+			scan.Probe[a] = r.Probe
+		}
+	}
+
+	if nil != i2c.device.info.engine.channel {
+		return i2c.scanChannel(scan)
+	}
+
+	cmd := []mpsse.Command{}
+	chk := [len(scan.Probe)]i2cCheck{}
+	for a, p := range scan.Probe {
+		if I2CProbeSkip == p {
+			continue
+		}
+		var c []mpsse.Command
+		c, chk[a] = i2c.address(uint(a), I2CProbeRead == p, i2cStartBit)
+		cmd = append(cmd, c...)
+		switch p {
+		case I2CProbeRead:
+			cmd = append(cmd, i2c.readByte(false)...)
+		case I2CProbeWrite:
+			cmd = append(cmd, i2c.writeByte(0x00)...)
+		}
+		cmd = append(cmd, i2c.stop()...)
+	}
+	if 0 == len(cmd) {
+		return scan, nil
+	}
+
+	resp, err := i2c.exec(0, cmd...)
+	if nil != err {
+		return nil, err
+	}
+
+	for a, p := range scan.Probe {
+		if I2CProbeSkip == p {
+			continue
+		}
+		err := chk[a].verify(resp)
+		var nack *I2CAddrNACKError
+		if nil != err && !errors.As(err, &nack) {
+			return nil, err
+		}
+		scan.ACK[a] = nil == err
+		resp = resp[chk[a].len()+p.resp():]
+	}
+
+	return scan, nil
+}
+
+// scanChannel performs the probes of Scan assigned in scan using the libMPSSE
+// I²C channel of a PortChannel, one read or write request per probe. Fast
+// transfers are used so that the stop condition is generated even if the slave
+// does not ACK its address.
+func (i2c *I2C) scanChannel(scan *I2CScan) (*I2CScan, error) {
+
+	opt := i2cStartBit | i2cStopBit | i2cFastTransfer
+
+	for a, p := range scan.Probe {
+		var err error
+		switch p {
+		case I2CProbeSkip:
+			continue
+		case I2CProbeQuick:
+			_, err = i2c.write(uint(a), nil, opt, nil)
+		case I2CProbeRead:
+			_, err = i2c.read(uint(a), 1, opt|i2cLastReadNACK)
+		case I2CProbeWrite:
+			_, err = i2c.write(uint(a), []uint8{0x00}, opt, nil)
+		}
+		var nack *I2CAddrNACKError
+		if nil != err && !errors.As(err, &nack) {
+			return nil, err
+		}
+		scan.ACK[a] = nil == err
+	}
+
+	return scan, nil
+}
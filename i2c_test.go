@@ -0,0 +1,592 @@
+package ft232h
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestI2CTx(t *testing.T) {
+
+	reg := &SimI2CRegFile{Reg: map[uint][]uint8{0x02: {0x12, 0x34}, 0x05: {0xBE, 0xEF}}}
+	ft, err := OpenSim("i2c", func(d *SimDevice) error { return d.AttachI2C(0x40, reg) })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	rd, err := ft.I2C.Tx(0x40, []uint8{0x02}, 2)
+	if nil != err {
+		t.Fatalf("Tx(): %v", err)
+	}
+	if exp := []uint8{0x12, 0x34}; !bytes.Equal(exp, rd) {
+		t.Fatalf("Tx()={% X}, expected={% X}", rd, exp)
+	}
+	if 1 != reg.Stops {
+		t.Fatalf("Stops={%d}, expected={%d}", reg.Stops, 1)
+	}
+
+	msg := []I2CMsg{
+		{Addr: 0x40, Buf: []uint8{0x05}},
+		{Addr: 0x40, Flags: I2CMsgRead, Buf: make([]uint8, 1)},
+		{Addr: 0x40, Flags: I2CMsgRead | I2CMsgNoStart, Buf: make([]uint8, 1)},
+	}
+	if n, err := ft.I2C.Transfer(msg); nil != err || len(msg) != n {
+		t.Fatalf("Transfer()={%d}, expected={%d}: %v", n, len(msg), err)
+	}
+	if 0xBE != msg[1].Buf[0] || 0xEF != msg[2].Buf[0] {
+		t.Fatalf("Transfer()={%02X %02X}, expected={%02X %02X}",
+			msg[1].Buf[0], msg[2].Buf[0], 0xBE, 0xEF)
+	}
+
+	// register 0x07 reads 02 12 02, i.e. a block count of 2
+	reg.Reg[0x07] = []uint8{0x02, 0x12, 0x02}
+	msg = []I2CMsg{
+		{Addr: 0x40, Buf: []uint8{0x07}},
+		{Addr: 0x40, Flags: I2CMsgRead | I2CMsgRecvLen, Buf: make([]uint8, 1)},
+	}
+	if n, err := ft.I2C.Transfer(msg); nil != err || len(msg) != n {
+		t.Fatalf("Transfer()={%d}, expected={%d}: %v", n, len(msg), err)
+	}
+	if exp := []uint8{0x02, 0x12, 0x02}; !bytes.Equal(exp, msg[1].Buf) {
+		t.Fatalf("Transfer()={% X}, expected={% X}", msg[1].Buf, exp)
+	}
+	reg.Reg[0x07] = []uint8{0x00}
+	if _, err := ft.I2C.Transfer(msg); nil == err {
+		t.Fatalf("Transfer(): expected error for zero receive length")
+	}
+
+	for _, tc := range []struct {
+		msg []I2CMsg
+		n   int
+		err error
+	}{
+		{[]I2CMsg{{Addr: 0x40, Buf: []uint8{0x02}}, {Addr: 0x41, Flags: I2CMsgRead, Buf: make([]uint8, 2)}}, 1, SDeviceNotFound},
+		{[]I2CMsg{{Addr: 0x41, Flags: I2CMsgIgnoreNACK, Buf: []uint8{0x00}}}, 1, nil},
+	} {
+		if n, err := ft.I2C.Transfer(tc.msg); !errors.Is(err, tc.err) || tc.n != n {
+			t.Fatalf("Transfer()={%d, %v}, expected={%d, %v}", n, err, tc.n, tc.err)
+		}
+	}
+
+	for _, msg := range [][]I2CMsg{
+		nil,
+		{{Addr: 0x40, Flags: I2CMsgNoStart, Buf: []uint8{0x00}}},
+		{{Addr: 0x80, Buf: []uint8{0x00}}},
+	} {
+		if _, err := ft.I2C.Transfer(msg); nil == err {
+			t.Fatalf("Transfer(%v): expected error", msg)
+		}
+	}
+
+	t.Run("single-exchange", func(t *testing.T) {
+		tpt := &testTransport{port: &testPort{low: 0xFF}}
+		ft, err := OpenMask(&Mask{Transport: tpt})
+		if nil != err {
+			t.Fatalf("OpenMask(): %v", err)
+		}
+		defer ft.Close()
+		if err := ft.I2C.Init(); nil != err {
+			t.Fatalf("I2C.Init(): %v", err)
+		}
+		tpt.port.nw = 0
+		if _, err := ft.I2C.Tx(0x50, []uint8{0x00, 0x10}, 16); nil != err {
+			t.Fatalf("Tx(): %v", err)
+		}
+		if 1 != tpt.port.nw {
+			t.Fatalf("USB writes={%d}, expected={%d}", tpt.port.nw, 1)
+		}
+	})
+}
+
+func TestI2CReadNACK(t *testing.T) {
+
+	reg := &SimI2CRegFile{Reg: map[uint][]uint8{0x02: {0x12, 0x34}}}
+	ft, err := OpenSim("i2c", func(d *SimDevice) error { return d.AttachI2C(0x40, reg) })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	// the last byte of a read ending in a stop condition is NACKed, with and
+	// without fast transfers
+	for _, noDelay := range []bool{true, false} {
+		cfg := I2CConfigDefault()
+		cfg.NoUSBDelay = noDelay
+		if err := ft.I2C.Config(cfg); nil != err {
+			t.Fatalf("I2C.Config(): %v", err)
+		}
+		if _, err := ft.I2C.Write(0x40, []uint8{0x02}, true, false); nil != err {
+			t.Fatalf("I2C.Write(): %v", err)
+		}
+		reg.Acks = nil
+		rd, err := ft.I2C.Read(0x40, 2, true, true)
+		if nil != err {
+			t.Fatalf("I2C.Read(): %v", err)
+		}
+		if exp := []uint8{0x12, 0x34}; !bytes.Equal(exp, rd) {
+			t.Fatalf("I2C.Read()={% X}, expected={% X}", rd, exp)
+		}
+		if exp := []bool{true, false}; fmt.Sprint(exp) != fmt.Sprint(reg.Acks) {
+			t.Fatalf("NoUSBDelay=%t: Acks={%v}, expected={%v}", noDelay, reg.Acks, exp)
+		}
+	}
+}
+
+func TestI2CTenBit(t *testing.T) {
+
+	ten := &SimI2CRegFile{Reg: map[uint][]uint8{0x02: {0x12, 0x34}, 0x05: {0xBE, 0xEF}}}
+	seven := &SimI2CRegFile{Reg: map[uint][]uint8{0x02: {0x56, 0x78}}}
+	ft, err := OpenSim("i2c", func(d *SimDevice) error {
+		if err := d.AttachI2CTenBit(0x2A5, ten); nil != err {
+			return err
+		}
+		return d.AttachI2C(0x25, seven)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	if _, err := ft.I2C.Tx(0x2A5, []uint8{0x02}, 2); nil == err {
+		t.Fatalf("Tx(): expected error for 10-bit address in 7-bit mode")
+	}
+
+	msg := []I2CMsg{
+		{Addr: 0x2A5, Flags: I2CMsgTen, Buf: []uint8{0x05}},
+		{Addr: 0x2A5, Flags: I2CMsgTen | I2CMsgRead, Buf: make([]uint8, 2)},
+		{Addr: 0x25, Buf: []uint8{0x02}},
+		{Addr: 0x25, Flags: I2CMsgRead, Buf: make([]uint8, 2)},
+	}
+	if n, err := ft.I2C.Transfer(msg); nil != err || len(msg) != n {
+		t.Fatalf("Transfer()={%d}, expected={%d}: %v", n, len(msg), err)
+	}
+	for i, exp := range map[int][]uint8{1: {0xBE, 0xEF}, 3: {0x56, 0x78}} {
+		if !bytes.Equal(exp, msg[i].Buf) {
+			t.Fatalf("Transfer()[%d]={% X}, expected={% X}", i, msg[i].Buf, exp)
+		}
+	}
+
+	for _, noDelay := range []bool{true, false} {
+		if err := ft.I2C.Option(&I2COption{TenBitAddr: true, NoUSBDelay: noDelay}); nil != err {
+			t.Fatalf("Option(): %v", err)
+		}
+		if !ft.I2C.GetConfig().TenBitAddr {
+			t.Fatalf("GetConfig(): expected 10-bit addressing")
+		}
+
+		rd, err := ft.I2C.Tx(0x2A5, []uint8{0x02}, 2)
+		if nil != err {
+			t.Fatalf("Tx(): %v", err)
+		}
+		if exp := []uint8{0x12, 0x34}; !bytes.Equal(exp, rd) {
+			t.Fatalf("Tx()={% X}, expected={% X}", rd, exp)
+		}
+
+		if _, err := ft.I2C.Write(0x2A5, []uint8{0x05}, true, false); nil != err {
+			t.Fatalf("Write(): %v", err)
+		}
+		rd, err = ft.I2C.Read(0x2A5, 2, true, true)
+		if nil != err {
+			t.Fatalf("Read(): %v", err)
+		}
+		if exp := []uint8{0xBE, 0xEF}; !bytes.Equal(exp, rd) {
+			t.Fatalf("Read()={% X}, expected={% X}", rd, exp)
+		}
+
+		r, err := ft.I2C.Reg(0x2A5, 0x02, Addr8Bit, MSB).Reader(2)
+		if nil != err {
+			t.Fatalf("Reader(): %v", err)
+		}
+		if val, err := r(true); nil != err || 0x1234 != val {
+			t.Fatalf("reader()={0x%04X}, expected={0x%04X}: %v", val, 0x1234, err)
+		}
+
+		// same low address byte, different A9-A8
+		if _, err := ft.I2C.Read(0x1A5, 2, true, true); !errors.Is(err, SDeviceNotFound) {
+			t.Fatalf("Read(): unexpected error: %v", err)
+		}
+		if _, err := ft.I2C.Write(0x0A5, []uint8{0x00}, true, true); !errors.Is(err, SDeviceNotFound) {
+			t.Fatalf("Write(): unexpected error: %v", err)
+		}
+	}
+}
+
+func TestI2CScan(t *testing.T) {
+	t.Run("MPSSE", func(t *testing.T) { testI2CScan(t, false) })
+	t.Run("Channel", func(t *testing.T) { testI2CScan(t, true) })
+}
+
+// testI2CScan tests the bus scan with simulated slave devices, with the
+// simulated FT232H emulating the libMPSSE I²C channel if channel is true.
+func testI2CScan(t *testing.T, channel bool) {
+
+	ina := &SimI2CRegFile{}
+	eep := &SimI2CRegFile{Addr: 0x10}
+	ft, err := OpenSim("i2c", func(d *SimDevice) error {
+		d.Channel = channel
+		if err := d.AttachI2C(0x40, ina); nil != err {
+			return err
+		}
+		return d.AttachI2C(0x50, eep)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	scan, err := ft.I2C.Scan()
+	if nil != err {
+		t.Fatalf("Scan(): %v", err)
+	}
+	if exp := []uint{0x40, 0x50}; fmt.Sprint(exp) != fmt.Sprint(scan.Found()) {
+		t.Fatalf("Found()={%v}, expected={%v}", scan.Found(), exp)
+	}
+	for a, exp := range map[uint]I2CProbe{
+		0x07: I2CProbeSkip, 0x08: I2CProbeQuick, 0x30: I2CProbeRead,
+		0x40: I2CProbeQuick, 0x50: I2CProbeRead, 0x78: I2CProbeSkip,
+	} {
+		if exp != scan.Probe[a] {
+			t.Fatalf("Probe[0x%02X]={%s}, expected={%s}", a, scan.Probe[a], exp)
+		}
+	}
+	if 1 != len(eep.Acks) || 0x10 != eep.Addr || 1 != ina.Stops {
+		t.Fatalf("unexpected probe: eep.Acks={%v} eep.Addr={0x%02X} ina.Stops={%d}",
+			eep.Acks, eep.Addr, ina.Stops)
+	}
+
+	row := strings.Split(scan.String(), "\n")
+	for i, exp := range map[int]string{
+		0: "     0  1  2  3  4  5  6  7  8  9  a  b  c  d  e  f",
+		1: "00:                         -- -- -- -- -- -- -- -- ",
+		5: "40: 40 -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- ",
+		6: "50: 50 -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- ",
+		8: "70: -- -- -- -- -- -- -- --                         ",
+	} {
+		if exp != row[i] {
+			t.Fatalf("String()[%d]={%q}, expected={%q}", i, row[i], exp)
+		}
+	}
+
+	scan, err = ft.I2C.Scan(
+		I2CScanRange{First: 0x00, Last: 0x7F, Probe: I2CProbeWrite},
+		I2CScanRange{First: 0x40, Last: 0x4F, Probe: I2CProbeSkip},
+	)
+	if nil != err {
+		t.Fatalf("Scan(): %v", err)
+	}
+	if exp := []uint{0x50}; fmt.Sprint(exp) != fmt.Sprint(scan.Found()) {
+		t.Fatalf("Found()={%v}, expected={%v}", scan.Found(), exp)
+	}
+	if 0x00 != eep.Addr {
+		t.Fatalf("eep.Addr={0x%02X}, expected={0x%02X}", eep.Addr, 0x00)
+	}
+
+	for _, r := range []I2CScanRange{
+		{First: 0x10, Last: 0x08, Probe: I2CProbeQuick},
+		{First: 0x00, Last: 0x80, Probe: I2CProbeQuick},
+		{First: 0x00, Last: 0x7F, Probe: I2CProbe(9)},
+	} {
+		if _, err := ft.I2C.Scan(r); nil == err {
+			t.Fatalf("Scan(%v): expected error", r)
+		}
+	}
+}
+
+// simNACKI2C is a simulated I²C slave that ACKs only the first accept data bytes
+// written in each transfer, and NACKs its address while busy is non-zero, like
+// an EEPROM during its internal write cycle.
+type simNACKI2C struct {
+	accept int
+	busy   int
+	n      int
+}
+
+func (s *simNACKI2C) Start(read bool) bool {
+	if s.busy > 0 {
+		s.busy--
+		return false
+	}
+	s.n = 0
+	return true
+}
+
+func (s *simNACKI2C) Write(data []uint8) uint {
+	for i := range data {
+		if s.n >= s.accept {
+			return uint(i)
+		}
+		s.n++
+	}
+	return uint(len(data))
+}
+
+func (s *simNACKI2C) Read(data []uint8) {}
+func (s *simNACKI2C) Stop()             {}
+
+func TestI2CError(t *testing.T) {
+
+	slave := &simNACKI2C{accept: 2}
+	ft, err := OpenSim("i2c", func(d *SimDevice) error { return d.AttachI2C(0x50, slave) })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	data := []uint8{0x00, 0x10, 0xAA, 0xBB}
+
+	n, ack, err := ft.I2C.WriteACK(0x50, data, true, true)
+	if nil != err || uint(len(data)) != n {
+		t.Fatalf("WriteACK()={%d}, expected={%d}: %v", n, len(data), err)
+	}
+	if exp := []bool{true, true, false, false}; fmt.Sprint(exp) != fmt.Sprint(ack) {
+		t.Fatalf("WriteACK()={%v}, expected={%v}", ack, exp)
+	}
+
+	if err := ft.I2C.Option(&I2COption{BreakOnNACK: true}); nil != err {
+		t.Fatalf("Option(): %v", err)
+	}
+	n, err = ft.I2C.Write(0x50, data, true, true)
+	if e, ok := err.(*I2CDataNACKError); !ok || 0x50 != e.Addr || 2 != e.Index || 2 != n {
+		t.Fatalf("Write()={%d, %v}, expected={%d, data NACK at byte %d}", n, err, 2, 2)
+	}
+	if !errors.Is(err, SFailedToWriteDevice) {
+		t.Fatalf("Write(): expected error to wrap %v", SFailedToWriteDevice)
+	}
+
+	// same with the default configuration, using fast transfers
+	cfg := I2CConfigDefault()
+	cfg.BreakOnNACK = true
+	if err := ft.I2C.Config(cfg); nil != err {
+		t.Fatalf("Config(): %v", err)
+	}
+	n, err = ft.I2C.Write(0x50, data, true, true)
+	var nack *I2CDataNACKError
+	if !errors.As(err, &nack) || 0x50 != nack.Addr || 2 != nack.Index || 2 != n {
+		t.Fatalf("Write()={%d, %v}, expected={%d, data NACK at byte %d}", n, err, 2, 2)
+	}
+
+	// retry while the slave is busy
+	slave.busy, slave.accept = 2, len(data)
+	for retry := 0; ; retry++ {
+		_, err := ft.I2C.Write(0x50, data, true, true)
+		if nil == err {
+			if 2 != retry {
+				t.Fatalf("Write(): succeeded after %d retries, expected %d", retry, 2)
+			}
+			break
+		}
+		if e, ok := err.(*I2CAddrNACKError); !ok || 0x50 != e.Addr || retry > 2 {
+			t.Fatalf("Write(): unexpected error: %v", err)
+		}
+	}
+
+	slave.accept = 1
+	msg := []I2CMsg{{Addr: 0x50, Buf: data}, {Addr: 0x50, Flags: I2CMsgRead, Buf: make([]uint8, 1)}}
+	if n, err := ft.I2C.Transfer(msg); 0 != n || 1 != err.(*I2CDataNACKError).Index {
+		t.Fatalf("Transfer()={%d, %v}, expected={%d, data NACK at byte %d}", n, err, 0, 1)
+	}
+
+	for _, tc := range []struct {
+		port *testPort
+		err  I2CBusError
+	}{
+		{&testPort{low: 0xFB}, I2CBusError{Addr: 0x50, Cause: I2CBusStuck, SCL: true, SDA: false}},
+		{&testPort{low: 0xFE}, I2CBusError{Addr: 0x50, Cause: I2CBusStuck, SCL: false, SDA: true}},
+		{&testPort{low: 0xFF, hold: 0x80}, I2CBusError{Addr: 0x50, Cause: I2CArbitrationLost}},
+	} {
+		ft, err := OpenMask(&Mask{Transport: &testTransport{port: tc.port}})
+		if nil != err {
+			t.Fatalf("OpenMask(): %v", err)
+		}
+		if err := ft.I2C.Init(); nil != err {
+			t.Fatalf("I2C.Init(): %v", err)
+		}
+		_, err = ft.I2C.Tx(0x50, []uint8{0x00}, 1)
+		if e, ok := err.(*I2CBusError); !ok || tc.err != *e {
+			t.Fatalf("Tx()={%v}, expected={%v}", err, &tc.err)
+		}
+		if !errors.Is(err, SIOError) {
+			t.Fatalf("Tx(): expected error to wrap %v", SIOError)
+		}
+		ft.Close()
+	}
+}
+
+// simStretchI2C is a simulated I²C slave with 16-bit registers that stretches
+// the clock for the given number of periods, or indefinitely.
+type simStretchI2C struct {
+	SimI2CRegFile
+	hold    int
+	forever bool
+}
+
+func (s *simStretchI2C) Stretch() bool {
+	if s.hold > 0 {
+		s.hold--
+		return true
+	}
+	return s.forever
+}
+
+func TestI2CStretch(t *testing.T) {
+
+	slave := &simStretchI2C{SimI2CRegFile: SimI2CRegFile{Reg: map[uint][]uint8{0x02: {0x12, 0x34}}}}
+	ft, err := OpenSim("i2c", func(d *SimDevice) error { return d.AttachI2C(0x48, slave) })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	// without adaptive clocking, bits clocked while SCL is held LOW are lost
+	slave.hold = 3
+	if _, err := ft.I2C.Tx(0x48, []uint8{0x02}, 2); nil == err {
+		t.Fatalf("Tx(): expected error without clock stretching")
+	}
+
+	opt := &I2COption{ClockStretch: true, StretchTimeout: 10 * time.Millisecond}
+	if err := ft.I2C.Option(opt); nil != err {
+		t.Fatalf("Option(): %v", err)
+	}
+	if cfg := ft.I2C.GetConfig(); !cfg.ClockStretch || opt.StretchTimeout != cfg.StretchTimeout {
+		t.Fatalf("GetConfig()={%t, %v}, expected={%t, %v}",
+			cfg.ClockStretch, cfg.StretchTimeout, true, opt.StretchTimeout)
+	}
+	if exp := uint8(0x87); exp != ft.DGPIO.Reserved() {
+		t.Fatalf("Reserved()={%08b}, expected={%08b}", ft.DGPIO.Reserved(), exp)
+	}
+
+	for _, hold := range []int{3, SimStretchLimit / 2} {
+		slave.hold = hold
+		rd, err := ft.I2C.Tx(0x48, []uint8{0x02}, 2)
+		if nil != err {
+			t.Fatalf("Tx(): %v", err)
+		}
+		if exp := []uint8{0x12, 0x34}; !bytes.Equal(exp, rd) {
+			t.Fatalf("Tx()={% X}, expected={% X}", rd, exp)
+		}
+	}
+
+	slave.forever = true
+	_, err = ft.I2C.Tx(0x48, []uint8{0x02}, 2)
+	if e, ok := err.(*I2CBusError); !ok || 0x48 != e.Addr || I2CStretchTimeout != e.Cause {
+		t.Fatalf("Tx()={%v}, expected clock stretching timeout", err)
+	}
+	if !errors.Is(err, SIOError) {
+		t.Fatalf("Tx(): expected error to wrap %v", SIOError)
+	}
+
+	// the interface recovers once the slave releases SCL
+	slave.forever = false
+	if _, err := ft.I2C.Tx(0x48, []uint8{0x02}, 2); nil != err {
+		t.Fatalf("Tx(): %v", err)
+	}
+
+	if err := ft.I2C.Option(&I2COption{}); nil != err {
+		t.Fatalf("Option(): %v", err)
+	}
+	if exp := uint8(0x07); exp != ft.DGPIO.Reserved() {
+		t.Fatalf("Reserved()={%08b}, expected={%08b}", ft.DGPIO.Reserved(), exp)
+	}
+	if I2CStretchTimeoutDefault != ft.I2C.GetConfig().StretchTimeout {
+		t.Fatalf("GetConfig(): expected default stretch timeout")
+	}
+}
+
+func TestI2CReg(t *testing.T) {
+
+	ina := &SimI2CRegFile{Reg: map[uint][]uint8{0x00: {0x61, 0x27}}} // INA260 reset value
+	ft, err := OpenSim("i2c", func(d *SimDevice) error { return d.AttachI2C(0x40, ina) })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	// INA260 configuration register
+	var (
+		rst  = I2CRegField{Name: "RST", Shift: 15, Width: 1}
+		avg  = I2CRegField{Name: "AVG", Shift: 9, Width: 3}
+		mode = I2CRegField{Name: "MODE", Shift: 0, Width: 3}
+	)
+	cfg := ft.I2C.Reg(0x40, 0x00, Addr8Bit, MSB)
+
+	wr, err := cfg.Writer(2)
+	if nil != err {
+		t.Fatalf("Writer(): %v", err)
+	}
+	if err := wr(0x4527); nil != err {
+		t.Fatalf("writer(): %v", err)
+	}
+	if v := MSB.Uint(2, ina.Peek(0x00, 2)); 0x4527 != v {
+		t.Fatalf("writer()={0x%04X}, expected={0x%04X}", v, 0x4527)
+	}
+
+	if err := cfg.Update(2, 0x00FF, 0x1234); nil != err {
+		t.Fatalf("Update(): %v", err)
+	}
+	if v := MSB.Uint(2, ina.Peek(0x00, 2)); 0x4534 != v {
+		t.Fatalf("Update()={0x%04X}, expected={0x%04X}", v, 0x4534)
+	}
+
+	if err := cfg.SetField(2, avg, 0x7); nil != err {
+		t.Fatalf("SetField(): %v", err)
+	}
+	if v := MSB.Uint(2, ina.Peek(0x00, 2)); 0x4F34 != v {
+		t.Fatalf("SetField()={0x%04X}, expected={0x%04X}", v, 0x4F34)
+	}
+	if err := cfg.SetField(2, mode, 0x8); nil == err {
+		t.Fatalf("SetField(): expected error for value exceeding bitfield")
+	}
+	for _, tc := range []struct {
+		f   I2CRegField
+		exp uint64
+	}{
+		{rst, 0}, {avg, 0x7}, {mode, 0x4},
+	} {
+		if v, err := cfg.Field(2, tc.f); nil != err || tc.exp != v {
+			t.Fatalf("Field(%s)={%d}, expected={%d}: %v", tc.f, v, tc.exp, err)
+		}
+	}
+
+	if exp := "AVG[11:9]"; exp != avg.String() {
+		t.Fatalf("String()={%s}, expected={%s}", avg, exp)
+	}
+	if exp := uint64(0x8000); exp != rst.Set(0, 1) || 1 != rst.Get(exp) {
+		t.Fatalf("Set()={0x%04X}, expected={0x%04X}", rst.Set(0, 1), exp)
+	}
+
+	// LSB-first registers
+	if err := ft.I2C.Reg(0x40, 0x01, Addr8Bit, LSB).Update(2, 0xFFFF, 0xABCD); nil != err {
+		t.Fatalf("Update(): %v", err)
+	}
+	if v := MSB.Uint(2, ina.Peek(0x01, 2)); 0xCDAB != v {
+		t.Fatalf("Update()={0x%04X}, expected={0x%04X}", v, 0xCDAB)
+	}
+}
@@ -0,0 +1,368 @@
+package ft232h
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// I2CMsg is a single message of a combined I²C transfer (see I2C.Transfer).
+// It is equivalent to struct i2c_msg of the Linux i2c-dev I2C_RDWR interface,
+// with identical flag values, so that drivers written for Linux can be ported
+// without changes to their message sequences.
+type I2CMsg struct {
+	Addr  uint       // unshifted 7-bit (or 10-bit) I²C slave address
+	Flags I2CMsgFlag // message flags (I2CMsgRead, etc.)
+	Buf   []uint8    // data written, or buffer filled with data read
+}
+
+// I2CMsgFlag holds the bitwise-OR of I²C message flags.
+type I2CMsgFlag uint16
+
+// Constants defining the I²C message flags, equal to the corresponding flags
+// I2C_M_* of the Linux i2c-dev interface.
+const (
+	// Read len(Buf) bytes from the slave into Buf. If not set, the bytes in Buf
+	// are written to the slave.
+	I2CMsgRead I2CMsgFlag = 0x0001
+	// Addr is a 10-bit slave address.
+	I2CMsgTen I2CMsgFlag = 0x0010
+	// The first byte read is the number of bytes that follow (an SMBus block
+	// count, 1-255), by which the length of Buf is increased. Buf must initially
+	// hold the count byte plus any bytes read after the block, e.g. a PEC byte.
+	// I2CMsgIgnoreNACK is not supported on the address of such a message.
+	I2CMsgRecvLen I2CMsgFlag = 0x0400
+	// Do not fail the transfer if the slave NACKs its address or a data byte.
+	I2CMsgIgnoreNACK I2CMsgFlag = 0x1000
+	// Do not generate a (repeated) start condition or address phase, continuing
+	// the previous message. Not allowed on the first message.
+	I2CMsgNoStart I2CMsgFlag = 0x4000
+)
+
+// read returns true if the receiver message reads from the slave.
+func (m *I2CMsg) read() bool { return (m.Flags & I2CMsgRead) > 0 }
+
+// ten returns true if the receiver message uses a 10-bit slave address.
+func (m *I2CMsg) ten() bool { return (m.Flags & I2CMsgTen) > 0 }
+
+// recvLen returns true if the receiver message reads its length from the slave.
+func (m *I2CMsg) recvLen() bool { return m.read() && (m.Flags&I2CMsgRecvLen) > 0 }
+
+// String returns a descriptive string of an I2CMsg.
+func (m I2CMsg) String() string {
+	dir := "W"
+	if m.read() {
+		dir = "R"
+	}
+	return fmt.Sprintf("{ Addr: 0x%02X, Flags: 0x%04X, %s: %d bytes }",
+		m.Addr, uint16(m.Flags), dir, len(m.Buf))
+}
+
+// Tx performs a combined I²C write-then-read transaction with the given 7-bit
+// (or 10-bit, see I2COption) slave address, e.g. for reading a slave register:
+// the given data w is written to the slave, followed by a repeated start
+// condition and the reading of rLen bytes, and then a stop condition.
+// The entire transaction is executed as a single MPSSE command buffer, or, if
+// the Port implements PortChannel, as a write and a read request sharing the
+// repeated start condition.
+// If w is empty, only the read is performed; if rLen is 0, only the write is
+// performed. If both, only the slave address is written, and the slave must ACK
+// it for the transaction to succeed.
+// Returns the bytes read and a non-nil error if unsuccessful.
+func (i2c *I2C) Tx(slave uint, w []uint8, rLen uint) ([]uint8, error) {
+
+	if nil != i2c.device.info.engine.channel {
+		if len(w) > 0 || 0 == rLen {
+			if _, err := i2c.Write(slave, w, true, 0 == rLen); nil != err {
+				return nil, err
+			}
+		}
+		if 0 == rLen {
+			return []uint8{}, nil
+		}
+		r, err := i2c.Read(slave, rLen, true, true)
+		if nil != err {
+			return nil, err
+		}
+		return r, nil
+	}
+
+	flag := I2CMsgFlag(0)
+	if i2c.config.tenBit {
+		flag |= I2CMsgTen
+	}
+
+	msg := []I2CMsg{}
+	if len(w) > 0 || 0 == rLen {
+		msg = append(msg, I2CMsg{Addr: slave, Flags: flag, Buf: w})
+	}
+	if rLen > 0 {
+		msg = append(msg, I2CMsg{Addr: slave, Flags: flag | I2CMsgRead, Buf: make([]uint8, rLen)})
+	}
+
+	if _, err := i2c.Transfer(msg); nil != err {
+		return nil, err
+	}
+	if rLen > 0 {
+		return msg[len(msg)-1].Buf, nil
+	}
+	return []uint8{}, nil
+}
+
+// Transfer performs a combined I²C transfer of the given messages with the
+// semantics of the Linux i2c-dev I2C_RDWR interface: each message begins with
+// a start condition (a repeated start for all but the first) and address phase,
+// unless I2CMsgNoStart is set, and a single stop condition follows the last
+// message. The last byte of each read message is NACKed.
+// A message with a 10-bit address (I2CMsgTen) is addressed with the header
+// 11110 A9 A8 W and the second address byte A7-A0; read messages then follow
+// with a repeated start condition and the header 11110 A9 A8 R.
+// The entire transfer is executed as a single MPSSE command buffer, and the
+// Buf of each read message is filled with the data read. The only exception is
+// a read message with I2CMsgRecvLen set, whose length is not known until its
+// first byte is received: the buffer is split after that byte, and the Buf of
+// the message is replaced with one of the received length.
+// If the Port implements PortChannel, each message is instead performed with a
+// separate read or write request, and the count byte of an I2CMsgRecvLen read
+// with a request of its own.
+// Returns the number of messages transferred successfully, and a non-nil error
+// if unsuccessful: I2CAddrNACKError if a slave did not ACK its address, or
+// I2CDataNACKError if a slave did not ACK a data byte written, unless the
+// message sets I2CMsgIgnoreNACK; or I2CBusError if the bus is stuck,
+// arbitration was lost, or clock stretching timed out.
+func (i2c *I2C) Transfer(msg []I2CMsg) (int, error) {
+
+	if 0 == len(msg) {
+		return 0, fmt.Errorf("no messages to transfer")
+	}
+
+	for i := range msg {
+		m := &msg[i]
+		if (m.Flags & I2CMsgNoStart) > 0 {
+			if 0 == i {
+				return 0, fmt.Errorf("first message must generate start condition")
+			}
+		} else if err := validSlave(m.Addr, m.ten()); nil != err {
+			return 0, err
+		}
+		if m.recvLen() && 0 == len(m.Buf) {
+			return 0, fmt.Errorf("receive length buffer must hold count byte")
+		}
+	}
+
+	if nil != i2c.device.info.engine.channel {
+		return i2c.transferChannel(msg)
+	}
+
+	cmd := []mpsse.Command{}
+	chk := make([]i2cCheck, len(msg)) // address phase of each message
+	done, skip := 0, 0                // messages and bytes already received
+	for i := range msg {
+		m := &msg[i]
+		if 0 == (m.Flags & I2CMsgNoStart) {
+			opt := i2cStartBit
+			if m.ten() {
+				opt |= i2cTenBitAddress
+			}
+			var c []mpsse.Command
+			c, chk[i] = i2c.address(m.Addr, m.read(), opt)
+			cmd = append(cmd, c...)
+		}
+		if m.read() {
+			beg := 0
+			if m.recvLen() {
+				n, err := i2c.recvLen(msg[done:i+1], chk[done:i+1], skip, cmd)
+				if nil != err {
+					return done + n, err
+				}
+				done, skip, beg = i, 1, 1
+				cmd, chk[i] = []mpsse.Command{}, i2cCheck{addr: m.Addr}
+			}
+			// the last byte is ACKed if the next message continues the read
+			cont := i+1 < len(msg) && (msg[i+1].Flags&I2CMsgNoStart) > 0
+			for j := beg; j < len(m.Buf); j++ {
+				cmd = append(cmd, i2c.readByte(cont || j < len(m.Buf)-1)...)
+			}
+		} else {
+			for _, b := range m.Buf {
+				cmd = append(cmd, i2c.writeByte(b)...)
+			}
+		}
+	}
+	cmd = append(cmd, i2c.stop()...)
+
+	resp, err := i2c.exec(msg[done].Addr, cmd...)
+	if nil != err {
+		return done, err
+	}
+
+	n, err := i2c.collect(msg[done:], chk[done:], skip, resp)
+	return done + n, err
+}
+
+// transferChannel performs the transfer of Transfer with a separate read or
+// write request of a PortChannel for each message, with a stop condition only
+// following the last. A message with I2CMsgNoStart set continues the previous
+// request without addressing the slave.
+// Returns the number of messages transferred successfully, and a non-nil error
+// if unsuccessful, in which case a stop condition has been generated.
+func (i2c *I2C) transferChannel(msg []I2CMsg) (int, error) {
+
+	// a fast transfer can omit the address phase
+	cont := i2cFastTransfer | i2cFastTransferBytes | i2cNoAddress
+
+	// fail generates the stop condition omitted by message i, returning err.
+	fail := func(i int, err error) (int, error) {
+		if i < len(msg)-1 {
+			if _, se := i2c.write(msg[i].Addr, nil, cont|i2cStopBit, nil); nil != se {
+				return i, se
+			}
+		}
+		return i, err
+	}
+
+	for i := range msg {
+		m := &msg[i]
+		ign := (m.Flags & I2CMsgIgnoreNACK) > 0
+
+		opt := i2cStartBit
+		if (m.Flags & I2CMsgNoStart) > 0 {
+			opt = cont
+		}
+		if m.ten() {
+			opt |= i2cTenBitAddress
+		}
+		if !ign {
+			opt |= i2cBreakOnNACK
+		}
+
+		var err error
+		if m.read() {
+			buf := m.Buf
+			if m.recvLen() {
+				// the count byte is ACKed, and the rest of the block continues the read
+				cnt, err := i2c.read(m.Addr, 1, opt)
+				if nil != err {
+					return fail(i, err)
+				}
+				if 0 == cnt[0] {
+					// the slave must be NACKed before stopping
+					if _, err := i2c.read(m.Addr, 1, cont|i2cLastReadNACK|i2cStopBit); nil != err {
+						return i, err
+					}
+					return i, fmt.Errorf("invalid receive length: %d", cnt[0])
+				}
+				m.Buf = make([]uint8, len(m.Buf)+int(cnt[0]))
+				m.Buf[0] = cnt[0]
+				buf, opt = m.Buf[1:], cont
+			}
+			// the last byte is ACKed if the next message continues the read
+			if i+1 == len(msg) || 0 == (msg[i+1].Flags&I2CMsgNoStart) {
+				opt |= i2cLastReadNACK
+			}
+			if i+1 == len(msg) {
+				opt |= i2cStopBit
+			}
+			var rd []uint8
+			rd, err = i2c.read(m.Addr, uint(len(buf)), opt)
+			copy(buf, rd)
+		} else {
+			if i+1 == len(msg) {
+				opt |= i2cStopBit
+			}
+			_, err = i2c.write(m.Addr, m.Buf, opt, nil)
+		}
+
+		if nil != err {
+			var nack *I2CAddrNACKError
+			if !(ign && errors.As(err, &nack)) {
+				return fail(i, err)
+			}
+		}
+	}
+
+	return len(msg), nil
+}
+
+// recvLen executes the given commands of a transfer, which end with the address
+// phase of the last of the given messages, a read message with I2CMsgRecvLen
+// set, and appends the commands reading its count byte. The messages preceding
+// it are verified (see collect), skipping the given number of bytes already
+// received by the first message, and the Buf of the last message is replaced
+// with one extended by the count received.
+// Returns the number of messages preceding the last that were transferred
+// successfully, and a non-nil error if unsuccessful, in which case a stop
+// condition has been generated.
+func (i2c *I2C) recvLen(msg []I2CMsg, chk []i2cCheck, skip int, cmd []mpsse.Command) (int, error) {
+
+	last := len(msg) - 1
+	m, c := &msg[last], chk[last]
+
+	cmd = append(cmd, i2c.readByte(true)...)
+	resp, err := i2c.exec(msg[0].Addr, cmd...)
+	if nil != err {
+		return 0, err
+	}
+
+	tail := len(resp) - c.len() - 1 // response to the last message
+	if n, err := i2c.collect(msg[:last], chk[:last], skip, resp[:tail]); nil != err {
+		return n, i2c.nack(true, err)
+	}
+	if err := c.verify(resp[tail:]); nil != err {
+		return last, i2c.nack(true, err)
+	}
+
+	count := resp[len(resp)-1]
+	if 0 == count {
+		// the count byte was ACKed, so the slave must be NACKed before stopping
+		term := append(i2c.readByte(false), i2c.stop()...)
+		if _, err := i2c.exec(m.Addr, term...); nil != err {
+			return last, err
+		}
+		return last, fmt.Errorf("invalid receive length: %d", count)
+	}
+
+	buf := make([]uint8, len(m.Buf)+int(count))
+	buf[0] = count
+	m.Buf = buf
+
+	return last, nil
+}
+
+// collect verifies the given response to the commands of a transfer generated
+// for the given messages and their address phases, filling the Buf of each read
+// message with the data read, except for the given number of bytes already
+// received by the first message.
+// Returns the number of messages transferred successfully, and a non-nil error
+// if unsuccessful (see Transfer).
+func (i2c *I2C) collect(msg []I2CMsg, chk []i2cCheck, skip int, resp []uint8) (int, error) {
+
+	for i := range msg {
+		m := &msg[i]
+		ign := (m.Flags & I2CMsgIgnoreNACK) > 0
+		if err := chk[i].verify(resp); nil != err {
+			if _, nack := err.(*I2CAddrNACKError); !(nack && ign) {
+				return i, err
+			}
+		}
+		resp = resp[chk[i].len():]
+		if m.read() {
+			resp = resp[copy(m.Buf[skip:], resp):]
+		} else {
+			for j, b := range m.Buf {
+				ack, err := written(m.Addr, b, resp[j*i2cWriteResp:])
+				if nil != err {
+					return i, err
+				}
+				if !ack && !ign {
+					return i, &I2CDataNACKError{Addr: m.Addr, Index: uint(j)}
+				}
+			}
+			resp = resp[len(m.Buf)*i2cWriteResp:]
+		}
+		skip = 0
+	}
+
+	return len(msg), nil
+}
@@ -0,0 +1,708 @@
+package ft232h
+
+import (
+	"fmt"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// JTAG stores interface configuration settings for a JTAG master and provides
+// methods for navigating the TAP controller state machine, shifting the
+// instruction (IR) and data (DR) registers, and enumerating the devices on the
+// scan chain.
+//
+// The JTAG signals use fixed pins of port "D": TCK (D0), TDI (D1), TDO (D2),
+// and TMS (D3). TDI and TMS change on the falling edge of TCK, and TDO is
+// sampled on the rising edge.
+// The interface must be initialized by calling either Init or Config (not both)
+// before use.
+type JTAG struct {
+	device *FT232H
+	config *JTAGConfig
+	state  JTAGState
+}
+
+// JTAGState represents a state of the IEEE 1149.1 TAP controller state
+// machine.
+type JTAGState uint8
+
+// Constants defining the states of the TAP controller.
+const (
+	JTAGTestLogicReset JTAGState = iota
+	JTAGRunTestIdle
+	JTAGSelectDRScan
+	JTAGCaptureDR
+	JTAGShiftDR
+	JTAGExit1DR
+	JTAGPauseDR
+	JTAGExit2DR
+	JTAGUpdateDR
+	JTAGSelectIRScan
+	JTAGCaptureIR
+	JTAGShiftIR
+	JTAGExit1IR
+	JTAGPauseIR
+	JTAGExit2IR
+	JTAGUpdateIR
+	jtagStateCount
+)
+
+// jtagNext is the TAP controller state transition table, indexed by state and
+// the level of TMS sampled on the rising edge of TCK.
+var jtagNext = [jtagStateCount][2]JTAGState{
+	JTAGTestLogicReset: {JTAGRunTestIdle, JTAGTestLogicReset},
+	JTAGRunTestIdle:    {JTAGRunTestIdle, JTAGSelectDRScan},
+	JTAGSelectDRScan:   {JTAGCaptureDR, JTAGSelectIRScan},
+	JTAGCaptureDR:      {JTAGShiftDR, JTAGExit1DR},
+	JTAGShiftDR:        {JTAGShiftDR, JTAGExit1DR},
+	JTAGExit1DR:        {JTAGPauseDR, JTAGUpdateDR},
+	JTAGPauseDR:        {JTAGPauseDR, JTAGExit2DR},
+	JTAGExit2DR:        {JTAGShiftDR, JTAGUpdateDR},
+	JTAGUpdateDR:       {JTAGRunTestIdle, JTAGSelectDRScan},
+	JTAGSelectIRScan:   {JTAGCaptureIR, JTAGTestLogicReset},
+	JTAGCaptureIR:      {JTAGShiftIR, JTAGExit1IR},
+	JTAGShiftIR:        {JTAGShiftIR, JTAGExit1IR},
+	JTAGExit1IR:        {JTAGPauseIR, JTAGUpdateIR},
+	JTAGPauseIR:        {JTAGPauseIR, JTAGExit2IR},
+	JTAGExit2IR:        {JTAGShiftIR, JTAGUpdateIR},
+	JTAGUpdateIR:       {JTAGRunTestIdle, JTAGSelectDRScan},
+}
+
+// String returns the IEEE 1149.1 name of the TAP controller state.
+func (s JTAGState) String() string {
+	switch s {
+	case JTAGTestLogicReset:
+		return "Test-Logic-Reset"
+	case JTAGRunTestIdle:
+		return "Run-Test/Idle"
+	case JTAGSelectDRScan:
+		return "Select-DR-Scan"
+	case JTAGCaptureDR:
+		return "Capture-DR"
+	case JTAGShiftDR:
+		return "Shift-DR"
+	case JTAGExit1DR:
+		return "Exit1-DR"
+	case JTAGPauseDR:
+		return "Pause-DR"
+	case JTAGExit2DR:
+		return "Exit2-DR"
+	case JTAGUpdateDR:
+		return "Update-DR"
+	case JTAGSelectIRScan:
+		return "Select-IR-Scan"
+	case JTAGCaptureIR:
+		return "Capture-IR"
+	case JTAGShiftIR:
+		return "Shift-IR"
+	case JTAGExit1IR:
+		return "Exit1-IR"
+	case JTAGPauseIR:
+		return "Pause-IR"
+	case JTAGExit2IR:
+		return "Exit2-IR"
+	case JTAGUpdateIR:
+		return "Update-IR"
+	default:
+		return "(invalid state)"
+	}
+}
+
+// Valid returns true if and only if the receiver is a TAP controller state.
+func (s JTAGState) Valid() bool {
+	return s < jtagStateCount
+}
+
+// Stable returns true if and only if the TAP controller can remain in the
+// receiver state indefinitely while TCK is clocked, i.e. Test-Logic-Reset,
+// Run-Test/Idle, Shift-DR, Pause-DR, Shift-IR, or Pause-IR.
+func (s JTAGState) Stable() bool {
+	return s.Valid() && (s == jtagNext[s][0] || s == jtagNext[s][1])
+}
+
+// Next returns the state the TAP controller enters from the receiver state on
+// the rising edge of TCK with the given level of TMS.
+func (s JTAGState) Next(tms bool) JTAGState {
+	if !s.Valid() {
+		return s
+	}
+	if tms {
+		return jtagNext[s][1]
+	}
+	return jtagNext[s][0]
+}
+
+// Path returns the shortest sequence of TMS levels moving the TAP controller
+// from the receiver state to the given state, as n bits (0-8) of tms clocked
+// LSB first. Returns n = 0 if the states are equal or either state is invalid.
+func (s JTAGState) Path(to JTAGState) (tms uint8, n uint) {
+
+	if !s.Valid() || !to.Valid() || s == to {
+		return 0, 0
+	}
+
+	// breadth-first search, preferring TMS LOW at each step
+	type node struct {
+		tms uint8
+		n   uint
+	}
+	seen := [jtagStateCount]bool{}
+	path := [jtagStateCount]node{}
+	seen[s] = true
+	queue := []JTAGState{s}
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		for b := uint8(0); b < 2; b++ {
+			r := jtagNext[q][b]
+			if seen[r] {
+				continue
+			}
+			seen[r] = true
+			path[r] = node{tms: path[q].tms | b<<path[q].n, n: path[q].n + 1}
+			if r == to {
+				return path[r].tms, path[r].n
+			}
+			queue = append(queue, r)
+		}
+	}
+	return 0, 0
+}
+
+// JTAGConfig holds all of the configuration settings for initializing a JTAG
+// interface.
+type JTAGConfig struct {
+	Clock   uint32 // TCK rate, valid range: 0-30000000 (30 MHz)
+	Latency byte   // 1-255 USB HiSpeed, 2-255 USB FullSpeed
+}
+
+// Constants related to JTAG interface initialization.
+const (
+	JTAGClockMaximum   uint32 = 30000000
+	JTAGClockDefault   uint32 = 1000000
+	JTAGLatencyDefault byte   = 2
+)
+
+// JTAGConfigDefault returns the default configuration settings for a JTAG
+// interface.
+func JTAGConfigDefault() *JTAGConfig {
+	return &JTAGConfig{
+		Clock:   JTAGClockDefault,
+		Latency: JTAGLatencyDefault,
+	}
+}
+
+// String returns a descriptive string of a JTAG configuration.
+func (c *JTAGConfig) String() string {
+	return fmt.Sprintf("{ Clock: \"%d Hz\", Latency: \"%d ms\" }", c.Clock, c.Latency)
+}
+
+// String returns a descriptive string of a JTAG interface.
+func (jtag *JTAG) String() string {
+	return fmt.Sprintf("{ FT232H: %p, Config: %s, State: %q }",
+		jtag.device, jtag.config, jtag.state)
+}
+
+// GetConfig returns the current configuration settings of the JTAG receiver.
+func (jtag *JTAG) GetConfig() *JTAGConfig {
+	cfg := *jtag.config
+	return &cfg
+}
+
+// Constants defining the JTAG pins of port "D".
+const (
+	jtagTCK uint8 = 0x01 // D0
+	jtagTDI uint8 = 0x02 // D1
+	jtagTDO uint8 = 0x04 // D2
+	jtagTMS uint8 = 0x08 // D3
+)
+
+// Config configures the JTAG interface with the given settings, and then
+// initializes the interface (see Init). Zero-valued Clock and Latency use their
+// default values. If cfg is nil, the default configuration is used (see
+// JTAGConfigDefault).
+func (jtag *JTAG) Config(cfg *JTAGConfig) error {
+
+	if nil == cfg {
+		cfg = JTAGConfigDefault()
+	}
+
+	c := *cfg
+	if 0 == c.Clock {
+		c.Clock = JTAGClockDefault
+	} else if c.Clock > JTAGClockMaximum {
+		return fmt.Errorf("invalid clock rate: %d", c.Clock)
+	}
+	if 0 == c.Latency {
+		c.Latency = JTAGLatencyDefault
+	}
+
+	jtag.config = &c
+
+	return jtag.Init()
+}
+
+// Init initializes the JTAG interface to a state ready for use, and then
+// resets the TAP controllers on the scan chain to Test-Logic-Reset (see
+// Reset). If Config has not been called, the default configuration is used
+// (see JTAGConfigDefault).
+func (jtag *JTAG) Init() error {
+
+	eng := jtag.device.info.engine
+
+	div, err := mpsse.Divisor(jtag.config.Clock)
+	if nil != err {
+		return err
+	}
+
+	if err := eng.init(jtag.config.Latency); nil != err {
+		return err
+	}
+
+	// TCK idles LOW, TMS idles HIGH, and TDO is the only input.
+	if _, err := eng.exec(mpsse.ClockDivisor(div),
+		eng.setLow(jtagTMS, jtagTCK|jtagTDI|jtagTMS)); nil != err {
+		return err
+	}
+
+	jtag.device.mode = ModeJTAG
+
+	if err := jtag.device.GPIO.Init(); nil != err { // reset GPIO
+		return err
+	}
+	if err := jtag.device.DGPIO.Init(); nil != err { // reset unreserved port "D" pins
+		return err
+	}
+
+	return jtag.Reset()
+}
+
+// Close closes both the JTAG interface and the connection to the FT232H device.
+func (jtag *JTAG) Close() error {
+	return jtag.device.Close()
+}
+
+// SetClock changes the TCK rate of the initialized JTAG interface.
+func (jtag *JTAG) SetClock(hz uint32) error {
+	if 0 == hz || hz > JTAGClockMaximum {
+		return fmt.Errorf("invalid clock rate: %d", hz)
+	}
+	div, err := mpsse.Divisor(hz)
+	if nil != err {
+		return err
+	}
+	if _, err := jtag.device.info.engine.exec(mpsse.ClockDivisor(div)); nil != err {
+		return err
+	}
+	jtag.config.Clock = hz
+	return nil
+}
+
+// State returns the current state of the TAP controllers, as tracked by the
+// JTAG interface. The tracked state is only valid if all TAP controllers on the
+// scan chain have been reset (see Reset) and every transition since was made
+// through the JTAG interface.
+func (jtag *JTAG) State() JTAGState {
+	return jtag.state
+}
+
+// jtagSeq is a sequence of MPSSE commands clocking the TAP controller, along
+// with the state and TMS level after the sequence is executed.
+type jtagSeq struct {
+	cmd   []mpsse.Command
+	state JTAGState
+	tms   bool
+}
+
+// seq returns a new, empty command sequence beginning at the receiver's
+// current state.
+func (jtag *JTAG) seq() *jtagSeq {
+	return &jtagSeq{
+		cmd:   []mpsse.Command{},
+		state: jtag.state,
+		tms:   0 != jtag.device.info.engine.low&jtagTMS,
+	}
+}
+
+// clockTMS appends the commands clocking the given n bits of seq on TMS, LSB
+// first, while holding TDI at the given level. If read is true, the level of
+// TDO is captured on the final clock, returned in bit 7 of the response of the
+// last command.
+func (s *jtagSeq) clockTMS(seq uint64, n uint, tdi bool, read bool) {
+	for n > 0 {
+		k := n
+		if k > mpsse.MaxTMS {
+			k = mpsse.MaxTMS
+		}
+		s.cmd = append(s.cmd, mpsse.ClockTMS{
+			In:      read && k == n,
+			OutEdge: mpsse.Falling,
+			InEdge:  mpsse.Rising,
+			Len:     int(k),
+			TMS:     uint8(seq & (1<<k - 1)),
+			TDI:     tdi,
+		})
+		for i := uint(0); i < k; i++ {
+			s.tms = 0 != seq&(1<<i)
+			s.state = s.state.Next(s.tms)
+		}
+		seq >>= k
+		n -= k
+	}
+}
+
+// goTo appends the commands moving the TAP controller to the given state.
+func (s *jtagSeq) goTo(to JTAGState) {
+	if tms, n := s.state.Path(to); n > 0 {
+		s.clockTMS(uint64(tms), n, false, false)
+	}
+}
+
+// idle appends the commands clocking TCK the given number of cycles while TMS
+// is held LOW.
+func (s *jtagSeq) idle(cycles uint) {
+	if 0 == cycles {
+		return
+	}
+	if s.tms {
+		s.clockTMS(0, 1, false, false)
+		cycles--
+	}
+	for cycles >= 8 {
+		k := cycles / 8
+		if k > mpsse.MaxBytes {
+			k = mpsse.MaxBytes
+		}
+		s.cmd = append(s.cmd, mpsse.ClockBytes{Len: int(k)})
+		cycles -= k * 8
+	}
+	if cycles > 0 {
+		s.cmd = append(s.cmd, mpsse.ClockBits(cycles))
+	}
+}
+
+// shift appends the commands shifting the given number of bits (at least 1) of
+// tdi, LSB first, through the register selected by the current Shift state.
+// TMS is held LOW for all but the final bit, which moves the TAP controller to
+// the Exit1 state. If read is true, the bits clocked in on TDO are returned in
+// the response, as (bits-1)/8 bytes, followed by 1 byte with the next
+// (bits-1)%8 bits in its high-order bits if non-zero, followed by 1 byte with
+// the final bit in bit 7.
+func (s *jtagSeq) shift(bits uint, tdi []uint8, read bool) {
+
+	data := mpsse.ClockData{
+		Out: true, In: read, LSB: true,
+		OutEdge: mpsse.Falling, InEdge: mpsse.Rising,
+	}
+
+	n := (bits - 1) / 8
+	for i := uint(0); i < n; {
+		k := n - i
+		if k > mpsse.MaxBytes {
+			k = mpsse.MaxBytes
+		}
+		c := data
+		c.Len, c.Data = int(k), tdi[i:i+k]
+		s.cmd = append(s.cmd, c)
+		i += k
+	}
+	if r := (bits - 1) % 8; r > 0 {
+		c := data
+		c.Bits, c.Len, c.Data = true, int(r), []uint8{tdi[n]}
+		s.cmd = append(s.cmd, c)
+	}
+
+	last := 0 != tdi[(bits-1)/8]&(1<<((bits-1)%8))
+	s.clockTMS(1, 1, last, read)
+}
+
+// exec executes the receiver sequence, updating the tracked state of the TAP
+// controller if successful.
+func (jtag *JTAG) exec(s *jtagSeq) ([]uint8, error) {
+
+	if 0 == len(s.cmd) {
+		return []uint8{}, nil
+	}
+	if ModeJTAG != jtag.device.mode {
+		return nil, fmt.Errorf("%s interface not initialized (mode: %s)",
+			ModeJTAG, jtag.device.mode)
+	}
+
+	eng := jtag.device.info.engine
+	resp, err := eng.exec(s.cmd...)
+	if nil != err {
+		return nil, err
+	}
+
+	jtag.state = s.state
+	eng.low &= ^jtagTMS
+	if s.tms {
+		eng.low |= jtagTMS
+	}
+	return resp, nil
+}
+
+// Reset moves the TAP controllers on the scan chain to Test-Logic-Reset from
+// any state by clocking TMS HIGH 5 times.
+func (jtag *JTAG) Reset() error {
+	s := jtag.seq()
+	s.clockTMS(0x1F, 5, false, false)
+	s.state = JTAGTestLogicReset
+	_, err := jtag.exec(s)
+	return err
+}
+
+// GoTo moves the TAP controller from its current state to the given state
+// along the shortest path.
+func (jtag *JTAG) GoTo(state JTAGState) error {
+	if !state.Valid() {
+		return fmt.Errorf("invalid TAP state: %d", state)
+	}
+	s := jtag.seq()
+	s.goTo(state)
+	_, err := jtag.exec(s)
+	return err
+}
+
+// TMS clocks the given n (0-64) bits of seq on TMS, LSB first, while holding
+// TDI LOW.
+func (jtag *JTAG) TMS(seq uint64, n uint) error {
+	if n > 64 {
+		return fmt.Errorf("invalid TMS sequence length: %d (0-64)", n)
+	}
+	s := jtag.seq()
+	s.clockTMS(seq, n, false, false)
+	_, err := jtag.exec(s)
+	return err
+}
+
+// Idle moves the TAP controller to Run-Test/Idle, and then clocks TCK the given
+// number of cycles while remaining in Run-Test/Idle.
+func (jtag *JTAG) Idle(cycles uint) error {
+	s := jtag.seq()
+	s.goTo(JTAGRunTestIdle)
+	s.idle(cycles)
+	_, err := jtag.exec(s)
+	return err
+}
+
+// scan shifts the given number of bits of tdi through the instruction register
+// (if ir is true) or the data register, and then moves the TAP controller to
+// the given end state. If read is true, the bits clocked in on TDO are
+// returned.
+func (jtag *JTAG) scan(ir bool, bits uint, tdi []uint8, end JTAGState, read bool) ([]uint8, error) {
+
+	size := (bits + 7) / 8
+	switch {
+	case 0 == bits:
+		return nil, fmt.Errorf("invalid register length: %d", bits)
+	case nil == tdi:
+		tdi = make([]uint8, size)
+	case uint(len(tdi)) < size:
+		return nil, fmt.Errorf("insufficient data for %d bits: %d bytes", bits, len(tdi))
+	}
+	if !end.Valid() {
+		return nil, fmt.Errorf("invalid TAP state: %d", end)
+	}
+
+	shift := JTAGShiftDR
+	if ir {
+		shift = JTAGShiftIR
+	}
+
+	s := jtag.seq()
+	s.goTo(shift)
+	s.shift(bits, tdi, read)
+	s.goTo(end)
+
+	resp, err := jtag.exec(s)
+	if nil != err || !read {
+		return nil, err
+	}
+
+	// unpack the bytes and bits clocked in (see jtagSeq.shift)
+	tdo := make([]uint8, size)
+	n, r := (bits-1)/8, (bits-1)%8
+	copy(tdo, resp[:n])
+	if r > 0 {
+		tdo[n] = resp[n] >> (8 - r)
+		resp = resp[1:]
+	}
+	tdo[n] |= (resp[n] >> 7) << r
+
+	return tdo, nil
+}
+
+// ShiftIR shifts the given number of bits of tdi, LSB first (bit 0 of tdi[0]
+// first), through the instruction registers of the scan chain, and then moves
+// the TAP controller to the given end state (e.g. Run-Test/Idle or Pause-IR).
+// The bits shifted out on TDO are returned in the same order. If tdi is nil,
+// all bits shifted in are 0.
+func (jtag *JTAG) ShiftIR(bits uint, tdi []uint8, end JTAGState) ([]uint8, error) {
+	return jtag.scan(true, bits, tdi, end, true)
+}
+
+// ShiftDR shifts the given number of bits of tdi, LSB first (bit 0 of tdi[0]
+// first), through the data registers of the scan chain, and then moves the TAP
+// controller to the given end state (e.g. Run-Test/Idle or Pause-DR).
+// The bits shifted out on TDO are returned in the same order. If tdi is nil,
+// all bits shifted in are 0.
+func (jtag *JTAG) ShiftDR(bits uint, tdi []uint8, end JTAGState) ([]uint8, error) {
+	return jtag.scan(false, bits, tdi, end, true)
+}
+
+// WriteIR is equivalent to ShiftIR, but does not read the bits shifted out.
+func (jtag *JTAG) WriteIR(bits uint, tdi []uint8, end JTAGState) error {
+	_, err := jtag.scan(true, bits, tdi, end, false)
+	return err
+}
+
+// WriteDR is equivalent to ShiftDR, but does not read the bits shifted out.
+func (jtag *JTAG) WriteDR(bits uint, tdi []uint8, end JTAGState) error {
+	_, err := jtag.scan(false, bits, tdi, end, false)
+	return err
+}
+
+// JTAGDevice describes a TAP found on the scan chain by JTAG.Scan.
+type JTAGDevice struct {
+	IDCode uint32 // 0 if the device has no IDCODE register
+	IRLen  uint   // 0 if it could not be determined
+}
+
+// Manufacturer returns the JEDEC manufacturer identity code (bits 11:1) of the
+// device IDCODE.
+func (d JTAGDevice) Manufacturer() uint16 {
+	return uint16(d.IDCode>>1) & 0x7FF
+}
+
+// Part returns the part number (bits 27:12) of the device IDCODE.
+func (d JTAGDevice) Part() uint16 {
+	return uint16(d.IDCode >> 12)
+}
+
+// Version returns the version (bits 31:28) of the device IDCODE.
+func (d JTAGDevice) Version() uint8 {
+	return uint8(d.IDCode >> 28)
+}
+
+// String returns a descriptive string of the device.
+func (d JTAGDevice) String() string {
+	return fmt.Sprintf("{ IDCode: 0x%08X, IRLen: %d }", d.IDCode, d.IRLen)
+}
+
+// Limits on the scan chain enumerated by JTAG.Scan.
+const (
+	JTAGScanMaxDevices = 32   // maximum number of devices on the scan chain
+	JTAGScanMaxIR      = 1024 // maximum total length of all instruction registers
+)
+
+// Scan enumerates the devices on the scan chain, returning them in order from
+// the device nearest TDO (whose registers are shifted out first) to the device
+// nearest TDI. The TAP controllers are reset before and after the scan.
+//
+// Following reset, each device selects either its 32-bit IDCODE register or
+// its 1-bit BYPASS register (which captures 0), so the IDCODE of each device
+// is read by shifting the data registers. The total length of the instruction
+// registers is found by filling them with 1s and counting the bits shifted
+// before the 0s that follow emerge on TDO. Since each instruction register
+// captures 1 and 0 in its two least-significant bits, the length of each
+// register is determined from the bits captured, if unambiguous; otherwise,
+// IRLen of every device is 0. The instruction registers are left filled with
+// 1s (BYPASS) before the final reset.
+func (jtag *JTAG) Scan() ([]JTAGDevice, error) {
+
+	if err := jtag.Reset(); nil != err {
+		return nil, err
+	}
+
+	// read IDCODE/BYPASS registers, followed by the 1s shifted in.
+	bits := uint(32 * (JTAGScanMaxDevices + 1))
+	tdi := make([]uint8, bits/8)
+	jtagFill(tdi, 0, bits)
+	tdo, err := jtag.ShiftDR(bits, tdi, JTAGRunTestIdle)
+	if nil != err {
+		return nil, err
+	}
+	dev := []JTAGDevice{}
+	for i := uint(0); ; {
+		if i+32 > bits {
+			return nil, fmt.Errorf("JTAG chain too long or TDO stuck LOW")
+		}
+		if !jtagBit(tdo, i) {
+			dev = append(dev, JTAGDevice{}) // BYPASS
+			i++
+			continue
+		}
+		id := uint32(0)
+		for k := uint(0); k < 32; k++ {
+			if jtagBit(tdo, i+k) {
+				id |= 1 << k
+			}
+		}
+		if 0xFFFFFFFF == id {
+			break
+		}
+		dev = append(dev, JTAGDevice{IDCode: id})
+		i += 32
+	}
+	if 0 == len(dev) {
+		return nil, fmt.Errorf("no devices found on JTAG chain (TDO stuck HIGH?)")
+	}
+
+	// shift 1s, then 0s, then 1s through the instruction registers.
+	const k = JTAGScanMaxIR
+	tdi = make([]uint8, 3*k/8)
+	jtagFill(tdi, 0, k)
+	jtagFill(tdi, 2*k, 3*k)
+	tdo, err = jtag.ShiftIR(3*k, tdi, JTAGRunTestIdle)
+	if nil != err {
+		return nil, err
+	}
+	total := uint(0)
+	for total < k && jtagBit(tdo, k+total) {
+		total++
+	}
+	if total >= k {
+		return nil, fmt.Errorf("JTAG instruction registers too long or TDO stuck HIGH")
+	}
+	if total < 2*uint(len(dev)) {
+		return nil, fmt.Errorf("invalid total instruction register length: %d", total)
+	}
+	start := []uint{}
+	for i := uint(0); i+1 < total; i++ {
+		if jtagBit(tdo, i) && !jtagBit(tdo, i+1) {
+			start = append(start, i)
+		}
+	}
+	switch {
+	case 1 == len(dev):
+		dev[0].IRLen = total
+	case len(start) == len(dev) && 0 == start[0]:
+		for i := range dev {
+			end := total
+			if i+1 < len(start) {
+				end = start[i+1]
+			}
+			dev[i].IRLen = end - start[i]
+		}
+	}
+
+	if err := jtag.Reset(); nil != err {
+		return nil, err
+	}
+
+	return dev, nil
+}
+
+// jtagFill sets bits from (inclusive) through to (exclusive) in data, LSB
+// first.
+func jtagFill(data []uint8, from uint, to uint) {
+	for i := from; i < to; i++ {
+		data[i/8] |= 1 << (i % 8)
+	}
+}
+
+// jtagBit returns the level of the given bit in data, LSB first.
+func jtagBit(data []uint8, bit uint) bool {
+	return 0 != data[bit/8]&(1<<(bit%8))
+}
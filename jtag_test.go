@@ -0,0 +1,310 @@
+package ft232h
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJTAGState(t *testing.T) {
+
+	for s := JTAGState(0); s < jtagStateCount; s++ {
+		r := s
+		for i := 0; i < 5; i++ {
+			r = r.Next(true)
+		}
+		if JTAGTestLogicReset != r {
+			t.Fatalf("Next(%s)={%s}, expected={%s}", s, r, JTAGTestLogicReset)
+		}
+		for to := JTAGState(0); to < jtagStateCount; to++ {
+			tms, n := s.Path(to)
+			if (0 == n) != (s == to) || n > 8 {
+				t.Fatalf("Path(%s, %s)={%d bits}", s, to, n)
+			}
+			r := s
+			for i := uint(0); i < n; i++ {
+				r = r.Next(0 != tms&(1<<i))
+			}
+			if to != r {
+				t.Fatalf("Path(%s, %s)={%0*b}, reached={%s}", s, to, n, tms, r)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		from, to JTAGState
+		tms      uint8
+		n        uint
+	}{
+		{JTAGTestLogicReset, JTAGRunTestIdle, 0b0, 1},
+		{JTAGRunTestIdle, JTAGShiftDR, 0b001, 3},
+		{JTAGRunTestIdle, JTAGShiftIR, 0b0011, 4},
+		{JTAGExit1DR, JTAGRunTestIdle, 0b01, 2},
+		{JTAGPauseIR, JTAGShiftIR, 0b01, 2},
+		{JTAGShiftIR, JTAGShiftDR, 0b00111, 5},
+	} {
+		if tms, n := tc.from.Path(tc.to); tc.tms != tms || tc.n != n {
+			t.Fatalf("Path(%s, %s)={%0*b}, expected={%0*b}", tc.from, tc.to, n, tms, tc.n, tc.tms)
+		}
+	}
+
+	stable := 0
+	for s := JTAGState(0); s < jtagStateCount+1; s++ {
+		if s.Stable() {
+			stable++
+		}
+	}
+	if 6 != stable || !JTAGPauseDR.Stable() || JTAGUpdateIR.Stable() {
+		t.Fatalf("Stable()={%d states}, expected={%d states}", stable, 6)
+	}
+}
+
+// openJTAG opens a simulated FT232H with the given scan chain attached, and
+// initializes its JTAG interface.
+func openJTAG(t *testing.T, chain SimJTAG) (*FT232H, *SimDevice) {
+	var dev *SimDevice
+	ft, err := OpenSim("jtag", func(d *SimDevice) error {
+		dev = d
+		d.AttachJTAG(chain)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	if err := ft.JTAG.Init(); nil != err {
+		t.Fatalf("Init(): %v", err)
+	}
+	return ft, dev
+}
+
+func TestJTAG(t *testing.T) {
+
+	tap := &SimTAP{IRLen: 4, CapIR: 0b0101, IDCode: 0x4BA00477, DRLen: 100}
+	ft, _ := openJTAG(t, SimChain{tap})
+	defer ft.Close()
+
+	if ModeJTAG != ft.mode || JTAGTestLogicReset != ft.JTAG.State() || JTAGTestLogicReset != tap.State {
+		t.Fatalf("Init()={%s, %s}, expected={%s, %s}",
+			ft.mode, ft.JTAG.State(), ModeJTAG, JTAGTestLogicReset)
+	}
+
+	t.Run("Navigate", func(t *testing.T) {
+		for _, s := range []JTAGState{
+			JTAGShiftDR, JTAGPauseIR, JTAGRunTestIdle, JTAGExit2DR, JTAGTestLogicReset,
+		} {
+			if err := ft.JTAG.GoTo(s); nil != err {
+				t.Fatalf("GoTo(%s): %v", s, err)
+			}
+			if s != ft.JTAG.State() || s != tap.State {
+				t.Fatalf("GoTo()={%s, %s}, expected={%s}", ft.JTAG.State(), tap.State, s)
+			}
+		}
+		if err := ft.JTAG.TMS(0b0110, 4); nil != err { // Run-Test/Idle, Select-DR, Select-IR, Capture-IR
+			t.Fatalf("TMS(): %v", err)
+		}
+		if JTAGCaptureIR != ft.JTAG.State() || JTAGCaptureIR != tap.State {
+			t.Fatalf("TMS()={%s, %s}, expected={%s}", ft.JTAG.State(), tap.State, JTAGCaptureIR)
+		}
+		tap.Idle = 0
+		for _, n := range []uint{0, 5, 1000} {
+			if err := ft.JTAG.Idle(n); nil != err {
+				t.Fatalf("Idle(%d): %v", n, err)
+			}
+		}
+		if 1005 != tap.Idle || JTAGRunTestIdle != tap.State {
+			t.Fatalf("Idle()={%d, %s}, expected={%d, %s}", tap.Idle, tap.State, 1005, JTAGRunTestIdle)
+		}
+	})
+
+	t.Run("Shift", func(t *testing.T) {
+		if err := ft.JTAG.Reset(); nil != err {
+			t.Fatalf("Reset(): %v", err)
+		}
+		id, err := ft.JTAG.ShiftDR(32, nil, JTAGRunTestIdle)
+		if nil != err {
+			t.Fatalf("ShiftDR(): %v", err)
+		}
+		if exp := LSB.Bytes(4, uint64(tap.IDCode)); !bytes.Equal(exp, id) {
+			t.Fatalf("ShiftDR()={% X}, expected={% X}", id, exp)
+		}
+		ir, err := ft.JTAG.ShiftIR(4, []uint8{uint8(SimTAPDATA)}, JTAGPauseIR)
+		if nil != err {
+			t.Fatalf("ShiftIR(): %v", err)
+		}
+		if 0b0101 != ir[0] || JTAGPauseIR != tap.State || SimTAPIDCODE != tap.IR {
+			t.Fatalf("ShiftIR()={%04b, %s}, expected={%04b, %s}", ir[0], tap.State, 0b0101, JTAGPauseIR)
+		}
+		if err := ft.JTAG.GoTo(JTAGRunTestIdle); nil != err || SimTAPDATA != tap.IR {
+			t.Fatalf("GoTo(): %v", err)
+		}
+
+		data := make([]uint8, 13)
+		for i := range data {
+			data[i] = uint8(i*37 + 1)
+		}
+		data[12] &= 0x0F // 100 bits
+		if err := ft.JTAG.WriteDR(100, data, JTAGPauseDR); nil != err {
+			t.Fatalf("WriteDR(): %v", err)
+		}
+		if JTAGPauseDR != tap.State {
+			t.Fatalf("WriteDR()={%s}, expected={%s}", tap.State, JTAGPauseDR)
+		}
+		for _, bits := range []uint{100, 1, 8, 9} {
+			// data register is rotated by each shift
+			tdo, err := ft.JTAG.ShiftDR(100, data, JTAGRunTestIdle)
+			if nil != err {
+				t.Fatalf("ShiftDR(): %v", err)
+			}
+			if !bytes.Equal(data, tdo) {
+				t.Fatalf("ShiftDR()={% X}, expected={% X}", tdo, data)
+			}
+			// shift the first bits of the register out separately
+			part := make([]uint8, (bits+7)/8)
+			if tdo, err = ft.JTAG.ShiftDR(bits, part, JTAGRunTestIdle); nil != err {
+				t.Fatalf("ShiftDR(%d): %v", bits, err)
+			}
+			exp := append([]uint8{}, data[:len(part)]...)
+			if r := bits % 8; r > 0 {
+				exp[len(exp)-1] &= 1<<r - 1
+			}
+			if !bytes.Equal(exp, tdo) {
+				t.Fatalf("ShiftDR(%d)={% X}, expected={% X}", bits, tdo, exp)
+			}
+			if err := ft.JTAG.WriteDR(100, data, JTAGRunTestIdle); nil != err {
+				t.Fatalf("WriteDR(): %v", err)
+			}
+		}
+
+		for _, err := range []error{
+			ft.JTAG.WriteDR(0, nil, JTAGRunTestIdle),
+			ft.JTAG.WriteDR(9, []uint8{0}, JTAGRunTestIdle),
+			ft.JTAG.WriteIR(4, nil, jtagStateCount),
+			ft.JTAG.GoTo(jtagStateCount),
+			ft.JTAG.TMS(0, 65),
+		} {
+			if nil == err {
+				t.Fatalf("expected error")
+			}
+		}
+	})
+
+	t.Run("DGPIO", func(t *testing.T) {
+		if 0x0F != ft.DGPIO.Reserved() {
+			t.Fatalf("Reserved()={0x%02X}, expected={0x%02X}", ft.DGPIO.Reserved(), 0x0F)
+		}
+		if err := ft.DGPIO.Set(D(3), true); nil == err {
+			t.Fatalf("Set(%s): expected error", D(3))
+		}
+		if err := ft.JTAG.GoTo(JTAGRunTestIdle); nil != err {
+			t.Fatalf("GoTo(): %v", err)
+		}
+		if err := ft.DGPIO.Set(D(4), true); nil != err {
+			t.Fatalf("Set(%s): %v", D(4), err)
+		}
+		// TMS must remain LOW while idling after changing port "D" pins
+		tap.Idle = 0
+		if err := ft.JTAG.Idle(16); nil != err || 16 != tap.Idle || JTAGRunTestIdle != tap.State {
+			t.Fatalf("Idle()={%d, %s}, expected={%d, %s}", tap.Idle, tap.State, 16, JTAGRunTestIdle)
+		}
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		if err := ft.JTAG.SetClock(6000000); nil != err || 6000000 != ft.JTAG.GetConfig().Clock {
+			t.Fatalf("SetClock(): %v", err)
+		}
+		if err := ft.JTAG.SetClock(JTAGClockMaximum + 1); nil == err {
+			t.Fatalf("SetClock(): expected error")
+		}
+		if err := ft.JTAG.Config(&JTAGConfig{Clock: JTAGClockMaximum + 1}); nil == err {
+			t.Fatalf("Config(): expected error")
+		}
+	})
+
+	if err := ft.SPI.Init(); nil != err {
+		t.Fatalf("SPI.Init(): %v", err)
+	}
+	if err := ft.JTAG.Reset(); nil == err {
+		t.Fatalf("Reset(): expected error in %s mode", ModeSPI)
+	}
+}
+
+func TestJTAGScan(t *testing.T) {
+
+	for _, tc := range []struct {
+		name  string
+		chain SimChain
+		exp   []JTAGDevice // TDO first
+	}{
+		{
+			name: "single",
+			chain: SimChain{
+				{IRLen: 6, CapIR: 0b010001, IDCode: 0x0362D093},
+			},
+			exp: []JTAGDevice{{IDCode: 0x0362D093, IRLen: 6}},
+		},
+		{
+			name: "chain",
+			chain: SimChain{
+				{IRLen: 4, CapIR: 0b0001, IDCode: 0x4BA00477},
+				{IRLen: 5, CapIR: 0b00001},
+				{IRLen: 6, CapIR: 0b000001, IDCode: 0x0362D093},
+			},
+			exp: []JTAGDevice{
+				{IDCode: 0x0362D093, IRLen: 6},
+				{IDCode: 0, IRLen: 5},
+				{IDCode: 0x4BA00477, IRLen: 4},
+			},
+		},
+		{
+			name: "ambiguous",
+			chain: SimChain{
+				{IRLen: 4, CapIR: 0b0101, IDCode: 0x4BA00477},
+				{IRLen: 4, CapIR: 0b0001, IDCode: 0x06413041},
+			},
+			exp: []JTAGDevice{
+				{IDCode: 0x06413041},
+				{IDCode: 0x4BA00477},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ft, _ := openJTAG(t, tc.chain)
+			defer ft.Close()
+			dev, err := ft.JTAG.Scan()
+			if nil != err {
+				t.Fatalf("Scan(): %v", err)
+			}
+			if len(tc.exp) != len(dev) {
+				t.Fatalf("Scan()={%v}, expected={%v}", dev, tc.exp)
+			}
+			for i := range dev {
+				if tc.exp[i] != dev[i] {
+					t.Fatalf("Scan()={%v}, expected={%v}", dev, tc.exp)
+				}
+			}
+			for _, tap := range tc.chain {
+				if JTAGTestLogicReset != tap.State || (0 != tap.IDCode && SimTAPIDCODE != tap.IR) {
+					t.Fatalf("Scan(): TAP not reset: %s, IR=0x%X", tap.State, tap.IR)
+				}
+			}
+		})
+	}
+
+	if d := (JTAGDevice{IDCode: 0x4BA00477}); 0x23B != d.Manufacturer() || 0xBA00 != d.Part() || 4 != d.Version() {
+		t.Fatalf("JTAGDevice={0x%03X, 0x%04X, %d}, expected={0x%03X, 0x%04X, %d}",
+			d.Manufacturer(), d.Part(), d.Version(), 0x23B, 0xBA00, 4)
+	}
+
+	// TDO stuck HIGH (no chain attached)
+	ft, err := OpenSim("jtag", nil)
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+	if err := ft.JTAG.Init(); nil != err {
+		t.Fatalf("Init(): %v", err)
+	}
+	ft.info.port.(*SimDevice).AttachJTAG(SimChain{})
+	if _, err := ft.JTAG.Scan(); nil == err {
+		t.Fatalf("Scan(): expected error for empty chain")
+	}
+}
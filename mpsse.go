@@ -0,0 +1,217 @@
+package ft232h
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// engine drives the MPSSE of an opened device by encoding commands (see package
+// mpsse) and exchanging them with the device Port. It also tracks the most
+// recently written direction and level of the pins on both ports, since MPSSE
+// can only set all 8 pins of a port at once.
+// If the Port implements PortChannel, the engine instead forwards the GPIO pin
+// configuration to the Port and rejects all MPSSE commands.
+type engine struct {
+	port    Port
+	channel PortChannel // non-nil if the Port provides GPIO, SPI, and I²C
+	low     uint8       // port "D" pin levels
+	lowDir  uint8       // port "D" pin directions
+	high    uint8       // port "C" pin levels
+	highDir uint8       // port "C" pin directions
+	serial  bool        // MPSSE disabled for UART mode
+}
+
+// Constants related to MPSSE engine initialization.
+const (
+	engineLatencyDefault byte = 2 // latency timer (ms) used when device is opened
+)
+
+// newEngine constructs a new MPSSE engine communicating with the given Port.
+func newEngine(port Port) *engine {
+	e := &engine{port: port}
+	if c, ok := port.(PortChannel); ok {
+		e.channel = c
+	}
+	return e
+}
+
+// init resets the device and enables the MPSSE with the given latency timer.
+// The MPSSE clock and all pins are reset to their default configuration: clock
+// divide-by-5, adaptive clocking, 3-phase clocking, and loopback are all
+// disabled, and all pins are inputs.
+// Returns a non-nil error if the device could not be reset or the MPSSE does
+// not respond to commands, or the Port implements PortChannel.
+func (e *engine) init(latency uint8) error {
+
+	if nil != e.channel {
+		return ErrNoMPSSE
+	}
+
+	if err := e.port.Reset(); nil != err {
+		return err
+	}
+	if err := e.port.Purge(); nil != err {
+		return err
+	}
+	if err := e.port.SetLatency(latency); nil != err {
+		return err
+	}
+	if err := e.port.SetBitMode(0, BitModeReset); nil != err {
+		return err
+	}
+	if err := e.port.SetBitMode(0, BitModeMPSSE); nil != err {
+		return err
+	}
+	e.serial = false
+	if err := e.sync(); nil != err {
+		return err
+	}
+
+	e.low, e.lowDir, e.high, e.highDir = 0, 0, 0, 0
+
+	_, err := e.exec(
+		mpsse.DivideBy5(false),
+		mpsse.AdaptiveClock(false),
+		mpsse.ThreePhase(false),
+		mpsse.Loopback(false),
+	)
+	return err
+}
+
+// initSerial resets the device and disables the MPSSE, placing the device in
+// asynchronous serial (UART) mode with the given latency timer. All subsequent
+// MPSSE commands are rejected until the MPSSE is re-enabled with init.
+func (e *engine) initSerial(latency uint8) error {
+
+	if err := e.port.Reset(); nil != err {
+		return err
+	}
+	if err := e.port.Purge(); nil != err {
+		return err
+	}
+	if err := e.port.SetLatency(latency); nil != err {
+		return err
+	}
+	if err := e.port.SetBitMode(0, BitModeReset); nil != err {
+		return err
+	}
+	e.serial = true
+	return nil
+}
+
+// sync verifies the MPSSE is accepting commands by sending an invalid opcode
+// and waiting for the bad command response, which echoes the invalid opcode.
+// Returns a non-nil error if the expected response was not received.
+func (e *engine) sync() error {
+
+	bad := uint8(mpsse.OpBadCommandExample)
+	if _, err := e.port.Write([]uint8{bad}); nil != err {
+		return err
+	}
+
+	resp := make([]uint8, 2)
+	if _, err := e.port.Read(resp); nil != err {
+		return err
+	}
+	if uint8(mpsse.OpBadCommandReply) != resp[0] || bad != resp[1] {
+		return fmt.Errorf("failed to synchronize MPSSE: {% X}", resp)
+	}
+	return nil
+}
+
+// exec encodes the given commands into a single buffer and writes it to the
+// device. If any of the commands produce a response, the device is instructed
+// to flush its buffer, and the response is read and returned.
+// Returns the response and a non-nil error if any command is malformed or the
+// device could not be written or read.
+func (e *engine) exec(cmd ...mpsse.Command) ([]uint8, error) {
+
+	if nil != e.channel {
+		return nil, ErrNoMPSSE
+	}
+	if e.serial {
+		return nil, fmt.Errorf("MPSSE not available in %s mode", ModeUART)
+	}
+
+	buf, err := mpsse.Encode(cmd...)
+	if nil != err {
+		return nil, err
+	}
+
+	n := mpsse.ResponseLen(cmd...)
+	if n > 0 {
+		buf, _ = mpsse.SendImmediate{}.Encode(buf)
+	}
+
+	if _, err := e.port.Write(buf); nil != err {
+		return nil, err
+	}
+
+	resp := make([]uint8, n)
+	if n > 0 {
+		if _, err := e.port.Read(resp); nil != err {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// setLow returns the command setting the direction dir and level val of all
+// pins on port "D", and records them as the current configuration.
+func (e *engine) setLow(val uint8, dir uint8) mpsse.Command {
+	e.low, e.lowDir = val, dir
+	return mpsse.SetLow{Value: val, Dir: dir}
+}
+
+// setHigh returns the command setting the direction dir and level val of all
+// pins on port "C", and records them as the current configuration.
+func (e *engine) setHigh(val uint8, dir uint8) mpsse.Command {
+	e.high, e.highDir = val, dir
+	return mpsse.SetHigh{Value: val, Dir: dir}
+}
+
+// timeout sets the read timeout of the device Port to the given duration, or
+// restores its default if d is zero. Has no effect if the Port does not
+// implement PortTimeout.
+func (e *engine) timeout(d time.Duration) error {
+	if p, ok := e.port.(PortTimeout); ok {
+		return p.SetReadTimeout(d)
+	}
+	return nil
+}
+
+// readLow reads the level of all pins on port "D", returning 0 and a non-nil
+// error if unsuccessful.
+func (e *engine) readLow() (uint8, error) {
+	resp, err := e.exec(mpsse.GetLow{})
+	if nil != err {
+		return 0, err
+	}
+	return resp[0], nil
+}
+
+// writeHigh sets the direction dir and level val of all pins on port "C",
+// returning a non-nil error if unsuccessful.
+func (e *engine) writeHigh(dir uint8, val uint8) error {
+	if nil != e.channel {
+		e.high, e.highDir = val, dir
+		return e.channel.WriteGPIO(dir, val)
+	}
+	_, err := e.exec(e.setHigh(val, dir))
+	return err
+}
+
+// readHigh reads the level of all pins on port "C", returning 0 and a non-nil
+// error if unsuccessful.
+func (e *engine) readHigh() (uint8, error) {
+	if nil != e.channel {
+		return e.channel.ReadGPIO()
+	}
+	resp, err := e.exec(mpsse.GetHigh{})
+	if nil != err {
+		return 0, err
+	}
+	return resp[0], nil
+}
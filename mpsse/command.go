@@ -0,0 +1,562 @@
+package mpsse
+
+import (
+	"fmt"
+)
+
+// Edge identifies the clock edge on which data is shifted.
+type Edge bool
+
+// Constants defining the clock edges.
+const (
+	Rising  Edge = false // +ve clock edge
+	Falling Edge = true  // -ve clock edge
+)
+
+// edge returns Falling if neg is true, otherwise Rising.
+func edge(neg bool) Edge { return Edge(neg) }
+
+// String returns a descriptive string of the clock edge.
+func (e Edge) String() string {
+	if Falling == e {
+		return "-ve"
+	}
+	return "+ve"
+}
+
+// ClockData clocks data bytes or bits out on TDI/DO and/or in on TDO/DI.
+//
+// In byte mode (Bits false), Len is the number of bytes to shift (1-65536), and
+// if Out is true, Data must contain exactly Len bytes.
+// In bit mode (Bits true), Len is the number of bits to shift (1-8), and if Out
+// is true, Data must contain exactly 1 byte. Bits are shifted out starting at
+// bit 7 if MSB first, or bit 0 if LSB first. Bits shifted in are returned in a
+// single byte, entering at bit 0 and shifting toward bit 7 if MSB first, or
+// entering at bit 7 and shifting toward bit 0 if LSB first.
+type ClockData struct {
+	Out     bool   // clock data out on TDI/DO
+	In      bool   // clock data in on TDO/DI
+	Bits    bool   // Len is given in bits instead of bytes
+	LSB     bool   // shift LSB first instead of MSB first
+	OutEdge Edge   // edge on which data is clocked out
+	InEdge  Edge   // edge on which data is clocked in
+	Len     int    // number of bytes (or bits if Bits) to shift
+	Data    []byte // data to clock out if Out
+}
+
+// Opcode returns the opcode of the data shifting command.
+func (c ClockData) Opcode() Opcode {
+	var op Opcode
+	if c.Out {
+		op |= OpOut
+		if Falling == c.OutEdge {
+			op |= OpOutFalling
+		}
+	}
+	if c.In {
+		op |= OpIn
+		if Falling == c.InEdge {
+			op |= OpInFalling
+		}
+	}
+	if c.Bits {
+		op |= OpBitMode
+	}
+	if c.LSB {
+		op |= OpLSBFirst
+	}
+	return op
+}
+
+// Encode appends the encoded data shifting command to buf.
+func (c ClockData) Encode(buf []byte) ([]byte, error) {
+	if !c.Out && !c.In {
+		return nil, fmt.Errorf("no data direction")
+	}
+	if c.Bits {
+		if c.Len < 1 || c.Len > MaxBits {
+			return nil, fmt.Errorf("invalid bit length: %d", c.Len)
+		}
+		buf = append(buf, uint8(c.Opcode()), uint8(c.Len-1))
+		if c.Out {
+			if 1 != len(c.Data) {
+				return nil, fmt.Errorf("bit mode requires 1 data byte: %d", len(c.Data))
+			}
+			buf = append(buf, c.Data[0])
+		}
+		return buf, nil
+	}
+	if c.Len < 1 || c.Len > MaxBytes {
+		return nil, fmt.Errorf("invalid byte length: %d", c.Len)
+	}
+	buf = append(buf, uint8(c.Opcode()), uint8(c.Len-1), uint8((c.Len-1)>>8))
+	if c.Out {
+		if c.Len != len(c.Data) {
+			return nil, fmt.Errorf("data length (%d) does not match byte length (%d)",
+				len(c.Data), c.Len)
+		}
+		buf = append(buf, c.Data...)
+	}
+	return buf, nil
+}
+
+// Response returns the number of bytes clocked in by the command.
+func (c ClockData) Response() int {
+	switch {
+	case !c.In:
+		return 0
+	case c.Bits:
+		return 1
+	}
+	return c.Len
+}
+
+// String returns a descriptive string of the data shifting command.
+func (c ClockData) String() string {
+	unit, order := "bytes", "MSB"
+	if c.Bits {
+		unit = "bits"
+	}
+	if c.LSB {
+		order = "LSB"
+	}
+	dir := ""
+	if c.Out {
+		dir += fmt.Sprintf(" Out(%s)", c.OutEdge)
+	}
+	if c.In {
+		dir += fmt.Sprintf(" In(%s)", c.InEdge)
+	}
+	return fmt.Sprintf("ClockData{%s %s %d %s}", dir[1:], order, c.Len, unit)
+}
+
+// ClockTMS clocks bits out on TMS/CS, while holding TDI/DO at a constant level,
+// and optionally clocks bits in on TDO/DI. Len is the number of bits to shift
+// (1-7), shifted out LSB first from TMS.
+type ClockTMS struct {
+	In      bool  // clock data in on TDO/DI
+	OutEdge Edge  // edge on which data is clocked out
+	InEdge  Edge  // edge on which data is clocked in
+	Len     int   // number of bits to shift
+	TMS     uint8 // bits to shift out on TMS/CS (bits 0-6)
+	TDI     bool  // level of TDI/DO held during the command
+}
+
+// Opcode returns the opcode of the TMS shifting command.
+func (c ClockTMS) Opcode() Opcode {
+	op := OpTMS | OpBitMode | OpLSBFirst
+	if Falling == c.OutEdge {
+		op |= OpOutFalling
+	}
+	if c.In {
+		op |= OpIn
+		if Falling == c.InEdge {
+			op |= OpInFalling
+		}
+	}
+	return op
+}
+
+// Encode appends the encoded TMS shifting command to buf.
+func (c ClockTMS) Encode(buf []byte) ([]byte, error) {
+	if c.Len < 1 || c.Len > MaxTMS {
+		return nil, fmt.Errorf("invalid TMS bit length: %d", c.Len)
+	}
+	b := c.TMS & 0x7F
+	if c.TDI {
+		b |= 0x80
+	}
+	return append(buf, uint8(c.Opcode()), uint8(c.Len-1), b), nil
+}
+
+// Response returns the number of bytes clocked in by the command.
+func (c ClockTMS) Response() int {
+	if c.In {
+		return 1
+	}
+	return 0
+}
+
+// String returns a descriptive string of the TMS shifting command.
+func (c ClockTMS) String() string {
+	in := ""
+	if c.In {
+		in = fmt.Sprintf(" In(%s)", c.InEdge)
+	}
+	return fmt.Sprintf("ClockTMS{Out(%s)%s %d bits TMS=%0*b TDI=%t}",
+		c.OutEdge, in, c.Len, c.Len, c.TMS&(1<<uint(c.Len)-1), c.TDI)
+}
+
+// SetLow sets the value and direction (1=output) of the low byte pins (ADBUS,
+// port "D" of FT232H).
+type SetLow struct {
+	Value uint8
+	Dir   uint8
+}
+
+// Opcode returns OpSetLow.
+func (c SetLow) Opcode() Opcode { return OpSetLow }
+
+// Encode appends the encoded command to buf.
+func (c SetLow) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(OpSetLow), c.Value, c.Dir), nil
+}
+
+// Response returns 0.
+func (c SetLow) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c SetLow) String() string {
+	return fmt.Sprintf("SetLow{Value=%08b Dir=%08b}", c.Value, c.Dir)
+}
+
+// SetHigh sets the value and direction (1=output) of the high byte pins (ACBUS,
+// port "C" of FT232H).
+type SetHigh struct {
+	Value uint8
+	Dir   uint8
+}
+
+// Opcode returns OpSetHigh.
+func (c SetHigh) Opcode() Opcode { return OpSetHigh }
+
+// Encode appends the encoded command to buf.
+func (c SetHigh) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(OpSetHigh), c.Value, c.Dir), nil
+}
+
+// Response returns 0.
+func (c SetHigh) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c SetHigh) String() string {
+	return fmt.Sprintf("SetHigh{Value=%08b Dir=%08b}", c.Value, c.Dir)
+}
+
+// GetLow reads the current level of the low byte pins (ADBUS, port "D").
+type GetLow struct{}
+
+// Opcode returns OpGetLow.
+func (c GetLow) Opcode() Opcode { return OpGetLow }
+
+// Encode appends the encoded command to buf.
+func (c GetLow) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(OpGetLow)), nil
+}
+
+// Response returns 1.
+func (c GetLow) Response() int { return 1 }
+
+// String returns a descriptive string of the command.
+func (c GetLow) String() string { return "GetLow{}" }
+
+// GetHigh reads the current level of the high byte pins (ACBUS, port "C").
+type GetHigh struct{}
+
+// Opcode returns OpGetHigh.
+func (c GetHigh) Opcode() Opcode { return OpGetHigh }
+
+// Encode appends the encoded command to buf.
+func (c GetHigh) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(OpGetHigh)), nil
+}
+
+// Response returns 1.
+func (c GetHigh) Response() int { return 1 }
+
+// String returns a descriptive string of the command.
+func (c GetHigh) String() string { return "GetHigh{}" }
+
+// Loopback connects (true) or disconnects (false) TDI/DO to TDO/DI internally.
+type Loopback bool
+
+// Opcode returns OpLoopbackOn or OpLoopbackOff.
+func (c Loopback) Opcode() Opcode {
+	if c {
+		return OpLoopbackOn
+	}
+	return OpLoopbackOff
+}
+
+// Encode appends the encoded command to buf.
+func (c Loopback) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(c.Opcode())), nil
+}
+
+// Response returns 0.
+func (c Loopback) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c Loopback) String() string { return fmt.Sprintf("Loopback{%t}", bool(c)) }
+
+// ClockDivisor sets the divisor of the TCK/SK clock. See Frequency and Divisor.
+type ClockDivisor uint16
+
+// Opcode returns OpClockDivisor.
+func (c ClockDivisor) Opcode() Opcode { return OpClockDivisor }
+
+// Encode appends the encoded command to buf.
+func (c ClockDivisor) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(OpClockDivisor), uint8(c), uint8(c>>8)), nil
+}
+
+// Response returns 0.
+func (c ClockDivisor) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c ClockDivisor) String() string {
+	return fmt.Sprintf("ClockDivisor{%d (%d Hz)}", uint16(c), c.Frequency(false))
+}
+
+// Constants defining the MPSSE master clock frequencies.
+const (
+	MasterClock    uint32 = 60000000 // divide-by-5 disabled
+	MasterClockDiv uint32 = 12000000 // divide-by-5 enabled
+)
+
+// Frequency returns the TCK/SK frequency (in Hz) produced by the clock divisor
+// with divide-by-5 enabled (div5 true) or disabled. The frequency of 3-phase
+// data clocking is 2/3 of the frequency returned.
+func (c ClockDivisor) Frequency(div5 bool) uint32 {
+	master := MasterClock
+	if div5 {
+		master = MasterClockDiv
+	}
+	return master / ((1 + uint32(c)) * 2)
+}
+
+// Divisor returns the clock divisor producing the greatest TCK/SK frequency
+// that is not greater than the given frequency hz, with divide-by-5 disabled.
+// Returns a non-nil error if hz is outside the range of possible frequencies.
+func Divisor(hz uint32) (ClockDivisor, error) {
+	if 0 == hz || hz > MasterClock/2 {
+		return 0, fmt.Errorf("clock frequency out of range: %d Hz", hz)
+	}
+	// round the divisor up so that the result never exceeds hz
+	div := (MasterClock + 2*hz - 1) / (2 * hz)
+	if div > 0x10000 {
+		return 0, fmt.Errorf("clock frequency out of range: %d Hz", hz)
+	}
+	return ClockDivisor(div - 1), nil
+}
+
+// SendImmediate flushes the device's buffer back to the host.
+type SendImmediate struct{}
+
+// Opcode returns OpSendImmediate.
+func (c SendImmediate) Opcode() Opcode { return OpSendImmediate }
+
+// Encode appends the encoded command to buf.
+func (c SendImmediate) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(OpSendImmediate)), nil
+}
+
+// Response returns 0.
+func (c SendImmediate) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c SendImmediate) String() string { return "SendImmediate{}" }
+
+// WaitIO pauses command execution until GPIOL1 is high (High true) or low.
+type WaitIO struct {
+	High bool
+}
+
+// Opcode returns OpWaitIOHigh or OpWaitIOLow.
+func (c WaitIO) Opcode() Opcode {
+	if c.High {
+		return OpWaitIOHigh
+	}
+	return OpWaitIOLow
+}
+
+// Encode appends the encoded command to buf.
+func (c WaitIO) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(c.Opcode())), nil
+}
+
+// Response returns 0.
+func (c WaitIO) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c WaitIO) String() string { return fmt.Sprintf("WaitIO{High=%t}", c.High) }
+
+// DivideBy5 enables (true) or disables (false) the divide-by-5 prescaler of the
+// 60 MHz master clock.
+type DivideBy5 bool
+
+// Opcode returns OpDivideBy5On or OpDivideBy5Off.
+func (c DivideBy5) Opcode() Opcode {
+	if c {
+		return OpDivideBy5On
+	}
+	return OpDivideBy5Off
+}
+
+// Encode appends the encoded command to buf.
+func (c DivideBy5) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(c.Opcode())), nil
+}
+
+// Response returns 0.
+func (c DivideBy5) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c DivideBy5) String() string { return fmt.Sprintf("DivideBy5{%t}", bool(c)) }
+
+// ThreePhase enables (true) or disables (false) 3-phase data clocking, which
+// holds data valid on both clock edges (required for I²C).
+type ThreePhase bool
+
+// Opcode returns OpThreePhaseOn or OpThreePhaseOff.
+func (c ThreePhase) Opcode() Opcode {
+	if c {
+		return OpThreePhaseOn
+	}
+	return OpThreePhaseOff
+}
+
+// Encode appends the encoded command to buf.
+func (c ThreePhase) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(c.Opcode())), nil
+}
+
+// Response returns 0.
+func (c ThreePhase) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c ThreePhase) String() string { return fmt.Sprintf("ThreePhase{%t}", bool(c)) }
+
+// AdaptiveClock enables (true) or disables (false) adaptive clocking, in which
+// each TCK/SK edge waits for the return clock on GPIOL3 (RTCK).
+type AdaptiveClock bool
+
+// Opcode returns OpAdaptiveOn or OpAdaptiveOff.
+func (c AdaptiveClock) Opcode() Opcode {
+	if c {
+		return OpAdaptiveOn
+	}
+	return OpAdaptiveOff
+}
+
+// Encode appends the encoded command to buf.
+func (c AdaptiveClock) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(c.Opcode())), nil
+}
+
+// Response returns 0.
+func (c AdaptiveClock) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c AdaptiveClock) String() string { return fmt.Sprintf("AdaptiveClock{%t}", bool(c)) }
+
+// ClockBits clocks TCK/SK for the given number of bits (1-8) without
+// transferring data.
+type ClockBits uint8
+
+// Opcode returns OpClockBits.
+func (c ClockBits) Opcode() Opcode { return OpClockBits }
+
+// Encode appends the encoded command to buf.
+func (c ClockBits) Encode(buf []byte) ([]byte, error) {
+	if c < 1 || c > MaxBits {
+		return nil, fmt.Errorf("invalid bit length: %d", c)
+	}
+	return append(buf, uint8(OpClockBits), uint8(c-1)), nil
+}
+
+// Response returns 0.
+func (c ClockBits) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c ClockBits) String() string { return fmt.Sprintf("ClockBits{%d}", uint8(c)) }
+
+// ClockBytes clocks TCK/SK for the given number of bytes (Len*8 bits, Len
+// 1-65536) without transferring data. If WaitIO is true, clocking stops early
+// once GPIOL1 is high (High true) or low.
+type ClockBytes struct {
+	Len    int
+	WaitIO bool
+	High   bool
+}
+
+// Opcode returns OpClockBytes, OpClockBytesIOHigh, or OpClockBytesIOLow.
+func (c ClockBytes) Opcode() Opcode {
+	switch {
+	case !c.WaitIO:
+		return OpClockBytes
+	case c.High:
+		return OpClockBytesIOHigh
+	}
+	return OpClockBytesIOLow
+}
+
+// Encode appends the encoded command to buf.
+func (c ClockBytes) Encode(buf []byte) ([]byte, error) {
+	if c.Len < 1 || c.Len > MaxBytes {
+		return nil, fmt.Errorf("invalid byte length: %d", c.Len)
+	}
+	return append(buf, uint8(c.Opcode()), uint8(c.Len-1), uint8((c.Len-1)>>8)), nil
+}
+
+// Response returns 0.
+func (c ClockBytes) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c ClockBytes) String() string {
+	if c.WaitIO {
+		return fmt.Sprintf("ClockBytes{%d WaitIO High=%t}", c.Len, c.High)
+	}
+	return fmt.Sprintf("ClockBytes{%d}", c.Len)
+}
+
+// ClockUntilIO clocks TCK/SK continuously until GPIOL1 is high (High true) or
+// low.
+type ClockUntilIO struct {
+	High bool
+}
+
+// Opcode returns OpClockUntilIOHigh or OpClockUntilIOLow.
+func (c ClockUntilIO) Opcode() Opcode {
+	if c.High {
+		return OpClockUntilIOHigh
+	}
+	return OpClockUntilIOLow
+}
+
+// Encode appends the encoded command to buf.
+func (c ClockUntilIO) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(c.Opcode())), nil
+}
+
+// Response returns 0.
+func (c ClockUntilIO) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c ClockUntilIO) String() string {
+	return fmt.Sprintf("ClockUntilIO{High=%t}", c.High)
+}
+
+// DriveZero configures the low byte (Low) and high byte (High) pins whose bit
+// is set to only drive when outputting 0, and tristate when outputting 1
+// (open-drain). Only available on FT232H.
+type DriveZero struct {
+	Low  uint8
+	High uint8
+}
+
+// Opcode returns OpDriveZero.
+func (c DriveZero) Opcode() Opcode { return OpDriveZero }
+
+// Encode appends the encoded command to buf.
+func (c DriveZero) Encode(buf []byte) ([]byte, error) {
+	return append(buf, uint8(OpDriveZero), c.Low, c.High), nil
+}
+
+// Response returns 0.
+func (c DriveZero) Response() int { return 0 }
+
+// String returns a descriptive string of the command.
+func (c DriveZero) String() string {
+	return fmt.Sprintf("DriveZero{Low=%08b High=%08b}", c.Low, c.High)
+}
@@ -0,0 +1,296 @@
+/*
+Package mpsse models the command set of FTDI's Multi-Protocol Synchronous Serial
+Engine (MPSSE) as typed Go values, and encodes/decodes those values to/from the
+byte streams exchanged with an MPSSE-capable device (e.g., FT232H).
+
+Each command type implements the Command interface. A sequence of commands is
+encoded into a single buffer with Encode, and the number of bytes the device
+will return in response to that buffer is given by ResponseLen. Decode performs
+the inverse of Encode, parsing a byte stream back into typed commands, which is
+useful for inspecting the exact wire traffic generated by a driver.
+
+The command set and opcode values are documented in FTDI application note
+AN_108 "Command Processor for MPSSE and MCU Host Bus Emulation Modes".
+*/
+package mpsse
+
+import (
+	"fmt"
+)
+
+// Opcode is the first byte of an encoded MPSSE command.
+type Opcode uint8
+
+// Bitmasks defining the fields of the data shifting command opcodes (those
+// with bit 7 clear).
+const (
+	OpOutFalling Opcode = 0x01 // clock data out on falling (-ve) edge
+	OpBitMode    Opcode = 0x02 // length is given in bits (1-8), not bytes
+	OpInFalling  Opcode = 0x04 // clock data in on falling (-ve) edge
+	OpLSBFirst   Opcode = 0x08 // shift data LSB first
+	OpOut        Opcode = 0x10 // clock data out on TDI/DO
+	OpIn         Opcode = 0x20 // clock data in on TDO/DI
+	OpTMS        Opcode = 0x40 // clock data out on TMS/CS
+)
+
+// Constants defining the opcodes of all other MPSSE commands.
+const (
+	OpSetLow            Opcode = 0x80 // set data bits low byte
+	OpGetLow            Opcode = 0x81 // read data bits low byte
+	OpSetHigh           Opcode = 0x82 // set data bits high byte
+	OpGetHigh           Opcode = 0x83 // read data bits high byte
+	OpLoopbackOn        Opcode = 0x84 // connect TDI/DO to TDO/DI
+	OpLoopbackOff       Opcode = 0x85 // disconnect TDI/DO from TDO/DI
+	OpClockDivisor      Opcode = 0x86 // set TCK/SK divisor
+	OpSendImmediate     Opcode = 0x87 // flush buffer back to host
+	OpWaitIOHigh        Opcode = 0x88 // wait until GPIOL1 (JTAG) is high
+	OpWaitIOLow         Opcode = 0x89 // wait until GPIOL1 (JTAG) is low
+	OpDivideBy5Off      Opcode = 0x8A // use 60 MHz master clock
+	OpDivideBy5On       Opcode = 0x8B // use 12 MHz master clock
+	OpThreePhaseOn      Opcode = 0x8C // enable 3-phase data clocking
+	OpThreePhaseOff     Opcode = 0x8D // disable 3-phase data clocking
+	OpClockBits         Opcode = 0x8E // clock n bits with no data transfer
+	OpClockBytes        Opcode = 0x8F // clock n*8 bits with no data transfer
+	OpClockUntilIOHigh  Opcode = 0x94 // clock continuously until GPIOL1 high
+	OpClockUntilIOLow   Opcode = 0x95 // clock continuously until GPIOL1 low
+	OpAdaptiveOn        Opcode = 0x96 // enable adaptive clocking
+	OpAdaptiveOff       Opcode = 0x97 // disable adaptive clocking
+	OpClockBytesIOHigh  Opcode = 0x9C // clock n*8 bits or until GPIOL1 high
+	OpClockBytesIOLow   Opcode = 0x9D // clock n*8 bits or until GPIOL1 low
+	OpDriveZero         Opcode = 0x9E // drive only on 0, tristate on 1
+	OpBadCommandReply   Opcode = 0xFA // response to an invalid opcode
+	OpBadCommandExample Opcode = 0xAB // an invalid opcode for sync
+)
+
+// Limits on the length of data shifting commands.
+const (
+	MaxBytes = 65536 // maximum bytes per byte-mode command
+	MaxBits  = 8     // maximum bits per bit-mode command
+	MaxTMS   = 7     // maximum bits per TMS command
+)
+
+// Command is a single MPSSE command.
+type Command interface {
+	// Opcode returns the opcode of the encoded command.
+	Opcode() Opcode
+	// Encode appends the encoded command to the given buffer, returning the
+	// resulting buffer, or a non-nil error if the command is malformed.
+	Encode(buf []byte) ([]byte, error)
+	// Response returns the number of bytes returned by the device in response
+	// to the command.
+	Response() int
+	// String returns a descriptive string of the command.
+	String() string
+}
+
+// Encode encodes the given commands, in order, into a single buffer. Returns a
+// nil buffer and non-nil error if any command is malformed.
+func Encode(cmd ...Command) ([]byte, error) {
+	buf := []byte{}
+	for i, c := range cmd {
+		var err error
+		if buf, err = c.Encode(buf); nil != err {
+			return nil, fmt.Errorf("command %d (%s): %v", i, c, err)
+		}
+	}
+	return buf, nil
+}
+
+// ResponseLen returns the total number of bytes returned by the device in
+// response to the given commands.
+func ResponseLen(cmd ...Command) int {
+	n := 0
+	for _, c := range cmd {
+		n += c.Response()
+	}
+	return n
+}
+
+// Decode parses the given buffer into a slice of commands. Returns the commands
+// successfully decoded and a non-nil error if the buffer contains an invalid
+// opcode or ends before the final command is complete.
+func Decode(buf []byte) ([]Command, error) {
+	cmd := []Command{}
+	for len(buf) > 0 {
+		c, n, err := DecodeOne(buf)
+		if nil != err {
+			return cmd, err
+		}
+		cmd = append(cmd, c)
+		buf = buf[n:]
+	}
+	return cmd, nil
+}
+
+// DecodeOne parses a single command from the beginning of the given buffer,
+// returning the command and number of bytes consumed. Returns a nil command
+// and non-nil error if the buffer begins with an invalid opcode or ends before
+// the command is complete.
+func DecodeOne(buf []byte) (Command, int, error) {
+
+	if 0 == len(buf) {
+		return nil, 0, fmt.Errorf("empty buffer")
+	}
+
+	op := Opcode(buf[0])
+
+	// arg returns the n bytes following the opcode, or an error if the buffer
+	// is too short.
+	arg := func(n int) ([]byte, error) {
+		if len(buf) < 1+n {
+			return nil, fmt.Errorf("incomplete command 0x%02X: need %d bytes, have %d",
+				uint8(op), 1+n, len(buf))
+		}
+		return buf[1 : 1+n], nil
+	}
+
+	switch {
+	case op < 0x80 && op&OpTMS > 0:
+		return decodeTMS(op, arg)
+	case op < 0x80:
+		return decodeData(op, arg)
+	}
+
+	switch op {
+	case OpSetLow, OpSetHigh:
+		a, err := arg(2)
+		if nil != err {
+			return nil, 0, err
+		}
+		if OpSetLow == op {
+			return SetLow{Value: a[0], Dir: a[1]}, 3, nil
+		}
+		return SetHigh{Value: a[0], Dir: a[1]}, 3, nil
+	case OpGetLow:
+		return GetLow{}, 1, nil
+	case OpGetHigh:
+		return GetHigh{}, 1, nil
+	case OpLoopbackOn, OpLoopbackOff:
+		return Loopback(OpLoopbackOn == op), 1, nil
+	case OpClockDivisor:
+		a, err := arg(2)
+		if nil != err {
+			return nil, 0, err
+		}
+		return ClockDivisor(uint16(a[0]) | uint16(a[1])<<8), 3, nil
+	case OpSendImmediate:
+		return SendImmediate{}, 1, nil
+	case OpWaitIOHigh, OpWaitIOLow:
+		return WaitIO{High: OpWaitIOHigh == op}, 1, nil
+	case OpDivideBy5Off, OpDivideBy5On:
+		return DivideBy5(OpDivideBy5On == op), 1, nil
+	case OpThreePhaseOn, OpThreePhaseOff:
+		return ThreePhase(OpThreePhaseOn == op), 1, nil
+	case OpClockBits:
+		a, err := arg(1)
+		if nil != err {
+			return nil, 0, err
+		}
+		if a[0] >= MaxBits {
+			return nil, 0, fmt.Errorf("invalid bit length: %d", a[0]+1)
+		}
+		return ClockBits(a[0] + 1), 2, nil
+	case OpClockBytes, OpClockBytesIOHigh, OpClockBytesIOLow:
+		a, err := arg(2)
+		if nil != err {
+			return nil, 0, err
+		}
+		n := (int(a[0]) | int(a[1])<<8) + 1
+		switch op {
+		case OpClockBytesIOHigh:
+			return ClockBytes{Len: n, WaitIO: true, High: true}, 3, nil
+		case OpClockBytesIOLow:
+			return ClockBytes{Len: n, WaitIO: true, High: false}, 3, nil
+		}
+		return ClockBytes{Len: n}, 3, nil
+	case OpClockUntilIOHigh, OpClockUntilIOLow:
+		return ClockUntilIO{High: OpClockUntilIOHigh == op}, 1, nil
+	case OpAdaptiveOn, OpAdaptiveOff:
+		return AdaptiveClock(OpAdaptiveOn == op), 1, nil
+	case OpDriveZero:
+		a, err := arg(2)
+		if nil != err {
+			return nil, 0, err
+		}
+		return DriveZero{Low: a[0], High: a[1]}, 3, nil
+	}
+
+	return nil, 0, fmt.Errorf("invalid opcode: 0x%02X", uint8(op))
+}
+
+// decodeData parses a data shifting command with the given opcode.
+func decodeData(op Opcode, arg func(int) ([]byte, error)) (Command, int, error) {
+
+	c := ClockData{
+		Out:     op&OpOut > 0,
+		In:      op&OpIn > 0,
+		Bits:    op&OpBitMode > 0,
+		LSB:     op&OpLSBFirst > 0,
+		OutEdge: edge(op&OpOutFalling > 0),
+		InEdge:  edge(op&OpInFalling > 0),
+	}
+
+	if !c.Out && !c.In {
+		return nil, 0, fmt.Errorf("invalid opcode: 0x%02X", uint8(op))
+	}
+
+	if c.Bits {
+		a, err := arg(1)
+		if nil != err {
+			return nil, 0, err
+		}
+		if a[0] >= MaxBits {
+			return nil, 0, fmt.Errorf("invalid bit length: %d", a[0]+1)
+		}
+		c.Len = int(a[0]) + 1
+		if !c.Out {
+			return c, 2, nil
+		}
+		d, err := arg(2)
+		if nil != err {
+			return nil, 0, err
+		}
+		c.Data = []byte{d[1]}
+		return c, 3, nil
+	}
+
+	a, err := arg(2)
+	if nil != err {
+		return nil, 0, err
+	}
+	c.Len = (int(a[0]) | int(a[1])<<8) + 1
+	if !c.Out {
+		return c, 3, nil
+	}
+	d, err := arg(2 + c.Len)
+	if nil != err {
+		return nil, 0, err
+	}
+	c.Data = append([]byte{}, d[2:]...)
+	return c, 3 + c.Len, nil
+}
+
+// decodeTMS parses a TMS shifting command with the given opcode.
+func decodeTMS(op Opcode, arg func(int) ([]byte, error)) (Command, int, error) {
+
+	// TMS commands are always bit mode, LSB first, and never clock out TDI/DO
+	if op&(OpBitMode|OpLSBFirst) != (OpBitMode|OpLSBFirst) || op&OpOut > 0 {
+		return nil, 0, fmt.Errorf("invalid opcode: 0x%02X", uint8(op))
+	}
+
+	a, err := arg(2)
+	if nil != err {
+		return nil, 0, err
+	}
+	if a[0] >= MaxTMS {
+		return nil, 0, fmt.Errorf("invalid TMS bit length: %d", a[0]+1)
+	}
+	return ClockTMS{
+		In:      op&OpIn > 0,
+		OutEdge: edge(op&OpOutFalling > 0),
+		InEdge:  edge(op&OpInFalling > 0),
+		Len:     int(a[0]) + 1,
+		TMS:     a[1] & 0x7F,
+		TDI:     a[1]&0x80 > 0,
+	}, 3, nil
+}
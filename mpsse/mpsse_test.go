@@ -0,0 +1,189 @@
+package mpsse
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+
+	data := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+
+	for _, test := range []struct {
+		name string
+		cmd  Command
+		enc  []byte
+		resp int
+	}{
+		{"write-bytes-mode0", ClockData{Out: true, OutEdge: Falling, Len: 4, Data: data},
+			[]byte{0x11, 0x03, 0x00, 0xDE, 0xAD, 0xBE, 0xEF}, 0},
+		{"read-bytes-mode0", ClockData{In: true, Len: 256},
+			[]byte{0x20, 0xFF, 0x00}, 256},
+		{"swap-bytes-mode0", ClockData{Out: true, In: true, OutEdge: Falling, Len: 1, Data: data[:1]},
+			[]byte{0x31, 0x00, 0x00, 0xDE}, 1},
+		{"swap-bytes-mode1", ClockData{Out: true, In: true, InEdge: Falling, Len: 1, Data: data[:1]},
+			[]byte{0x34, 0x00, 0x00, 0xDE}, 1},
+		{"write-bytes-lsb", ClockData{Out: true, OutEdge: Falling, LSB: true, Len: 1, Data: data[:1]},
+			[]byte{0x19, 0x00, 0x00, 0xDE}, 0},
+		{"write-bytes-max", ClockData{Out: true, Len: MaxBytes, Data: make([]byte, MaxBytes)},
+			append([]byte{0x10, 0xFF, 0xFF}, make([]byte, MaxBytes)...), 0},
+		{"write-bits", ClockData{Out: true, OutEdge: Falling, Bits: true, Len: 3, Data: []byte{0xA0}},
+			[]byte{0x13, 0x02, 0xA0}, 0},
+		{"read-bits", ClockData{In: true, Bits: true, Len: 8},
+			[]byte{0x22, 0x07}, 1},
+		{"tms", ClockTMS{OutEdge: Falling, Len: 5, TMS: 0x1F},
+			[]byte{0x4B, 0x04, 0x1F}, 0},
+		{"tms-read", ClockTMS{In: true, OutEdge: Falling, Len: 1, TMS: 0x01, TDI: true},
+			[]byte{0x6B, 0x00, 0x81}, 1},
+		{"set-low", SetLow{Value: 0x08, Dir: 0x0B}, []byte{0x80, 0x08, 0x0B}, 0},
+		{"set-high", SetHigh{Value: 0x80, Dir: 0xFF}, []byte{0x82, 0x80, 0xFF}, 0},
+		{"get-low", GetLow{}, []byte{0x81}, 1},
+		{"get-high", GetHigh{}, []byte{0x83}, 1},
+		{"loopback-on", Loopback(true), []byte{0x84}, 0},
+		{"loopback-off", Loopback(false), []byte{0x85}, 0},
+		{"divisor", ClockDivisor(0x1234), []byte{0x86, 0x34, 0x12}, 0},
+		{"send-immediate", SendImmediate{}, []byte{0x87}, 0},
+		{"wait-high", WaitIO{High: true}, []byte{0x88}, 0},
+		{"wait-low", WaitIO{High: false}, []byte{0x89}, 0},
+		{"div5-off", DivideBy5(false), []byte{0x8A}, 0},
+		{"div5-on", DivideBy5(true), []byte{0x8B}, 0},
+		{"3phase-on", ThreePhase(true), []byte{0x8C}, 0},
+		{"3phase-off", ThreePhase(false), []byte{0x8D}, 0},
+		{"clock-bits", ClockBits(8), []byte{0x8E, 0x07}, 0},
+		{"clock-bytes", ClockBytes{Len: 2}, []byte{0x8F, 0x01, 0x00}, 0},
+		{"clock-until-high", ClockUntilIO{High: true}, []byte{0x94}, 0},
+		{"clock-until-low", ClockUntilIO{High: false}, []byte{0x95}, 0},
+		{"adaptive-on", AdaptiveClock(true), []byte{0x96}, 0},
+		{"adaptive-off", AdaptiveClock(false), []byte{0x97}, 0},
+		{"clock-bytes-high", ClockBytes{Len: 1, WaitIO: true, High: true}, []byte{0x9C, 0x00, 0x00}, 0},
+		{"clock-bytes-low", ClockBytes{Len: 1, WaitIO: true}, []byte{0x9D, 0x00, 0x00}, 0},
+		{"drive-zero", DriveZero{Low: 0x07, High: 0x00}, []byte{0x9E, 0x07, 0x00}, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			enc, err := Encode(test.cmd)
+			if nil != err {
+				t.Fatalf("Encode(%s): %v", test.cmd, err)
+			}
+			if !bytes.Equal(test.enc, enc) {
+				t.Fatalf("Encode(%s)={% X}, expected={% X}", test.cmd, enc, test.enc)
+			}
+			if test.resp != ResponseLen(test.cmd) {
+				t.Fatalf("ResponseLen(%s)={%d}, expected={%d}",
+					test.cmd, ResponseLen(test.cmd), test.resp)
+			}
+			dec, err := Decode(enc)
+			if nil != err {
+				t.Fatalf("Decode({% X}): %v", enc, err)
+			}
+			if 1 != len(dec) || !reflect.DeepEqual(test.cmd, dec[0]) {
+				t.Fatalf("Decode({% X})={%v}, expected={%v}", enc, dec, test.cmd)
+			}
+		})
+	}
+}
+
+func TestEncodeInvalid(t *testing.T) {
+
+	for _, test := range []struct {
+		name string
+		cmd  Command
+	}{
+		{"no-direction", ClockData{Len: 1}},
+		{"zero-bytes", ClockData{In: true, Len: 0}},
+		{"too-many-bytes", ClockData{In: true, Len: MaxBytes + 1}},
+		{"too-many-bits", ClockData{In: true, Bits: true, Len: MaxBits + 1}},
+		{"short-data", ClockData{Out: true, Len: 2, Data: []byte{0x00}}},
+		{"bits-data", ClockData{Out: true, Bits: true, Len: 2, Data: []byte{0, 0}}},
+		{"too-many-tms", ClockTMS{Len: MaxTMS + 1}},
+		{"zero-clock-bits", ClockBits(0)},
+		{"zero-clock-bytes", ClockBytes{Len: 0}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if enc, err := Encode(test.cmd); nil == err {
+				t.Fatalf("Encode(%s)={% X}, expected error", test.cmd, enc)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+
+	t.Run("sequence", func(t *testing.T) {
+		cmd := []Command{
+			SetLow{Value: 0x08, Dir: 0x0B},
+			ClockData{Out: true, OutEdge: Falling, Len: 2, Data: []byte{0x9F, 0x00}},
+			ClockData{In: true, Len: 3},
+			SetLow{Value: 0x08, Dir: 0x0B},
+			SendImmediate{},
+		}
+		enc, err := Encode(cmd...)
+		if nil != err {
+			t.Fatalf("Encode: %v", err)
+		}
+		dec, err := Decode(enc)
+		if nil != err {
+			t.Fatalf("Decode: %v", err)
+		}
+		if !reflect.DeepEqual(cmd, dec) {
+			t.Fatalf("Decode={%v}, expected={%v}", dec, cmd)
+		}
+		if 3 != ResponseLen(dec...) {
+			t.Fatalf("ResponseLen={%d}, expected={%d}", ResponseLen(dec...), 3)
+		}
+	})
+
+	for _, test := range []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty-data", []byte{0x00, 0x00, 0x00}},
+		{"invalid-opcode", []byte{0xAB}},
+		{"short-set", []byte{0x80, 0x00}},
+		{"short-data", []byte{0x10, 0x01, 0x00, 0xAA}},
+		{"short-bits", []byte{0x12, 0x00}},
+		{"long-bits", []byte{0x22, 0x08}},
+		{"tms-out", []byte{0x5B, 0x00, 0x00}},
+		{"long-tms", []byte{0x4B, 0x07, 0x00}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if dec, err := Decode(test.buf); nil == err {
+				t.Fatalf("Decode({% X})={%v}, expected error", test.buf, dec)
+			}
+		})
+	}
+}
+
+func TestDivisor(t *testing.T) {
+
+	for _, test := range []struct {
+		hz  uint32
+		div ClockDivisor
+		out uint32
+	}{
+		{30000000, 0, 30000000},
+		{10000000, 2, 10000000},
+		{1000000, 29, 1000000},
+		{400000, 74, 400000},
+		{100000, 299, 100000},
+		{7000000, 4, 6000000},
+		{458, 65502, 457},
+	} {
+		div, err := Divisor(test.hz)
+		if nil != err {
+			t.Fatalf("Divisor(%d): %v", test.hz, err)
+		}
+		if test.div != div {
+			t.Fatalf("Divisor(%d)={%d}, expected={%d}", test.hz, div, test.div)
+		}
+		if out := div.Frequency(false); test.out != out {
+			t.Fatalf("Divisor(%d).Frequency={%d}, expected={%d}", test.hz, out, test.out)
+		}
+	}
+
+	for _, hz := range []uint32{0, 100, 457, 30000001} {
+		if _, err := Divisor(hz); nil == err {
+			t.Fatalf("Divisor(%d): expected error", hz)
+		}
+	}
+}
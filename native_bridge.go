@@ -1,3 +1,6 @@
+//go:build cgo
+// +build cgo
+
 package ft232h
 
 // #cgo               CFLAGS: -I${SRCDIR}/native/inc
@@ -13,290 +16,256 @@ package ft232h
 // #include "stdlib.h"
 import "C"
 
-// Type aliases for the native types needed by the C libraries.
-type (
-	Handle C.FT_HANDLE
-	Status C.FT_STATUS
-	Chip   C.FT_DEVICE
-	Mode   C.int
+import (
+	"fmt"
+	"time"
 )
 
-// Constants related to device status
-const (
-	SOK                      Status = C.FT_OK
-	SInvalidHandle           Status = C.FT_INVALID_HANDLE
-	SDeviceNotFound          Status = C.FT_DEVICE_NOT_FOUND
-	SDeviceNotOpened         Status = C.FT_DEVICE_NOT_OPENED
-	SIOError                 Status = C.FT_IO_ERROR
-	SInsufficientResources   Status = C.FT_INSUFFICIENT_RESOURCES
-	SInvalidParameter        Status = C.FT_INVALID_PARAMETER
-	SInvalidBaudRate         Status = C.FT_INVALID_BAUD_RATE
-	SDeviceNotOpenedForErase Status = C.FT_DEVICE_NOT_OPENED_FOR_ERASE
-	SDeviceNotOpenedForWrite Status = C.FT_DEVICE_NOT_OPENED_FOR_WRITE
-	SFailedToWriteDevice     Status = C.FT_FAILED_TO_WRITE_DEVICE
-	SEEPROMReadFailed        Status = C.FT_EEPROM_READ_FAILED
-	SEEPROMWriteFailed       Status = C.FT_EEPROM_WRITE_FAILED
-	SEEPROMEraseFailed       Status = C.FT_EEPROM_ERASE_FAILED
-	SEEPROMNotPresent        Status = C.FT_EEPROM_NOT_PRESENT
-	SEEPROMNotProgrammed     Status = C.FT_EEPROM_NOT_PROGRAMMED
-	SInvalidArgs             Status = C.FT_INVALID_ARGS
-	SNotSupported            Status = C.FT_NOT_SUPPORTED
-	SOtherError              Status = C.FT_OTHER_ERROR
-	SDeviceListNotReady      Status = C.FT_DEVICE_LIST_NOT_READY
-)
+// Handle is the native device handle used by the D2XX driver.
+type Handle C.FT_HANDLE
 
-// OK returns true if the status equals SOK, otherwise false.
-func (s Status) OK() bool {
-	return SOK == s
-}
-
-// Error implements the error interface. Returns the string "unknown error" if
-// the status is invalid.
-func (s Status) Error() string {
-	switch s {
-	case SOK:
-		return "OK"
-	case SInvalidHandle:
-		return "invalid handle"
-	case SDeviceNotFound:
-		return "device not found"
-	case SDeviceNotOpened:
-		return "device not opened"
-	case SIOError:
-		return "IO error"
-	case SInsufficientResources:
-		return "insufficient resources"
-	case SInvalidParameter:
-		return "invalid parameter"
-	case SInvalidBaudRate:
-		return "invalid baud rate"
-	case SDeviceNotOpenedForErase:
-		return "device not opened for erase"
-	case SDeviceNotOpenedForWrite:
-		return "device not opened for write"
-	case SFailedToWriteDevice:
-		return "failed to write device"
-	case SEEPROMReadFailed:
-		return "EEPROM read failed"
-	case SEEPROMWriteFailed:
-		return "EEPROM write failed"
-	case SEEPROMEraseFailed:
-		return "EEPROM erase failed"
-	case SEEPROMNotPresent:
-		return "EEPROM not present"
-	case SEEPROMNotProgrammed:
-		return "EEPROM not programmed"
-	case SInvalidArgs:
-		return "invalid args"
-	case SNotSupported:
-		return "not supported"
-	case SOtherError:
-		return "other error"
-	case SDeviceListNotReady:
-		return "device list not ready"
-	default:
-		return "(unknown error)"
-	}
-}
-
-// Constants defining the FTDI chip identifiers specified by FTDI.
-const (
-	CFTBM      Chip = C.FT_DEVICE_BM
-	CFTAM      Chip = C.FT_DEVICE_AM
-	CFT100AX   Chip = C.FT_DEVICE_100AX
-	CFTUnknown Chip = C.FT_DEVICE_UNKNOWN
-	CFT2232C   Chip = C.FT_DEVICE_2232C
-	CFT232R    Chip = C.FT_DEVICE_232R
-	CFT2232H   Chip = C.FT_DEVICE_2232H
-	CFT4232H   Chip = C.FT_DEVICE_4232H
-	CFT232H    Chip = C.FT_DEVICE_232H
-	CFTX       Chip = C.FT_DEVICE_X_SERIES
-	CFT4222H0  Chip = C.FT_DEVICE_4222H_0
-	CFT4222H12 Chip = C.FT_DEVICE_4222H_1_2
-	CFT4222H3  Chip = C.FT_DEVICE_4222H_3
-	CFT4222P   Chip = C.FT_DEVICE_4222_PROG
-	CFT900     Chip = C.FT_DEVICE_900
-	CFT930     Chip = C.FT_DEVICE_930
-	CUMFTPD3A  Chip = C.FT_DEVICE_UMFTPD3A
-)
+// d2xx implements Transport using FTDI's proprietary D2XX driver and the
+// open-source libMPSSE driver, linked statically via cgo.
+type d2xx struct{}
 
-// String returns the descriptive string representation of an FTDI chip.
-// Returns the string "invalid chip" if the chip is not defined.
-func (c Chip) String() string {
-	switch c {
-	case CFTBM:
-		return "FTBM"
-	case CFTAM:
-		return "FTAM"
-	case CFT100AX:
-		return "FT100AX"
-	case CFTUnknown:
-		return "FTUnknown"
-	case CFT2232C:
-		return "FT2232C"
-	case CFT232R:
-		return "FT232R"
-	case CFT2232H:
-		return "FT2232H"
-	case CFT4232H:
-		return "FT4232H"
-	case CFT232H:
-		return "FT232H"
-	case CFTX:
-		return "FTX"
-	case CFT4222H0:
-		return "FT4222H0"
-	case CFT4222H12:
-		return "FT4222H12"
-	case CFT4222H3:
-		return "FT4222H3"
-	case CFT4222P:
-		return "FT4222P"
-	case CFT900:
-		return "FT900"
-	case CFT930:
-		return "FT930"
-	case CUMFTPD3A:
-		return "UMFTPD3A"
-	default:
-		return "(invalid chip)"
-	}
-}
-
-// Constants defining the legacy protocols supported by MPSSE.
-const (
-	ModeNone Mode = 0
-	ModeSPI  Mode = 1
-	ModeI2C  Mode = 2
-)
+// d2xxPort implements Port for a device opened through the D2XX driver, and
+// PortChannel using the libMPSSE driver.
+type d2xxPort struct {
+	index  int
+	handle Handle
+}
+
+func init() {
+	DefaultTransport = d2xx{}
+}
+
+// Devices requests the D2XX driver allocate and populate an internal list of
+// MPSSE-capable USB devices connected to the system, and then parses and
+// returns a slice of DeviceInfo pointers for all devices stored in that list.
+// Returns a nil slice and non-nil error if the device list could not be created
+// or read.
+// Returns an empty slice and nil error if no devices were found in the list.
+func (d2xx) Devices() ([]*DeviceInfo, error) {
 
-// String returns a string describing the legacy protocol supported by MPSSE.
-// Returns the string "unknown" if the mode is invalid.
-func (m Mode) String() string {
-	switch m {
-	case ModeNone:
-		return "(none)"
-	case ModeSPI:
-		return "SPI"
-	case ModeI2C:
-		return "I²C"
-	default:
-		return "(invalid mode)"
-	}
-}
-
-// _FT_CreateDeviceInfoList requests the D2XX driver allocate and populate an
-// internal list of MPSSE-capable USB devices connected to the system, returning
-// the number of devices found if successful.
-// Returns 0 and a non-nil error if the device list could not be created.
-func _FT_CreateDeviceInfoList() (uint, error) {
 	var n C.DWORD
 	stat := Status(C.FT_CreateDeviceInfoList(&n))
 	if !stat.OK() {
-		return 0, stat
+		return nil, stat
 	}
-	return uint(n), nil
-}
 
-// _FT_GetDeviceInfoList parses and returns a slice of deviceInfo pointers for
-// all devices stored in the internal device list of the D2XX driver.
-// Returns a nil slice and non-nil error if the device list could not be read.
-// Returns an empty slice and nil error if no devices were found in the list.
-func _FT_GetDeviceInfoList(n uint) ([]*deviceInfo, error) {
-	ndev := C.DWORD(n)
+	if 0 == n {
+		return []*DeviceInfo{}, nil
+	}
+
+	ndev := n
 	list := make([]C.FT_DEVICE_LIST_INFO_NODE, n)
-	stat := Status(C.FT_GetDeviceInfoList(&list[0], &ndev))
+	stat = Status(C.FT_GetDeviceInfoList(&list[0], &ndev))
 	if !stat.OK() {
 		return nil, stat
 	}
-	info := make([]*deviceInfo, n)
+	info := make([]*DeviceInfo, n)
 	for i, node := range list {
 		// parse the C struct into our simpler Go definition
-		info[i] = &deviceInfo{
-			index:     i,
-			isOpen:    1 == (node.Flags & 0x01),
-			isHiSpeed: 2 == (node.Flags & 0x02),
-			chip:      Chip(node.Type),
-			vid:       (uint32(node.ID) >> 16) & 0xFFFF,
-			pid:       (uint32(node.ID)) & 0xFFFF,
-			locID:     uint32(node.LocId),
-			serial:    C.GoString(&node.SerialNumber[0]),
-			desc:      C.GoString(&node.Description[0]),
-			handle:    Handle(node.ftHandle),
+		info[i] = &DeviceInfo{
+			Index:   i,
+			Open:    1 == (node.Flags & 0x01),
+			HiSpeed: 2 == (node.Flags & 0x02),
+			Chip:    Chip(node.Type),
+			VID:     (uint32(node.ID) >> 16) & 0xFFFF,
+			PID:     (uint32(node.ID)) & 0xFFFF,
+			LocID:   uint32(node.LocId),
+			Serial:  C.GoString(&node.SerialNumber[0]),
+			Desc:    C.GoString(&node.Description[0]),
 		}
 	}
 	return info, nil
 }
 
-// _FT_Open attempts to open a raw USB interface through the D2XX driver,
+// Constants defining the USB parameters configured when a device is opened.
+const (
+	d2xxTransferSize = 65536 // USB request transfer size (bytes)
+	d2xxTimeout      = 5000  // read and write timeouts (ms)
+)
+
+// Open attempts to open a raw USB interface through the D2XX driver, returning
+// a nil Port and non-nil error if unsuccessful.
+// The USB transfer size is maximized, special characters are disabled, and the
+// read and write timeouts are configured before returning.
+func (d2xx) Open(index int) (Port, error) {
+	p := &d2xxPort{index: index}
+	stat := Status(C.FT_Open(C.int(index), (*C.PVOID)(&p.handle)))
+	if !stat.OK() {
+		return nil, stat
+	}
+	for _, f := range []func() C.FT_STATUS{
+		func() C.FT_STATUS {
+			return C.FT_SetUSBParameters(C.PVOID(p.handle),
+				d2xxTransferSize, d2xxTransferSize)
+		},
+		func() C.FT_STATUS {
+			return C.FT_SetChars(C.PVOID(p.handle), 0, 0, 0, 0)
+		},
+		func() C.FT_STATUS {
+			return C.FT_SetTimeouts(C.PVOID(p.handle), d2xxTimeout, d2xxTimeout)
+		},
+	} {
+		if stat = Status(f()); !stat.OK() {
+			p.Close()
+			return nil, stat
+		}
+	}
+	return p, nil
+}
+
+// Close attempts to close a USB interface opened through the D2XX driver,
 // returning a non-nil error if unsuccessful.
-func _FT_Open(info *deviceInfo) error {
-	stat := Status(C.FT_Open(C.int(info.index), (*C.PVOID)(&info.handle)))
+func (p *d2xxPort) Close() error {
+	stat := Status(C.FT_Close(C.PVOID(p.handle)))
 	if !stat.OK() {
 		return stat
 	}
 	return nil
 }
 
-// _FT_Close attempts to close a USB interface opened through the D2XX driver,
-// returning a non-nil error if unsuccessful.
-func _FT_Close(info *deviceInfo) error {
-	stat := Status(C.FT_Close(C.PVOID(info.handle)))
+// Reset sends a reset command to the device using the D2XX driver, returning a
+// non-nil error if unsuccessful.
+func (p *d2xxPort) Reset() error {
+	stat := Status(C.FT_ResetDevice(C.PVOID(p.handle)))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
+
+// Purge purges the receive and transmit buffers of the device using the D2XX
+// driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) Purge() error {
+	stat := Status(C.FT_Purge(C.PVOID(p.handle), C.FT_PURGE_RX|C.FT_PURGE_TX))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
+
+// SetLatency sets the latency timer of the device (in milliseconds) using the
+// D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) SetLatency(ms uint8) error {
+	stat := Status(C.FT_SetLatencyTimer(C.PVOID(p.handle), C.UCHAR(ms)))
 	if !stat.OK() {
 		return stat
 	}
 	return nil
 }
 
-// _FT_WriteGPIO sets the level val and direction dir for all pins on port "C"
-// of the FT232H using the D2XX driver, returns a non-nil error if the driver
-// could not set the pin configuration.
-func _FT_WriteGPIO(gpio *GPIO, dir uint8, val uint8) error {
-	stat := Status(C.FT_WriteGPIO(C.PVOID(gpio.device.info.handle), C.uint8(dir), C.uint8(val)))
+// SetBitMode sets the bit mode and pin direction mask of the device using the
+// D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) SetBitMode(mask uint8, mode BitMode) error {
+	stat := Status(C.FT_SetBitMode(C.PVOID(p.handle), C.UCHAR(mask), C.UCHAR(mode)))
 	if !stat.OK() {
 		return stat
 	}
 	return nil
 }
 
-// _FT_ReadGPIO reads the level of all pins on port "C" of the FT232H using the
-// D2XX driver, returning 0 and a non-nil error if the pins could not be read.
-func _FT_ReadGPIO(gpio *GPIO) (uint8, error) {
+// SetReadTimeout sets the read timeout of the device using the D2XX driver, or
+// restores the default timeout if d is zero, returning a non-nil error if
+// unsuccessful.
+func (p *d2xxPort) SetReadTimeout(d time.Duration) error {
+	ms := C.ULONG(d / time.Millisecond)
+	if 0 == ms {
+		ms = d2xxTimeout
+	}
+	stat := Status(C.FT_SetTimeouts(C.PVOID(p.handle), ms, d2xxTimeout))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
+
+// Write writes the given data slice to the device using the D2XX driver,
+// returning the number of bytes written, and a non-nil error if unsuccessful.
+func (p *d2xxPort) Write(data []uint8) (int, error) {
+	var sent C.DWORD
+	if 0 == len(data) {
+		return 0, nil
+	}
+	stat := Status(C.FT_Write(C.PVOID(p.handle),
+		C.LPVOID(&data[0]), C.DWORD(len(data)), &sent))
+	if !stat.OK() {
+		return int(sent), stat
+	}
+	if int(sent) < len(data) {
+		return int(sent), fmt.Errorf("write timeout: %d of %d bytes", sent, len(data))
+	}
+	return int(sent), nil
+}
+
+// Read reads len(data) bytes from the device into the given data slice using
+// the D2XX driver, returning the number of bytes read, and a non-nil error if
+// unsuccessful or the read timed out.
+func (p *d2xxPort) Read(data []uint8) (int, error) {
+	var recv C.DWORD
+	if 0 == len(data) {
+		return 0, nil
+	}
+	stat := Status(C.FT_Read(C.PVOID(p.handle),
+		C.LPVOID(&data[0]), C.DWORD(len(data)), &recv))
+	if !stat.OK() {
+		return int(recv), stat
+	}
+	if int(recv) < len(data) {
+		return int(recv), fmt.Errorf("%w: %d of %d bytes", ErrReadTimeout, recv, len(data))
+	}
+	return int(recv), nil
+}
+
+// WriteGPIO sets the level val and direction dir for all pins on port "C" of
+// the FT232H using the D2XX driver, returns a non-nil error if the driver could
+// not set the pin configuration.
+func (p *d2xxPort) WriteGPIO(dir uint8, val uint8) error {
+	stat := Status(C.FT_WriteGPIO(C.PVOID(p.handle), C.uint8(dir), C.uint8(val)))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
+
+// ReadGPIO reads the level of all pins on port "C" of the FT232H using the D2XX
+// driver, returning 0 and a non-nil error if the pins could not be read.
+func (p *d2xxPort) ReadGPIO() (uint8, error) {
 	var val C.uint8
-	stat := Status(C.FT_ReadGPIO(C.PVOID(gpio.device.info.handle), &val))
+	stat := Status(C.FT_ReadGPIO(C.PVOID(p.handle), &val))
 	if !stat.OK() {
 		return 0, stat
 	}
 	return uint8(val), nil
 }
 
-// _SPI_InitChannel initializes the MPSSE engine in SPI master mode with the
-// configuration defined in the given spi using the libMPSSE driver.
-// If the FT232H device is already opened in any mode (including SPI), the
-// interface is first closed before re-opening with the new configuration.
+// SPIInit initializes the MPSSE engine in SPI master mode with the given
+// configuration using the libMPSSE driver.
+// The device is first closed before re-opening with the new configuration.
 // Returns a non-nil error if the interface could not be closed or (re)opened.
-func _SPI_InitChannel(spi *SPI) error {
+func (p *d2xxPort) SPIInit(clock uint32, latency uint8, options uint32, pin uint32) error {
 
 	// close any open channels before trying to init
-	if err := spi.device.Close(); nil != err {
+	if err := p.Close(); nil != err {
 		return err
 	}
 
-	stat := Status(C.SPI_OpenChannel(C.uint32(spi.device.info.index),
-		(*C.PVOID)(&spi.device.info.handle)))
+	stat := Status(C.SPI_OpenChannel(C.uint32(p.index), (*C.PVOID)(&p.handle)))
 	if !stat.OK() {
 		return stat
 	}
 
 	config := C.SPI_ChannelConfig{
-		ClockRate:     C.uint32(spi.config.clockRate),
-		LatencyTimer:  C.uint8(spi.config.latency),
-		configOptions: C.uint32(spi.config.options),
-		Pin:           C.uint32(spi.config.pin),
+		ClockRate:     C.uint32(clock),
+		LatencyTimer:  C.uint8(latency),
+		configOptions: C.uint32(options),
+		Pin:           C.uint32(pin),
 		reserved:      C.uint16(0),
 	}
 
-	stat = Status(C.SPI_InitChannel(C.PVOID(spi.device.info.handle), &config))
+	stat = Status(C.SPI_InitChannel(C.PVOID(p.handle), &config))
 	if !stat.OK() {
 		return stat
 	}
@@ -304,209 +273,92 @@ func _SPI_InitChannel(spi *SPI) error {
 	return nil
 }
 
-// _SPI_Change reconfigures the dynamic interface parameters of an open SPI
+// SPIChange reconfigures the dynamic interface parameters of an open SPI
 // interface using the libMPSSE driver, returning a non-nil error if
 // unsuccessful.
-func _SPI_Change(spi *SPI) error {
-	stat := Status(C.SPI_ChangeCS(C.PVOID(spi.device.info.handle),
-		C.uint32(spi.config.options)))
+func (p *d2xxPort) SPIChange(options uint32) error {
+	stat := Status(C.SPI_ChangeCS(C.PVOID(p.handle), C.uint32(options)))
 	if !stat.OK() {
 		return stat
 	}
 	return nil
 }
 
-// _SPI_Read performs an SPI read using the libMPSSE driver with the given open
-// SPI interface, number of bytes to read, and transfer options, returning a
-// slice of uint8 containing the bytes successfully read, and a non-nil error if
-// there was an error.
-// If the given data slice length is greater than UINT16_MAX (65536), multiple
-// read requests are performed with the libMPSSE driver. In this case, if the CS
-// assert/deassert options are set, the CS line is only asserted and/or
-// deasserted with the first and last transfer requests, respectively.
-func _SPI_Read(spi *SPI, count uint, opt spiXferOption) ([]uint8, error) {
-
-	// note that MPSSE has a limitation on the size of SPI transfers, since the
-	// packet length has to fit into 16 bits, so the max transfer size is 65536.
-	// we break up the buffer here to transmit as much as possible at once.
-	const MaxTransferBytes = 65536
+// SPIRead performs an SPI read into the given data slice using the libMPSSE
+// driver with the given transfer options, returning the number of bytes
+// successfully read, and a non-nil error if there was an error.
+func (p *d2xxPort) SPIRead(data []uint8, opt uint32) (uint, error) {
 	var sent C.uint32
-
-	data := make([]uint8, count)
-
-	ass := (opt & spiCSAssert) > 0
-	dea := (opt & spiCSDeAssert) > 0
-
-	for beg := uint(0); beg < count; beg += MaxTransferBytes {
-
-		end := beg + MaxTransferBytes
-		if end > count {
-			end = count
-		}
-
-		// dont assert if this isn't the first packet
-		if ass {
-			if beg > 0 {
-				opt &= ^spiCSAssert
-			}
-		}
-
-		// don't deassert if this isn't the last packet
-		if dea {
-			if end < count {
-				opt &= ^spiCSDeAssert
-			} else {
-				opt |= spiCSDeAssert
-			}
-		}
-
-		stat := Status(C.SPI_Read(C.PVOID(spi.device.info.handle),
-			(*C.uint8)(&data[beg]), C.uint32(end-beg), &sent, C.uint32(opt)))
-		if !stat.OK() {
-			return data[:beg+uint(sent)], stat
-		}
-
+	if 0 == len(data) {
+		return 0, nil
+	}
+	stat := Status(C.SPI_Read(C.PVOID(p.handle),
+		(*C.uint8)(&data[0]), C.uint32(len(data)), &sent, C.uint32(opt)))
+	if !stat.OK() {
+		return uint(sent), stat
 	}
-	return data, nil
+	return uint(sent), nil
 }
 
-// _SPI_Write performs an SPI write using the libMPSSE driver with the given
-// open SPI interface, slice of uint8 data to send, and transfer options,
-// returning the total number of bytes successfully transferred, and a non-nil
-// error if there was an error.
-// If the given data slice length is greater than UINT16_MAX (65536), multiple
-// write requests are performed with the libMPSSE driver. In this case, if the
-// CS assert/deassert options are set, the CS line is only asserted and/or
-// deasserted with the first and last transfer requests, respectively.
-func _SPI_Write(spi *SPI, data []uint8, opt spiXferOption) (uint, error) {
-
-	// note that MPSSE has a limitation on the size of SPI transfers, since the
-	// packet length has to fit into 16 bits, so the max transfer size is 65536.
-	// we break up the buffer here to transmit as much as possible at once.
-	const MaxTransferBytes = 65536
+// SPIWrite performs an SPI write of the given data slice using the libMPSSE
+// driver with the given transfer options, returning the number of bytes
+// successfully transferred, and a non-nil error if there was an error.
+func (p *d2xxPort) SPIWrite(data []uint8, opt uint32) (uint, error) {
 	var sent C.uint32
-
-	dataLen := uint(len(data))
-
-	ass := (opt & spiCSAssert) > 0
-	dea := (opt & spiCSDeAssert) > 0
-
-	for beg := uint(0); beg < dataLen; beg += MaxTransferBytes {
-
-		end := beg + MaxTransferBytes
-		if end > dataLen {
-			end = dataLen
-		}
-
-		// dont assert if this isn't the first packet
-		if ass {
-			if beg > 0 {
-				opt &= ^spiCSAssert
-			}
-		}
-
-		// don't deassert if this isn't the last packet
-		if dea {
-			if end < dataLen {
-				opt &= ^spiCSDeAssert
-			} else {
-				opt |= spiCSDeAssert
-			}
-		}
-
-		stat := Status(C.SPI_Write(C.PVOID(spi.device.info.handle),
-			(*C.uint8)(&data[beg]), C.uint32(end-beg), &sent, C.uint32(opt)))
-		if !stat.OK() {
-			return beg + uint(sent), stat
-		}
-
+	if 0 == len(data) {
+		return 0, nil
 	}
-	return uint(dataLen), nil
+	stat := Status(C.SPI_Write(C.PVOID(p.handle),
+		(*C.uint8)(&data[0]), C.uint32(len(data)), &sent, C.uint32(opt)))
+	if !stat.OK() {
+		return uint(sent), stat
+	}
+	return uint(sent), nil
 }
 
-// _SPI_Swap performs a simultaneous SPI read+write using the libMPSSE driver
-// with the given open SPI interface, slice of uint8 data to send, and transfer
-// options, returning a slice of uint8 containing the bytes successfully read,
+// SPISwap performs a simultaneous SPI read+write using the libMPSSE driver
+// with the given slice of data to send and slice of equal length to receive,
+// and transfer options, returning the number of bytes successfully swapped,
 // and a non-nil error if there was an error.
 // Simultaneous read+write in libMPSSE means that "one bit is clocked in and one
 // bit is clocked out during every clock cycle."
-// If the given data slice length is greater than UINT16_MAX (65536), multiple
-// readwrite requests are performed with the libMPSSE driver. In this case, if
-// the CS assert/deassert options are set, the CS line is only asserted and/or
-// deasserted with the first and last transfer requests, respectively.
-func _SPI_Swap(spi *SPI, send []uint8, opt spiXferOption) ([]uint8, error) {
-
-	// note that MPSSE has a limitation on the size of SPI transfers, since the
-	// packet length has to fit into 16 bits, so the max transfer size is 65536.
-	// we break up the buffer here to transmit as much as possible at once.
-	const MaxTransferBytes = 65536
+func (p *d2xxPort) SPISwap(recv []uint8, send []uint8, opt uint32) (uint, error) {
 	var swap C.uint32
-
-	dataLen := uint(len(send))
-	recv := make([]uint8, dataLen)
-
-	ass := (opt & spiCSAssert) > 0
-	dea := (opt & spiCSDeAssert) > 0
-
-	for beg := uint(0); beg < dataLen; beg += MaxTransferBytes {
-
-		end := beg + MaxTransferBytes
-		if end > dataLen {
-			end = dataLen
-		}
-
-		// dont assert if this isn't the first packet
-		if ass {
-			if beg > 0 {
-				opt &= ^spiCSAssert
-			}
-		}
-
-		// don't deassert if this isn't the last packet
-		if dea {
-			if end < dataLen {
-				opt &= ^spiCSDeAssert
-			} else {
-				opt |= spiCSDeAssert
-			}
-		}
-
-		stat := Status(C.SPI_ReadWrite(C.PVOID(spi.device.info.handle),
-			(*C.uint8)(&recv[beg]), (*C.uint8)(&send[beg]),
-			C.uint32(end-beg), &swap, C.uint32(opt)))
-		if !stat.OK() {
-			return recv[:beg+uint(swap)], stat
-		}
-
+	if 0 == len(send) {
+		return 0, nil
+	}
+	stat := Status(C.SPI_ReadWrite(C.PVOID(p.handle),
+		(*C.uint8)(&recv[0]), (*C.uint8)(&send[0]),
+		C.uint32(len(send)), &swap, C.uint32(opt)))
+	if !stat.OK() {
+		return uint(swap), stat
 	}
-	return recv, nil
+	return uint(swap), nil
 }
 
-// _I2C_InitChannel initializes the MPSSE engine in I²C master mode with the
-// configuration defined in the given i2c using the libMPSSE driver.
-// If the FT232H device is already opened in any mode (including I²C), the
-// interface is first closed before re-opening with the new configuration.
+// I2CInit initializes the MPSSE engine in I²C master mode with the given
+// configuration using the libMPSSE driver.
+// The device is first closed before re-opening with the new configuration.
 // Returns a non-nil error if the interface could not be closed or (re)opened.
-func _I2C_InitChannel(i2c *I2C) error {
+func (p *d2xxPort) I2CInit(clock uint32, latency uint8, options uint32) error {
 
 	// close any open channels before trying to init
-	if err := i2c.device.Close(); nil != err {
+	if err := p.Close(); nil != err {
 		return err
 	}
 
-	stat := Status(C.I2C_OpenChannel(C.uint32(i2c.device.info.index),
-		(*C.PVOID)(&i2c.device.info.handle)))
+	stat := Status(C.I2C_OpenChannel(C.uint32(p.index), (*C.PVOID)(&p.handle)))
 	if !stat.OK() {
 		return stat
 	}
 
 	config := C.I2C_ChannelConfig{
-		ClockRate:    C.I2C_CLOCKRATE(i2c.config.clockRate),
-		LatencyTimer: C.uint8(i2c.config.latency),
-		Options:      C.uint32(i2c.config.options),
+		ClockRate:    C.I2C_CLOCKRATE(clock),
+		LatencyTimer: C.uint8(latency),
+		Options:      C.uint32(options),
 	}
 
-	stat = Status(C.I2C_InitChannel(C.PVOID(i2c.device.info.handle), &config))
+	stat = Status(C.I2C_InitChannel(C.PVOID(p.handle), &config))
 	if !stat.OK() {
 		return stat
 	}
@@ -514,118 +366,172 @@ func _I2C_InitChannel(i2c *I2C) error {
 	return nil
 }
 
-// _I2C_Read performs an I²C read using the libMPSSE driver with the given open
-// I²C interface, number of bytes to read, and transfer options, returning a
-// slice of uint8 containing the bytes successfully read, and a non-nil error if
-// there was an error.
-// If the given data slice length is greater than UINT16_MAX (65536), multiple
-// read requests are performed with the libMPSSE driver. In this case, if the
-// I²C start/stop bits are set, they are only generated on the first and last
-// transfer requests, respectively.
-func _I2C_Read(i2c *I2C, addr uint, count uint, opt i2cXferOption) ([]uint8, error) {
-
-	// note that MPSSE has a limitation on the size of I²C transfers, since the
-	// packet length has to fit into 16 bits, so the max transfer size is 65536.
-	// we break up the buffer here to transmit as much as possible at once.
-	const MaxTransferBytes = 65536
+// I2CRead performs an I²C read into the given data slice using the libMPSSE
+// driver with the given 7-bit slave address and transfer options, returning
+// the number of bytes successfully read, and a non-nil error if there was an
+// error.
+func (p *d2xxPort) I2CRead(addr uint, data []uint8, opt uint32) (uint, error) {
 	var sent C.uint32
-
-	data := make([]uint8, count)
-
-	start := (opt & i2cStartBit) > 0
-	stop := (opt & i2cStopBit) > 0
-
-	for beg := uint(0); beg < count; beg += MaxTransferBytes {
-
-		end := beg + MaxTransferBytes
-		if end > count {
-			end = count
-		}
-
-		if beg > 0 {
-			// TBD: don't readdress the slave (is this correct?)
-			opt |= i2cNoAddress
-			// dont send start if this isn't the first packet
-			if start {
-				opt &= ^i2cStartBit
-			}
-		}
-
-		// don't send stop if this isn't the last packet
-		if stop {
-			if end < count {
-				opt &= ^i2cStopBit
-			} else {
-				opt |= i2cStopBit
-			}
-		}
-
-		stat := Status(C.I2C_DeviceRead(C.PVOID(i2c.device.info.handle),
-			C.uint32(addr), C.uint32(end-beg), (*C.uint8)(&data[beg]), &sent,
-			C.uint32(opt)))
-		if !stat.OK() {
-			return data[:beg+uint(sent)], stat
-		}
-
+	// libMPSSE rejects a nil buffer, even if only the address is written.
+	var none C.uint8
+	buf := &none
+	if len(data) > 0 {
+		buf = (*C.uint8)(&data[0])
 	}
+	stat := Status(C.I2C_DeviceRead(C.PVOID(p.handle),
+		C.uint32(addr), C.uint32(len(data)), buf, &sent, C.uint32(opt)))
+	if !stat.OK() {
+		return uint(sent), stat
+	}
+	return uint(sent), nil
+}
 
-	return data, nil
+// I2CWrite performs an I²C write of the given data slice using the libMPSSE
+// driver with the given 7-bit slave address and transfer options, returning
+// the number of bytes successfully transferred, and a non-nil error if there
+// was an error.
+func (p *d2xxPort) I2CWrite(addr uint, data []uint8, opt uint32) (uint, error) {
+	var sent C.uint32
+	// libMPSSE rejects a nil buffer, even if only the address is written.
+	var none C.uint8
+	buf := &none
+	if len(data) > 0 {
+		buf = (*C.uint8)(&data[0])
+	}
+	stat := Status(C.I2C_DeviceWrite(C.PVOID(p.handle),
+		C.uint32(addr), C.uint32(len(data)), buf, &sent, C.uint32(opt)))
+	if !stat.OK() {
+		return uint(sent), stat
+	}
+	return uint(sent), nil
 }
 
-// _I2C_Write performs an I²C write using the libMPSSE driver with the given
-// open I²C interface, 7-bit slave address, slice of uint8 data to send, and
-// transfer options, returning the total number of bytes successfully
-// transferred, and a non-nil error if there was an error.
-// If the given data slice length is greater than UINT16_MAX (65536), multiple
-// write requests are performed with the libMPSSE driver. In this case, if the
-// I²C start/stop bits are set, they are only generated on the first and last
-// transfer requests, respectively.
-func _I2C_Write(i2c *I2C, addr uint, data []uint8, opt i2cXferOption) (uint, error) {
+// SetBaudRate sets the baud rate of the device in UART mode using the D2XX
+// driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) SetBaudRate(baud uint32) error {
+	stat := Status(C.FT_SetBaudRate(C.PVOID(p.handle), C.ULONG(baud)))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
 
-	// note that MPSSE has a limitation on the size of I²C transfers, since the
-	// packet length has to fit into 16 bits, so the max transfer size is 65536.
-	// we break up the buffer here to transmit as much as possible at once.
-	const MaxTransferBytes = 65536
-	var sent C.uint32
+// SetLine sets the number of data bits, parity, and number of stop bits of the
+// device in UART mode using the D2XX driver, returning a non-nil error if
+// unsuccessful.
+func (p *d2xxPort) SetLine(bits uint8, parity UARTParity, stop UARTStopBits) error {
+	stat := Status(C.FT_SetDataCharacteristics(C.PVOID(p.handle),
+		C.UCHAR(bits), C.UCHAR(stop), C.UCHAR(parity)))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
 
-	dataLen := uint(len(data))
+// SetFlowControl sets the hardware flow control of the device in UART mode
+// using the D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) SetFlowControl(flow UARTFlow) error {
+	stat := Status(C.FT_SetFlowControl(C.PVOID(p.handle), C.USHORT(flow), 0, 0))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
 
-	start := (opt & i2cStartBit) > 0
-	stop := (opt & i2cStopBit) > 0
+// SetBreak sets (if on is true) or clears the break condition on TXD using the
+// D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) SetBreak(on bool) error {
+	var stat Status
+	if on {
+		stat = Status(C.FT_SetBreakOn(C.PVOID(p.handle)))
+	} else {
+		stat = Status(C.FT_SetBreakOff(C.PVOID(p.handle)))
+	}
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
 
-	for beg := uint(0); beg < dataLen; beg += MaxTransferBytes {
+// SetDTR asserts (if on is true) or de-asserts the DTR# output using the D2XX
+// driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) SetDTR(on bool) error {
+	var stat Status
+	if on {
+		stat = Status(C.FT_SetDtr(C.PVOID(p.handle)))
+	} else {
+		stat = Status(C.FT_ClrDtr(C.PVOID(p.handle)))
+	}
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
 
-		end := beg + MaxTransferBytes
-		if end > dataLen {
-			end = dataLen
-		}
+// SetRTS asserts (if on is true) or de-asserts the RTS# output using the D2XX
+// driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) SetRTS(on bool) error {
+	var stat Status
+	if on {
+		stat = Status(C.FT_SetRts(C.PVOID(p.handle)))
+	} else {
+		stat = Status(C.FT_ClrRts(C.PVOID(p.handle)))
+	}
+	if !stat.OK() {
+		return stat
+	}
+	return nil
+}
 
-		if beg > 0 {
-			// TBD: don't readdress the slave (is this correct?)
-			opt |= i2cNoAddress
-			// dont send start if this isn't the first packet
-			if start {
-				opt &= ^i2cStartBit
-			}
-		}
+// ModemStatus reads the modem status (low byte) and line status (high byte) of
+// the device using the D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) ModemStatus() (UARTStatus, error) {
+	var status C.ULONG
+	stat := Status(C.FT_GetModemStatus(C.PVOID(p.handle), &status))
+	if !stat.OK() {
+		return 0, stat
+	}
+	return UARTStatus(status & 0xFFFF), nil
+}
 
-		// don't send stop if this isn't the last packet
-		if stop {
-			if end < dataLen {
-				opt &= ^i2cStopBit
-			} else {
-				opt |= i2cStopBit
-			}
-		}
+// Queue returns the number of received bytes that can be read without waiting
+// using the D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) Queue() (int, error) {
+	var recv C.DWORD
+	stat := Status(C.FT_GetQueueStatus(C.PVOID(p.handle), &recv))
+	if !stat.OK() {
+		return 0, stat
+	}
+	return int(recv), nil
+}
 
-		stat := Status(C.I2C_DeviceWrite(C.PVOID(i2c.device.info.handle),
-			C.uint32(addr), C.uint32(end-beg), (*C.uint8)(&data[beg]), &sent,
-			C.uint32(opt)))
-		if !stat.OK() {
-			return beg + uint(sent), stat
-		}
+// ReadEEPROM reads the configuration EEPROM word at the given word address
+// using the D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) ReadEEPROM(addr uint8) (uint16, error) {
+	var value C.WORD
+	stat := Status(C.FT_ReadEE(C.PVOID(p.handle), C.DWORD(addr), &value))
+	if !stat.OK() {
+		return 0, stat
+	}
+	return uint16(value), nil
+}
 
+// WriteEEPROM writes the configuration EEPROM word at the given word address
+// using the D2XX driver, returning a non-nil error if unsuccessful.
+func (p *d2xxPort) WriteEEPROM(addr uint8, value uint16) error {
+	stat := Status(C.FT_WriteEE(C.PVOID(p.handle), C.DWORD(addr), C.WORD(value)))
+	if !stat.OK() {
+		return stat
 	}
+	return nil
+}
 
-	return uint(dataLen), nil
+// EraseEEPROM erases the entire configuration EEPROM using the D2XX driver,
+// returning a non-nil error if unsuccessful.
+func (p *d2xxPort) EraseEEPROM() error {
+	stat := Status(C.FT_EraseEE(C.PVOID(p.handle)))
+	if !stat.OK() {
+		return stat
+	}
+	return nil
 }
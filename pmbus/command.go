@@ -0,0 +1,288 @@
+package pmbus
+
+import (
+	"fmt"
+)
+
+// Command is a PMBus command code.
+type Command uint8
+
+// Constants defining the standard PMBus command codes (PMBus Specification Part
+// II, Appendix I). Command codes 0xD0-0xFD are manufacturer specific.
+const (
+	CmdPage                  Command = 0x00 // PAGE
+	CmdOperation             Command = 0x01 // OPERATION
+	CmdOnOffConfig           Command = 0x02 // ON_OFF_CONFIG
+	CmdClearFaults           Command = 0x03 // CLEAR_FAULTS
+	CmdPhase                 Command = 0x04 // PHASE
+	CmdPagePlusWrite         Command = 0x05 // PAGE_PLUS_WRITE
+	CmdPagePlusRead          Command = 0x06 // PAGE_PLUS_READ
+	CmdWriteProtect          Command = 0x10 // WRITE_PROTECT
+	CmdStoreDefaultAll       Command = 0x11 // STORE_DEFAULT_ALL
+	CmdRestoreDefaultAll     Command = 0x12 // RESTORE_DEFAULT_ALL
+	CmdStoreDefaultCode      Command = 0x13 // STORE_DEFAULT_CODE
+	CmdRestoreDefaultCode    Command = 0x14 // RESTORE_DEFAULT_CODE
+	CmdStoreUserAll          Command = 0x15 // STORE_USER_ALL
+	CmdRestoreUserAll        Command = 0x16 // RESTORE_USER_ALL
+	CmdStoreUserCode         Command = 0x17 // STORE_USER_CODE
+	CmdRestoreUserCode       Command = 0x18 // RESTORE_USER_CODE
+	CmdCapability            Command = 0x19 // CAPABILITY
+	CmdQuery                 Command = 0x1A // QUERY
+	CmdSMBAlertMask          Command = 0x1B // SMBALERT_MASK
+	CmdVoutMode              Command = 0x20 // VOUT_MODE
+	CmdVoutCommand           Command = 0x21 // VOUT_COMMAND
+	CmdVoutTrim              Command = 0x22 // VOUT_TRIM
+	CmdVoutCalOffset         Command = 0x23 // VOUT_CAL_OFFSET
+	CmdVoutMax               Command = 0x24 // VOUT_MAX
+	CmdVoutMarginHigh        Command = 0x25 // VOUT_MARGIN_HIGH
+	CmdVoutMarginLow         Command = 0x26 // VOUT_MARGIN_LOW
+	CmdVoutTransitionRate    Command = 0x27 // VOUT_TRANSITION_RATE
+	CmdVoutDroop             Command = 0x28 // VOUT_DROOP
+	CmdVoutScaleLoop         Command = 0x29 // VOUT_SCALE_LOOP
+	CmdVoutScaleMonitor      Command = 0x2A // VOUT_SCALE_MONITOR
+	CmdVoutMin               Command = 0x2B // VOUT_MIN
+	CmdCoefficients          Command = 0x30 // COEFFICIENTS
+	CmdPoutMax               Command = 0x31 // POUT_MAX
+	CmdMaxDuty               Command = 0x32 // MAX_DUTY
+	CmdFrequencySwitch       Command = 0x33 // FREQUENCY_SWITCH
+	CmdPowerMode             Command = 0x34 // POWER_MODE
+	CmdVinOn                 Command = 0x35 // VIN_ON
+	CmdVinOff                Command = 0x36 // VIN_OFF
+	CmdInterleave            Command = 0x37 // INTERLEAVE
+	CmdIoutCalGain           Command = 0x38 // IOUT_CAL_GAIN
+	CmdIoutCalOffset         Command = 0x39 // IOUT_CAL_OFFSET
+	CmdFanConfig12           Command = 0x3A // FAN_CONFIG_1_2
+	CmdFanCommand1           Command = 0x3B // FAN_COMMAND_1
+	CmdFanCommand2           Command = 0x3C // FAN_COMMAND_2
+	CmdFanConfig34           Command = 0x3D // FAN_CONFIG_3_4
+	CmdFanCommand3           Command = 0x3E // FAN_COMMAND_3
+	CmdFanCommand4           Command = 0x3F // FAN_COMMAND_4
+	CmdVoutOVFaultLimit      Command = 0x40 // VOUT_OV_FAULT_LIMIT
+	CmdVoutOVFaultResponse   Command = 0x41 // VOUT_OV_FAULT_RESPONSE
+	CmdVoutOVWarnLimit       Command = 0x42 // VOUT_OV_WARN_LIMIT
+	CmdVoutUVWarnLimit       Command = 0x43 // VOUT_UV_WARN_LIMIT
+	CmdVoutUVFaultLimit      Command = 0x44 // VOUT_UV_FAULT_LIMIT
+	CmdVoutUVFaultResponse   Command = 0x45 // VOUT_UV_FAULT_RESPONSE
+	CmdIoutOCFaultLimit      Command = 0x46 // IOUT_OC_FAULT_LIMIT
+	CmdIoutOCFaultResponse   Command = 0x47 // IOUT_OC_FAULT_RESPONSE
+	CmdIoutOCLVFaultLimit    Command = 0x48 // IOUT_OC_LV_FAULT_LIMIT
+	CmdIoutOCLVFaultResponse Command = 0x49 // IOUT_OC_LV_FAULT_RESPONSE
+	CmdIoutOCWarnLimit       Command = 0x4A // IOUT_OC_WARN_LIMIT
+	CmdIoutUCFaultLimit      Command = 0x4B // IOUT_UC_FAULT_LIMIT
+	CmdIoutUCFaultResponse   Command = 0x4C // IOUT_UC_FAULT_RESPONSE
+	CmdOTFaultLimit          Command = 0x4F // OT_FAULT_LIMIT
+	CmdOTFaultResponse       Command = 0x50 // OT_FAULT_RESPONSE
+	CmdOTWarnLimit           Command = 0x51 // OT_WARN_LIMIT
+	CmdUTWarnLimit           Command = 0x52 // UT_WARN_LIMIT
+	CmdUTFaultLimit          Command = 0x53 // UT_FAULT_LIMIT
+	CmdUTFaultResponse       Command = 0x54 // UT_FAULT_RESPONSE
+	CmdVinOVFaultLimit       Command = 0x55 // VIN_OV_FAULT_LIMIT
+	CmdVinOVFaultResponse    Command = 0x56 // VIN_OV_FAULT_RESPONSE
+	CmdVinOVWarnLimit        Command = 0x57 // VIN_OV_WARN_LIMIT
+	CmdVinUVWarnLimit        Command = 0x58 // VIN_UV_WARN_LIMIT
+	CmdVinUVFaultLimit       Command = 0x59 // VIN_UV_FAULT_LIMIT
+	CmdVinUVFaultResponse    Command = 0x5A // VIN_UV_FAULT_RESPONSE
+	CmdIinOCFaultLimit       Command = 0x5B // IIN_OC_FAULT_LIMIT
+	CmdIinOCFaultResponse    Command = 0x5C // IIN_OC_FAULT_RESPONSE
+	CmdIinOCWarnLimit        Command = 0x5D // IIN_OC_WARN_LIMIT
+	CmdPowerGoodOn           Command = 0x5E // POWER_GOOD_ON
+	CmdPowerGoodOff          Command = 0x5F // POWER_GOOD_OFF
+	CmdTonDelay              Command = 0x60 // TON_DELAY
+	CmdTonRise               Command = 0x61 // TON_RISE
+	CmdTonMaxFaultLimit      Command = 0x62 // TON_MAX_FAULT_LIMIT
+	CmdTonMaxFaultResponse   Command = 0x63 // TON_MAX_FAULT_RESPONSE
+	CmdToffDelay             Command = 0x64 // TOFF_DELAY
+	CmdToffFall              Command = 0x65 // TOFF_FALL
+	CmdToffMaxWarnLimit      Command = 0x66 // TOFF_MAX_WARN_LIMIT
+	CmdPoutOPFaultLimit      Command = 0x68 // POUT_OP_FAULT_LIMIT
+	CmdPoutOPFaultResponse   Command = 0x69 // POUT_OP_FAULT_RESPONSE
+	CmdPoutOPWarnLimit       Command = 0x6A // POUT_OP_WARN_LIMIT
+	CmdPinOPWarnLimit        Command = 0x6B // PIN_OP_WARN_LIMIT
+	CmdStatusByte            Command = 0x78 // STATUS_BYTE
+	CmdStatusWord            Command = 0x79 // STATUS_WORD
+	CmdStatusVout            Command = 0x7A // STATUS_VOUT
+	CmdStatusIout            Command = 0x7B // STATUS_IOUT
+	CmdStatusInput           Command = 0x7C // STATUS_INPUT
+	CmdStatusTemperature     Command = 0x7D // STATUS_TEMPERATURE
+	CmdStatusCML             Command = 0x7E // STATUS_CML
+	CmdStatusOther           Command = 0x7F // STATUS_OTHER
+	CmdStatusMfrSpecific     Command = 0x80 // STATUS_MFR_SPECIFIC
+	CmdStatusFans12          Command = 0x81 // STATUS_FANS_1_2
+	CmdStatusFans34          Command = 0x82 // STATUS_FANS_3_4
+	CmdReadEin               Command = 0x86 // READ_EIN
+	CmdReadEout              Command = 0x87 // READ_EOUT
+	CmdReadVin               Command = 0x88 // READ_VIN
+	CmdReadIin               Command = 0x89 // READ_IIN
+	CmdReadVcap              Command = 0x8A // READ_VCAP
+	CmdReadVout              Command = 0x8B // READ_VOUT
+	CmdReadIout              Command = 0x8C // READ_IOUT
+	CmdReadTemperature1      Command = 0x8D // READ_TEMPERATURE_1
+	CmdReadTemperature2      Command = 0x8E // READ_TEMPERATURE_2
+	CmdReadTemperature3      Command = 0x8F // READ_TEMPERATURE_3
+	CmdReadFanSpeed1         Command = 0x90 // READ_FAN_SPEED_1
+	CmdReadFanSpeed2         Command = 0x91 // READ_FAN_SPEED_2
+	CmdReadFanSpeed3         Command = 0x92 // READ_FAN_SPEED_3
+	CmdReadFanSpeed4         Command = 0x93 // READ_FAN_SPEED_4
+	CmdReadDutyCycle         Command = 0x94 // READ_DUTY_CYCLE
+	CmdReadFrequency         Command = 0x95 // READ_FREQUENCY
+	CmdReadPout              Command = 0x96 // READ_POUT
+	CmdReadPin               Command = 0x97 // READ_PIN
+	CmdPMBusRevision         Command = 0x98 // PMBUS_REVISION
+	CmdMfrID                 Command = 0x99 // MFR_ID
+	CmdMfrModel              Command = 0x9A // MFR_MODEL
+	CmdMfrRevision           Command = 0x9B // MFR_REVISION
+	CmdMfrLocation           Command = 0x9C // MFR_LOCATION
+	CmdMfrDate               Command = 0x9D // MFR_DATE
+	CmdMfrSerial             Command = 0x9E // MFR_SERIAL
+)
+
+// commandName contains the name of each standard command code, as given in the
+// PMBus specification.
+var commandName = map[Command]string{
+	CmdPage:                  "PAGE",
+	CmdOperation:             "OPERATION",
+	CmdOnOffConfig:           "ON_OFF_CONFIG",
+	CmdClearFaults:           "CLEAR_FAULTS",
+	CmdPhase:                 "PHASE",
+	CmdPagePlusWrite:         "PAGE_PLUS_WRITE",
+	CmdPagePlusRead:          "PAGE_PLUS_READ",
+	CmdWriteProtect:          "WRITE_PROTECT",
+	CmdStoreDefaultAll:       "STORE_DEFAULT_ALL",
+	CmdRestoreDefaultAll:     "RESTORE_DEFAULT_ALL",
+	CmdStoreDefaultCode:      "STORE_DEFAULT_CODE",
+	CmdRestoreDefaultCode:    "RESTORE_DEFAULT_CODE",
+	CmdStoreUserAll:          "STORE_USER_ALL",
+	CmdRestoreUserAll:        "RESTORE_USER_ALL",
+	CmdStoreUserCode:         "STORE_USER_CODE",
+	CmdRestoreUserCode:       "RESTORE_USER_CODE",
+	CmdCapability:            "CAPABILITY",
+	CmdQuery:                 "QUERY",
+	CmdSMBAlertMask:          "SMBALERT_MASK",
+	CmdVoutMode:              "VOUT_MODE",
+	CmdVoutCommand:           "VOUT_COMMAND",
+	CmdVoutTrim:              "VOUT_TRIM",
+	CmdVoutCalOffset:         "VOUT_CAL_OFFSET",
+	CmdVoutMax:               "VOUT_MAX",
+	CmdVoutMarginHigh:        "VOUT_MARGIN_HIGH",
+	CmdVoutMarginLow:         "VOUT_MARGIN_LOW",
+	CmdVoutTransitionRate:    "VOUT_TRANSITION_RATE",
+	CmdVoutDroop:             "VOUT_DROOP",
+	CmdVoutScaleLoop:         "VOUT_SCALE_LOOP",
+	CmdVoutScaleMonitor:      "VOUT_SCALE_MONITOR",
+	CmdVoutMin:               "VOUT_MIN",
+	CmdCoefficients:          "COEFFICIENTS",
+	CmdPoutMax:               "POUT_MAX",
+	CmdMaxDuty:               "MAX_DUTY",
+	CmdFrequencySwitch:       "FREQUENCY_SWITCH",
+	CmdPowerMode:             "POWER_MODE",
+	CmdVinOn:                 "VIN_ON",
+	CmdVinOff:                "VIN_OFF",
+	CmdInterleave:            "INTERLEAVE",
+	CmdIoutCalGain:           "IOUT_CAL_GAIN",
+	CmdIoutCalOffset:         "IOUT_CAL_OFFSET",
+	CmdFanConfig12:           "FAN_CONFIG_1_2",
+	CmdFanCommand1:           "FAN_COMMAND_1",
+	CmdFanCommand2:           "FAN_COMMAND_2",
+	CmdFanConfig34:           "FAN_CONFIG_3_4",
+	CmdFanCommand3:           "FAN_COMMAND_3",
+	CmdFanCommand4:           "FAN_COMMAND_4",
+	CmdVoutOVFaultLimit:      "VOUT_OV_FAULT_LIMIT",
+	CmdVoutOVFaultResponse:   "VOUT_OV_FAULT_RESPONSE",
+	CmdVoutOVWarnLimit:       "VOUT_OV_WARN_LIMIT",
+	CmdVoutUVWarnLimit:       "VOUT_UV_WARN_LIMIT",
+	CmdVoutUVFaultLimit:      "VOUT_UV_FAULT_LIMIT",
+	CmdVoutUVFaultResponse:   "VOUT_UV_FAULT_RESPONSE",
+	CmdIoutOCFaultLimit:      "IOUT_OC_FAULT_LIMIT",
+	CmdIoutOCFaultResponse:   "IOUT_OC_FAULT_RESPONSE",
+	CmdIoutOCLVFaultLimit:    "IOUT_OC_LV_FAULT_LIMIT",
+	CmdIoutOCLVFaultResponse: "IOUT_OC_LV_FAULT_RESPONSE",
+	CmdIoutOCWarnLimit:       "IOUT_OC_WARN_LIMIT",
+	CmdIoutUCFaultLimit:      "IOUT_UC_FAULT_LIMIT",
+	CmdIoutUCFaultResponse:   "IOUT_UC_FAULT_RESPONSE",
+	CmdOTFaultLimit:          "OT_FAULT_LIMIT",
+	CmdOTFaultResponse:       "OT_FAULT_RESPONSE",
+	CmdOTWarnLimit:           "OT_WARN_LIMIT",
+	CmdUTWarnLimit:           "UT_WARN_LIMIT",
+	CmdUTFaultLimit:          "UT_FAULT_LIMIT",
+	CmdUTFaultResponse:       "UT_FAULT_RESPONSE",
+	CmdVinOVFaultLimit:       "VIN_OV_FAULT_LIMIT",
+	CmdVinOVFaultResponse:    "VIN_OV_FAULT_RESPONSE",
+	CmdVinOVWarnLimit:        "VIN_OV_WARN_LIMIT",
+	CmdVinUVWarnLimit:        "VIN_UV_WARN_LIMIT",
+	CmdVinUVFaultLimit:       "VIN_UV_FAULT_LIMIT",
+	CmdVinUVFaultResponse:    "VIN_UV_FAULT_RESPONSE",
+	CmdIinOCFaultLimit:       "IIN_OC_FAULT_LIMIT",
+	CmdIinOCFaultResponse:    "IIN_OC_FAULT_RESPONSE",
+	CmdIinOCWarnLimit:        "IIN_OC_WARN_LIMIT",
+	CmdPowerGoodOn:           "POWER_GOOD_ON",
+	CmdPowerGoodOff:          "POWER_GOOD_OFF",
+	CmdTonDelay:              "TON_DELAY",
+	CmdTonRise:               "TON_RISE",
+	CmdTonMaxFaultLimit:      "TON_MAX_FAULT_LIMIT",
+	CmdTonMaxFaultResponse:   "TON_MAX_FAULT_RESPONSE",
+	CmdToffDelay:             "TOFF_DELAY",
+	CmdToffFall:              "TOFF_FALL",
+	CmdToffMaxWarnLimit:      "TOFF_MAX_WARN_LIMIT",
+	CmdPoutOPFaultLimit:      "POUT_OP_FAULT_LIMIT",
+	CmdPoutOPFaultResponse:   "POUT_OP_FAULT_RESPONSE",
+	CmdPoutOPWarnLimit:       "POUT_OP_WARN_LIMIT",
+	CmdPinOPWarnLimit:        "PIN_OP_WARN_LIMIT",
+	CmdStatusByte:            "STATUS_BYTE",
+	CmdStatusWord:            "STATUS_WORD",
+	CmdStatusVout:            "STATUS_VOUT",
+	CmdStatusIout:            "STATUS_IOUT",
+	CmdStatusInput:           "STATUS_INPUT",
+	CmdStatusTemperature:     "STATUS_TEMPERATURE",
+	CmdStatusCML:             "STATUS_CML",
+	CmdStatusOther:           "STATUS_OTHER",
+	CmdStatusMfrSpecific:     "STATUS_MFR_SPECIFIC",
+	CmdStatusFans12:          "STATUS_FANS_1_2",
+	CmdStatusFans34:          "STATUS_FANS_3_4",
+	CmdReadEin:               "READ_EIN",
+	CmdReadEout:              "READ_EOUT",
+	CmdReadVin:               "READ_VIN",
+	CmdReadIin:               "READ_IIN",
+	CmdReadVcap:              "READ_VCAP",
+	CmdReadVout:              "READ_VOUT",
+	CmdReadIout:              "READ_IOUT",
+	CmdReadTemperature1:      "READ_TEMPERATURE_1",
+	CmdReadTemperature2:      "READ_TEMPERATURE_2",
+	CmdReadTemperature3:      "READ_TEMPERATURE_3",
+	CmdReadFanSpeed1:         "READ_FAN_SPEED_1",
+	CmdReadFanSpeed2:         "READ_FAN_SPEED_2",
+	CmdReadFanSpeed3:         "READ_FAN_SPEED_3",
+	CmdReadFanSpeed4:         "READ_FAN_SPEED_4",
+	CmdReadDutyCycle:         "READ_DUTY_CYCLE",
+	CmdReadFrequency:         "READ_FREQUENCY",
+	CmdReadPout:              "READ_POUT",
+	CmdReadPin:               "READ_PIN",
+	CmdPMBusRevision:         "PMBUS_REVISION",
+	CmdMfrID:                 "MFR_ID",
+	CmdMfrModel:              "MFR_MODEL",
+	CmdMfrRevision:           "MFR_REVISION",
+	CmdMfrLocation:           "MFR_LOCATION",
+	CmdMfrDate:               "MFR_DATE",
+	CmdMfrSerial:             "MFR_SERIAL",
+}
+
+// String returns the name of a standard command code, or the command code in
+// hex if it is not a standard command.
+func (c Command) String() string {
+	if s, ok := commandName[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("0x%02X", uint8(c))
+}
+
+// vout returns true if the data of the receiver command is in the format given
+// by VOUT_MODE, i.e. the command sets or reads an output voltage.
+func (c Command) vout() bool {
+	switch c {
+	case CmdVoutCommand, CmdVoutTrim, CmdVoutCalOffset, CmdVoutMax,
+		CmdVoutMarginHigh, CmdVoutMarginLow, CmdVoutMin,
+		CmdVoutOVFaultLimit, CmdVoutOVWarnLimit, CmdVoutUVWarnLimit,
+		CmdVoutUVFaultLimit, CmdPowerGoodOn, CmdPowerGoodOff, CmdReadVout:
+		return true
+	}
+	return false
+}
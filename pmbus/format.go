@@ -0,0 +1,143 @@
+package pmbus
+
+import (
+	"fmt"
+	"math"
+)
+
+// Constants defining the range of the LINEAR11 data format: an 11-bit two's
+// complement mantissa, and a 5-bit two's complement exponent.
+const (
+	linear11MantMin = -1 << 10
+	linear11MantMax = 1<<10 - 1
+	linear11ExpMin  = -1 << 4
+	linear11ExpMax  = 1<<4 - 1
+)
+
+// signed returns the given n-bit two's complement value v sign-extended.
+func signed(v uint16, n uint) int {
+	shift := 16 - n
+	return int(int16(v<<shift) >> shift)
+}
+
+// DecodeLinear11 returns the value of the given word in LINEAR11 format, used
+// by most PMBus commands other than those of the output voltage: bits 15-11
+// are a two's complement exponent N, and bits 10-0 are a two's complement
+// mantissa Y, such that the value is Y·2ᴺ.
+func DecodeLinear11(w uint16) float64 {
+	return math.Ldexp(float64(signed(w&0x07FF, 11)), signed(w>>11, 5))
+}
+
+// EncodeLinear11 returns the word in LINEAR11 format (see DecodeLinear11)
+// nearest the given value x, using the smallest exponent that can represent x,
+// and a non-nil error if x is out of range.
+func EncodeLinear11(x float64) (uint16, error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, fmt.Errorf("invalid LINEAR11 value: %g", x)
+	}
+	for n := linear11ExpMin; n <= linear11ExpMax; n++ {
+		y := math.Round(math.Ldexp(x, -n))
+		if y >= linear11MantMin && y <= linear11MantMax {
+			return uint16(n&0x1F)<<11 | uint16(int(y)&0x07FF), nil
+		}
+	}
+	return 0, fmt.Errorf("LINEAR11 value out of range: %g", x)
+}
+
+// DecodeLinear16 returns the value of the given word in LINEAR16 format, used
+// by the output voltage commands when VOUT_MODE selects linear mode: the word
+// is an unsigned mantissa Y, and the exponent N is given by VOUT_MODE, such
+// that the value is Y·2ᴺ.
+func DecodeLinear16(w uint16, exp int) float64 {
+	return math.Ldexp(float64(w), exp)
+}
+
+// EncodeLinear16 returns the word in LINEAR16 format (see DecodeLinear16)
+// nearest the given value x with the given exponent, and a non-nil error if x
+// is out of range.
+func EncodeLinear16(x float64, exp int) (uint16, error) {
+	y := math.Round(math.Ldexp(x, -exp))
+	if math.IsNaN(y) || y < 0 || y > math.MaxUint16 {
+		return 0, fmt.Errorf("LINEAR16 value out of range: %g (exponent %d)", x, exp)
+	}
+	return uint16(y), nil
+}
+
+// Direct contains the coefficients of the DIRECT data format, given by a device
+// datasheet or read with the COEFFICIENTS command: a 16-bit two's complement
+// word Y represents the value X = (Y·10⁻ᴿ - B) / M.
+type Direct struct {
+	M int // slope coefficient
+	B int // offset
+	R int // exponent
+}
+
+// String returns a descriptive string of a Direct.
+func (c Direct) String() string {
+	return fmt.Sprintf("{ M: %d, B: %d, R: %d }", c.M, c.B, c.R)
+}
+
+// Decode returns the value of the given word in the receiver DIRECT format.
+func (c Direct) Decode(w uint16) float64 {
+	return (float64(int16(w))*math.Pow10(-c.R) - float64(c.B)) / float64(c.M)
+}
+
+// Encode returns the word in the receiver DIRECT format nearest the given value
+// x, and a non-nil error if x is out of range or the coefficients are invalid.
+func (c Direct) Encode(x float64) (uint16, error) {
+	if 0 == c.M {
+		return 0, fmt.Errorf("invalid DIRECT coefficients: %s", c)
+	}
+	y := math.Round((float64(c.M)*x + float64(c.B)) * math.Pow10(c.R))
+	if math.IsNaN(y) || y < math.MinInt16 || y > math.MaxInt16 {
+		return 0, fmt.Errorf("DIRECT value out of range: %g %s", x, c)
+	}
+	return uint16(int16(y)), nil
+}
+
+// VoutMode is the value of the VOUT_MODE command, which selects the data
+// format of the output voltage commands: bits 7-5 are the mode (see VoutFormat),
+// and bits 4-0 are the mode's parameter, e.g. the LINEAR16 exponent.
+type VoutMode uint8
+
+// VoutFormat identifies the data format selected by VOUT_MODE.
+type VoutFormat uint8
+
+// Constants defining the data formats selected by VOUT_MODE.
+const (
+	VoutLinear   VoutFormat = 0 // LINEAR16, with exponent given by VOUT_MODE
+	VoutVID      VoutFormat = 1 // VID code, with VID code type given by VOUT_MODE
+	VoutDirect   VoutFormat = 2 // DIRECT, with coefficients of the command
+	VoutIEEEHalf VoutFormat = 3 // IEEE 754 half precision
+)
+
+// String returns a descriptive string of a VoutFormat.
+func (f VoutFormat) String() string {
+	switch f {
+	case VoutLinear:
+		return "Linear"
+	case VoutVID:
+		return "VID"
+	case VoutDirect:
+		return "Direct"
+	case VoutIEEEHalf:
+		return "IEEE Half"
+	default:
+		return fmt.Sprintf("Unknown format (%d)", uint8(f))
+	}
+}
+
+// Format returns the data format selected by the receiver VOUT_MODE.
+func (m VoutMode) Format() VoutFormat { return VoutFormat(m >> 5) }
+
+// Exponent returns the LINEAR16 exponent of the receiver VOUT_MODE, a 5-bit
+// two's complement value. It is only meaningful if Format is VoutLinear.
+func (m VoutMode) Exponent() int { return signed(uint16(m)&0x1F, 5) }
+
+// String returns a descriptive string of a VoutMode.
+func (m VoutMode) String() string {
+	if VoutLinear == m.Format() {
+		return fmt.Sprintf("%s (exponent %d)", m.Format(), m.Exponent())
+	}
+	return fmt.Sprintf("%s (0x%02X)", m.Format(), uint8(m)&0x1F)
+}
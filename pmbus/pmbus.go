@@ -0,0 +1,243 @@
+/*
+Package pmbus implements a Power Management Bus (PMBus) client on top of the
+SMBus protocol layer (see package github.com/ardnew/ft232h/smbus).
+
+A Device knows the standard PMBus command codes (see Command) and the data
+format of each: output voltage commands (e.g., READ_VOUT, VOUT_COMMAND) are
+decoded in the format selected by VOUT_MODE, either LINEAR16 or DIRECT, and all
+other values (e.g., READ_VIN, READ_IOUT, READ_TEMPERATURE_1) in LINEAR11 format,
+or in DIRECT format if the device's coefficients for the command have been set
+(see SetDirect). Values are returned as floating-point engineering units: volts,
+amperes, watts, degrees Celsius, etc.
+
+The VOUT_MODE of the current page is read once and cached, until PAGE or
+VOUT_MODE is written with the Device (see WriteByteData). Changes of VOUT_MODE
+made by other means are not seen until then.
+
+The status registers (STATUS_WORD, STATUS_VOUT, etc.) are decoded into named
+flags (see StatusWord, etc.).
+
+The command set and data formats are described in the PMBus Power System
+Management Protocol Specification, Part II, revision 1.3.
+*/
+package pmbus
+
+import (
+	"fmt"
+
+	"github.com/ardnew/ft232h"
+	"github.com/ardnew/ft232h/smbus"
+)
+
+// Device is a PMBus device on the I²C interface of an FT232H. The embedded SMBus
+// device may be used for manufacturer specific commands and to enable packet
+// error checking.
+type Device struct {
+	*smbus.Device
+	direct map[Command]Direct
+	vout   *VoutMode // cached VOUT_MODE of the current page
+}
+
+// New returns a new Device with the given unshifted 7-bit slave address on the
+// given I²C interface, which must already be initialized (see ft232h.I2C.Init).
+func New(i2c *ft232h.I2C, addr uint) (*Device, error) {
+	sm, err := smbus.New(i2c, addr)
+	if nil != err {
+		return nil, err
+	}
+	return &Device{Device: sm, direct: map[Command]Direct{}}, nil
+}
+
+// SetDirect sets the DIRECT format coefficients of the given command, which are
+// used to decode and encode its values instead of LINEAR11 format (or instead
+// of the format selected by VOUT_MODE, if it selects DIRECT format).
+func (d *Device) SetDirect(cmd Command, c Direct) {
+	d.direct[cmd] = c
+}
+
+// Page returns the current page, i.e. the output (or rail) to which the paged
+// commands apply.
+func (d *Device) Page() (uint8, error) {
+	return d.ReadByteData(uint8(CmdPage))
+}
+
+// SetPage selects the page, i.e. the output (or rail) to which the paged
+// commands apply. Page 0xFF selects all pages for writing.
+func (d *Device) SetPage(page uint8) error {
+	return d.WriteByteData(uint8(CmdPage), page)
+}
+
+// WriteByteData performs the SMBus write byte protocol, writing the given byte
+// b with the given command code cmd (see smbus.Device.WriteByteData). Writing
+// PAGE or VOUT_MODE invalidates the cached VOUT_MODE.
+func (d *Device) WriteByteData(cmd uint8, b uint8) error {
+	if CmdPage == Command(cmd) || CmdVoutMode == Command(cmd) {
+		d.vout = nil
+	}
+	return d.Device.WriteByteData(cmd, b)
+}
+
+// ClearFaults clears all fault and warning bits of all status registers.
+func (d *Device) ClearFaults() error {
+	return d.SendByte(uint8(CmdClearFaults))
+}
+
+// VoutMode returns the value of VOUT_MODE, which selects the data format of the
+// output voltage commands, and caches it for the current page.
+func (d *Device) VoutMode() (VoutMode, error) {
+	m, err := d.ReadByteData(uint8(CmdVoutMode))
+	if nil != err {
+		return 0, err
+	}
+	mode := VoutMode(m)
+	d.vout = &mode
+	return mode, nil
+}
+
+// Revision returns the value of PMBUS_REVISION: the PMBus Part I revision in
+// bits 7-4, and the Part II revision in bits 3-0 (0 = 1.0, 1 = 1.1, etc.).
+func (d *Device) Revision() (uint8, error) {
+	return d.ReadByteData(uint8(CmdPMBusRevision))
+}
+
+// Read reads the word of the given command and returns its value in
+// engineering units, decoded in the command's data format (see package
+// documentation).
+func (d *Device) Read(cmd Command) (float64, error) {
+	dec, err := d.decoder(cmd)
+	if nil != err {
+		return 0, err
+	}
+	w, err := d.ReadWordData(uint8(cmd))
+	if nil != err {
+		return 0, err
+	}
+	return dec(w), nil
+}
+
+// Write writes the given value x in engineering units to the word of the given
+// command, encoded in the command's data format (see package documentation).
+func (d *Device) Write(cmd Command, x float64) error {
+	enc, err := d.encoder(cmd)
+	if nil != err {
+		return err
+	}
+	w, err := enc(x)
+	if nil != err {
+		return fmt.Errorf("%s: %v", cmd, err)
+	}
+	return d.WriteWordData(uint8(cmd), w)
+}
+
+// decoder returns the function decoding the words of the given command.
+func (d *Device) decoder(cmd Command) (func(uint16) float64, error) {
+	if c, ok := d.direct[cmd]; ok {
+		return c.Decode, nil
+	}
+	if !cmd.vout() {
+		return DecodeLinear11, nil
+	}
+	m, err := d.linear16(cmd)
+	if nil != err {
+		return nil, err
+	}
+	return func(w uint16) float64 { return DecodeLinear16(w, m.Exponent()) }, nil
+}
+
+// encoder returns the function encoding the words of the given command.
+func (d *Device) encoder(cmd Command) (func(float64) (uint16, error), error) {
+	if c, ok := d.direct[cmd]; ok {
+		return c.Encode, nil
+	}
+	if !cmd.vout() {
+		return EncodeLinear11, nil
+	}
+	m, err := d.linear16(cmd)
+	if nil != err {
+		return nil, err
+	}
+	return func(x float64) (uint16, error) { return EncodeLinear16(x, m.Exponent()) }, nil
+}
+
+// linear16 returns the VOUT_MODE of the current page for the given output
+// voltage command, without DIRECT format coefficients, reading it only if not
+// cached. Returns a non-nil error if VOUT_MODE does not select LINEAR16 format.
+func (d *Device) linear16(cmd Command) (VoutMode, error) {
+	if nil == d.vout {
+		if _, err := d.VoutMode(); nil != err {
+			return 0, err
+		}
+	}
+	m := *d.vout
+	switch m.Format() {
+	case VoutLinear:
+		return m, nil
+	case VoutDirect:
+		return 0, fmt.Errorf("%s: DIRECT format coefficients not set", cmd)
+	default:
+		return 0, fmt.Errorf("%s: unsupported VOUT_MODE format: %s", cmd, m)
+	}
+}
+
+// ReadVin returns the input voltage in volts (READ_VIN).
+func (d *Device) ReadVin() (float64, error) { return d.Read(CmdReadVin) }
+
+// ReadIin returns the input current in amperes (READ_IIN).
+func (d *Device) ReadIin() (float64, error) { return d.Read(CmdReadIin) }
+
+// ReadPin returns the input power in watts (READ_PIN).
+func (d *Device) ReadPin() (float64, error) { return d.Read(CmdReadPin) }
+
+// ReadVout returns the output voltage in volts (READ_VOUT).
+func (d *Device) ReadVout() (float64, error) { return d.Read(CmdReadVout) }
+
+// ReadIout returns the output current in amperes (READ_IOUT).
+func (d *Device) ReadIout() (float64, error) { return d.Read(CmdReadIout) }
+
+// ReadPout returns the output power in watts (READ_POUT).
+func (d *Device) ReadPout() (float64, error) { return d.Read(CmdReadPout) }
+
+// ReadTemperature returns the temperature in degrees Celsius of the given
+// sensor 1-3 (READ_TEMPERATURE_1, etc.).
+func (d *Device) ReadTemperature(sensor int) (float64, error) {
+	if sensor < 1 || sensor > 3 {
+		return 0, fmt.Errorf("invalid temperature sensor: %d (1-3)", sensor)
+	}
+	return d.Read(CmdReadTemperature1 + Command(sensor-1))
+}
+
+// StatusWord returns the value of STATUS_WORD.
+func (d *Device) StatusWord() (StatusWord, error) {
+	s, err := d.ReadWordData(uint8(CmdStatusWord))
+	return StatusWord(s), err
+}
+
+// StatusVout returns the value of STATUS_VOUT.
+func (d *Device) StatusVout() (StatusVout, error) {
+	s, err := d.ReadByteData(uint8(CmdStatusVout))
+	return StatusVout(s), err
+}
+
+// StatusIout returns the value of STATUS_IOUT.
+func (d *Device) StatusIout() (StatusIout, error) {
+	s, err := d.ReadByteData(uint8(CmdStatusIout))
+	return StatusIout(s), err
+}
+
+// StatusInput returns the value of STATUS_INPUT.
+func (d *Device) StatusInput() (StatusInput, error) {
+	s, err := d.ReadByteData(uint8(CmdStatusInput))
+	return StatusInput(s), err
+}
+
+// StatusTemperature returns the value of STATUS_TEMPERATURE.
+func (d *Device) StatusTemperature() (StatusTemperature, error) {
+	s, err := d.ReadByteData(uint8(CmdStatusTemperature))
+	return StatusTemperature(s), err
+}
+
+// StatusCML returns the value of STATUS_CML.
+func (d *Device) StatusCML() (StatusCML, error) {
+	s, err := d.ReadByteData(uint8(CmdStatusCML))
+	return StatusCML(s), err
+}
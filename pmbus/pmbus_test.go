@@ -0,0 +1,201 @@
+package pmbus
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/ardnew/ft232h"
+)
+
+func TestPMBus(t *testing.T) {
+	t.Run("MPSSE", func(t *testing.T) { testPMBus(t, false) })
+	t.Run("Channel", func(t *testing.T) { testPMBus(t, true) })
+}
+
+// testPMBus tests the PMBus commands with a simulated register-file device,
+// with the simulated FT232H emulating the libMPSSE I²C channel if channel is
+// true (see ft232h.PortChannel).
+func testPMBus(t *testing.T, channel bool) {
+
+	const addr = 0x40
+
+	// each command code read returns the bytes stored for it, and the bytes of
+	// each transaction written are logged
+	reg := &ft232h.SimI2CRegFile{
+		Reg: map[uint][]uint8{
+			uint(CmdVoutMode):         {0x17},       // LINEAR16, exponent -9
+			uint(CmdReadVin):          {0xC0, 0xE0}, // 12.0 V (192·2⁻⁴)
+			uint(CmdReadIout):         {0xC0, 0xDE}, // -320·2⁻⁵ = -10.0 A
+			uint(CmdReadVout):         {0x9A, 0x01}, // 410·2⁻⁹ ≈ 0.8008 V
+			uint(CmdReadTemperature2): {0xD2, 0x04}, // DIRECT, m=1 b=0 R=1: 123.4 °C
+			uint(CmdStatusWord):       {0x42, 0x88}, // VOUT|POWER_GOOD#|OFF|CML
+			uint(CmdStatusCML):        {0x20},       // PEC_FAILED
+			uint(CmdPMBusRevision):    {0x33},
+		},
+	}
+	ft, err := ft232h.OpenSim("pmbus", func(d *ft232h.SimDevice) error {
+		d.Channel = channel
+		return d.AttachI2C(addr, reg)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	pm, err := New(ft.I2C, addr)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	pm.SetDirect(CmdReadTemperature2, Direct{M: 1, B: 0, R: 1})
+
+	for _, tc := range []struct {
+		name string
+		fn   func() (float64, error)
+		exp  float64
+	}{
+		{"ReadVin", pm.ReadVin, 12.0},
+		{"ReadIout", pm.ReadIout, -10.0},
+		{"ReadVout", pm.ReadVout, 410.0 / 512.0},
+		{"ReadTemperature", func() (float64, error) { return pm.ReadTemperature(2) }, 123.4},
+	} {
+		if v, err := tc.fn(); nil != err || math.Abs(tc.exp-v) > 1e-9 {
+			t.Fatalf("%s()={%g}, expected={%g}: %v", tc.name, v, tc.exp, err)
+		}
+	}
+
+	if _, err := pm.ReadTemperature(4); nil == err {
+		t.Fatalf("ReadTemperature(4): expected error")
+	}
+
+	if rev, err := pm.Revision(); nil != err || 0x33 != rev {
+		t.Fatalf("Revision()={%02X}, expected={%02X}: %v", rev, 0x33, err)
+	}
+
+	sw, err := pm.StatusWord()
+	if exp := StatusWordVout | StatusWordPowerGoodN | StatusWordOff | StatusWordCML; nil != err || exp != sw {
+		t.Fatalf("StatusWord()={%04X}, expected={%04X}: %v", uint16(sw), uint16(exp), err)
+	}
+	if exp := "CML|OFF|POWER_GOOD#|VOUT"; exp != sw.String() {
+		t.Fatalf("StatusWord.String()={%s}, expected={%s}", sw, exp)
+	}
+	if cml, err := pm.StatusCML(); nil != err || StatusCMLPEC != cml {
+		t.Fatalf("StatusCML()={%s}, expected={%s}: %v", cml, StatusCMLPEC, err)
+	}
+
+	reg.Log = nil
+	if err := pm.Write(CmdVoutCommand, 1.0); nil != err {
+		t.Fatalf("Write(): %v", err)
+	}
+	if err := pm.Write(CmdVinOn, 9.5); nil != err {
+		t.Fatalf("Write(): %v", err)
+	}
+	if err := pm.ClearFaults(); nil != err {
+		t.Fatalf("ClearFaults(): %v", err)
+	}
+	for i, exp := range [][]uint8{
+		{uint8(CmdVoutCommand), 0x00, 0x02}, // 512·2⁻⁹
+		{uint8(CmdVinOn), 0x60, 0xD2},       // 608·2⁻⁶
+		{uint8(CmdClearFaults)},
+	} {
+		if i >= len(reg.Log) || !bytes.Equal(exp, reg.Log[i]) {
+			t.Fatalf("transaction %d={% X}, expected={% X}", i, reg.Log, exp)
+		}
+	}
+
+	// VOUT_MODE is cached until PAGE or VOUT_MODE is written with the Device
+	reg.Reg[uint(CmdVoutMode)] = []uint8{0x16} // LINEAR16, exponent -10
+	if v, err := pm.ReadVout(); nil != err || 410.0/512.0 != v {
+		t.Fatalf("ReadVout()={%g}, expected={%g}: %v", v, 410.0/512.0, err)
+	}
+	if err := pm.SetPage(1); nil != err {
+		t.Fatalf("SetPage(): %v", err)
+	}
+	if v, err := pm.ReadVout(); nil != err || 410.0/1024.0 != v {
+		t.Fatalf("ReadVout()={%g}, expected={%g}: %v", v, 410.0/1024.0, err)
+	}
+
+	if err := pm.WriteByteData(uint8(CmdVoutMode), 0x40); nil != err { // DIRECT
+		t.Fatalf("WriteByteData(): %v", err)
+	}
+	if _, err := pm.ReadVout(); nil == err {
+		t.Fatalf("ReadVout(): expected error without DIRECT coefficients")
+	}
+	pm.SetDirect(CmdReadVout, Direct{M: 4, B: 0, R: 0})
+	if v, err := pm.ReadVout(); nil != err || 102.5 != v {
+		t.Fatalf("ReadVout()={%g}, expected={%g}: %v", v, 102.5, err)
+	}
+}
+
+func TestLinear11(t *testing.T) {
+	for _, tc := range []struct {
+		w uint16
+		x float64
+	}{
+		{0xE0C0, 12.0},      // 192·2⁻⁴
+		{0x0001, 1.0},       // 1·2⁰
+		{0x03FF, 1023.0},    // 1023·2⁰
+		{0x07FF, -1.0},      // -1·2⁰
+		{0xD2CD, 11.203125}, // 717·2⁻⁶
+		{0x7BFF, 1023.0 * (1 << 15)},
+	} {
+		if x := DecodeLinear11(tc.w); tc.x != x {
+			t.Fatalf("DecodeLinear11(%04X)={%g}, expected={%g}", tc.w, x, tc.x)
+		}
+		w, err := EncodeLinear11(tc.x)
+		if nil != err {
+			t.Fatalf("EncodeLinear11(%g): %v", tc.x, err)
+		}
+		if x := DecodeLinear11(w); tc.x != x {
+			t.Fatalf("DecodeLinear11(EncodeLinear11(%g))={%g}", tc.x, x)
+		}
+	}
+	// smallest exponent is used for best precision
+	if w, err := EncodeLinear11(1.0); nil != err || 0xBA00 != w {
+		t.Fatalf("EncodeLinear11(1.0)={%04X}, expected={%04X}: %v", w, 0xBA00, err)
+	}
+	for _, x := range []float64{math.NaN(), math.Inf(1), 1024.0 * (1 << 15)} {
+		if _, err := EncodeLinear11(x); nil == err {
+			t.Fatalf("EncodeLinear11(%g): expected error", x)
+		}
+	}
+}
+
+func TestLinear16(t *testing.T) {
+	mode := VoutMode(0x17)
+	if VoutLinear != mode.Format() || -9 != mode.Exponent() {
+		t.Fatalf("VoutMode(0x17)={%s}, expected={%s (exponent %d)}", mode, VoutLinear, -9)
+	}
+	if x := DecodeLinear16(0x0200, mode.Exponent()); 1.0 != x {
+		t.Fatalf("DecodeLinear16()={%g}, expected={%g}", x, 1.0)
+	}
+	if w, err := EncodeLinear16(3.3, mode.Exponent()); nil != err || 0x069A != w {
+		t.Fatalf("EncodeLinear16()={%04X}, expected={%04X}: %v", w, 0x069A, err)
+	}
+	for _, x := range []float64{-1.0, 128.0} {
+		if _, err := EncodeLinear16(x, mode.Exponent()); nil == err {
+			t.Fatalf("EncodeLinear16(%g): expected error", x)
+		}
+	}
+}
+
+func TestDirect(t *testing.T) {
+	c := Direct{M: 736, B: -2400, R: -2}
+	for _, w := range []uint16{0x0000, 0x7FFF, 0x8000, 0x1234} {
+		x := c.Decode(w)
+		e, err := c.Encode(x)
+		if nil != err || w != e {
+			t.Fatalf("Encode(Decode(%04X))={%04X}: %v", w, e, err)
+		}
+	}
+	if _, err := c.Encode(1e6); nil == err {
+		t.Fatalf("Encode(): expected error")
+	}
+	if _, err := (Direct{}).Encode(1.0); nil == err {
+		t.Fatalf("Encode(): expected error for invalid coefficients")
+	}
+}
@@ -0,0 +1,169 @@
+package pmbus
+
+import (
+	"strings"
+)
+
+// StatusWord is the value of the STATUS_WORD command, summarizing the status of
+// a device. Its low byte is the value of STATUS_BYTE. A set bit in the high byte
+// indicates a set bit in the corresponding detailed status register, e.g.
+// StatusWordVout for STATUS_VOUT.
+type StatusWord uint16
+
+// Constants defining the flags of StatusWord.
+const (
+	StatusWordNoneOfTheAbove StatusWord = 1 << iota // fault or warning not listed
+	StatusWordCML                                   // communication, memory, or logic fault
+	StatusWordTemperature                           // temperature fault or warning
+	StatusWordVinUVFault                            // input undervoltage fault
+	StatusWordIoutOCFault                           // output overcurrent fault
+	StatusWordVoutOVFault                           // output overvoltage fault
+	StatusWordOff                                   // unit is not providing power to the output
+	StatusWordBusy                                  // device was busy and unable to respond
+	StatusWordUnknown                               // fault type not given in bits 15-1
+	StatusWordOther                                 // bit in STATUS_OTHER is set
+	StatusWordFans                                  // fan or airflow fault or warning
+	StatusWordPowerGoodN                            // POWER_GOOD signal is negated
+	StatusWordMfrSpecific                           // bit in STATUS_MFR_SPECIFIC is set
+	StatusWordInput                                 // input voltage, current, or power fault or warning
+	StatusWordIoutPout                              // output current or power fault or warning
+	StatusWordVout                                  // output voltage fault or warning
+)
+
+// String returns the names of the flags set in a StatusWord.
+func (s StatusWord) String() string {
+	return flags(uint16(s), []string{
+		"NONE_OF_THE_ABOVE", "CML", "TEMPERATURE", "VIN_UV_FAULT",
+		"IOUT_OC_FAULT", "VOUT_OV_FAULT", "OFF", "BUSY",
+		"UNKNOWN", "OTHER", "FANS", "POWER_GOOD#",
+		"MFR_SPECIFIC", "INPUT", "IOUT/POUT", "VOUT",
+	})
+}
+
+// StatusVout is the value of the STATUS_VOUT command.
+type StatusVout uint8
+
+// Constants defining the flags of StatusVout.
+const (
+	StatusVoutTrackingError StatusVout = 1 << iota // VOUT tracking error
+	StatusVoutToffMaxWarn                          // TOFF_MAX warning
+	StatusVoutTonMaxFault                          // TON_MAX fault
+	StatusVoutMaxMinWarn                           // VOUT_MAX or VOUT_MIN warning
+	StatusVoutUVFault                              // VOUT_UV_FAULT
+	StatusVoutUVWarn                               // VOUT_UV_WARNING
+	StatusVoutOVWarn                               // VOUT_OV_WARNING
+	StatusVoutOVFault                              // VOUT_OV_FAULT
+)
+
+// String returns the names of the flags set in a StatusVout.
+func (s StatusVout) String() string {
+	return flags(uint16(s), []string{
+		"VOUT_TRACKING_ERROR", "TOFF_MAX_WARNING", "TON_MAX_FAULT", "VOUT_MAX_MIN_WARNING",
+		"VOUT_UV_FAULT", "VOUT_UV_WARNING", "VOUT_OV_WARNING", "VOUT_OV_FAULT",
+	})
+}
+
+// StatusIout is the value of the STATUS_IOUT command.
+type StatusIout uint8
+
+// Constants defining the flags of StatusIout.
+const (
+	StatusIoutPoutOPWarn  StatusIout = 1 << iota // POUT_OP_WARNING
+	StatusIoutPoutOPFault                        // POUT_OP_FAULT
+	StatusIoutPowerLimit                         // in power limiting mode
+	StatusIoutShareFault                         // current share fault
+	StatusIoutUCFault                            // IOUT_UC_FAULT
+	StatusIoutOCWarn                             // IOUT_OC_WARNING
+	StatusIoutOCLVFault                          // IOUT_OC_LV_FAULT
+	StatusIoutOCFault                            // IOUT_OC_FAULT
+)
+
+// String returns the names of the flags set in a StatusIout.
+func (s StatusIout) String() string {
+	return flags(uint16(s), []string{
+		"POUT_OP_WARNING", "POUT_OP_FAULT", "POWER_LIMITING", "CURRENT_SHARE_FAULT",
+		"IOUT_UC_FAULT", "IOUT_OC_WARNING", "IOUT_OC_LV_FAULT", "IOUT_OC_FAULT",
+	})
+}
+
+// StatusInput is the value of the STATUS_INPUT command.
+type StatusInput uint8
+
+// Constants defining the flags of StatusInput.
+const (
+	StatusInputPinOPWarn  StatusInput = 1 << iota // PIN_OP_WARNING
+	StatusInputIinOCWarn                          // IIN_OC_WARNING
+	StatusInputIinOCFault                         // IIN_OC_FAULT
+	StatusInputVinLowOff                          // unit off for insufficient input voltage
+	StatusInputVinUVFault                         // VIN_UV_FAULT
+	StatusInputVinUVWarn                          // VIN_UV_WARNING
+	StatusInputVinOVWarn                          // VIN_OV_WARNING
+	StatusInputVinOVFault                         // VIN_OV_FAULT
+)
+
+// String returns the names of the flags set in a StatusInput.
+func (s StatusInput) String() string {
+	return flags(uint16(s), []string{
+		"PIN_OP_WARNING", "IIN_OC_WARNING", "IIN_OC_FAULT", "VIN_LOW_OFF",
+		"VIN_UV_FAULT", "VIN_UV_WARNING", "VIN_OV_WARNING", "VIN_OV_FAULT",
+	})
+}
+
+// StatusTemperature is the value of the STATUS_TEMPERATURE command. Bits 3-0
+// are reserved.
+type StatusTemperature uint8
+
+// Constants defining the flags of StatusTemperature.
+const (
+	StatusTemperatureUTFault StatusTemperature = 1 << (iota + 4) // UT_FAULT
+	StatusTemperatureUTWarn                                      // UT_WARNING
+	StatusTemperatureOTWarn                                      // OT_WARNING
+	StatusTemperatureOTFault                                     // OT_FAULT
+)
+
+// String returns the names of the flags set in a StatusTemperature.
+func (s StatusTemperature) String() string {
+	return flags(uint16(s), []string{
+		"", "", "", "",
+		"UT_FAULT", "UT_WARNING", "OT_WARNING", "OT_FAULT",
+	})
+}
+
+// StatusCML is the value of the STATUS_CML command, reporting communication,
+// memory, and logic faults. Bit 2 is reserved.
+type StatusCML uint8
+
+// Constants defining the flags of StatusCML.
+const (
+	StatusCMLOtherMemory    StatusCML = 1 << iota // other memory or logic fault
+	StatusCMLOtherComm                            // other communication fault
+	_                                             // reserved
+	StatusCMLProcessor                            // processor fault
+	StatusCMLMemory                               // memory fault
+	StatusCMLPEC                                  // packet error check failed
+	StatusCMLInvalidData                          // invalid or unsupported data received
+	StatusCMLInvalidCommand                       // invalid or unsupported command received
+)
+
+// String returns the names of the flags set in a StatusCML.
+func (s StatusCML) String() string {
+	return flags(uint16(s), []string{
+		"OTHER_MEMORY_LOGIC_FAULT", "OTHER_COMMUNICATION_FAULT", "", "PROCESSOR_FAULT",
+		"MEMORY_FAULT", "PEC_FAILED", "INVALID_DATA", "INVALID_COMMAND",
+	})
+}
+
+// flags returns the given names of the bits set in v, indexed by bit number and
+// separated by "|", or "OK" if no named bits are set.
+func flags(v uint16, name []string) string {
+	set := []string{}
+	for i, n := range name {
+		if "" != n && 0 != (v&(1<<uint(i))) {
+			set = append(set, n)
+		}
+	}
+	if 0 == len(set) {
+		return "OK"
+	}
+	return strings.Join(set, "|")
+}
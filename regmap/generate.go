@@ -0,0 +1,199 @@
+package regmap
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Generate writes the Go source code of the typed accessors of the receiver
+// register map to the given writer. The given source is the name of the
+// register map file, recorded in the generated code's header.
+//
+// The generated code declares:
+//   - type Regs, constructed with NewRegs, with methods reading (Name) and
+//     writing (SetName) each register, reading each register scaled to
+//     engineering units (NameScaled), and reading (NameField) and
+//     read-modify-writing (SetNameField) each bitfield of readable registers
+//   - constants RegName, the address of each register, and NameReset, the
+//     reset value of each register with a known reset value
+//   - variables NameField, the ft232h.RegField of each bitfield
+func (m *Map) Generate(w io.Writer, source string) error {
+
+	if err := m.Validate(); nil != err {
+		return err
+	}
+	if "" == m.Package {
+		return fmt.Errorf("package name not given")
+	}
+
+	var buf bytes.Buffer
+	if err := genTemplate.Execute(&buf, struct {
+		*Map
+		Source string
+	}{m, source}); nil != err {
+		return err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if nil != err {
+		return fmt.Errorf("formatting generated code: %v", err)
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+// genFuncs contains the functions available to genTemplate.
+var genFuncs = template.FuncMap{
+	"comment": func(s string) string {
+		return strings.Replace(strings.TrimSpace(s), "\n", "\n// ", -1)
+	},
+	"bit": func(b *uint) string {
+		if nil == b {
+			return "ft232h.SPIRegNoBit"
+		}
+		return fmt.Sprintf("%d", *b)
+	},
+	"hex": func(v interface{}, bytes uint) string {
+		return fmt.Sprintf("0x%0*X", 2*bytes, v)
+	},
+}
+
+// genTemplate is the template of the generated code, executed with a Map.
+var genTemplate = template.Must(template.New("regmap").Funcs(genFuncs).Parse(`
+// Code generated by regmapgen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"fmt"
+
+	"github.com/ardnew/ft232h"
+)
+
+// Constants defining the register addresses of the {{.Device}}.
+const (
+{{- range .Registers}}
+	Reg{{.Name}} uint = {{hex .Addr $.AddrBytes}}
+{{- end}}
+)
+
+{{if .HasReset}}
+// Constants defining the reset values of the {{.Device}} registers.
+const (
+{{- range .Registers}}{{if .Reset}}
+	{{.Name}}Reset uint{{.GoBits}} = {{hex .ResetValue .Bytes}}
+{{- end}}{{end}}
+)
+{{end}}{{range $r := .Registers}}{{if .Fields}}
+// Bitfields of the {{$.Device}} {{.Name}} register.
+var (
+{{- range .Fields}}
+	{{$r.Name}}{{.Name}} = ft232h.RegField{Name: "{{.Name}}", Shift: {{.Bits.Shift}}, Width: {{.Bits.Width}}}{{if .Doc}} // {{.Doc}}{{end}}
+{{- end}}
+)
+{{end}}{{end}}
+// Regs provides typed access to the registers of the {{.Device}}.
+type Regs struct {
+{{- if eq .Bus "i2c"}}
+	i2c   *ft232h.I2C
+	slave uint
+{{- else}}
+	spi *ft232h.SPI
+{{- end}}
+}
+{{if eq .Bus "i2c"}}
+// NewRegs returns a new Regs for the {{.Device}} with the given unshifted I²C
+// slave address on the given I²C interface.
+func NewRegs(i2c *ft232h.I2C, slave uint) *Regs {
+	return &Regs{i2c: i2c, slave: slave}
+}
+
+// reg returns the I2CReg of the register with the given address.
+func (r *Regs) reg(addr uint) *ft232h.I2CReg {
+	return r.i2c.Reg(r.slave, addr, ft232h.Addr{{.AddrSpace}}Bit, ft232h.{{.ByteOrder}})
+}
+{{else}}
+// NewRegs returns a new Regs for the {{.Device}} on the given SPI interface,
+// which must already be configured with the device's chip-select pin and SPI
+// mode.
+func NewRegs(spi *ft232h.SPI) *Regs {
+	return &Regs{spi: spi}
+}
+
+// spiFormat is the command format of the {{.Device}} registers.
+var spiFormat = ft232h.SPIRegFormat{
+	Space:     ft232h.Addr{{.AddrSpace}}Bit,
+	Order:     ft232h.{{.ByteOrder}},
+	ReadBit:   {{bit .SPI.ReadBit}},
+	ReadClear: {{.SPI.ReadClear}},
+	IncBit:    {{bit .SPI.IncBit}},
+	Dummy:     {{.SPI.Dummy}},
+}
+
+// reg returns the SPIReg of the register with the given address.
+func (r *Regs) reg(addr uint) *ft232h.SPIReg {
+	return r.spi.Reg(addr, spiFormat)
+}
+{{end}}
+// read reads the register with the given address and size in bytes.
+func (r *Regs) read(addr uint, size uint) (uint64, error) {
+	return r.reg(addr).Read(size)
+}
+
+// write writes the given value to the register with the given address and size
+// in bytes.
+func (r *Regs) write(addr uint, size uint, value uint64) error {
+	return r.reg(addr).Write(size, value)
+}
+
+// setField performs a read-modify-write of the register with the given address
+// and size in bytes, setting the given bitfield to value.
+func (r *Regs) setField(addr uint, size uint, field ft232h.RegField, value uint64) error {
+	return r.reg(addr).SetField(size, field, value)
+}
+
+// String returns a descriptive string of a Regs.
+func (r *Regs) String() string {
+{{- if eq .Bus "i2c"}}
+	return fmt.Sprintf("{{.Device}} (I²C 0x%02X)", r.slave)
+{{- else}}
+	return fmt.Sprintf("{{.Device}} (SPI %s)", r.spi)
+{{- end}}
+}
+{{range $r := .Registers}}{{if .Access.Readable}}
+// {{.Name}} reads the {{.Name}} register.{{if .Doc}}
+// {{comment .Doc}}{{end}}
+func (r *Regs) {{.Name}}() ({{.GoType}}, error) {
+	v, err := r.read(Reg{{.Name}}, {{.Bytes}})
+	return {{.GoType}}(v), err
+}
+{{end}}{{if .Access.Writable}}
+// Set{{.Name}} writes the {{.Name}} register.{{if and .Doc (not .Access.Readable)}}
+// {{comment .Doc}}{{end}}
+func (r *Regs) Set{{.Name}}(value {{.GoType}}) error {
+	return r.write(Reg{{.Name}}, {{.Bytes}}, uint64(uint{{.GoBits}}(value)))
+}
+{{end}}{{if and .Scale .Access.Readable}}
+// {{.Name}}Scaled reads the {{.Name}} register, returning its value in {{or .Unit "engineering units"}}.
+func (r *Regs) {{.Name}}Scaled() (float64, error) {
+	v, err := r.{{.Name}}()
+	return float64(v) * {{.Scale}}, err
+}
+{{end}}{{if .Access.Readable}}{{range .Fields}}
+// {{$r.Name}}{{.Name}} reads the {{.Name}}[{{.Bits}}] bitfield of the {{$r.Name}} register.
+func (r *Regs) {{$r.Name}}{{.Name}}() (uint64, error) {
+	v, err := r.read(Reg{{$r.Name}}, {{$r.Bytes}})
+	return {{$r.Name}}{{.Name}}.Get(v), err
+}
+{{if $r.Access.Writable}}
+// Set{{$r.Name}}{{.Name}} sets the {{.Name}}[{{.Bits}}] bitfield of the {{$r.Name}} register,
+// leaving all other bits unchanged.
+func (r *Regs) Set{{$r.Name}}{{.Name}}(value uint64) error {
+	return r.setField(Reg{{$r.Name}}, {{$r.Bytes}}, {{$r.Name}}{{.Name}}, value)
+}
+{{end}}{{end}}{{end}}{{end}}`))
@@ -0,0 +1,384 @@
+/*
+Package regmap describes the register map of a peripheral device, and generates
+typed Go accessors for its registers and bitfields built on the I²C (I2CReg) or
+SPI (SPIReg) register interfaces of github.com/ardnew/ft232h.
+
+A register map is described in JSON (see Map), for example:
+
+	{
+	  "package": "ina260",
+	  "device": "INA260",
+	  "bus": "i2c",
+	  "addrSpace": 8,
+	  "byteOrder": "MSB",
+	  "registers": [
+	    {
+	      "name": "Config", "addr": "0x00", "width": 16, "access": "rw",
+	      "reset": "0x6127",
+	      "fields": [
+	        { "name": "RST",  "bits": "15" },
+	        { "name": "AVG",  "bits": "11:9" },
+	        { "name": "MODE", "bits": "2:0" }
+	      ]
+	    },
+	    {
+	      "name": "Current", "addr": "0x01", "width": 16, "access": "r",
+	      "signed": true, "scale": 1.25, "unit": "mA"
+	    }
+	  ]
+	}
+
+Integer values may be given as JSON numbers, or as strings in any base accepted
+by strconv.ParseUint (e.g., "0x6127").
+
+The regmapgen command generates the accessors from a register map file, and is
+intended to be run with go generate, e.g.:
+
+	//go:generate go run github.com/ardnew/ft232h/regmap/regmapgen -in ina260.json
+*/
+package regmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Map describes the register map of a peripheral device.
+type Map struct {
+	Package   string     `json:"package"`   // name of the generated Go package ($GOPACKAGE with regmapgen, if empty)
+	Device    string     `json:"device"`    // name of the device, used in docs
+	Bus       Bus        `json:"bus"`       // serial interface of the device
+	AddrSpace uint       `json:"addrSpace"` // register address bits: 8 (default), 16, 32, or 64
+	ByteOrder string     `json:"byteOrder"` // byte order of addresses and data: "MSB" (default) or "LSB"
+	SPI       *SPIFormat `json:"spi"`       // command format of an SPI device
+	Registers []Register `json:"registers"` // registers of the device
+}
+
+// Bus identifies the serial interface of a device.
+type Bus string
+
+// Constants defining the supported serial interfaces.
+const (
+	BusI2C Bus = "i2c"
+	BusSPI Bus = "spi"
+)
+
+// SPIFormat describes the command format of an SPI device: each transfer begins
+// with a command of AddrSpace bits containing the register address and the
+// optional flag bits (see ft232h.SPIRegFormat).
+type SPIFormat struct {
+	ReadBit   *uint `json:"readBit"`   // position of the read/write flag bit, if any
+	ReadClear bool  `json:"readClear"` // read/write flag is clear to read and set to write
+	IncBit    *uint `json:"incBit"`    // position of the auto-increment flag bit, if any
+	Dummy     uint  `json:"dummy"`     // number of dummy clock cycles between a read command and its data
+}
+
+// flags returns the mask of the flag bits of the command format, and a non-nil
+// error if the flag bits are outside the given address space or overlap.
+func (f *SPIFormat) flags(space uint) (uint64, error) {
+	mask := uint64(0)
+	for _, b := range []*uint{f.ReadBit, f.IncBit} {
+		if nil == b {
+			continue
+		}
+		if *b >= space {
+			return 0, fmt.Errorf("SPI flag bit outside %d-bit address space: %d", space, *b)
+		}
+		if 0 != mask&(1<<*b) {
+			return 0, fmt.Errorf("SPI flag bits overlap: %d", *b)
+		}
+		mask |= 1 << *b
+	}
+	return mask, nil
+}
+
+// Register describes a register of a device.
+type Register struct {
+	Name   string  `json:"name"`   // exported Go identifier of the register
+	Doc    string  `json:"doc"`    // description of the register
+	Addr   Uint    `json:"addr"`   // register address
+	Width  uint    `json:"width"`  // register width in bits, a multiple of 8
+	Access Access  `json:"access"` // access mode: "r", "w", or "rw" (default)
+	Reset  *Uint   `json:"reset"`  // value after reset, if known
+	Signed bool    `json:"signed"` // value is two's complement (width 8, 16, 32, or 64)
+	Scale  float64 `json:"scale"`  // engineering units per LSB, if non-zero
+	Unit   string  `json:"unit"`   // engineering unit of the scaled value
+	Fields []Field `json:"fields"` // bitfields of the register
+}
+
+// Field describes a bitfield of a register.
+type Field struct {
+	Name string `json:"name"` // exported Go identifier of the bitfield
+	Doc  string `json:"doc"`  // single-line description of the bitfield
+	Bits Bits   `json:"bits"` // bit range "msb:lsb", or single bit "n"
+}
+
+// Access identifies the access mode of a register.
+type Access string
+
+// Constants defining the register access modes.
+const (
+	AccessRead      Access = "r"
+	AccessWrite     Access = "w"
+	AccessReadWrite Access = "rw"
+)
+
+// Readable returns true if the register can be read.
+func (a Access) Readable() bool { return AccessWrite != a }
+
+// Writable returns true if the register can be written.
+func (a Access) Writable() bool { return AccessRead != a }
+
+// Uint is an unsigned integer given as a JSON number or string.
+type Uint uint64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (u *Uint) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	v, err := strconv.ParseUint(s, 0, 64)
+	if nil != err {
+		return fmt.Errorf("invalid unsigned integer: %s", b)
+	}
+	*u = Uint(v)
+	return nil
+}
+
+// Bits is the bit range of a bitfield.
+type Bits struct {
+	Shift uint // bit position of the least significant bit
+	Width uint // number of bits
+}
+
+// String returns the bit range in the form "msb:lsb", or "n" for a single bit.
+func (b Bits) String() string {
+	if b.Width > 1 {
+		return fmt.Sprintf("%d:%d", b.Shift+b.Width-1, b.Shift)
+	}
+	return fmt.Sprintf("%d", b.Shift)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Bits) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	hi, lo := s, s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		hi, lo = s[:i], s[i+1:]
+	}
+	h, herr := strconv.ParseUint(hi, 10, 8)
+	l, lerr := strconv.ParseUint(lo, 10, 8)
+	if nil != herr || nil != lerr || h < l {
+		return fmt.Errorf("invalid bit range: %s", data)
+	}
+	b.Shift, b.Width = uint(l), uint(h-l+1)
+	return nil
+}
+
+// Load reads and validates a register map in JSON format from the given
+// reader, returning the register map and a non-nil error if unsuccessful.
+// Defaults are applied to any optional settings not given.
+func Load(r io.Reader) (*Map, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	m := &Map{}
+	if err := dec.Decode(m); nil != err {
+		return nil, err
+	}
+	if err := m.Validate(); nil != err {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate applies defaults to the optional settings of the receiver register
+// map, and returns a non-nil error if the register map is not valid.
+func (m *Map) Validate() error {
+
+	if "" != m.Package && !token.IsIdentifier(m.Package) {
+		return fmt.Errorf("invalid package name: %q", m.Package)
+	}
+
+	if 0 == m.AddrSpace {
+		m.AddrSpace = 8
+	}
+	switch m.AddrSpace {
+	case 8, 16, 32, 64:
+	default:
+		return fmt.Errorf("invalid address space: %d (8, 16, 32, or 64)", m.AddrSpace)
+	}
+
+	flags := uint64(0)
+	switch m.Bus {
+	case BusI2C:
+		if nil != m.SPI {
+			return fmt.Errorf("SPI command format given for %s device", m.Bus)
+		}
+	case BusSPI:
+		if nil == m.SPI {
+			m.SPI = &SPIFormat{}
+		}
+		f, err := m.SPI.flags(m.AddrSpace)
+		if nil != err {
+			return err
+		}
+		flags = f
+	default:
+		return fmt.Errorf("invalid bus: %q (%q or %q)", m.Bus, BusI2C, BusSPI)
+	}
+
+	if "" == m.ByteOrder {
+		m.ByteOrder = "MSB"
+	}
+	if m.ByteOrder != "MSB" && m.ByteOrder != "LSB" {
+		return fmt.Errorf("invalid byte order: %q (MSB or LSB)", m.ByteOrder)
+	}
+
+	if 0 == len(m.Registers) {
+		return fmt.Errorf("no registers defined")
+	}
+
+	name := map[string]bool{}
+	for i := range m.Registers {
+		r := &m.Registers[i]
+		if err := r.validate(m.AddrSpace); nil != err {
+			return err
+		}
+		if 0 != uint64(r.Addr)&flags {
+			return fmt.Errorf("register %s: address overlaps SPI flag bits: 0x%X",
+				r.Name, uint64(r.Addr))
+		}
+		for _, n := range r.names() {
+			if name[n] {
+				return fmt.Errorf("duplicate identifier: %s", n)
+			}
+			name[n] = true
+		}
+	}
+
+	return nil
+}
+
+// validate applies defaults to the optional settings of the receiver register,
+// and returns a non-nil error if the register is not valid in the given
+// address space.
+func (r *Register) validate(space uint) error {
+
+	if !exported(r.Name) {
+		return fmt.Errorf("invalid register name: %q (exported Go identifier)", r.Name)
+	}
+
+	if space < 64 && uint64(r.Addr) >= 1<<space {
+		return fmt.Errorf("register %s: address outside %d-bit address space: 0x%X",
+			r.Name, space, uint64(r.Addr))
+	}
+
+	if 0 == r.Width || r.Width > 64 || 0 != r.Width%8 {
+		return fmt.Errorf("register %s: invalid width: %d (8-64, multiple of 8)",
+			r.Name, r.Width)
+	}
+
+	if r.Signed && r.Width != r.GoBits() {
+		return fmt.Errorf("register %s: signed width must be 8, 16, 32, or 64: %d",
+			r.Name, r.Width)
+	}
+
+	if "" == r.Access {
+		r.Access = AccessReadWrite
+	}
+	switch r.Access {
+	case AccessRead, AccessWrite, AccessReadWrite:
+	default:
+		return fmt.Errorf("register %s: invalid access mode: %q (r, w, or rw)",
+			r.Name, r.Access)
+	}
+
+	if nil != r.Reset && r.Width < 64 && uint64(*r.Reset) >= 1<<r.Width {
+		return fmt.Errorf("register %s: reset value exceeds width: 0x%X",
+			r.Name, uint64(*r.Reset))
+	}
+
+	used := uint64(0)
+	for _, f := range r.Fields {
+		if !exported(f.Name) {
+			return fmt.Errorf("register %s: invalid field name: %q (exported Go identifier)",
+				r.Name, f.Name)
+		}
+		if strings.ContainsAny(f.Doc, "\r\n") {
+			return fmt.Errorf("register %s: field %s: description must be a single line",
+				r.Name, f.Name)
+		}
+		if 0 == f.Bits.Width || f.Bits.Shift+f.Bits.Width > r.Width {
+			return fmt.Errorf("register %s: field %s: bits outside register: %s",
+				r.Name, f.Name, f.Bits)
+		}
+		mask := (^uint64(0) >> (64 - f.Bits.Width)) << f.Bits.Shift
+		if 0 != used&mask {
+			return fmt.Errorf("register %s: field %s: bits overlap another field: %s",
+				r.Name, f.Name, f.Bits)
+		}
+		used |= mask
+	}
+
+	return nil
+}
+
+// names returns the identifiers of the receiver register's bitfields, each
+// prefixed with the register name, and the register name itself.
+func (r *Register) names() []string {
+	n := []string{r.Name}
+	for _, f := range r.Fields {
+		n = append(n, r.Name+f.Name)
+	}
+	return n
+}
+
+// AddrBytes returns the size of a register address in bytes.
+func (m *Map) AddrBytes() uint { return m.AddrSpace / 8 }
+
+// HasReset returns true if the reset value of any register is known.
+func (m *Map) HasReset() bool {
+	for _, r := range m.Registers {
+		if nil != r.Reset {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetValue returns the reset value of the register, or 0 if unknown.
+func (r *Register) ResetValue() uint64 {
+	if nil == r.Reset {
+		return 0
+	}
+	return uint64(*r.Reset)
+}
+
+// Bytes returns the register width in bytes.
+func (r *Register) Bytes() uint { return r.Width / 8 }
+
+// GoBits returns the width of the smallest Go integer type that can hold the
+// register value.
+func (r *Register) GoBits() uint {
+	for _, b := range []uint{8, 16, 32} {
+		if r.Width <= b {
+			return b
+		}
+	}
+	return 64
+}
+
+// GoType returns the Go integer type of the register value.
+func (r *Register) GoType() string {
+	if r.Signed {
+		return fmt.Sprintf("int%d", r.GoBits())
+	}
+	return fmt.Sprintf("uint%d", r.GoBits())
+}
+
+// exported returns true if the given name is an exported Go identifier.
+func exported(name string) bool {
+	return token.IsIdentifier(name) && token.IsExported(name)
+}
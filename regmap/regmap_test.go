@@ -0,0 +1,169 @@
+package regmap
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const testMapI2C = `{
+  "package": "dev",
+  "device": "DEV",
+  "bus": "i2c",
+  "registers": [
+    {
+      "name": "Config", "addr": "0x00", "width": 16, "reset": "0x6127",
+      "fields": [
+        { "name": "RST", "bits": "15", "doc": "reset" },
+        { "name": "AVG", "bits": "11:9" }
+      ]
+    },
+    { "name": "Current", "addr": 1, "width": 16, "access": "r", "signed": true, "scale": 1.25, "unit": "mA" },
+    { "name": "Command", "addr": "0x10", "width": 8, "access": "w" }
+  ]
+}`
+
+const testMapSPI = `{
+  "package": "dev",
+  "device": "DEV",
+  "bus": "spi",
+  "addrSpace": 16,
+  "byteOrder": "LSB",
+  "spi": { "readBit": 15, "dummy": 8 },
+  "registers": [
+    { "name": "WhoAmI", "addr": "0x0F", "width": 8, "access": "r", "reset": "0x33" },
+    { "name": "Out", "addr": "0x28", "width": 24, "access": "r" }
+  ]
+}`
+
+func TestLoad(t *testing.T) {
+
+	m, err := Load(strings.NewReader(testMapI2C))
+	if nil != err {
+		t.Fatalf("Load(): %v", err)
+	}
+	if 8 != m.AddrSpace || "MSB" != m.ByteOrder {
+		t.Fatalf("Load()={%d, %s}, expected={%d, %s}", m.AddrSpace, m.ByteOrder, 8, "MSB")
+	}
+	cfg := m.Registers[0]
+	if AccessReadWrite != cfg.Access || 0x6127 != cfg.ResetValue() || 2 != cfg.Bytes() {
+		t.Fatalf("Load()={%s, %04X, %d}, expected={%s, %04X, %d}",
+			cfg.Access, cfg.ResetValue(), cfg.Bytes(), AccessReadWrite, 0x6127, 2)
+	}
+	if (Bits{Shift: 9, Width: 3}) != cfg.Fields[1].Bits || "11:9" != cfg.Fields[1].Bits.String() {
+		t.Fatalf("Load()={%+v}, expected={%s}", cfg.Fields[1].Bits, "11:9")
+	}
+	if "int16" != m.Registers[1].GoType() {
+		t.Fatalf("GoType()={%s}, expected={%s}", m.Registers[1].GoType(), "int16")
+	}
+
+	for _, tc := range []struct {
+		name string
+		json string
+	}{
+		{"unknown field", `{"package":"p","bus":"i2c","extra":1,"registers":[{"name":"A","width":8}]}`},
+		{"bus", `{"package":"p","bus":"uart","registers":[{"name":"A","width":8}]}`},
+		{"SPI format", `{"package":"p","bus":"i2c","spi":{},"registers":[{"name":"A","width":8}]}`},
+		{"address space", `{"package":"p","bus":"i2c","addrSpace":12,"registers":[{"name":"A","width":8}]}`},
+		{"byte order", `{"package":"p","bus":"i2c","byteOrder":"BE","registers":[{"name":"A","width":8}]}`},
+		{"no registers", `{"package":"p","bus":"i2c"}`},
+		{"register name", `{"package":"p","bus":"i2c","registers":[{"name":"a","width":8}]}`},
+		{"address", `{"package":"p","bus":"i2c","registers":[{"name":"A","addr":256,"width":8}]}`},
+		{"width", `{"package":"p","bus":"i2c","registers":[{"name":"A","width":12}]}`},
+		{"signed width", `{"package":"p","bus":"i2c","registers":[{"name":"A","width":24,"signed":true}]}`},
+		{"access", `{"package":"p","bus":"i2c","registers":[{"name":"A","width":8,"access":"x"}]}`},
+		{"reset", `{"package":"p","bus":"i2c","registers":[{"name":"A","width":8,"reset":"0x100"}]}`},
+		{"bits", `{"package":"p","bus":"i2c","registers":[{"name":"A","width":8,"fields":[{"name":"F","bits":"8"}]}]}`},
+		{"bit range", `{"package":"p","bus":"i2c","registers":[{"name":"A","width":8,"fields":[{"name":"F","bits":"0:3"}]}]}`},
+		{"overlap", `{"package":"p","bus":"i2c","registers":[{"name":"A","width":8,"fields":[{"name":"F","bits":"3:0"},{"name":"G","bits":"4:3"}]}]}`},
+		{"SPI flag bit", `{"package":"p","bus":"spi","spi":{"readBit":8},"registers":[{"name":"A","width":8}]}`},
+		{"SPI flag overlap", `{"package":"p","bus":"spi","spi":{"readBit":7,"incBit":7},"registers":[{"name":"A","width":8}]}`},
+		{"SPI address", `{"package":"p","bus":"spi","spi":{"readBit":7},"registers":[{"name":"A","addr":"0x80","width":8}]}`},
+		{"duplicate", `{"package":"p","bus":"i2c","registers":[{"name":"AB","width":8},{"name":"A","addr":1,"width":8,"fields":[{"name":"B","bits":"0"}]}]}`},
+	} {
+		if _, err := Load(strings.NewReader(tc.json)); nil == err {
+			t.Fatalf("Load(): expected error for invalid %s", tc.name)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+
+	for _, tc := range []struct {
+		json string
+		decl []string
+		none []string
+	}{
+		{
+			json: testMapI2C,
+			decl: []string{
+				"RegConfig  uint = 0x00",
+				"ConfigReset uint16 = 0x6127",
+				`ConfigRST = ft232h.RegField{Name: "RST", Shift: 15, Width: 1} // reset`,
+				"func NewRegs(i2c *ft232h.I2C, slave uint) *Regs",
+				"ft232h.Addr8Bit, ft232h.MSB",
+				"func (r *Regs) Config() (uint16, error)",
+				"func (r *Regs) SetConfigAVG(value uint64) error",
+				"func (r *Regs) Current() (int16, error)",
+				"func (r *Regs) CurrentScaled() (float64, error)",
+				"func (r *Regs) SetCommand(value uint8) error",
+			},
+			none: []string{
+				"func (r *Regs) SetCurrent(",
+				"func (r *Regs) Command(",
+			},
+		},
+		{
+			json: testMapSPI,
+			decl: []string{
+				"RegWhoAmI uint = 0x000F",
+				"WhoAmIReset uint8 = 0x33",
+				"func NewRegs(spi *ft232h.SPI) *Regs",
+				"Space:     ft232h.Addr16Bit",
+				"Order:     ft232h.LSB",
+				"ReadBit:   15",
+				"IncBit:    ft232h.SPIRegNoBit",
+				"Dummy:     8",
+				"func (r *Regs) reg(addr uint) *ft232h.SPIReg",
+				"func (r *Regs) Out() (uint32, error)",
+			},
+			none: []string{
+				"func (r *Regs) SetWhoAmI(",
+			},
+		},
+	} {
+		m, err := Load(strings.NewReader(tc.json))
+		if nil != err {
+			t.Fatalf("Load(): %v", err)
+		}
+		var buf bytes.Buffer
+		if err := m.Generate(&buf, "dev.json"); nil != err {
+			t.Fatalf("Generate(): %v", err)
+		}
+		src := buf.String()
+		if _, err := parser.ParseFile(token.NewFileSet(), "dev_regs.go", src, 0); nil != err {
+			t.Fatalf("Generate(): invalid Go source: %v", err)
+		}
+		if !strings.HasPrefix(src, "// Code generated by regmapgen from dev.json. DO NOT EDIT.") {
+			t.Fatalf("Generate(): missing generated code header")
+		}
+		for _, d := range tc.decl {
+			if !strings.Contains(src, d) {
+				t.Fatalf("Generate(): missing {%s}", d)
+			}
+		}
+		for _, d := range tc.none {
+			if strings.Contains(src, d) {
+				t.Fatalf("Generate(): unexpected {%s}", d)
+			}
+		}
+	}
+
+	m, _ := Load(strings.NewReader(testMapI2C))
+	m.Package = ""
+	if err := m.Generate(&bytes.Buffer{}, "dev.json"); nil == err {
+		t.Fatalf("Generate(): expected error without package name")
+	}
+}
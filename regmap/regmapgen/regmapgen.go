@@ -0,0 +1,65 @@
+// Command regmapgen generates typed Go accessors for the registers and
+// bitfields of a peripheral device from its register map file (see package
+// github.com/ardnew/ft232h/regmap).
+//
+// It is intended to be run with go generate, e.g.:
+//
+//	//go:generate go run github.com/ardnew/ft232h/regmap/regmapgen -in ina260.json
+//
+// which writes the generated code to ina260_regs.go. If the register map does
+// not name a package, the package of the file containing the go:generate
+// directive ($GOPACKAGE) is used.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ardnew/ft232h/regmap"
+)
+
+func main() {
+
+	log.SetFlags(0)
+	log.SetPrefix("regmapgen: ")
+
+	in := flag.String("in", "", "register map `file` (JSON)")
+	out := flag.String("out", "", "output `file` (default: <in>_regs.go)")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "package `name`, if not given in the register map")
+	flag.Parse()
+
+	if "" == *in {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if "" == *out {
+		*out = strings.TrimSuffix(*in, filepath.Ext(*in)) + "_regs.go"
+	}
+
+	f, err := os.Open(*in)
+	if nil != err {
+		log.Fatalf("%v", err)
+	}
+	defer f.Close()
+
+	m, err := regmap.Load(f)
+	if nil != err {
+		log.Fatalf("%s: %v", *in, err)
+	}
+	if "" == m.Package {
+		m.Package = *pkg
+	}
+
+	var buf bytes.Buffer
+	if err := m.Generate(&buf, filepath.Base(*in)); nil != err {
+		log.Fatalf("%s: %v", *in, err)
+	}
+	if err := ioutil.WriteFile(*out, buf.Bytes(), 0644); nil != err {
+		log.Fatalf("%v", err)
+	}
+}
@@ -0,0 +1,1569 @@
+package ft232h
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// Sim implements Transport with simulated FT232H devices stored entirely in
+// memory. Each simulated device interprets the MPSSE commands written to it,
+// and routes the resulting pin activity to Go-implemented GPIO, SPI, and I²C
+// peripherals attached to it, so that device drivers can be exercised without
+// any hardware connected.
+//
+// Use a Sim by adding one or more devices with Add, attaching peripherals to
+// each device, and then opening a device with OpenMask (using a Mask with the
+// Transport field set to the Sim), or by assigning the Sim to DefaultTransport.
+//
+// A simulated device with its Channel field set is instead opened as a Port
+// implementing PortChannel, emulating the GPIO, SPI, and I²C channels provided
+// by libMPSSE with the D2XX/libMPSSE bridge, so that the paths taken by drivers
+// on that transport can also be exercised without hardware.
+type Sim struct {
+	dev []*SimDevice
+}
+
+// SimDevice is a simulated FT232H added to a Sim.
+//
+// The simulated pins model the wiring expected by the SPI and I²C interfaces.
+// Port "D" pins D0-D2 are SCLK/MOSI/MISO in SPI mode, and SCL/SDA-out/SDA-in
+// in I²C mode (with D1 and D2 connected together and pulled up). SPI slaves are
+// selected by driving their CS pin, on either port, as an output LOW. I²C
+// slaves are addressed by start conditions generated on SCL and SDA.
+type SimDevice struct {
+	VID     uint32
+	PID     uint32
+	Serial  string
+	Desc    string
+	Channel bool // opened as a Port implementing PortChannel
+
+	open    bool
+	mode    BitMode
+	latency uint8
+	resp    []uint8 // bytes queued for the host to read
+
+	low      uint8 // port "D" output pin levels
+	lowDir   uint8 // port "D" pin directions
+	high     uint8 // port "C" output pin levels
+	highDir  uint8 // port "C" pin directions
+	loopback bool
+	adaptive bool // adaptive clocking enabled
+	stalled  bool // MPSSE waiting indefinitely on RTCK
+
+	gpio  []SimGPIO
+	dgpio []SimGPIO
+	spi   []*simSPISlave
+	i2c   map[uint]SimI2C
+	bus   simI2CBus
+	uart  SimUART
+	jtag  SimJTAG
+
+	baud     uint32       // UART baud rate
+	dataBits uint8        // UART data bits
+	parity   UARTParity   // UART parity bit
+	stopBits UARTStopBits // UART stop bits
+	flow     UARTFlow     // UART hardware flow control
+	dtr, rts bool         // UART modem control outputs asserted
+	brk      bool         // UART break condition on TXD
+
+	eeprom [EEPROMSize / 2]uint16 // configuration EEPROM words
+}
+
+// SimGPIO is a simulated peripheral connected to the GPIO ("C" port) pins of a
+// simulated FT232H.
+type SimGPIO interface {
+	// WriteGPIO is called with the direction and level of all pins each time
+	// the GPIO pins are written.
+	WriteGPIO(dir uint8, val uint8)
+	// ReadGPIO returns the levels driven by the peripheral on all pins. Only the
+	// levels of pins configured as inputs are observed by the FT232H.
+	ReadGPIO() uint8
+}
+
+// SimSPI is a simulated SPI slave device connected to a simulated FT232H.
+type SimSPI interface {
+	// Select is called with true when the slave's CS line is asserted, and with
+	// false when it is de-asserted.
+	Select(active bool)
+	// Swap receives the bytes clocked out by the master (MOSI) and returns the
+	// bytes clocked in to the master (MISO). The returned slice must have the
+	// same length as mosi. During SPI reads, mosi contains all zeros.
+	Swap(mosi []uint8) []uint8
+}
+
+// SimSPIBits is optionally implemented by a SimSPI that supports transfers of
+// individual bits. Bit-granular transfers are clocked as whole bytes followed by
+// a partial byte of 1-8 bits. If a slave does not implement SimSPIBits, the
+// partial byte is passed to Swap as a single byte.
+type SimSPIBits interface {
+	// SwapBits receives the first n bits clocked out by the master (MOSI),
+	// packed in transfer order from bit 7, and returns the bits clocked in to
+	// the master (MISO) packed in the same order.
+	SwapBits(mosi []uint8, n uint) []uint8
+}
+
+// SimI2C is a simulated I²C slave device connected to a simulated FT232H.
+// Data bytes are transferred one at a time as they are clocked on the bus, so
+// Write and Read may each be called any number of times between Start and Stop.
+type SimI2C interface {
+	// Start is called on each start (or repeated start) condition addressing
+	// the slave. The read flag is the R/W bit of the address byte. Returns true
+	// if the slave ACKs its address.
+	Start(read bool) bool
+	// Write receives the next bytes written by the master, returning the number
+	// of bytes ACKed by the slave (stopping at the first NACK).
+	Write(data []uint8) uint
+	// Read fills the given slice with the next bytes read by the master.
+	Read(data []uint8)
+	// Stop is called on each stop condition following a start condition that
+	// addressed the slave.
+	Stop()
+}
+
+// SimI2CStretch is an optional interface implemented by a SimI2C slave that
+// stretches the clock by holding SCL LOW. The simulated FT232H waits for the
+// slave to release SCL only if adaptive clocking is enabled; otherwise, each
+// bit clocked while SCL is held LOW is lost.
+type SimI2CStretch interface {
+	// Stretch is called before each bit clocked on the bus, returning true if
+	// the slave is holding SCL LOW. While adaptive clocking is enabled, it is
+	// called repeatedly until it returns false, each call representing one
+	// period of the MPSSE clock. A slave holding SCL LOW for more than
+	// SimStretchLimit periods stalls the simulated MPSSE until its bit mode is
+	// reset, and the response to all pending commands is never received.
+	Stretch() bool
+}
+
+// SimI2CAck is an optional interface implemented by a SimI2C slave that
+// observes the ACK bit clocked out by the master after each byte it reads.
+type SimI2CAck interface {
+	// Ack is called after each byte read by the master, with ack true if the
+	// master ACKed the byte, or false if it NACKed the byte, ending the read.
+	Ack(ack bool)
+}
+
+// SimUART is a simulated serial device connected to the TXD and RXD lines of a
+// simulated FT232H in UART mode.
+type SimUART interface {
+	// Transmit receives the bytes written by the host on TXD, and returns the
+	// bytes the device sends in response on RXD, which are queued for the host
+	// to read.
+	Transmit(data []uint8) []uint8
+}
+
+// SimUARTModem is an optional interface implemented by a SimUART connected to
+// the modem control and status lines of a simulated FT232H.
+type SimUARTModem interface {
+	// Control is called with the state of the DTR# and RTS# outputs (true if
+	// asserted) each time either changes.
+	Control(dtr bool, rts bool)
+	// Status returns the modem status inputs driven by the device, as a
+	// combination of UARTStatusCTS, UARTStatusDSR, UARTStatusRI, and
+	// UARTStatusDCD.
+	Status() UARTStatus
+	// Break is called with true when the host begins a break condition on TXD,
+	// and with false when it ends.
+	Break(on bool)
+}
+
+// SimJTAG is a simulated JTAG scan chain connected to the TCK (D0), TDI (D1),
+// TDO (D2), and TMS (D3) pins of a simulated FT232H.
+type SimJTAG interface {
+	// Clock is called on each rising edge of TCK with the levels of TMS and TDI,
+	// returning the level of TDO sampled by the FT232H.
+	Clock(tms bool, tdi bool) bool
+}
+
+// SimStretchLimit is the number of MPSSE clock periods after which a simulated
+// I²C slave stretching the clock is considered to hold SCL LOW indefinitely.
+const SimStretchLimit = 1000
+
+// simSPISlave associates an SPI slave device with its CS pin.
+type simSPISlave struct {
+	cs     Pin
+	dev    SimSPI
+	active bool
+}
+
+// Constants defining the default attributes of a simulated FT232H.
+const (
+	SimVIDDefault uint32 = 0x0403
+	SimPIDDefault uint32 = 0x6014
+)
+
+// NewSim constructs a new Sim with no devices.
+func NewSim() *Sim {
+	return &Sim{dev: []*SimDevice{}}
+}
+
+// Add adds a new simulated FT232H with the given USB attributes, returning the
+// device so that peripherals can be attached. If vid or pid is 0, the default
+// FTDI vendor ID and FT232H product ID are used, respectively.
+func (s *Sim) Add(vid uint32, pid uint32, serial string, desc string) *SimDevice {
+	if 0 == vid {
+		vid = SimVIDDefault
+	}
+	if 0 == pid {
+		pid = SimPIDDefault
+	}
+	d := &SimDevice{
+		VID:    vid,
+		PID:    pid,
+		Serial: serial,
+		Desc:   desc,
+		i2c:    map[uint]SimI2C{},
+	}
+	for i := range d.eeprom {
+		d.eeprom[i] = 0xFFFF // blank
+	}
+	s.dev = append(s.dev, d)
+	return d
+}
+
+// OpenSim opens a new Sim containing a single simulated FT232H with the default
+// USB attributes and the given description, after calling attach (if non-nil)
+// with the device to connect its peripherals. Returns a nil FT232H and non-nil
+// error if attach or opening the device fails.
+func OpenSim(desc string, attach func(d *SimDevice) error) (*FT232H, error) {
+	s := NewSim()
+	d := s.Add(0, 0, "SIM0", desc)
+	if nil != attach {
+		if err := attach(d); nil != err {
+			return nil, err
+		}
+	}
+	return OpenMask(&Mask{Transport: s})
+}
+
+// Devices returns a slice of DeviceInfo pointers describing each of the
+// simulated devices in the order they were added.
+func (s *Sim) Devices() ([]*DeviceInfo, error) {
+	info := make([]*DeviceInfo, len(s.dev))
+	for i, d := range s.dev {
+		info[i] = &DeviceInfo{
+			Index:   i,
+			Open:    d.open,
+			HiSpeed: true,
+			Chip:    CFT232H,
+			VID:     d.VID,
+			PID:     d.PID,
+			LocID:   uint32(i),
+			Serial:  d.Serial,
+			Desc:    d.Desc,
+		}
+	}
+	return info, nil
+}
+
+// Open opens the simulated device at the given index, returning a nil Port and
+// non-nil error if the index is invalid or the device is already open.
+func (s *Sim) Open(index int) (Port, error) {
+	if index < 0 || index >= len(s.dev) {
+		return nil, SDeviceNotFound
+	}
+	d := s.dev[index]
+	if d.open {
+		return nil, SDeviceNotOpened
+	}
+	d.open = true
+	if d.Channel {
+		return &simChannel{SimDevice: d}, nil
+	}
+	return d, nil
+}
+
+// AttachGPIO connects the given peripheral to the GPIO ("C" port) pins.
+func (d *SimDevice) AttachGPIO(p SimGPIO) {
+	d.gpio = append(d.gpio, p)
+}
+
+// AttachDGPIO connects the given peripheral to the port "D" pins. The
+// peripheral observes all pins, including those used by SPI and I²C, but only
+// the levels it drives on pins configured as inputs are observed by the FT232H.
+func (d *SimDevice) AttachDGPIO(p SimGPIO) {
+	d.dgpio = append(d.dgpio, p)
+}
+
+// AttachSPI connects the given SPI slave device using the given active-LOW CS
+// pin, which may be either a DPin (D3-D7) or CPin (GPIO). Returns a non-nil
+// error if the CS pin is invalid or is already used by another slave.
+func (d *SimDevice) AttachSPI(cs Pin, p SimSPI) error {
+	if nil == cs || !cs.Valid() || (cs.IsMPSSE() && cs.Pos() < 3) {
+		return fmt.Errorf("invalid CS pin: %v", cs)
+	}
+	for _, s := range d.spi {
+		if s.cs.Equals(cs) {
+			return fmt.Errorf("CS pin already in use: %s", cs)
+		}
+	}
+	d.spi = append(d.spi, &simSPISlave{cs: cs, dev: p})
+	return nil
+}
+
+// AttachUART connects the given serial device to the UART lines, replacing any
+// previously attached device.
+func (d *SimDevice) AttachUART(p SimUART) {
+	d.uart = p
+}
+
+// AttachJTAG connects the given scan chain to the JTAG pins, replacing any
+// previously attached chain. While no SPI slave is selected, all data shifting
+// and clocking commands clock the scan chain instead of the I²C bus.
+func (d *SimDevice) AttachJTAG(p SimJTAG) {
+	d.jtag = p
+}
+
+// AttachI2C connects the given I²C slave device using the given unshifted 7-bit
+// slave address. Returns a non-nil error if the address is invalid or is
+// already used by another slave.
+func (d *SimDevice) AttachI2C(slave uint, p SimI2C) error {
+	if slave > 0x7F {
+		return fmt.Errorf("invalid slave address: 0x%02X", slave)
+	}
+	if _, ok := d.i2c[slave]; ok {
+		return fmt.Errorf("slave address already in use: 0x%02X", slave)
+	}
+	d.i2c[slave] = p
+	return nil
+}
+
+// AttachI2CTenBit connects the given I²C slave device using the given 10-bit
+// slave address. Returns a non-nil error if the address is invalid or is
+// already used by another slave.
+func (d *SimDevice) AttachI2CTenBit(slave uint, p SimI2C) error {
+	if slave > 0x3FF {
+		return fmt.Errorf("invalid slave address: 0x%03X", slave)
+	}
+	if _, ok := d.i2c[slave|simI2CTenBit]; ok {
+		return fmt.Errorf("slave address already in use: 0x%03X", slave)
+	}
+	d.i2c[slave|simI2CTenBit] = p
+	return nil
+}
+
+// Close closes the simulated device.
+func (d *SimDevice) Close() error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	d.open = false
+	d.resp = nil
+	return nil
+}
+
+// Reset resets the simulated device, discarding any unread bytes.
+func (d *SimDevice) Reset() error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	d.resp = nil
+	return nil
+}
+
+// Purge discards any unread bytes.
+func (d *SimDevice) Purge() error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	d.resp = nil
+	return nil
+}
+
+// SetLatency sets the simulated latency timer, which has no effect.
+func (d *SimDevice) SetLatency(ms uint8) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	d.latency = ms
+	return nil
+}
+
+// SetBitMode sets the bit mode of the simulated device. Only BitModeReset and
+// BitModeMPSSE are supported. Resetting the bit mode configures all pins as
+// inputs and places the device in UART mode.
+func (d *SimDevice) SetBitMode(mask uint8, mode BitMode) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	switch mode {
+	case BitModeReset:
+		d.setLow(0, 0)
+		d.setHigh(0, 0)
+		d.bus.reset()
+		d.loopback, d.adaptive, d.stalled = false, false, false
+	case BitModeMPSSE:
+	default:
+		return SNotSupported
+	}
+	d.mode = mode
+	return nil
+}
+
+// Write interprets the given bytes as a sequence of MPSSE commands. Invalid
+// opcodes produce the MPSSE bad command response. In UART mode, the bytes are
+// instead transmitted to the attached SimUART, if any, unless a break condition
+// is set.
+func (d *SimDevice) Write(data []uint8) (int, error) {
+	if !d.open {
+		return 0, SDeviceNotOpened
+	}
+	switch d.mode {
+	case BitModeReset:
+		if nil != d.uart && !d.brk {
+			d.resp = append(d.resp, d.uart.Transmit(data)...)
+		}
+		return len(data), nil
+	case BitModeMPSSE:
+	default:
+		return 0, SNotSupported
+	}
+	for buf := data; len(buf) > 0; {
+		cmd, n, err := mpsse.DecodeOne(buf)
+		if nil != err {
+			d.resp = append(d.resp, uint8(mpsse.OpBadCommandReply), buf[0])
+			buf = buf[1:]
+			continue
+		}
+		d.exec(cmd)
+		buf = buf[n:]
+	}
+	return len(data), nil
+}
+
+// Read reads the bytes produced in response to previously written commands.
+// Returns a non-nil error if fewer than len(data) bytes are available.
+func (d *SimDevice) Read(data []uint8) (int, error) {
+	if !d.open {
+		return 0, SDeviceNotOpened
+	}
+	n := copy(data, d.resp)
+	d.resp = d.resp[n:]
+	if n < len(data) {
+		return n, fmt.Errorf("%w: %d of %d bytes", ErrReadTimeout, n, len(data))
+	}
+	return n, nil
+}
+
+// SetBaudRate sets the simulated UART baud rate, returning a non-nil error if
+// the FT232H cannot produce it.
+func (d *SimDevice) SetBaudRate(baud uint32) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	if _, _, _, err := usbBaudDivisor(baud); nil != err {
+		return err
+	}
+	d.baud = baud
+	return nil
+}
+
+// SetLine sets the simulated UART data characteristics.
+func (d *SimDevice) SetLine(bits uint8, parity UARTParity, stop UARTStopBits) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	d.dataBits, d.parity, d.stopBits = bits, parity, stop
+	return nil
+}
+
+// SetFlowControl sets the simulated UART hardware flow control, which has no
+// effect.
+func (d *SimDevice) SetFlowControl(flow UARTFlow) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	d.flow = flow
+	return nil
+}
+
+// SetBreak sets or clears the break condition on TXD, notifying the attached
+// SimUART if it implements SimUARTModem.
+func (d *SimDevice) SetBreak(on bool) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	if on != d.brk {
+		d.brk = on
+		if m, ok := d.uart.(SimUARTModem); ok {
+			m.Break(on)
+		}
+	}
+	return nil
+}
+
+// SetDTR asserts or de-asserts the DTR# output.
+func (d *SimDevice) SetDTR(on bool) error {
+	return d.modemControl(on, d.rts)
+}
+
+// SetRTS asserts or de-asserts the RTS# output.
+func (d *SimDevice) SetRTS(on bool) error {
+	return d.modemControl(d.dtr, on)
+}
+
+// modemControl sets the DTR# and RTS# outputs, notifying the attached SimUART
+// if it implements SimUARTModem.
+func (d *SimDevice) modemControl(dtr bool, rts bool) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	if dtr != d.dtr || rts != d.rts {
+		d.dtr, d.rts = dtr, rts
+		if m, ok := d.uart.(SimUARTModem); ok {
+			m.Control(dtr, rts)
+		}
+	}
+	return nil
+}
+
+// ModemStatus returns the modem status inputs driven by the attached SimUART,
+// if it implements SimUARTModem, along with the line status of an idle
+// transmitter.
+func (d *SimDevice) ModemStatus() (UARTStatus, error) {
+	if !d.open {
+		return 0, SDeviceNotOpened
+	}
+	status := UARTStatusTHRE | UARTStatusTEMT
+	if m, ok := d.uart.(SimUARTModem); ok {
+		status |= m.Status() & 0x00F0
+	}
+	return status, nil
+}
+
+// Queue returns the number of bytes available to Read.
+func (d *SimDevice) Queue() (int, error) {
+	if !d.open {
+		return 0, SDeviceNotOpened
+	}
+	return len(d.resp), nil
+}
+
+// ReadEEPROM reads the simulated configuration EEPROM word at the given word
+// address, which is initially blank (0xFFFF).
+func (d *SimDevice) ReadEEPROM(addr uint8) (uint16, error) {
+	if !d.open {
+		return 0, SDeviceNotOpened
+	}
+	if int(addr) >= len(d.eeprom) {
+		return 0, SEEPROMReadFailed
+	}
+	return d.eeprom[addr], nil
+}
+
+// WriteEEPROM writes the simulated configuration EEPROM word at the given word
+// address.
+func (d *SimDevice) WriteEEPROM(addr uint8, value uint16) error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	if int(addr) >= len(d.eeprom) {
+		return SEEPROMWriteFailed
+	}
+	d.eeprom[addr] = value
+	return nil
+}
+
+// EraseEEPROM erases the simulated configuration EEPROM.
+func (d *SimDevice) EraseEEPROM() error {
+	if !d.open {
+		return SDeviceNotOpened
+	}
+	for i := range d.eeprom {
+		d.eeprom[i] = 0xFFFF
+	}
+	return nil
+}
+
+// simChannel is a simulated FT232H opened as a Port implementing PortChannel.
+// Each transfer drives the simulated pins as the MPSSE commands issued by
+// libMPSSE would, so the attached peripherals observe the same bus activity.
+type simChannel struct {
+	*SimDevice
+	spi spiOption // SPI channel configuration options
+}
+
+// WriteGPIO sets the direction and level of all GPIO ("C" port) pins.
+func (c *simChannel) WriteGPIO(dir uint8, val uint8) error {
+	if !c.open {
+		return SDeviceNotOpened
+	}
+	c.setHigh(val, dir)
+	return nil
+}
+
+// ReadGPIO returns the level of all GPIO ("C" port) pins.
+func (c *simChannel) ReadGPIO() (uint8, error) {
+	if !c.open {
+		return 0, SDeviceNotOpened
+	}
+	return c.readHigh(), nil
+}
+
+// SPIInit initializes the SPI channel with the given configuration options and
+// initial port "D" pin directions and levels, with CS de-asserted.
+func (c *simChannel) SPIInit(clock uint32, latency uint8, options uint32, pin uint32) error {
+	if !c.open {
+		return SDeviceNotOpened
+	}
+	c.mode, c.latency, c.spi = BitModeMPSSE, latency, spiOption(options)
+	c.setLow(uint8(pin>>8), uint8(pin))
+	c.spiSelect(false)
+	return nil
+}
+
+// SPIChange changes the configuration options of the SPI channel, including
+// its CS pin, which is de-asserted.
+func (c *simChannel) SPIChange(options uint32) error {
+	if !c.open {
+		return SDeviceNotOpened
+	}
+	c.spi = spiOption(options)
+	c.spiSelect(false)
+	return nil
+}
+
+// SPIRead clocks len(data) bytes in from the selected SPI slaves.
+func (c *simChannel) SPIRead(data []uint8, opt uint32) (uint, error) {
+	return c.spiTransfer(data, nil, len(data), spiXferOption(opt))
+}
+
+// SPIWrite clocks the given bytes out to the selected SPI slaves.
+func (c *simChannel) SPIWrite(data []uint8, opt uint32) (uint, error) {
+	return c.spiTransfer(nil, data, len(data), spiXferOption(opt))
+}
+
+// SPISwap clocks the given bytes send out to, and len(send) bytes recv in from,
+// the selected SPI slaves.
+func (c *simChannel) SPISwap(recv []uint8, send []uint8, opt uint32) (uint, error) {
+	return c.spiTransfer(recv, send, len(send), spiXferOption(opt))
+}
+
+// spiTransfer clocks n bytes, asserting and de-asserting the configured CS pin
+// before and after transfer as requested by the given transfer options.
+func (c *simChannel) spiTransfer(recv []uint8, send []uint8, n int, opt spiXferOption) (uint, error) {
+	if !c.open {
+		return 0, SDeviceNotOpened
+	}
+	if (opt & spiCSAssert) > 0 {
+		c.spiSelect(true)
+	}
+	if n > 0 {
+		copy(recv, c.clock(mpsse.ClockData{
+			Out:  nil != send,
+			In:   nil != recv,
+			Len:  n,
+			Data: send,
+		}))
+	}
+	if (opt & spiCSDeAssert) > 0 {
+		c.spiSelect(false)
+	}
+	return uint(n), nil
+}
+
+// spiSelect drives the configured CS pin on port "D" to its asserted level if
+// assert is true, or its de-asserted level otherwise.
+func (c *simChannel) spiSelect(assert bool) {
+	mask := c.spi.cs().Mask()
+	if assert == c.spi.activeLow() {
+		c.setLow(c.low & ^mask, c.lowDir|mask)
+	} else {
+		c.setLow(c.low|mask, c.lowDir|mask)
+	}
+}
+
+// I2CInit initializes the I²C channel with SCL and SDA released HIGH.
+func (c *simChannel) I2CInit(clock uint32, latency uint8, options uint32) error {
+	if !c.open {
+		return SDeviceNotOpened
+	}
+	c.mode, c.latency = BitModeMPSSE, latency
+	c.setLow(simSCLK|simMOSI, simSCLK|simMOSI)
+	return nil
+}
+
+// I2CRead reads len(data) bytes from the I²C slave with the given address,
+// returning SDeviceNotFound if the slave does not ACK its address.
+func (c *simChannel) I2CRead(addr uint, data []uint8, opt uint32) (uint, error) {
+	if !c.open {
+		return 0, SDeviceNotOpened
+	}
+	o := i2cXferOption(opt)
+	if err := c.i2cAddress(addr, true, o); nil != err {
+		return 0, err
+	}
+	for i := range data {
+		nack := i == len(data)-1 && (o&i2cLastReadNACK) > 0
+		data[i] = c.clock(mpsse.ClockData{In: true, Len: 1})[0]
+		c.i2cBit(nack)
+	}
+	if (o & i2cStopBit) > 0 {
+		c.i2cStop()
+	}
+	return uint(len(data)), nil
+}
+
+// I2CWrite writes the given bytes to the I²C slave with the given address,
+// returning SDeviceNotFound if the slave does not ACK its address, or
+// SFailedToWriteDevice if the slave does not ACK a data byte and the transfer
+// options request breaking on NACK.
+func (c *simChannel) I2CWrite(addr uint, data []uint8, opt uint32) (uint, error) {
+	if !c.open {
+		return 0, SDeviceNotOpened
+	}
+	o := i2cXferOption(opt)
+	if err := c.i2cAddress(addr, false, o); nil != err {
+		return 0, err
+	}
+	for i, b := range data {
+		if !c.i2cByte(b) && (o&i2cBreakOnNACK) > 0 {
+			if (o & i2cStopBit) > 0 {
+				c.i2cStop()
+			}
+			return uint(i), SFailedToWriteDevice
+		}
+	}
+	if (o & i2cStopBit) > 0 {
+		c.i2cStop()
+	}
+	return uint(len(data)), nil
+}
+
+// i2cAddress generates the start condition and address phase requested by the
+// given transfer options, returning SDeviceNotFound (after generating the
+// requested stop condition) if the slave does not ACK its address.
+func (c *simChannel) i2cAddress(addr uint, read bool, opt i2cXferOption) error {
+	if (opt & i2cStartBit) > 0 {
+		c.setLow(simSCLK|simMOSI, simSCLK|simMOSI) // SDA HIGH, SCL HIGH
+		c.setLow(simSCLK, simSCLK|simMOSI)         // SDA LOW, SCL HIGH
+		c.setLow(0, simSCLK|simMOSI)               // SDA LOW, SCL LOW
+	}
+	if (opt & i2cNoAddress) > 0 {
+		return nil
+	}
+	b := uint8(addr << 1)
+	if read {
+		b |= 1
+	}
+	if !c.i2cByte(b) {
+		if (opt & i2cStopBit) > 0 {
+			c.i2cStop()
+		}
+		return SDeviceNotFound
+	}
+	return nil
+}
+
+// i2cByte clocks the given byte out on SDA, returning true if the slave ACKs it.
+func (c *simChannel) i2cByte(b uint8) bool {
+	c.clock(mpsse.ClockData{Out: true, Len: 1, Data: []uint8{b}})
+	return 0 == c.clock(mpsse.ClockData{In: true, Bits: true, Len: 1})[0]&1
+}
+
+// i2cBit clocks a single bit out on SDA, HIGH if set is true, e.g. the ACK
+// (LOW) or NACK (HIGH) bit following a byte read.
+func (c *simChannel) i2cBit(set bool) {
+	var b uint8
+	if set {
+		b = 0x80
+	}
+	c.clock(mpsse.ClockData{Out: true, Bits: true, Len: 1, Data: []uint8{b}})
+}
+
+// i2cStop generates a stop condition, leaving SCL and SDA released HIGH.
+func (c *simChannel) i2cStop() {
+	c.setLow(0, simSCLK|simMOSI)               // SDA LOW, SCL LOW
+	c.setLow(simSCLK, simSCLK|simMOSI)         // SDA LOW, SCL HIGH
+	c.setLow(simSCLK|simMOSI, simSCLK|simMOSI) // SDA HIGH, SCL HIGH
+}
+
+// exec executes a single MPSSE command. Commands are discarded while the MPSSE
+// is stalled.
+func (d *SimDevice) exec(cmd mpsse.Command) {
+	if d.stalled {
+		return
+	}
+	switch c := cmd.(type) {
+	case mpsse.SetLow:
+		d.setLow(c.Value, c.Dir)
+	case mpsse.SetHigh:
+		d.setHigh(c.Value, c.Dir)
+	case mpsse.GetLow:
+		d.resp = append(d.resp, d.readLow())
+	case mpsse.GetHigh:
+		d.resp = append(d.resp, d.readHigh())
+	case mpsse.Loopback:
+		d.loopback = bool(c)
+	case mpsse.AdaptiveClock:
+		d.adaptive = bool(c)
+	case mpsse.ClockData:
+		resp := d.clock(c)
+		if !d.stalled {
+			d.resp = append(d.resp, resp...)
+		}
+	case mpsse.ClockTMS:
+		d.resp = append(d.resp, d.clockTMS(c)...)
+	case mpsse.ClockBits:
+		d.clockIdle(int(c))
+	case mpsse.ClockBytes:
+		d.clockIdle(8 * c.Len)
+	default:
+		// all other commands only affect timing. respond with zeros to any that
+		// clock data in.
+		d.resp = append(d.resp, make([]uint8, cmd.Response())...)
+	}
+}
+
+// Constants defining the simulated port "D" pin wiring.
+const (
+	simSCLK uint8 = 0x01 // D0 - SCLK, SCL
+	simMOSI uint8 = 0x02 // D1 - MOSI, SDA (output)
+	simMISO uint8 = 0x04 // D2 - MISO, SDA (input), TDO
+	simTMS  uint8 = 0x08 // D3 - TMS
+)
+
+// scl returns the level of the I²C SCL line, which is pulled up when not driven.
+func (d *SimDevice) scl() bool {
+	return 0 == (d.lowDir&simSCLK) || (d.low&simSCLK) > 0
+}
+
+// sda returns the level of the I²C SDA line driven by the master, which is
+// pulled up when not driven.
+func (d *SimDevice) sda() bool {
+	return 0 == (d.lowDir&simMOSI) || (d.low&simMOSI) > 0
+}
+
+// setLow sets the direction and level of all pins on port "D", notifying all
+// port "D" GPIO peripherals, and generating I²C start/stop conditions and
+// selecting SPI slaves as necessary.
+func (d *SimDevice) setLow(val uint8, dir uint8) {
+	scl, sda := d.scl(), d.sda()
+	d.low, d.lowDir = val, dir
+	for _, p := range d.dgpio {
+		p.WriteGPIO(dir, val)
+	}
+	if scl && d.scl() {
+		if sda && !d.sda() {
+			d.bus.start()
+		} else if !sda && d.sda() {
+			d.bus.stop()
+		}
+	}
+	d.selectSPI()
+}
+
+// setHigh sets the direction and level of all pins on port "C", notifying all
+// GPIO peripherals and selecting SPI slaves as necessary.
+func (d *SimDevice) setHigh(val uint8, dir uint8) {
+	d.high, d.highDir = val, dir
+	for _, p := range d.gpio {
+		p.WriteGPIO(dir, val)
+	}
+	d.selectSPI()
+}
+
+// readLow returns the level of all pins on port "D". Output pins read back the
+// level most recently written, and input pins read the levels driven by all
+// port "D" GPIO peripherals (wired-OR). The SDA input pin also reads the level
+// of SDA, and the SCL pin reads HIGH when released (pulled up).
+func (d *SimDevice) readLow() uint8 {
+	var in uint8
+	for _, p := range d.dgpio {
+		in |= p.ReadGPIO()
+	}
+	if d.sda() {
+		in |= simMISO
+	}
+	if d.scl() {
+		in |= simSCLK
+	}
+	return (d.low & d.lowDir) | (in & ^d.lowDir)
+}
+
+// readHigh returns the level of all pins on port "C". Output pins read back the
+// level most recently written, and input pins read the levels driven by all
+// GPIO peripherals (wired-OR).
+func (d *SimDevice) readHigh() uint8 {
+	var in uint8
+	for _, p := range d.gpio {
+		in |= p.ReadGPIO()
+	}
+	return (d.high & d.highDir) | (in & ^d.highDir)
+}
+
+// selectSPI notifies each SPI slave whose CS line has changed state. Pins
+// configured as input are never considered asserted.
+func (d *SimDevice) selectSPI() {
+	for _, s := range d.spi {
+		val, dir := d.low, d.lowDir
+		if !s.cs.IsMPSSE() {
+			val, dir = d.high, d.highDir
+		}
+		act := (dir&s.cs.Mask()) > 0 && 0 == (val&s.cs.Mask())
+		if act != s.active {
+			s.active = act
+			s.dev.Select(act)
+		}
+	}
+}
+
+// spiActive returns true if any SPI slave is selected.
+func (d *SimDevice) spiActive() bool {
+	for _, s := range d.spi {
+		if s.active {
+			return true
+		}
+	}
+	return false
+}
+
+// clock executes a data shifting command, returning the bytes clocked in. If
+// any SPI slave is selected, whole bytes are exchanged with all selected
+// slaves. Otherwise, each bit is clocked on the JTAG scan chain, if attached,
+// or else the I²C bus.
+func (d *SimDevice) clock(c mpsse.ClockData) []uint8 {
+
+	if d.spiActive() {
+		if c.Bits {
+			return d.clockSPIBits(c)
+		}
+		return d.clockSPI(c)
+	}
+
+	n := c.Len
+	if !c.Bits {
+		n *= 8
+	}
+
+	in := make([]uint8, (n+7)/8)
+	for i := 0; i < n; i++ {
+		// the bit clocked out by the master is HIGH unless it is driving SDA LOW
+		out := true
+		if c.Out && (d.lowDir&simMOSI) > 0 {
+			b, k := c.Data[i/8], uint(i%8)
+			if c.LSB {
+				out = (b>>k)&1 > 0
+			} else {
+				out = (b>>(7-k))&1 > 0
+			}
+		}
+		var bit bool
+		if nil != d.jtag {
+			bit = d.jtag.Clock(0 != d.low&simTMS, out)
+		} else {
+			if d.stretch() {
+				if d.stalled {
+					return nil
+				}
+				continue // bit lost
+			}
+			bit = d.bus.clock(d.i2c, out)
+		}
+		if d.loopback {
+			bit = out
+		}
+		if bit {
+			if k := uint(i % 8); c.LSB {
+				in[i/8] |= 1 << k
+			} else {
+				in[i/8] |= 0x80 >> k
+			}
+		}
+	}
+
+	switch {
+	case !c.In:
+		return nil
+	case !c.Bits:
+		return in
+	}
+	return []uint8{simBits(in[0], n, c.LSB)}
+}
+
+// clockTMS executes a TMS shifting command on the JTAG scan chain, if attached,
+// returning the response of the command. TMS retains the level of the last bit
+// shifted, and TDI the level held during the command.
+func (d *SimDevice) clockTMS(c mpsse.ClockTMS) []uint8 {
+	var in uint8
+	for i := 0; i < c.Len; i++ {
+		tms := 0 != c.TMS&(1<<uint(i))
+		var bit bool
+		if nil != d.jtag {
+			bit = d.jtag.Clock(tms, c.TDI)
+		}
+		if d.loopback {
+			bit = c.TDI
+		}
+		if bit {
+			in |= 1 << uint(i)
+		}
+		d.low &= ^(simTMS | simMOSI)
+		if tms {
+			d.low |= simTMS
+		}
+		if c.TDI {
+			d.low |= simMOSI
+		}
+	}
+	if !c.In {
+		return nil
+	}
+	return []uint8{simBits(in, c.Len, true)}
+}
+
+// clockIdle clocks the JTAG scan chain, if attached, the given number of cycles
+// without transferring data, holding TMS and TDI at their current levels.
+func (d *SimDevice) clockIdle(n int) {
+	if nil == d.jtag || d.spiActive() {
+		return
+	}
+	for i := 0; i < n; i++ {
+		d.jtag.Clock(0 != d.low&simTMS, 0 != d.low&simMOSI)
+	}
+}
+
+// stretch returns true if an I²C slave is holding SCL LOW when the next bit is
+// clocked, and adaptive clocking is disabled; i.e., the bit is lost. If adaptive
+// clocking is enabled, waits for all slaves to release SCL, stalling the MPSSE
+// if any slave holds SCL LOW for more than SimStretchLimit periods.
+func (d *SimDevice) stretch() bool {
+	held := func() bool {
+		any := false
+		for _, p := range d.i2c {
+			if s, ok := p.(SimI2CStretch); ok && s.Stretch() {
+				any = true
+			}
+		}
+		return any
+	}
+	if !d.adaptive {
+		return held()
+	}
+	for n := 0; held(); n++ {
+		if n >= SimStretchLimit {
+			d.stalled = true
+			return true
+		}
+	}
+	return false
+}
+
+// simBits returns the response of a bit-mode data shifting command of n bits,
+// given the bits clocked in packed into a byte in transfer order (from bit 7 if
+// lsb is false, from bit 0 otherwise). The MPSSE shifts bits in at bit 0 (and
+// then left) when MSB first, and at bit 7 (and then right) when LSB first.
+func simBits(in uint8, n int, lsb bool) uint8 {
+	if lsb {
+		return in << uint(8-n)
+	}
+	return in >> uint(8-n)
+}
+
+// clockSPI exchanges the bytes of a data shifting command with all selected
+// SPI slaves, returning the bytes clocked in from all selected slaves
+// (wired-AND, reads 0xFF if none are selected).
+func (d *SimDevice) clockSPI(c mpsse.ClockData) []uint8 {
+	mosi := make([]uint8, c.Len)
+	if c.Out {
+		copy(mosi, c.Data)
+	}
+	if c.LSB {
+		reverse(mosi)
+	}
+	miso := make([]uint8, c.Len)
+	for i := range miso {
+		miso[i] = 0xFF
+	}
+	for _, s := range d.spi {
+		if s.active {
+			in := s.dev.Swap(append([]uint8{}, mosi...))
+			for i := range miso {
+				if i < len(in) {
+					miso[i] &= in[i]
+				}
+			}
+		}
+	}
+	if d.loopback {
+		copy(miso, mosi)
+	}
+	if !c.In {
+		return nil
+	}
+	if c.LSB {
+		reverse(miso)
+	}
+	return miso
+}
+
+// clockSPIBits exchanges the bits of a bit-mode data shifting command with all
+// selected SPI slaves, returning the response of the command. Slaves that
+// implement SimSPIBits receive the bits with SwapBits, and all others receive a
+// single byte with Swap.
+func (d *SimDevice) clockSPIBits(c mpsse.ClockData) []uint8 {
+	// left-align the bits in transfer order
+	var mosi uint8
+	if c.Out {
+		mosi = c.Data[0]
+		if c.LSB {
+			mosi = bits.Reverse8(mosi)
+		}
+		mosi &= ^uint8(0xFF >> uint(c.Len))
+	}
+	miso := uint8(0xFF)
+	for _, s := range d.spi {
+		if s.active {
+			var in []uint8
+			if p, ok := s.dev.(SimSPIBits); ok {
+				in = p.SwapBits([]uint8{mosi}, uint(c.Len))
+			} else {
+				in = s.dev.Swap([]uint8{mosi})
+			}
+			if len(in) > 0 {
+				miso &= in[0]
+			}
+		}
+	}
+	if d.loopback {
+		miso = mosi
+	}
+	if !c.In {
+		return nil
+	}
+	if c.LSB {
+		miso = bits.Reverse8(miso)
+	}
+	return []uint8{simBits(miso, c.Len, c.LSB)}
+}
+
+// reverse reverses the order of bits in each byte of the given slice.
+func reverse(data []uint8) {
+	for i, b := range data {
+		data[i] = bits.Reverse8(b)
+	}
+}
+
+// simI2CState represents the current phase of the simulated I²C bus.
+type simI2CState int
+
+// Constants defining the phases of the simulated I²C bus.
+const (
+	simI2CIdle     simI2CState = iota // no transfer, or transfer NACKed
+	simI2CAddr                        // address byte
+	simI2CAddrAck                     // address ACK bit
+	simI2CAddrLow                     // second address byte of 10-bit address
+	simI2CWrite                       // data byte written by master
+	simI2CWriteAck                    // ACK bit of data written by master
+	simI2CRead                        // data byte read by master
+	simI2CReadAck                     // ACK bit of data read by master
+)
+
+// simI2CTenBit is added to the keys of 10-bit slave addresses in the map of
+// slaves attached to a simulated FT232H, distinguishing them from 7-bit keys.
+const simI2CTenBit uint = 0x8000
+
+// simI2CBus is the bit-level state of the simulated I²C bus.
+type simI2CBus struct {
+	state simI2CState
+	bit   uint   // number of bits clocked in the current byte
+	shift uint8  // bits of the current byte
+	read  bool   // R/W bit of the most recent address byte
+	ack   bool   // slave ACKed the most recent byte
+	slave SimI2C // slave addressed by the most recent start condition
+	high  uint   // bits A9-A8 of the most recent 10-bit address header
+	hdr   bool   // most recent address byte is a 10-bit address write header
+	ten   bool   // slave was addressed with a 10-bit address
+}
+
+// reset returns the bus to idle without notifying any slave.
+func (b *simI2CBus) reset() {
+	*b = simI2CBus{}
+}
+
+// start handles a start (or repeated start) condition.
+func (b *simI2CBus) start() {
+	b.state, b.bit, b.shift = simI2CAddr, 0, 0
+}
+
+// stop handles a stop condition, notifying the addressed slave.
+func (b *simI2CBus) stop() {
+	if nil != b.slave {
+		b.slave.Stop()
+	}
+	b.reset()
+}
+
+// next begins clocking the next byte in the given phase.
+func (b *simI2CBus) next(state simI2CState) {
+	b.state, b.bit, b.shift = state, 0, 0
+}
+
+// clock clocks a single bit on the bus, with the master driving SDA LOW if out
+// is false, and returns the resulting level of SDA.
+func (b *simI2CBus) clock(slave map[uint]SimI2C, out bool) bool {
+
+	// shift adds the bit to the current byte, returning true if complete.
+	shift := func(bit bool) bool {
+		b.shift <<= 1
+		if bit {
+			b.shift |= 1
+		}
+		b.bit++
+		return 8 == b.bit
+	}
+
+	switch b.state {
+	case simI2CAddr:
+		if shift(out) {
+			b.read = (b.shift & 1) > 0
+			b.hdr = false
+			if 0xF0 == (b.shift & 0xF8) {
+				// 10-bit address header 11110 A9 A8 R/W. A read header addresses
+				// the slave most recently addressed with the same 10-bit address.
+				high := uint(b.shift>>1) & 0x03
+				if b.read {
+					b.ack = b.ten && high == b.high && b.slave.Start(true)
+				} else {
+					b.ack = false
+					for a := range slave {
+						if (a&simI2CTenBit) > 0 && (a>>8)&0x03 == high {
+							b.ack = true
+						}
+					}
+					b.high, b.hdr, b.ten = high, b.ack, false
+				}
+			} else {
+				p, ok := slave[uint(b.shift>>1)]
+				b.ack = ok && p.Start(b.read)
+				if b.ack {
+					b.slave = p
+				}
+				b.ten = false
+			}
+			b.state = simI2CAddrAck
+		}
+		return out
+
+	case simI2CAddrLow:
+		if shift(out) {
+			p, ok := slave[(b.high<<8)|uint(b.shift)|simI2CTenBit]
+			b.ack = ok && p.Start(false)
+			if b.ack {
+				b.slave, b.ten = p, true
+			}
+			b.state = simI2CAddrAck
+		}
+		return out
+
+	case simI2CAddrAck:
+		switch {
+		case !b.ack:
+			b.next(simI2CIdle)
+		case b.hdr:
+			b.hdr = false
+			b.next(simI2CAddrLow)
+		case b.read:
+			b.next(simI2CRead)
+		default:
+			b.next(simI2CWrite)
+		}
+		return out && !b.ack
+
+	case simI2CWrite:
+		if shift(out) {
+			b.ack = 1 == b.slave.Write([]uint8{b.shift})
+			b.state = simI2CWriteAck
+		}
+		return out
+
+	case simI2CWriteAck:
+		b.next(simI2CWrite)
+		return out && !b.ack
+
+	case simI2CRead:
+		if 0 == b.bit {
+			buf := []uint8{0}
+			b.slave.Read(buf)
+			b.shift = buf[0]
+		}
+		bit := (b.shift>>(7-b.bit))&1 > 0
+		if b.bit++; 8 == b.bit {
+			b.state = simI2CReadAck
+		}
+		return out && bit
+
+	case simI2CReadAck:
+		if a, ok := b.slave.(SimI2CAck); ok {
+			a.Ack(!out)
+		}
+		if out {
+			b.next(simI2CIdle) // master NACK ends the read
+		} else {
+			b.next(simI2CRead)
+		}
+		return out
+	}
+
+	return out
+}
+
+// Instructions of a SimTAP. All other instructions select the BYPASS register.
+const (
+	SimTAPIDCODE uint64 = 0x01 // 32-bit IDCODE register, or BYPASS if IDCode is 0
+	SimTAPDATA   uint64 = 0x02 // data register of DRLen bits
+	SimTAPSTATUS uint64 = 0x03 // 8-bit status register, captures 0 while Busy
+)
+
+// SimTAP is a simulated IEEE 1149.1 TAP controller implementing SimJTAG, with
+// an instruction register of IRLen bits and the data registers selected by the
+// SimTAP instructions. Test-Logic-Reset selects IDCODE, or BYPASS if IDCode is
+// 0. Its exported fields may be inspected and modified between transfers.
+type SimTAP struct {
+	State  JTAGState
+	IRLen  uint
+	CapIR  uint64 // value captured by the instruction register
+	IDCode uint32
+	DRLen  uint
+	Busy   int // number of captures of the status register returning 0
+	IR     uint64
+	DR     []bool // data register, LSB first
+	Idle   int    // number of clocks in Run-Test/Idle
+	sr     []bool // shift register, TDO end first
+}
+
+// Clock advances the TAP controller on a rising edge of TCK, returning TDO.
+func (t *SimTAP) Clock(tms bool, tdi bool) bool {
+	tdo := true
+	switch t.State {
+	case JTAGTestLogicReset:
+		t.IR = 1<<t.IRLen - 1
+		if 0 != t.IDCode {
+			t.IR = SimTAPIDCODE
+		}
+	case JTAGRunTestIdle:
+		t.Idle++
+	case JTAGCaptureIR:
+		t.sr = SimTAPBits(t.CapIR, t.IRLen)
+	case JTAGCaptureDR:
+		switch {
+		case SimTAPIDCODE == t.IR && 0 != t.IDCode:
+			t.sr = SimTAPBits(uint64(t.IDCode), 32)
+		case SimTAPDATA == t.IR:
+			if nil == t.DR {
+				t.DR = make([]bool, t.DRLen)
+			}
+			t.sr = append([]bool{}, t.DR...)
+		case SimTAPSTATUS == t.IR:
+			t.sr = SimTAPBits(0x01, 8)
+			if t.Busy > 0 {
+				t.sr = SimTAPBits(0x00, 8)
+				t.Busy--
+			}
+		default:
+			t.sr = []bool{false}
+		}
+	case JTAGShiftIR, JTAGShiftDR:
+		tdo = t.sr[0]
+		t.sr = append(t.sr[1:], tdi)
+	case JTAGUpdateIR:
+		t.IR = 0
+		for i, b := range t.sr {
+			if b {
+				t.IR |= 1 << uint(i)
+			}
+		}
+	case JTAGUpdateDR:
+		if SimTAPDATA == t.IR {
+			t.DR = append([]bool{}, t.sr...)
+		}
+	}
+	t.State = t.State.Next(tms)
+	return tdo
+}
+
+// SimTAPBits returns the n least-significant bits of v, LSB first.
+func SimTAPBits(v uint64, n uint) []bool {
+	b := make([]bool, n)
+	for i := range b {
+		b[i] = 0 != v&(1<<uint(i))
+	}
+	return b
+}
+
+// SimChain is a simulated JTAG scan chain implementing SimJTAG, ordered from
+// TDI to TDO.
+type SimChain []*SimTAP
+
+// Clock clocks each TAP controller of the chain, returning the TDO of the last.
+func (c SimChain) Clock(tms bool, tdi bool) bool {
+	for _, t := range c {
+		tdi = t.Clock(tms, tdi)
+	}
+	return tdi
+}
+
+// SimI2CRegFile is a simulated I²C slave implementing SimI2C with a file of
+// registers, each holding any number of bytes. The first AddrSize bytes of each
+// write transfer select a register, most-significant byte first, and each byte
+// read or written following the selection is transferred with the next byte of
+// the selected register. Bytes beyond the contents of a register read as 0xFF.
+// Each transfer begins with the first byte of the selected register.
+//
+// If AutoInc is set, each register holds a single byte, and the selected
+// register is incremented after each byte transferred, like the memory of an
+// EEPROM. The register address then wraps around to 0 at Size, and each write
+// wraps around within its page of PageSize registers, if they are non-zero.
+//
+// Its exported fields may be inspected and modified between transfers.
+type SimI2CRegFile struct {
+	Reg      map[uint][]uint8 // contents of each register, by address
+	AddrSize uint             // number of register address bytes (1 if 0)
+	AutoInc  bool             // increment the register address after each byte
+	Size     uint             // number of registers with AutoInc (unlimited if 0)
+	PageSize uint             // number of registers in a page with AutoInc (unlimited if 0)
+	ReadOnly bool             // log, but do not store, the bytes written
+	Cycle    int              // address NACKs after each write (write cycle), or forever if negative
+	Addr     uint             // address of the selected register
+	Busy     int              // address NACKs remaining, or forever if negative
+	Polls    int              // number of address NACKs
+	Writes   int              // number of write transfers with data bytes
+	Stops    int              // number of stop conditions
+	Log      [][]uint8        // bytes written in each transfer not containing a read
+	Acks     []bool           // ACK bits clocked out by the master after each byte read
+	base     uint             // register address offset of the addressed block
+	setup    uint             // register address bytes remaining
+	word     uint             // register address bytes received
+	n        uint             // byte of the selected register
+	w        []uint8          // bytes written since the last stop condition
+	data     bool             // data bytes written since the last stop condition
+	read     bool             // addressed for reading since the last stop condition
+}
+
+// Block returns a SimI2C selecting the registers of the receiver offset by the
+// given base address, for attaching at each of the slave addresses of a device
+// whose address bits select a block of its registers, like a 24Cxx EEPROM.
+func (s *SimI2CRegFile) Block(base uint) SimI2C {
+	return &simI2CRegBlock{SimI2CRegFile: s, base: base}
+}
+
+// simI2CRegBlock is the SimI2C of a block of registers of a SimI2CRegFile.
+type simI2CRegBlock struct {
+	*SimI2CRegFile
+	base uint
+}
+
+// Start begins a transfer with the block of registers.
+func (b *simI2CRegBlock) Start(read bool) bool { return b.start(read, b.base) }
+
+// Start begins a transfer, NACKing the address while Busy is non-zero.
+func (s *SimI2CRegFile) Start(read bool) bool { return s.start(read, 0) }
+
+func (s *SimI2CRegFile) start(read bool, base uint) bool {
+	if 0 != s.Busy {
+		if s.Busy > 0 {
+			s.Busy--
+		}
+		s.Polls++
+		return false
+	}
+	s.base, s.n = base, 0
+	if read {
+		s.read = true
+	} else {
+		s.setup, s.word = s.AddrSize, 0
+		if 0 == s.setup {
+			s.setup = 1
+		}
+	}
+	return true
+}
+
+// Write selects a register with the first bytes of a write transfer, and then
+// writes the given bytes to the selected register.
+func (s *SimI2CRegFile) Write(data []uint8) uint {
+	s.w = append(s.w, data...)
+	for _, b := range data {
+		if s.setup > 0 {
+			s.word = s.word<<8 | uint(b)
+			if s.setup--; 0 == s.setup {
+				s.Addr = s.wrap(s.base + s.word)
+			}
+			continue
+		}
+		s.data = true
+		if !s.ReadOnly {
+			if nil == s.Reg {
+				s.Reg = map[uint][]uint8{}
+			}
+			r := s.Reg[s.Addr]
+			for uint(len(r)) <= s.n {
+				r = append(r, 0xFF)
+			}
+			r[s.n] = b
+			s.Reg[s.Addr] = r
+		}
+		s.next(true)
+	}
+	return uint(len(data))
+}
+
+// Read reads the given number of bytes from the selected register.
+func (s *SimI2CRegFile) Read(data []uint8) {
+	for i := range data {
+		data[i] = 0xFF
+		if r := s.Reg[s.Addr]; s.n < uint(len(r)) {
+			data[i] = r[s.n]
+		}
+		s.next(false)
+	}
+}
+
+// Stop ends a transfer, beginning a write cycle if data bytes were written.
+func (s *SimI2CRegFile) Stop() {
+	s.Stops++
+	if !s.read {
+		s.Log = append(s.Log, s.w)
+	}
+	if s.data {
+		s.Writes++
+		s.Busy = s.Cycle
+	}
+	s.w, s.data, s.read = nil, false, false
+}
+
+// Ack records the ACK bit clocked out by the master after a byte read.
+func (s *SimI2CRegFile) Ack(ack bool) { s.Acks = append(s.Acks, ack) }
+
+// Peek returns the first n bytes read from the register with the given address,
+// without changing the state of the receiver.
+func (s *SimI2CRegFile) Peek(addr uint, n uint) []uint8 {
+	a, i := s.Addr, s.n
+	defer func() { s.Addr, s.n = a, i }()
+	s.Addr, s.n = addr, 0
+	data := make([]uint8, n)
+	s.Read(data)
+	return data
+}
+
+// next advances to the next byte transferred after a read or write.
+func (s *SimI2CRegFile) next(write bool) {
+	if !s.AutoInc {
+		s.n++
+		return
+	}
+	addr := s.Addr + 1
+	if write && s.PageSize > 0 {
+		addr = s.Addr - s.Addr%s.PageSize + addr%s.PageSize
+	}
+	s.Addr = s.wrap(addr)
+}
+
+// wrap returns the given register address wrapped around at Size with AutoInc.
+func (s *SimI2CRegFile) wrap(addr uint) uint {
+	if s.AutoInc && s.Size > 0 {
+		return addr % s.Size
+	}
+	return addr
+}
@@ -0,0 +1,161 @@
+package ft232h
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// simRegSPI is a simulated SPI slave with 128 8-bit registers. The first byte
+// of each transfer selects the register address (bits 6:0) and direction (bit 7
+// set for read). Subsequent bytes read/write consecutive registers.
+type simRegSPI struct {
+	reg    [128]uint8
+	ptr    uint8
+	read   bool
+	first  bool
+	active bool
+}
+
+func (s *simRegSPI) Select(active bool) { s.active, s.first = active, active }
+
+func (s *simRegSPI) Swap(mosi []uint8) []uint8 {
+	miso := make([]uint8, len(mosi))
+	for i, b := range mosi {
+		if s.first {
+			s.ptr, s.read, s.first = b&0x7F, (b&0x80) > 0, false
+			continue
+		}
+		if s.read {
+			miso[i] = s.reg[s.ptr]
+		} else {
+			s.reg[s.ptr] = b
+		}
+		s.ptr = (s.ptr + 1) & 0x7F
+	}
+	return miso
+}
+
+// simLoopGPIO is a simulated GPIO peripheral that drives the complement of the
+// output pins' levels onto the input pins.
+type simLoopGPIO struct {
+	dir, val uint8
+}
+
+func (g *simLoopGPIO) WriteGPIO(dir uint8, val uint8) { g.dir, g.val = dir, val }
+func (g *simLoopGPIO) ReadGPIO() uint8                { return ^g.val }
+
+func TestSim(t *testing.T) {
+
+	sim := NewSim()
+	sim.Add(0, 0, "SIM0", "first")
+	dev := sim.Add(0x0403, 0x6014, "SIM1", "second")
+
+	spiD := &simRegSPI{}
+	spiC := &simRegSPI{}
+	reg := &SimI2CRegFile{Reg: map[uint][]uint8{0x02: {0x12, 0x34}}}
+	gpio := &simLoopGPIO{}
+
+	if err := dev.AttachSPI(D(3), spiD); nil != err {
+		t.Fatalf("AttachSPI(): %v", err)
+	}
+	if err := dev.AttachSPI(C(7), spiC); nil != err {
+		t.Fatalf("AttachSPI(): %v", err)
+	}
+	if err := dev.AttachSPI(D(3), spiC); nil == err {
+		t.Fatalf("AttachSPI(): expected error for duplicate CS pin")
+	}
+	if err := dev.AttachI2C(0x40, reg); nil != err {
+		t.Fatalf("AttachI2C(): %v", err)
+	}
+	dev.AttachGPIO(gpio)
+
+	ft, err := OpenMask(&Mask{Desc: "second", Transport: sim})
+	if nil != err {
+		t.Fatalf("OpenMask(): %v", err)
+	}
+	defer ft.Close()
+
+	if 1 != ft.Index() || "SIM1" != ft.Serial() {
+		t.Fatalf("opened wrong device: %s", ft)
+	}
+
+	t.Run("GPIO", func(t *testing.T) {
+		if err := ft.GPIO.Config(&GPIOConfig{Dir: 0x0F, Val: 0x05}); nil != err {
+			t.Fatalf("GPIO.Config(): %v", err)
+		}
+		if gpio.dir != 0x0F || gpio.val != 0x05 {
+			t.Fatalf("GPIO dir={%08b} val={%08b}", gpio.dir, gpio.val)
+		}
+		val, err := ft.GPIO.Read()
+		if nil != err {
+			t.Fatalf("GPIO.Read(): %v", err)
+		}
+		if exp := uint8(0xF5); val != exp {
+			t.Fatalf("GPIO.Read()={%08b}, expected={%08b}", val, exp)
+		}
+	})
+
+	t.Run("SPI", func(t *testing.T) {
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		if spiD.active || spiC.active {
+			t.Fatalf("expected no slaves selected after init")
+		}
+		// write then read back registers using CS auto-assertion on D3
+		if _, err := ft.SPI.Write([]uint8{0x10, 0xAA, 0xBB}, true, true); nil != err {
+			t.Fatalf("SPI.Write(): %v", err)
+		}
+		if spiD.active {
+			t.Fatalf("expected slave de-selected after transfer")
+		}
+		if _, err := ft.SPI.Write([]uint8{0x90}, true, false); nil != err {
+			t.Fatalf("SPI.Write(): %v", err)
+		}
+		if !spiD.active {
+			t.Fatalf("expected slave selected between transfers")
+		}
+		rd, err := ft.SPI.Read(2, false, true)
+		if nil != err {
+			t.Fatalf("SPI.Read(): %v", err)
+		}
+		if exp := []uint8{0xAA, 0xBB}; !bytes.Equal(rd, exp) {
+			t.Fatalf("SPI.Read()={%X}, expected={%X}", rd, exp)
+		}
+		// swap with the slave using CS on GPIO pin C7
+		if _, err := ft.SPI.WriteTo(C(7), []uint8{0x20, 0x55}, true, true); nil != err {
+			t.Fatalf("SPI.WriteTo(): %v", err)
+		}
+		rd, err = ft.SPI.SwapWith(C(7), []uint8{0xA0, 0x00}, true, true)
+		if nil != err {
+			t.Fatalf("SPI.SwapWith(): %v", err)
+		}
+		if exp := []uint8{0x00, 0x55}; !bytes.Equal(rd, exp) {
+			t.Fatalf("SPI.SwapWith()={%X}, expected={%X}", rd, exp)
+		}
+		if 0 != spiD.reg[0x20] || spiC.active {
+			t.Fatalf("unexpected transfer to de-selected slave")
+		}
+	})
+
+	t.Run("I2C", func(t *testing.T) {
+		if err := ft.I2C.Init(); nil != err {
+			t.Fatalf("I2C.Init(): %v", err)
+		}
+		r, err := ft.I2C.Reg(0x40, 0x02, Addr8Bit, MSB).Reader(2)
+		if nil != err {
+			t.Fatalf("Reader(): %v", err)
+		}
+		val, err := r(false)
+		if nil != err {
+			t.Fatalf("reader(): %v", err)
+		}
+		if 0x1234 != val || 0x02 != reg.Addr {
+			t.Fatalf("reader()={0x%04X} Addr={0x%02X}", val, reg.Addr)
+		}
+		if _, err := ft.I2C.Write(0x41, []uint8{0x00}, true, true); !errors.Is(err, SDeviceNotFound) {
+			t.Fatalf("I2C.Write(): unexpected error: %v", err)
+		}
+	})
+}
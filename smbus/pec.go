@@ -0,0 +1,52 @@
+package smbus
+
+import (
+	"fmt"
+
+	"github.com/ardnew/ft232h"
+)
+
+// PECError is the error returned when the packet error code (PEC) received from
+// an SMBus slave does not match the PEC calculated from the bytes transferred.
+// It wraps ft232h.SIOError, so that errors.Is(err, ft232h.SIOError) reports
+// true.
+type PECError struct {
+	Addr uint  // unshifted slave address
+	Recv uint8 // PEC received from the slave
+	Calc uint8 // PEC calculated
+}
+
+// Error implements the error interface.
+func (e *PECError) Error() string {
+	return fmt.Sprintf("SMBus slave 0x%02X: PEC mismatch (received 0x%02X, calculated 0x%02X)",
+		e.Addr, e.Recv, e.Calc)
+}
+
+// Unwrap returns the Status wrapped by the error.
+func (e *PECError) Unwrap() error { return ft232h.SIOError }
+
+// crc8Table contains the CRC-8 of each byte value.
+var crc8Table = func() (t [256]uint8) {
+	for i := range t {
+		c := uint8(i)
+		for b := 0; b < 8; b++ {
+			if 0 != (c & 0x80) {
+				c = (c << 1) ^ 0x07
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// CRC8 returns the CRC-8 used as the SMBus packet error code, with polynomial
+// x⁸ + x² + x + 1 (0x07), of the given data, continuing from the given crc.
+// The PEC of an entire transaction is calculated with an initial crc of 0.
+func CRC8(crc uint8, data ...uint8) uint8 {
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+	return crc
+}
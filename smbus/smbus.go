@@ -0,0 +1,336 @@
+/*
+Package smbus implements the System Management Bus (SMBus) protocol, version
+3.x, on top of the I²C interface of an FT232H (see ft232h.I2C).
+
+Each SMBus transaction is a method of Device, named after the corresponding
+function of the Linux i2c-smbus interface (e.g., ReadByteData for
+i2c_smbus_read_byte_data), and is performed as a single I²C transfer (see
+ft232h.I2C.Transfer). Multi-byte values are transferred least significant byte
+first, as required by the SMBus specification.
+
+On a device whose Port implements ft232h.PortChannel (such as the default
+D2XX/libMPSSE bridge), each message of the transfer is performed with a
+separate libMPSSE request, with the repeated start between the command code
+and the data read, and the byte count of a block read requested on its own.
+
+If packet error checking (PEC) is enabled on a Device (see SetPEC), a CRC-8
+packet error code is appended to each transaction written to the slave, and
+the packet error code appended by the slave to each transaction read is
+verified (see CRC8).
+
+The protocols are described in the System Management Bus (SMBus)
+Specification, version 3.1, published by the SBS Implementers Forum.
+*/
+package smbus
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ardnew/ft232h"
+)
+
+// Constants defining SMBus slave addresses reserved by the specification.
+const (
+	HostAddress          uint = 0x08 // SMBus host, target of host notify
+	AlertResponseAddress uint = 0x0C // responds with the address of an alerting device
+	DeviceDefaultAddress uint = 0x61 // address resolution protocol (ARP)
+)
+
+// BlockMax is the maximum number of data bytes in an SMBus block transfer.
+const BlockMax = 255
+
+// Device is an SMBus slave device on the I²C interface of an FT232H.
+type Device struct {
+	i2c  *ft232h.I2C
+	addr uint
+	pec  bool
+}
+
+// New returns a new Device with the given unshifted 7-bit slave address on the
+// given I²C interface, which must already be initialized (see ft232h.I2C.Init).
+// Packet error checking is initially disabled.
+func New(i2c *ft232h.I2C, addr uint) (*Device, error) {
+	if nil == i2c {
+		return nil, fmt.Errorf("nil I²C interface")
+	}
+	if addr < ft232h.I2CSlaveAddressMin || addr > ft232h.I2CSlaveAddressMax {
+		return nil, fmt.Errorf("invalid SMBus slave address: 0x%02X", addr)
+	}
+	return &Device{i2c: i2c, addr: addr}, nil
+}
+
+// String returns a descriptive string of a Device.
+func (d *Device) String() string {
+	return fmt.Sprintf("{ Addr: 0x%02X, PEC: %t }", d.addr, d.pec)
+}
+
+// Addr returns the unshifted 7-bit slave address of the receiver Device.
+func (d *Device) Addr() uint { return d.addr }
+
+// PEC returns true if packet error checking is enabled.
+func (d *Device) PEC() bool { return d.pec }
+
+// SetPEC enables or disables packet error checking. The slave device must
+// support PEC, and may also need to be configured to enable it.
+func (d *Device) SetPEC(enable bool) { d.pec = enable }
+
+// Quick performs the SMBus quick command, which transfers only the R/W bit of
+// the address byte, given by read. Packet error checking is not used.
+func (d *Device) Quick(read bool) error {
+	msg := ft232h.I2CMsg{Addr: d.addr}
+	if read {
+		msg.Flags = ft232h.I2CMsgRead
+	}
+	_, err := d.i2c.Transfer([]ft232h.I2CMsg{msg})
+	return err
+}
+
+// SendByte performs the SMBus send byte protocol, writing the given byte b
+// without a command code.
+func (d *Device) SendByte(b uint8) error {
+	_, err := d.transfer([]uint8{b}, 0, false)
+	return err
+}
+
+// ReceiveByte performs the SMBus receive byte protocol, reading a single byte
+// without a command code.
+func (d *Device) ReceiveByte() (uint8, error) {
+	rd, err := d.transfer(nil, 1, false)
+	if nil != err {
+		return 0, err
+	}
+	return rd[0], nil
+}
+
+// WriteByteData performs the SMBus write byte protocol, writing the given byte
+// b with the given command code cmd.
+func (d *Device) WriteByteData(cmd uint8, b uint8) error {
+	_, err := d.transfer([]uint8{cmd, b}, 0, false)
+	return err
+}
+
+// ReadByteData performs the SMBus read byte protocol, reading a single byte
+// with the given command code cmd.
+func (d *Device) ReadByteData(cmd uint8) (uint8, error) {
+	rd, err := d.transfer([]uint8{cmd}, 1, false)
+	if nil != err {
+		return 0, err
+	}
+	return rd[0], nil
+}
+
+// WriteWordData performs the SMBus write word protocol, writing the given
+// 16-bit word w with the given command code cmd.
+func (d *Device) WriteWordData(cmd uint8, w uint16) error {
+	wr := []uint8{cmd, 0, 0}
+	binary.LittleEndian.PutUint16(wr[1:], w)
+	_, err := d.transfer(wr, 0, false)
+	return err
+}
+
+// ReadWordData performs the SMBus read word protocol, reading a 16-bit word
+// with the given command code cmd.
+func (d *Device) ReadWordData(cmd uint8) (uint16, error) {
+	rd, err := d.transfer([]uint8{cmd}, 2, false)
+	if nil != err {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(rd), nil
+}
+
+// Write32Data performs the SMBus write 32 protocol, writing the given 32-bit
+// value v with the given command code cmd.
+func (d *Device) Write32Data(cmd uint8, v uint32) error {
+	wr := []uint8{cmd, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(wr[1:], v)
+	_, err := d.transfer(wr, 0, false)
+	return err
+}
+
+// Read32Data performs the SMBus read 32 protocol, reading a 32-bit value with
+// the given command code cmd.
+func (d *Device) Read32Data(cmd uint8) (uint32, error) {
+	rd, err := d.transfer([]uint8{cmd}, 4, false)
+	if nil != err {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(rd), nil
+}
+
+// Write64Data performs the SMBus write 64 protocol, writing the given 64-bit
+// value v with the given command code cmd.
+func (d *Device) Write64Data(cmd uint8, v uint64) error {
+	wr := []uint8{cmd, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint64(wr[1:], v)
+	_, err := d.transfer(wr, 0, false)
+	return err
+}
+
+// Read64Data performs the SMBus read 64 protocol, reading a 64-bit value with
+// the given command code cmd.
+func (d *Device) Read64Data(cmd uint8) (uint64, error) {
+	rd, err := d.transfer([]uint8{cmd}, 8, false)
+	if nil != err {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(rd), nil
+}
+
+// ProcessCall performs the SMBus process call protocol, writing the given
+// 16-bit word w with the given command code cmd, and then reading the 16-bit
+// word returned by the slave.
+func (d *Device) ProcessCall(cmd uint8, w uint16) (uint16, error) {
+	wr := []uint8{cmd, 0, 0}
+	binary.LittleEndian.PutUint16(wr[1:], w)
+	rd, err := d.transfer(wr, 2, false)
+	if nil != err {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(rd), nil
+}
+
+// BlockWrite performs the SMBus block write protocol, writing the byte count
+// and then the given data (at most BlockMax bytes) with the given command code
+// cmd.
+func (d *Device) BlockWrite(cmd uint8, data []uint8) error {
+	wr, err := block(cmd, data)
+	if nil != err {
+		return err
+	}
+	_, err = d.transfer(wr, 0, false)
+	return err
+}
+
+// BlockRead performs the SMBus block read protocol with the given command code
+// cmd, returning the data read, whose length is given by the byte count sent
+// by the slave (1 to BlockMax bytes).
+func (d *Device) BlockRead(cmd uint8) ([]uint8, error) {
+	return d.transfer([]uint8{cmd}, 0, true)
+}
+
+// BlockProcessCall performs the SMBus block write-block read process call
+// protocol, writing the byte count and then the given data (at most BlockMax
+// bytes) with the given command code cmd, and then returning the block of data
+// read from the slave.
+func (d *Device) BlockProcessCall(cmd uint8, data []uint8) ([]uint8, error) {
+	wr, err := block(cmd, data)
+	if nil != err {
+		return nil, err
+	}
+	return d.transfer(wr, 0, true)
+}
+
+// block returns the bytes written by a block write with the given command code
+// cmd and data, and a non-nil error if data is not a valid block.
+func block(cmd uint8, data []uint8) ([]uint8, error) {
+	if 0 == len(data) || len(data) > BlockMax {
+		return nil, fmt.Errorf("invalid block length: %d (1-%d)", len(data), BlockMax)
+	}
+	return append([]uint8{cmd, uint8(len(data))}, data...), nil
+}
+
+// transfer performs an SMBus transaction as a single I²C transfer, writing the
+// given bytes w, if any, and then, following a repeated start condition,
+// reading either r bytes, or a block if block is true, returning the bytes read
+// (excluding a block's byte count) and a non-nil error if unsuccessful.
+// If packet error checking is enabled, the PEC is appended to the bytes written
+// by a transaction that does not read, otherwise the PEC sent by the slave
+// following the bytes read is verified.
+func (d *Device) transfer(w []uint8, r int, block bool) ([]uint8, error) {
+
+	msg := make([]ft232h.I2CMsg, 0, 2)
+	if len(w) > 0 {
+		msg = append(msg, ft232h.I2CMsg{Addr: d.addr, Buf: w})
+	}
+
+	read := r > 0 || block
+	if read {
+		flag := ft232h.I2CMsgRead
+		if block {
+			flag |= ft232h.I2CMsgRecvLen
+			r = 1 // byte count
+		}
+		if d.pec {
+			r++
+		}
+		msg = append(msg, ft232h.I2CMsg{Addr: d.addr, Flags: flag, Buf: make([]uint8, r)})
+	} else if d.pec {
+		msg[0].Buf = append(w, pec(msg, 0))
+	}
+
+	if _, err := d.i2c.Transfer(msg); nil != err {
+		return nil, err
+	}
+	if !read {
+		return nil, nil
+	}
+
+	rd := msg[len(msg)-1].Buf
+	if d.pec {
+		recv := rd[len(rd)-1]
+		if calc := pec(msg, 1); calc != recv {
+			return nil, &PECError{Addr: d.addr, Recv: recv, Calc: calc}
+		}
+		rd = rd[:len(rd)-1]
+	}
+	if block {
+		rd = rd[1:]
+	}
+	return rd, nil
+}
+
+// pec returns the PEC of the given messages, i.e. the CRC-8 of each message's
+// address byte and data, excluding the given number of trailing bytes of the
+// last message.
+func pec(msg []ft232h.I2CMsg, trim int) uint8 {
+	crc := uint8(0)
+	for i, m := range msg {
+		rw := uint8(0)
+		if (m.Flags & ft232h.I2CMsgRead) > 0 {
+			rw = 1
+		}
+		buf := m.Buf
+		if i == len(msg)-1 {
+			buf = buf[:len(buf)-trim]
+		}
+		crc = CRC8(crc, uint8(m.Addr<<1)|rw)
+		crc = CRC8(crc, buf...)
+	}
+	return crc
+}
+
+// HostNotify is an SMBus host notify message, written by an SMBus device acting
+// as master to the SMBus host address (HostAddress).
+type HostNotify struct {
+	Addr uint   // unshifted 7-bit slave address of the notifying device
+	Data uint16 // status data
+}
+
+// String returns a descriptive string of a HostNotify.
+func (n HostNotify) String() string {
+	return fmt.Sprintf("{ Addr: 0x%02X, Data: 0x%04X }", n.Addr, n.Data)
+}
+
+// Bytes returns the bytes of the receiver message written to the SMBus host
+// following its address byte: the address of the notifying device (shifted,
+// with the W bit), and the data, least significant byte first.
+func (n HostNotify) Bytes() []uint8 {
+	return []uint8{uint8(n.Addr << 1), uint8(n.Data), uint8(n.Data >> 8)}
+}
+
+// ParseHostNotify parses the given bytes received by the SMBus host following
+// its address byte (see HostNotify.Bytes), returning the host notify message
+// and a non-nil error if the bytes are not a valid host notify message.
+func ParseHostNotify(b []uint8) (HostNotify, error) {
+	if 3 != len(b) {
+		return HostNotify{}, fmt.Errorf("invalid host notify length: %d (3)", len(b))
+	}
+	if 0 != (b[0] & 0x01) {
+		return HostNotify{}, fmt.Errorf("invalid host notify device address: 0x%02X", b[0])
+	}
+	return HostNotify{
+		Addr: uint(b[0] >> 1),
+		Data: binary.LittleEndian.Uint16(b[1:]),
+	}, nil
+}
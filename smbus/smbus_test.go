@@ -0,0 +1,224 @@
+package smbus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ardnew/ft232h"
+)
+
+// simSMBus is a simulated SMBus slave device with read-only registers. Each
+// command code read returns exactly the bytes stored for it (a block read must
+// include the byte count), followed by the PEC if enabled.
+type simSMBus struct {
+	*ft232h.SimI2CRegFile
+	addr uint
+	pec  bool
+	bad  bool    // send an invalid PEC
+	w    []uint8 // bytes written since the last stop
+	n    int     // register bytes remaining to be read
+	crc  []uint8 // PEC to be read
+}
+
+func (s *simSMBus) Start(read bool) bool {
+	if read {
+		reg := s.Reg[s.Addr]
+		crc := uint8(0)
+		if len(s.w) > 0 {
+			crc = CRC8(crc, uint8(s.addr<<1))
+			crc = CRC8(crc, s.w...)
+		}
+		crc = CRC8(crc, uint8(s.addr<<1)|1)
+		crc = CRC8(crc, reg...)
+		if s.bad {
+			crc = ^crc
+		}
+		s.n, s.crc = len(reg), nil
+		if s.pec {
+			s.crc = []uint8{crc}
+		}
+	}
+	return s.SimI2CRegFile.Start(read)
+}
+
+func (s *simSMBus) Write(data []uint8) uint {
+	s.w = append(s.w, data...)
+	return s.SimI2CRegFile.Write(data)
+}
+
+func (s *simSMBus) Read(data []uint8) {
+	for i := range data {
+		switch {
+		case s.n > 0:
+			s.SimI2CRegFile.Read(data[i : i+1])
+			s.n--
+		case len(s.crc) > 0:
+			data[i], s.crc = s.crc[0], s.crc[1:]
+		default:
+			data[i] = 0xFF
+		}
+	}
+}
+
+func (s *simSMBus) Stop() {
+	s.w = nil
+	s.SimI2CRegFile.Stop()
+}
+
+func TestSMBus(t *testing.T) {
+	t.Run("MPSSE", func(t *testing.T) { testSMBus(t, false) })
+	t.Run("Channel", func(t *testing.T) { testSMBus(t, true) })
+}
+
+// testSMBus tests each SMBus protocol with a simulated slave device, with the
+// simulated FT232H emulating the libMPSSE I²C channel if channel is true (see
+// ft232h.PortChannel).
+func testSMBus(t *testing.T, channel bool) {
+
+	const addr = 0x0B // smart battery
+
+	bat := &simSMBus{
+		addr: addr,
+		SimI2CRegFile: &ft232h.SimI2CRegFile{ReadOnly: true, Reg: map[uint][]uint8{
+			0x00: {0x5A},
+			0x07: {0xAB},
+			0x08: {0xAB, 0x0B}, // temperature
+			0x20: {0x05, 'A', 'C', 'M', 'E', '!'},
+			0x30: {0x01, 0x02, 0x03, 0x04},
+			0x31: {0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		}},
+	}
+	ft, err := ft232h.OpenSim("smbus", func(d *ft232h.SimDevice) error {
+		d.Channel = channel
+		return d.AttachI2C(addr, bat)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.I2C.Init(); nil != err {
+		t.Fatalf("I2C.Init(): %v", err)
+	}
+
+	for _, a := range []uint{0x00, 0x78} {
+		if _, err := New(ft.I2C, a); nil == err {
+			t.Fatalf("New(0x%02X): expected error", a)
+		}
+	}
+
+	sm, err := New(ft.I2C, addr)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+
+	for _, pec := range []bool{false, true} {
+		sm.SetPEC(pec)
+		bat.pec, bat.Addr, bat.Log = pec, 0x00, nil
+
+		if b, err := sm.ReceiveByte(); nil != err || 0x5A != b {
+			t.Fatalf("ReceiveByte()={%02X}, expected={%02X}: %v", b, 0x5A, err)
+		}
+		if b, err := sm.ReadByteData(0x07); nil != err || 0xAB != b {
+			t.Fatalf("ReadByteData()={%02X}, expected={%02X}: %v", b, 0xAB, err)
+		}
+		if w, err := sm.ReadWordData(0x08); nil != err || 0x0BAB != w {
+			t.Fatalf("ReadWordData()={%04X}, expected={%04X}: %v", w, 0x0BAB, err)
+		}
+		if w, err := sm.ProcessCall(0x08, 0x1234); nil != err || 0x0BAB != w {
+			t.Fatalf("ProcessCall()={%04X}, expected={%04X}: %v", w, 0x0BAB, err)
+		}
+		if v, err := sm.Read32Data(0x30); nil != err || 0x04030201 != v {
+			t.Fatalf("Read32Data()={%08X}, expected={%08X}: %v", v, 0x04030201, err)
+		}
+		if v, err := sm.Read64Data(0x31); nil != err || 0x0807060504030201 != v {
+			t.Fatalf("Read64Data()={%016X}, expected={%016X}: %v", v, uint64(0x0807060504030201), err)
+		}
+		if b, err := sm.BlockRead(0x20); nil != err || "ACME!" != string(b) {
+			t.Fatalf("BlockRead()={%q}, expected={%q}: %v", b, "ACME!", err)
+		}
+		if b, err := sm.BlockProcessCall(0x20, []uint8{0x01}); nil != err || "ACME!" != string(b) {
+			t.Fatalf("BlockProcessCall()={%q}, expected={%q}: %v", b, "ACME!", err)
+		}
+
+		write := []struct {
+			name string
+			fn   func() error
+			exp  []uint8
+		}{
+			{"SendByte", func() error { return sm.SendByte(0xA5) }, []uint8{0xA5}},
+			{"WriteByteData", func() error { return sm.WriteByteData(0x01, 0x02) }, []uint8{0x01, 0x02}},
+			{"WriteWordData", func() error { return sm.WriteWordData(0x01, 0x1234) }, []uint8{0x01, 0x34, 0x12}},
+			{"Write32Data", func() error { return sm.Write32Data(0x01, 0x12345678) }, []uint8{0x01, 0x78, 0x56, 0x34, 0x12}},
+			{"Write64Data", func() error { return sm.Write64Data(0x01, 0x0102030405060708) }, []uint8{0x01, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01}},
+			{"BlockWrite", func() error { return sm.BlockWrite(0x01, []uint8{0xAA, 0xBB}) }, []uint8{0x01, 0x02, 0xAA, 0xBB}},
+		}
+		for _, tc := range write {
+			bat.Log = nil
+			if err := tc.fn(); nil != err {
+				t.Fatalf("%s(): %v", tc.name, err)
+			}
+			exp := tc.exp
+			if pec {
+				exp = append(exp, CRC8(CRC8(0, addr<<1), exp...))
+			}
+			if 1 != len(bat.Log) || !bytes.Equal(exp, bat.Log[0]) {
+				t.Fatalf("%s()={% X}, expected={% X}", tc.name, bat.Log, exp)
+			}
+		}
+	}
+
+	bat.bad = true
+	var pe *PECError
+	if _, err := sm.ReadWordData(0x08); !errors.As(err, &pe) || !errors.Is(err, ft232h.SIOError) {
+		t.Fatalf("ReadWordData(): %v, expected PEC error", err)
+	}
+	bat.bad = false
+
+	for _, data := range [][]uint8{nil, make([]uint8, BlockMax+1)} {
+		if err := sm.BlockWrite(0x01, data); nil == err {
+			t.Fatalf("BlockWrite(%d bytes): expected error", len(data))
+		}
+	}
+
+	for _, read := range []bool{false, true} {
+		if err := sm.Quick(read); nil != err {
+			t.Fatalf("Quick(%t): %v", read, err)
+		}
+	}
+	absent, err := New(ft.I2C, 0x0C)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	if err := absent.Quick(false); !errors.Is(err, ft232h.SDeviceNotFound) {
+		t.Fatalf("Quick(): %v, expected={%v}", err, ft232h.SDeviceNotFound)
+	}
+}
+
+func TestCRC8(t *testing.T) {
+	// CRC-8/SMBUS check value
+	if crc := CRC8(0, []uint8("123456789")...); 0xF4 != crc {
+		t.Fatalf("CRC8()={%02X}, expected={%02X}", crc, 0xF4)
+	}
+	// continuing a CRC is equivalent to computing it at once
+	if a, b := CRC8(CRC8(0, 0x16, 0x08), 0x17), CRC8(0, 0x16, 0x08, 0x17); a != b {
+		t.Fatalf("CRC8()={%02X}, expected={%02X}", a, b)
+	}
+}
+
+func TestHostNotify(t *testing.T) {
+	n := HostNotify{Addr: 0x0B, Data: 0x1234}
+	b := n.Bytes()
+	if exp := []uint8{0x16, 0x34, 0x12}; !bytes.Equal(exp, b) {
+		t.Fatalf("Bytes()={% X}, expected={% X}", b, exp)
+	}
+	if p, err := ParseHostNotify(b); nil != err || n != p {
+		t.Fatalf("ParseHostNotify()={%v}, expected={%v}: %v", p, n, err)
+	}
+	for _, b := range [][]uint8{{0x16, 0x34}, {0x17, 0x34, 0x12}} {
+		if _, err := ParseHostNotify(b); nil == err {
+			t.Fatalf("ParseHostNotify(% X): expected error", b)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package ft232h
 
 import (
 	"fmt"
+
+	"github.com/ardnew/ft232h/mpsse"
 )
 
 // SPI stores interface configuration settings for an SPI master and provides
@@ -89,6 +91,7 @@ func (c *spiConfig) SPIConfig() *SPIConfig {
 			CS:        c.options.cs(),
 			ActiveLow: c.options.activeLow(),
 			Mode:      c.options.mode(),
+			LSBFirst:  c.options.lsbFirst(),
 		},
 		Clock:   c.clockRate,
 		Latency: c.latency,
@@ -105,26 +108,30 @@ func (c *spiConfig) SPIConfig() *SPIConfig {
 // stop. In both cases, the current value of the ActiveLow flag determines if
 // the CS line driven LOW (ActiveLow true, DEFAULT) or HIGH (ActiveLow false)
 // when asserting and then de-asserting.
+//
+// Data is transferred most-significant bit first by default. If LSBFirst is
+// true, each byte is instead transferred least-significant bit first.
 type SPIOption struct {
 	CS        Pin  // CS pin to assert when writing (can be DPin or CPin (GPIO))
 	ActiveLow bool // CS asserted "active" by driving pin LOW or HIGH
-	Mode      byte // SPI operating mode (mode 0 and 2 support only)
+	Mode      byte // SPI operating mode (0-3)
+	LSBFirst  bool // transfer each byte LSB first (default MSB first)
 }
 
 // spiOption stores the various SPI configuration options as a 32-bit bitmap.
 type spiOption uint32
 
 func (o spiOption) String() string {
-	return fmt.Sprintf("{ ChipSelect: %q, ActiveLow: %t, SPIMode: %d }",
-		o.cs(), o.activeLow(), o.mode())
+	return fmt.Sprintf("{ ChipSelect: %q, ActiveLow: %t, SPIMode: %d, LSBFirst: %t }",
+		o.cs(), o.activeLow(), o.mode(), o.lsbFirst())
 }
 
-// Constants defining SPI operating modes (supports mode 0 and 2 only (CPHA=2))
+// Constants defining SPI operating modes (bit 1 is CPOL, bit 0 is CPHA)
 const (
-	spiMode0       spiOption = 0x00000000 // capture on RISE, propagate on FALL
-	spiMode1       spiOption = 0x00000001 // capture on FALL, propagate on RISE
-	spiMode2       spiOption = 0x00000002 // capture on FALL, propagate on RISE
-	spiMode3       spiOption = 0x00000003 // capture on RISE, propagate on FALL
+	spiMode0       spiOption = 0x00000000 // idle LOW, capture on RISE, propagate on FALL
+	spiMode1       spiOption = 0x00000001 // idle LOW, capture on FALL, propagate on RISE
+	spiMode2       spiOption = 0x00000002 // idle HIGH, capture on FALL, propagate on RISE
+	spiMode3       spiOption = 0x00000003 // idle HIGH, capture on RISE, propagate on FALL
 	spiModeMask    spiOption = 0x00000003
 	spiModeDefault           = spiMode0
 )
@@ -148,10 +155,18 @@ const (
 	spiCSActiveDefault           = spiCSActiveLow
 )
 
+// Constants defining the order bits are transferred in each byte
+const (
+	spiMSBFirst     spiOption = 0x00000000 // transfer MSB first
+	spiLSBFirst     spiOption = 0x00000040 // transfer LSB first
+	spiOrderMask    spiOption = 0x00000040
+	spiOrderDefault           = spiMSBFirst
+)
+
 // Constants with values shared by fields of the SPI configuration.
 const (
 	spiOptionInvalid spiOption = 0xAAAAAAAA
-	spiOptionDefault           = spiCSActiveDefault | spiCSDefault | spiModeDefault
+	spiOptionDefault           = spiCSActiveDefault | spiCSDefault | spiModeDefault | spiOrderDefault
 )
 
 // Valid verifies the spiOption receiver opt isnt equal to the sentinel value
@@ -181,6 +196,28 @@ func (opt spiOption) activeLow() bool {
 	return spiCSActiveLow == (opt & spiCSActiveMask)
 }
 
+// lsbFirst reads the bit order flag in the spiOption receiver opt and returns
+// true if bytes are transferred LSB first, false if MSB first.
+func (opt spiOption) lsbFirst() bool {
+	return spiLSBFirst == (opt & spiOrderMask)
+}
+
+// clock reads the SPI mode in the spiOption receiver opt and returns the idle
+// level of SCLK (CPOL, true=HIGH), and the clock edges on which data is clocked
+// out on MOSI and clocked in from MISO.
+func (opt spiOption) clock() (idle bool, out mpsse.Edge, in mpsse.Edge) {
+	switch opt & spiModeMask {
+	case spiMode1:
+		return false, mpsse.Rising, mpsse.Falling
+	case spiMode2:
+		return true, mpsse.Rising, mpsse.Falling
+	case spiMode3:
+		return true, mpsse.Falling, mpsse.Rising
+	default: // spiMode0
+		return false, mpsse.Falling, mpsse.Rising
+	}
+}
+
 // spiOptionCS translates a DPin p to its corresponding chip-select mask for the
 // option field of an SPI configuration struct.
 func (p DPin) spiOptionCS() spiOption {
@@ -218,9 +255,9 @@ func (p DPin) spiPin(cfg *spiPinConfig) uint32 {
 // spiPinConfigDefault defines the default spiPinConfig value for each DPin.
 // all output pins are configured LOW except for the default CS pin (D3) since
 // we also have spiCSActiveLow by default. this means we won't activate the
-// default slave line until intended. it also means SCLK idles LOW (change
-// initVal to PinHI to idle HIGH). All GPIO pins on this port are configured as
-// input LOW lines.
+// default slave line until intended. SCLK is then driven to the idle level of
+// the configured SPI mode (CPOL) during initialization. All GPIO pins on this
+// port are configured as input LOW lines.
 func spiPinConfigDefault() uint32 {
 	var pin uint32
 	for i, cfg := range [NumDPins]*spiPinConfig{
@@ -276,10 +313,13 @@ func (spi *SPI) Change(cs Pin) error {
 
 	spi.config.chipSelect = cs // update only if we didnt return early on error
 
-	// only invoke the driver if we have an active SPI channel. otherwise, these
+	// only update the pins if we have an active SPI channel. otherwise, these
 	// options get set on next Init().
 	if ModeSPI == spi.device.mode {
-		if err := _SPI_Change(spi); nil != err {
+		if ch := spi.device.info.engine.channel; nil != ch {
+			return ch.SPIChange(uint32(spi.config.options & ^spiOrderMask))
+		}
+		if _, err := spi.device.info.engine.exec(spi.idle()); nil != err {
 			return err
 		}
 	}
@@ -287,6 +327,21 @@ func (spi *SPI) Change(cs Pin) error {
 	return nil
 }
 
+// ChangeBitOrder changes the order bits are transferred in each byte, LSB first
+// if lsbFirst is true, otherwise MSB first.
+// It can be called while the SPI interface is open without having to first
+// close and reopen the device, and takes effect on the next transfer.
+func (spi *SPI) ChangeBitOrder(lsbFirst bool) error {
+
+	spi.config.options &= ^(spiOrderMask)
+
+	if lsbFirst {
+		spi.config.options |= spiLSBFirst
+	}
+
+	return nil
+}
+
 // Option changes the dynamic configuration parameters of the SPI interface.
 // It can be called while the SPI interface is open without having to first
 // close and reopen the device.
@@ -302,7 +357,12 @@ func (spi *SPI) Option(opt *SPIOption) error {
 		return fmt.Errorf("invalid SPI mode: Mode %d", opt.Mode)
 	}
 
-	spi.config.options = activeOpt | modeOpt
+	orderOpt := spiMSBFirst
+	if opt.LSBFirst {
+		orderOpt = spiLSBFirst
+	}
+
+	spi.config.options = activeOpt | modeOpt | orderOpt
 
 	return spi.Change(opt.CS)
 }
@@ -349,13 +409,39 @@ func (spi *SPI) Config(cfg *SPIConfig) error {
 // initializing the interface.
 func (spi *SPI) Init() error {
 
-	if err := _SPI_InitChannel(spi); nil != err {
+	eng := spi.device.info.engine
+
+	if nil != eng.channel {
+		if err := eng.channel.SPIInit(spi.config.clockRate, spi.config.latency,
+			uint32(spi.config.options & ^spiOrderMask), spi.config.pin); nil != err {
+			return err
+		}
+		spi.device.mode = ModeSPI
+		return spi.device.GPIO.Init() // reset GPIO
+	}
+
+	div, err := mpsse.Divisor(spi.config.clockRate)
+	if nil != err {
+		return err
+	}
+
+	if err := eng.init(spi.config.latency); nil != err {
+		return err
+	}
+
+	// start with the initial pin configuration, and then drive SCLK and CS to
+	// their idle levels.
+	eng.low, eng.lowDir = uint8(spi.config.pin>>8), uint8(spi.config.pin)
+	if _, err := eng.exec(mpsse.ClockDivisor(div), spi.idle()); nil != err {
 		return err
 	}
 
 	spi.device.mode = ModeSPI
 
-	return spi.device.GPIO.Init() // reset GPIO
+	if err := spi.device.GPIO.Init(); nil != err { // reset GPIO
+		return err
+	}
+	return spi.device.DGPIO.Init() // reset unreserved port "D" pins
 }
 
 // Close closes both the SPI interface and the connection to the FT232H device.
@@ -371,32 +457,13 @@ func (spi *SPI) Close() error {
 // an error.
 func (spi *SPI) Read(count uint, start bool, stop bool) ([]uint8, error) {
 
-	cs := spi.config.chipSelect
-	opt := spiXferDefault
-	ass := 0 == uint32(spiCSActiveLow&spi.config.options)
-
-	if start {
-		if cs.IsMPSSE() {
-			opt |= spiCSAssert
-		} else {
-			opt &= ^spiCSAssert
-			if err := spi.device.GPIO.Set(cs.(CPin), ass); nil != err {
-				return nil, err
-			}
-		}
+	opt, done, err := spi.begin(start, stop)
+	if nil != err {
+		return nil, err
 	}
+	defer done()
 
-	if stop {
-		if cs.IsMPSSE() {
-			opt |= spiCSDeAssert
-		} else {
-			opt &= ^spiCSDeAssert
-			// deassert on return
-			defer func() { spi.device.GPIO.Set(cs.(CPin), !ass) }()
-		}
-	}
-
-	return _SPI_Read(spi, count, opt)
+	return spi.read(count, opt)
 }
 
 // ReadFrom returns the result of Read after configuring the active CS line.
@@ -421,32 +488,13 @@ func (spi *SPI) ReadFrom(cs Pin, count uint, start bool, stop bool) ([]uint8, er
 // was an error.
 func (spi *SPI) Write(data []uint8, start bool, stop bool) (uint, error) {
 
-	cs := spi.config.chipSelect
-	opt := spiXferDefault
-	ass := 0 == uint32(spiCSActiveLow&spi.config.options)
-
-	if start {
-		if cs.IsMPSSE() {
-			opt |= spiCSAssert
-		} else {
-			opt &= ^spiCSAssert
-			if err := spi.device.GPIO.Set(cs.(CPin), ass); nil != err {
-				return 0, err
-			}
-		}
+	opt, done, err := spi.begin(start, stop)
+	if nil != err {
+		return 0, err
 	}
+	defer done()
 
-	if stop {
-		if cs.IsMPSSE() {
-			opt |= spiCSDeAssert
-		} else {
-			opt &= ^spiCSDeAssert
-			// deassert on return
-			defer func() { spi.device.GPIO.Set(cs.(CPin), !ass) }()
-		}
-	}
-
-	return _SPI_Write(spi, data, opt)
+	return spi.write(data, opt)
 }
 
 // WriteTo returns the result of Write after configuring the active CS line.
@@ -473,17 +521,125 @@ func (spi *SPI) WriteTo(cs Pin, data []uint8, start bool, stop bool) (uint, erro
 // an error.
 func (spi *SPI) Swap(data []uint8, start bool, stop bool) ([]uint8, error) {
 
+	opt, done, err := spi.begin(start, stop)
+	if nil != err {
+		return nil, err
+	}
+	defer done()
+
+	return spi.swap(data, opt)
+}
+
+// SwapWith returns the result of Swap after configuring the active CS line.
+// If the given CS pin is not the same as the currently configured CS pin, the
+// CS configuration is changed and persists after swapping.
+func (spi *SPI) SwapWith(cs Pin, data []uint8, start bool, stop bool) ([]uint8, error) {
+
+	if (start || stop) && !cs.Equals(spi.config.chipSelect) {
+		// change if we are writing to a slave different than currently configured
+		if err := spi.Change(cs); nil != err {
+			return nil, err
+		}
+	}
+	return spi.Swap(data, start, stop)
+}
+
+// WriteBits writes the first count bits of the given byte slice data to the SPI
+// interface, for devices using frames that are not a whole number of bytes
+// (e.g., 9-bit displays or 12-bit DACs).
+// There is no maximum number of bits to write.
+//
+// Bits are packed into data in transfer order, beginning with data[0]. Each
+// byte is transferred from bit 7 to bit 0 when MSB first (the default), or from
+// bit 0 to bit 7 when LSB first (see SPIOption.LSBFirst). Thus, when count is
+// not a multiple of 8, the final partial byte holds its count%8 bits in its
+// most-significant bits when MSB first, or in its least-significant bits when
+// LSB first, and the remaining bits are ignored. For example, the 9-bit frame
+// 0x1A5 is written MSB first as []uint8{0xD2, 0x80}.
+//
+// If start is true, the CS line is asserted before transfer.
+// If stop is true, the CS line is de-asserted after transfer.
+// Returns the number of bits successfully written and a non-nil error if there
+// was an error.
+func (spi *SPI) WriteBits(data []uint8, count uint, start bool, stop bool) (uint, error) {
+
+	if uint(len(data))*8 < count {
+		return 0, fmt.Errorf("insufficient data: %d bits, expected %d", len(data)*8, count)
+	}
+
+	opt, done, err := spi.begin(start, stop)
+	if nil != err {
+		return 0, err
+	}
+	defer done()
+
+	return spi.bits(nil, data, count, opt)
+}
+
+// ReadBits reads the given count number of bits from the SPI interface.
+// There is no maximum number of bits to read.
+// The bits are packed into the returned slice as described by WriteBits, with
+// the unused bits of a final partial byte cleared.
+// If start is true, the CS line is asserted before transfer.
+// If stop is true, the CS line is de-asserted after transfer.
+// Returns the slice of bytes containing the bits successfully read and a
+// non-nil error if there was an error.
+func (spi *SPI) ReadBits(count uint, start bool, stop bool) ([]uint8, error) {
+
+	opt, done, err := spi.begin(start, stop)
+	if nil != err {
+		return nil, err
+	}
+	defer done()
+
+	data := make([]uint8, (count+7)/8)
+	n, err := spi.bits(data, nil, count, opt)
+	return data[:(n+7)/8], err
+}
+
+// SwapBits simultaneously reads and writes the first count bits of the given
+// byte slice data on the SPI interface.
+// There is no maximum number of bits to swap.
+// The bits are packed into both slices as described by WriteBits, with the
+// unused bits of a final partial byte in the returned slice cleared.
+// If start is true, the CS line is asserted before transfer.
+// If stop is true, the CS line is de-asserted after transfer.
+// Returns the slice of bytes containing the bits successfully read and a
+// non-nil error if there was an error.
+func (spi *SPI) SwapBits(data []uint8, count uint, start bool, stop bool) ([]uint8, error) {
+
+	if uint(len(data))*8 < count {
+		return nil, fmt.Errorf("insufficient data: %d bits, expected %d", len(data)*8, count)
+	}
+
+	opt, done, err := spi.begin(start, stop)
+	if nil != err {
+		return nil, err
+	}
+	defer done()
+
+	recv := make([]uint8, (count+7)/8)
+	n, err := spi.bits(recv, data, count, opt)
+	return recv[:(n+7)/8], err
+}
+
+// begin returns the transfer options for asserting the configured CS line
+// before transfer if start is true, and de-asserting it after transfer if stop
+// is true. If the CS line is a CPin (GPIO), it is asserted immediately, and the
+// returned function (which must be called after transfer) de-asserts it.
+func (spi *SPI) begin(start bool, stop bool) (spiXferOption, func(), error) {
+
 	cs := spi.config.chipSelect
 	opt := spiXferDefault
 	ass := 0 == uint32(spiCSActiveLow&spi.config.options)
+	done := func() {}
 
 	if start {
 		if cs.IsMPSSE() {
 			opt |= spiCSAssert
 		} else {
-			opt &= ^spiCSAssert
 			if err := spi.device.GPIO.Set(cs.(CPin), ass); nil != err {
-				return nil, err
+				return opt, done, err
 			}
 		}
 	}
@@ -492,25 +648,272 @@ func (spi *SPI) Swap(data []uint8, start bool, stop bool) ([]uint8, error) {
 		if cs.IsMPSSE() {
 			opt |= spiCSDeAssert
 		} else {
-			opt &= ^spiCSDeAssert
-			// deassert on return
-			defer func() { spi.device.GPIO.Set(cs.(CPin), !ass) }()
+			done = func() { spi.device.GPIO.Set(cs.(CPin), !ass) }
 		}
 	}
 
-	return _SPI_Swap(spi, data, opt)
+	return opt, done, nil
 }
 
-// SwapWith returns the result of Swap after configuring the active CS line.
-// If the given CS pin is not the same as the currently configured CS pin, the
-// CS configuration is changed and persists after swapping.
-func (spi *SPI) SwapWith(cs Pin, data []uint8, start bool, stop bool) ([]uint8, error) {
+// read performs an SPI read using the MPSSE engine with the given number of
+// bytes to read and transfer options, returning a slice of uint8 containing the
+// bytes successfully read, and a non-nil error if there was an error.
+func (spi *SPI) read(count uint, opt spiXferOption) ([]uint8, error) {
 
-	if (start || stop) && !cs.Equals(spi.config.chipSelect) {
-		// change if we are writing to a slave different than currently configured
-		if err := spi.Change(cs); nil != err {
-			return nil, err
+	data := make([]uint8, count)
+	n, err := spi.chunk(data, nil, count, opt)
+	return data[:n], err
+}
+
+// write performs an SPI write using the MPSSE engine with the given slice of
+// uint8 data to send and transfer options, returning the total number of bytes
+// successfully transferred, and a non-nil error if there was an error.
+func (spi *SPI) write(data []uint8, opt spiXferOption) (uint, error) {
+	return spi.chunk(nil, data, uint(len(data)), opt)
+}
+
+// swap performs a simultaneous SPI read+write using the MPSSE engine with the
+// given slice of uint8 data to send and transfer options, returning a slice of
+// uint8 containing the bytes successfully read, and a non-nil error if there
+// was an error.
+func (spi *SPI) swap(send []uint8, opt spiXferOption) ([]uint8, error) {
+
+	recv := make([]uint8, len(send))
+	n, err := spi.chunk(recv, send, uint(len(send)), opt)
+	return recv[:n], err
+}
+
+// bits performs a bit-granular SPI transfer of the given number of bits using
+// the MPSSE engine with the given transfer options. If send is non-nil, its
+// bits are clocked out on MOSI. If recv is non-nil, its bits are clocked in from
+// MISO. Both slices must have length of at least count/8 bytes, rounded up. See
+// WriteBits for the packing of bits.
+// Returns the number of bits transferred and a non-nil error if there was an
+// error.
+func (spi *SPI) bits(recv []uint8, send []uint8, count uint, opt spiXferOption) (uint, error) {
+	return spi.chunk(recv, send, count, opt|spiXferBits)
+}
+
+// chunk performs an SPI transfer of the given size using the MPSSE engine with
+// the given transfer options. The size is given in bits if the option
+// spiXferBits is set, otherwise in bytes. If send is non-nil, its bits are
+// clocked out on MOSI. If recv is non-nil, its bits are clocked in from MISO.
+// Both slices must have length of at least size bytes (or size/8 bytes, rounded
+// up, if spiXferBits is set).
+// If the size is greater than the MPSSE transfer limit (65536 bytes), multiple
+// transfers are performed with the MPSSE engine, with the partial byte of a
+// bit-granular transfer always following the last whole byte. In this case, if
+// the CS assert/deassert options are set, the CS line is only asserted and/or
+// deasserted with the first and last transfer requests, respectively.
+// Returns the number of bits (or bytes) transferred and a non-nil error if
+// there was an error.
+func (spi *SPI) chunk(recv []uint8, send []uint8, size uint, opt spiXferOption) (uint, error) {
+
+	count, rem := size, uint(0)
+	if (opt & spiXferBits) > 0 {
+		count, rem = (size+7)/8, size%8
+	}
+
+	ass := (opt & spiCSAssert) > 0
+	dea := (opt & spiCSDeAssert) > 0
+
+	// returns the given number of bytes converted to the units of size
+	unit := func(n uint) uint {
+		if (opt & spiXferBits) > 0 {
+			return n * 8
 		}
+		return n
 	}
-	return spi.Swap(data, start, stop)
+
+	// slices the given buffer, preserving nil
+	part := func(b []uint8, beg uint, end uint) []uint8 {
+		if nil == b {
+			return nil
+		}
+		return b[beg:end]
+	}
+
+	for beg := uint(0); beg < count; beg += maxTransferBytes {
+
+		end := beg + maxTransferBytes
+		if end > count {
+			end = count
+		}
+
+		// dont assert if this isn't the first packet
+		if ass {
+			if beg > 0 {
+				opt &= ^spiCSAssert
+			}
+		}
+
+		// don't deassert if this isn't the last packet, and only the last packet
+		// contains the partial byte.
+		last := uint(0)
+		if end < count {
+			if dea {
+				opt &= ^spiCSDeAssert
+			}
+		} else {
+			if dea {
+				opt |= spiCSDeAssert
+			}
+			last = rem
+		}
+
+		sent, err := spi.transfer(part(recv, beg, end), part(send, beg, end), last, opt)
+		if nil != err {
+			return unit(beg + sent), err
+		}
+
+	}
+	return size, nil
+}
+
+// transfer performs a single SPI transfer of at most 65536 bytes using the
+// MPSSE engine with the given transfer options. If send is non-nil, its bytes
+// are clocked out on MOSI. If recv is non-nil, len(recv) bytes are clocked in
+// from MISO. If both are non-nil, they must have equal length.
+// If rem is non-zero, only the first rem bits of the last byte are transferred
+// (see WriteBits for the packing of bits).
+// Returns the number of whole bytes transferred and a non-nil error if there
+// was an error.
+func (spi *SPI) transfer(recv []uint8, send []uint8, rem uint, opt spiXferOption) (uint, error) {
+
+	count := len(send)
+	if nil == send {
+		count = len(recv)
+	}
+	if 0 == count {
+		return 0, nil
+	}
+
+	if ch := spi.device.info.engine.channel; nil != ch {
+		return spi.transferChannel(ch, recv, send, rem, opt)
+	}
+
+	full := count
+	if rem > 0 {
+		full-- // the last byte is partial
+	}
+
+	_, out, in := spi.config.options.clock()
+	lsb := spi.config.options.lsbFirst()
+
+	cmd := []mpsse.Command{}
+	if (opt & spiCSAssert) > 0 {
+		cmd = append(cmd, spi.cs(true))
+	}
+	if full > 0 {
+		data := send
+		if nil != send {
+			data = send[:full]
+		}
+		cmd = append(cmd, mpsse.ClockData{
+			Out:     nil != send,
+			In:      nil != recv,
+			LSB:     lsb,
+			OutEdge: out,
+			InEdge:  in,
+			Len:     full,
+			Data:    data,
+		})
+	}
+	if rem > 0 {
+		// MPSSE clocks out bits from bit 7 when MSB first and from bit 0 when LSB
+		// first, matching the packing of partial bytes.
+		data := send
+		if nil != send {
+			data = send[full:count]
+		}
+		cmd = append(cmd, mpsse.ClockData{
+			Out:     nil != send,
+			In:      nil != recv,
+			Bits:    true,
+			LSB:     lsb,
+			OutEdge: out,
+			InEdge:  in,
+			Len:     int(rem),
+			Data:    data,
+		})
+	}
+	if (opt & spiCSDeAssert) > 0 {
+		cmd = append(cmd, spi.cs(false))
+	}
+
+	resp, err := spi.device.info.engine.exec(cmd...)
+	if nil != err {
+		return 0, err
+	}
+	copy(recv, resp)
+	if rem > 0 && nil != recv {
+		// MPSSE shifts bits in at bit 0 when MSB first and at bit 7 when LSB
+		// first, so realign the partial byte to match the packing of bits.
+		if lsb {
+			recv[full] >>= 8 - rem
+		} else {
+			recv[full] <<= 8 - rem
+		}
+	}
+	return uint(full), nil
+}
+
+// transferChannel performs a single SPI transfer identical to transfer using the
+// SPI channel of the given PortChannel, which supports neither bit-granular nor
+// LSB-first transfers.
+func (spi *SPI) transferChannel(ch PortChannel, recv []uint8, send []uint8, rem uint, opt spiXferOption) (uint, error) {
+
+	if rem > 0 {
+		return 0, fmt.Errorf("bit-granular transfer: %w", ErrNoMPSSE)
+	}
+	if spi.config.options.lsbFirst() {
+		return 0, fmt.Errorf("LSB-first transfer: %w", ErrNoMPSSE)
+	}
+
+	// the size of whole bytes is always given in bytes
+	opt &= ^spiXferBits
+
+	switch {
+	case nil == recv:
+		return ch.SPIWrite(send, uint32(opt))
+	case nil == send:
+		return ch.SPIRead(recv, uint32(opt))
+	default:
+		return ch.SPISwap(recv, send, uint32(opt))
+	}
+}
+
+// cs returns the command driving the CS pin on port "D" configured in the SPI
+// options to its asserted level if assert is true, or its de-asserted level
+// otherwise. The levels of all other pins are unchanged.
+func (spi *SPI) cs(assert bool) mpsse.Command {
+	eng := spi.device.info.engine
+	mask := spi.config.options.cs().Mask()
+	return eng.setLow(spi.csLevel(eng.low, assert), eng.lowDir|mask)
+}
+
+// idle returns the command driving SCLK to its idle level for the configured
+// SPI mode, and the CS pin on port "D" configured in the SPI options to its
+// de-asserted level. The levels of all other pins are unchanged.
+func (spi *SPI) idle() mpsse.Command {
+	eng := spi.device.info.engine
+	sclk, mask := D(0).Mask(), spi.config.options.cs().Mask()
+	val := spi.csLevel(eng.low, false)
+	if idle, _, _ := spi.config.options.clock(); idle {
+		val |= sclk
+	} else {
+		val &= ^sclk
+	}
+	return eng.setLow(val, eng.lowDir|sclk|mask)
+}
+
+// csLevel returns the given port "D" pin levels val with the CS pin configured
+// in the SPI options set to its asserted level if assert is true, or its
+// de-asserted level otherwise.
+func (spi *SPI) csLevel(val uint8, assert bool) uint8 {
+	mask := spi.config.options.cs().Mask()
+	if assert == spi.config.options.activeLow() {
+		return val & ^mask
+	}
+	return val | mask
 }
@@ -0,0 +1,218 @@
+package ft232h
+
+import (
+	"fmt"
+)
+
+// SPIRegNoBit indicates an SPIRegFormat flag bit is not used.
+const SPIRegNoBit = -1
+
+// SPIRegFormat describes the command format of an SPI slave device's registers.
+// Each register transfer begins with a command containing the register address
+// in its low-order bits, along with optional read/write and auto-increment flag
+// bits, followed by the register data.
+//
+// For example, the common convention "bit 7 = R/W (1 = read), bits 6:0 =
+// address" is expressed as:
+//
+//	SPIRegFormat{Space: Addr8Bit, Order: MSB, ReadBit: 7, IncBit: SPIRegNoBit}
+type SPIRegFormat struct {
+	Space     AddrSpace // size of the command, i.e. the address and flag bits
+	Order     ByteOrder // byte order of the command and register data
+	ReadBit   int       // position of the read/write flag bit, or SPIRegNoBit
+	ReadClear bool      // read/write flag is clear to read and set to write (default: set to read)
+	IncBit    int       // position of the auto-increment flag bit, or SPIRegNoBit
+	Dummy     uint      // number of dummy clock cycles between a read command and its data
+}
+
+// Predefined command formats of common SPI slave devices.
+var (
+	// bit 7 = R/W (1 = read), bits 6:0 = address, e.g. Bosch BME280, BMP280
+	SPIRegFormatRW7 = SPIRegFormat{
+		Space: Addr8Bit, Order: MSB, ReadBit: 7, IncBit: SPIRegNoBit,
+	}
+	// bit 7 = R/W (1 = read), bit 6 = auto-increment, bits 5:0 = address, with
+	// little-endian data, e.g. ST LIS3DH, L3GD20
+	SPIRegFormatRW7Inc6 = SPIRegFormat{
+		Space: Addr8Bit, Order: LSB, ReadBit: 7, IncBit: 6,
+	}
+)
+
+// SPIReg represents a read-write register of an SPI slave device, selected by
+// the currently configured CS line of the SPI interface (see SPIOption).
+type SPIReg struct {
+	spi    *SPI         // the SPI interface to use
+	addr   uint         // register address to read/write
+	format SPIRegFormat // command format of the register
+}
+
+// Reg constructs a new SPIReg for conveniently reading and writing data in SPI
+// slave device registers using the given command format.
+func (spi *SPI) Reg(addr uint, format SPIRegFormat) *SPIReg {
+	return &SPIReg{
+		spi:    spi,
+		addr:   addr,
+		format: format,
+	}
+}
+
+// flag returns the mask of the given flag bit position in the command, and a
+// non-nil error if the position is outside the command.
+func (reg *SPIReg) flag(name string, pos int) (uint64, error) {
+	if SPIRegNoBit == pos {
+		return 0, nil
+	}
+	if pos < 0 || uint(pos) >= reg.format.Space.Bits() {
+		return 0, fmt.Errorf("%s bit outside %s command: %d",
+			name, reg.format.Space, pos)
+	}
+	return 1 << uint(pos), nil
+}
+
+// command checks that fields of an SPIReg pass basic sanity requirements.
+// Returns the byte-ordered command to send for reading (if read is true) or
+// writing the given count number of bytes.
+func (reg *SPIReg) command(read bool, count uint) ([]uint8, error) {
+
+	if nil == reg {
+		return nil, fmt.Errorf("invalid receiver (nil)")
+	}
+
+	f := reg.format
+	b := f.Space.Bytes()
+	if 0 == b {
+		return nil, fmt.Errorf("invalid command space: %d", f.Space)
+	}
+
+	rw, err := reg.flag("read/write", f.ReadBit)
+	if nil != err {
+		return nil, err
+	}
+	inc, err := reg.flag("auto-increment", f.IncBit)
+	if nil != err {
+		return nil, err
+	}
+	if 0 != rw&inc {
+		return nil, fmt.Errorf("read/write and auto-increment bits overlap: %d", f.ReadBit)
+	}
+
+	cmd := uint64(reg.addr)
+	if 0 != cmd&(rw|inc) ||
+		(f.Space.Bits() < 64 && cmd >= 1<<f.Space.Bits()) {
+		return nil, fmt.Errorf("register address outside %s command: 0x%02X",
+			f.Space, reg.addr)
+	}
+
+	if read != f.ReadClear {
+		cmd |= rw
+	}
+	if count > 1 {
+		cmd |= inc
+	}
+
+	c := f.Order.Bytes(b, cmd)
+	if 0 == len(c) {
+		return nil, fmt.Errorf("invalid byte order: %d", f.Order)
+	}
+
+	return c, nil
+}
+
+// ReadBytes reads the given count number of bytes from consecutive registers,
+// beginning with the receiver's register, in a single transaction. The
+// auto-increment bit, if any, is set in the command if count is greater than 1.
+func (reg *SPIReg) ReadBytes(count uint) ([]uint8, error) {
+
+	cmd, err := reg.command(true, count)
+	if nil != err {
+		return nil, err
+	}
+
+	rd, err := reg.spi.Tx().
+		Assert().Write(cmd).Clock(reg.format.Dummy).Read(count).Deassert().
+		Exec()
+	if nil != err {
+		return nil, err
+	}
+
+	return rd[0], nil
+}
+
+// WriteBytes writes the given data to consecutive registers, beginning with the
+// receiver's register, in a single transaction. The auto-increment bit, if
+// any, is set in the command if data has more than 1 byte.
+func (reg *SPIReg) WriteBytes(data []uint8) error {
+
+	cmd, err := reg.command(false, uint(len(data)))
+	if nil != err {
+		return err
+	}
+
+	_, err = reg.spi.Tx().
+		Assert().Write(append(cmd, data...)).Deassert().
+		Exec()
+	return err
+}
+
+// Read reads a register of the given size (in bytes, 1-8) in a single
+// transaction, returning its value formatted with the SPIRegFormat byte order.
+func (reg *SPIReg) Read(size uint) (uint64, error) {
+
+	if 0 == size || size > 8 {
+		return 0, fmt.Errorf("invalid register size: %d (1-8)", size)
+	}
+
+	dat, err := reg.ReadBytes(size)
+	if nil != err {
+		return 0, err
+	}
+
+	return reg.format.Order.Uint(size, dat), nil
+}
+
+// Write writes the given value to a register of the given size (in bytes, 1-8),
+// formatted with the SPIRegFormat byte order.
+func (reg *SPIReg) Write(size uint, value uint64) error {
+
+	if 0 == size || size > 8 {
+		return fmt.Errorf("invalid register size: %d (1-8)", size)
+	}
+
+	return reg.WriteBytes(reg.format.Order.Bytes(size, value))
+}
+
+// Update performs a read-modify-write of a register of the given size (in
+// bytes, 1-8): the bits set in mask are replaced with the corresponding bits of
+// value, and all other bits are unchanged.
+func (reg *SPIReg) Update(size uint, mask uint64, value uint64) error {
+
+	curr, err := reg.Read(size)
+	if nil != err {
+		return err
+	}
+
+	return reg.Write(size, (curr & ^mask)|(value&mask))
+}
+
+// Field reads a register of the given size (in bytes, 1-8), returning the value
+// of the given bitfield.
+func (reg *SPIReg) Field(size uint, field RegField) (uint64, error) {
+
+	v, err := reg.Read(size)
+	if nil != err {
+		return 0, err
+	}
+
+	return field.Get(v), nil
+}
+
+// SetField performs a read-modify-write of a register of the given size (in
+// bytes, 1-8), setting the given bitfield to value, and returns a non-nil error
+// if value does not fit in the bitfield.
+func (reg *SPIReg) SetField(size uint, field RegField, value uint64) error {
+	if value > field.Max() {
+		return fmt.Errorf("value exceeds bitfield %s: %d (max %d)",
+			field, value, field.Max())
+	}
+	return reg.Update(size, field.Mask(), value<<field.Shift)
+}
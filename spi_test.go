@@ -0,0 +1,569 @@
+package ft232h
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+func TestSPIMode(t *testing.T) {
+
+	for _, test := range []struct {
+		mode  byte
+		idle  bool  // SCLK idle level (CPOL)
+		write uint8 // opcode of write command
+		read  uint8 // opcode of read command
+		swap  uint8 // opcode of swap command
+	}{
+		{0, false, 0x11, 0x20, 0x31},
+		{1, false, 0x10, 0x24, 0x34},
+		{2, true, 0x10, 0x24, 0x34},
+		{3, true, 0x11, 0x20, 0x31},
+	} {
+		t.Run(string('0'+rune(test.mode)), func(t *testing.T) {
+
+			tpt := &testTransport{port: &testPort{}}
+			ft, err := OpenMask(&Mask{Transport: tpt})
+			if nil != err {
+				t.Fatalf("OpenMask(): %v", err)
+			}
+			defer ft.Close()
+
+			cfg := SPIConfigDefault()
+			cfg.Mode = test.mode
+			if err := ft.SPI.Config(cfg); nil != err {
+				t.Fatalf("SPI.Config(): %v", err)
+			}
+			if test.mode != ft.SPI.GetConfig().Mode {
+				t.Fatalf("GetConfig().Mode={%d}, expected={%d}",
+					ft.SPI.GetConfig().Mode, test.mode)
+			}
+
+			// every pin update must leave SCLK as an output at its idle level
+			sclk := func(cmd []mpsse.Command) {
+				for _, c := range cmd {
+					if s, ok := c.(mpsse.SetLow); ok {
+						if 0 == (s.Dir & D(0).Mask()) {
+							t.Fatalf("SCLK configured as input: %v", s)
+						}
+						if idle := (s.Value & D(0).Mask()) > 0; idle != test.idle {
+							t.Fatalf("SCLK idle={%t}, expected={%t}: %v", idle, test.idle, s)
+						}
+					}
+				}
+			}
+
+			for _, xfer := range []struct {
+				name   string
+				opcode uint8
+				fn     func() error
+			}{
+				{"write", test.write, func() error {
+					_, err := ft.SPI.Write([]uint8{0xA5, 0x5A}, true, true)
+					return err
+				}},
+				{"read", test.read, func() error {
+					_, err := ft.SPI.Read(2, true, true)
+					return err
+				}},
+				{"swap", test.swap, func() error {
+					_, err := ft.SPI.Swap([]uint8{0xA5, 0x5A}, true, true)
+					return err
+				}},
+			} {
+				tpt.port.cmd = nil
+				if err := xfer.fn(); nil != err {
+					t.Fatalf("SPI %s: %v", xfer.name, err)
+				}
+				sclk(tpt.port.cmd)
+				// CS assert, transfer, CS de-assert (and then SendImmediate if reading)
+				if len(tpt.port.cmd) < 3 {
+					t.Fatalf("SPI %s: commands={%v}, expected 3", xfer.name, tpt.port.cmd)
+				}
+				enc, err := mpsse.Encode(tpt.port.cmd[1])
+				if nil != err {
+					t.Fatalf("Encode(%v): %v", tpt.port.cmd[1], err)
+				}
+				if xfer.opcode != enc[0] {
+					t.Fatalf("SPI %s: opcode={0x%02X}, expected={0x%02X}",
+						xfer.name, enc[0], xfer.opcode)
+				}
+			}
+
+			// changing mode on an open channel updates the SCLK idle level
+			tpt.port.cmd = nil
+			next := (test.mode + 2) % 4
+			if err := ft.SPI.Option(&SPIOption{CS: D(3), ActiveLow: true, Mode: next}); nil != err {
+				t.Fatalf("SPI.Option(): %v", err)
+			}
+			test.idle = !test.idle
+			sclk(tpt.port.cmd)
+			if 0 == len(tpt.port.cmd) {
+				t.Fatalf("SPI.Option(): expected pin update")
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		tpt := &testTransport{port: &testPort{}}
+		ft, err := OpenMask(&Mask{Transport: tpt})
+		if nil != err {
+			t.Fatalf("OpenMask(): %v", err)
+		}
+		defer ft.Close()
+		if err := ft.SPI.Option(&SPIOption{CS: D(3), Mode: 4}); nil == err {
+			t.Fatalf("SPI.Option(): expected error for mode 4")
+		}
+	})
+}
+
+func TestSPILSBFirst(t *testing.T) {
+
+	slave := &simRegSPI{}
+	ft, err := OpenSim("lsb", func(d *SimDevice) error { return d.AttachSPI(D(3), slave) })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	cfg := SPIConfigDefault()
+	cfg.LSBFirst = true
+	if err := ft.SPI.Config(cfg); nil != err {
+		t.Fatalf("SPI.Config(): %v", err)
+	}
+	if !ft.SPI.GetConfig().LSBFirst {
+		t.Fatalf("GetConfig().LSBFirst={false}, expected={true}")
+	}
+
+	// the MSB-first slave sees each byte bit-reversed: register address 0x08
+	// (write) becomes 0x10, and data 0x03 becomes 0xC0.
+	if _, err := ft.SPI.Write([]uint8{0x08, 0x03}, true, true); nil != err {
+		t.Fatalf("SPI.Write(): %v", err)
+	}
+	if exp := uint8(0xC0); exp != slave.reg[0x10] {
+		t.Fatalf("reg[0x10]={0x%02X}, expected={0x%02X}", slave.reg[0x10], exp)
+	}
+	rd, err := ft.SPI.Swap([]uint8{0x09, 0x00}, true, true)
+	if nil != err {
+		t.Fatalf("SPI.Swap(): %v", err)
+	}
+	if exp := uint8(0x03); exp != rd[1] {
+		t.Fatalf("SPI.Swap()={0x%02X}, expected={0x%02X}", rd[1], exp)
+	}
+
+	// change bit order on the open channel
+	if err := ft.SPI.ChangeBitOrder(false); nil != err {
+		t.Fatalf("SPI.ChangeBitOrder(): %v", err)
+	}
+	rd, err = ft.SPI.Swap([]uint8{0x90, 0x00}, true, true)
+	if nil != err {
+		t.Fatalf("SPI.Swap(): %v", err)
+	}
+	if exp := uint8(0xC0); exp != rd[1] {
+		t.Fatalf("SPI.Swap()={0x%02X}, expected={0x%02X}", rd[1], exp)
+	}
+
+	// the LSB-first write command is generated
+	tpt := &testTransport{port: &testPort{}}
+	ft2, err := OpenMask(&Mask{Transport: tpt})
+	if nil != err {
+		t.Fatalf("OpenMask(): %v", err)
+	}
+	defer ft2.Close()
+	if err := ft2.SPI.Config(cfg); nil != err {
+		t.Fatalf("SPI.Config(): %v", err)
+	}
+	tpt.port.cmd = nil
+	if _, err := ft2.SPI.Write([]uint8{0x01}, false, false); nil != err {
+		t.Fatalf("SPI.Write(): %v", err)
+	}
+	enc, err := mpsse.Encode(tpt.port.cmd...)
+	if nil != err {
+		t.Fatalf("Encode(): %v", err)
+	}
+	if exp := uint8(0x19); exp != enc[0] {
+		t.Fatalf("opcode={0x%02X}, expected={0x%02X}", enc[0], exp)
+	}
+}
+
+// simBitSPI is a simulated SPI slave that records the stream of bits clocked
+// in on MOSI, and clocks out on MISO the bit stream with bit i set IFF i is a
+// multiple of 3.
+type simBitSPI struct {
+	mosi []bool
+}
+
+func (s *simBitSPI) Select(active bool) {
+	if active {
+		s.mosi = nil
+	}
+}
+
+func (s *simBitSPI) Swap(mosi []uint8) []uint8 {
+	miso := make([]uint8, len(mosi))
+	for i, b := range mosi {
+		miso[i] = s.SwapBits([]uint8{b}, 8)[0]
+	}
+	return miso
+}
+
+func (s *simBitSPI) SwapBits(mosi []uint8, n uint) []uint8 {
+	var miso uint8
+	for k := uint(0); k < n; k++ {
+		if 0 == len(s.mosi)%3 {
+			miso |= 0x80 >> k
+		}
+		s.mosi = append(s.mosi, (mosi[0]&(0x80>>k)) > 0)
+	}
+	return []uint8{miso}
+}
+
+// stream returns the number of bits and the value of the first 64 bits recorded
+func (s *simBitSPI) stream() (int, uint64) {
+	var v uint64
+	for i, b := range s.mosi {
+		if i < 64 {
+			v <<= 1
+			if b {
+				v |= 1
+			}
+		}
+	}
+	return len(s.mosi), v
+}
+
+func TestSPIBits(t *testing.T) {
+
+	slave := &simBitSPI{}
+	ft, err := OpenSim("bits", func(d *SimDevice) error { return d.AttachSPI(D(3), slave) })
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.SPI.Init(); nil != err {
+		t.Fatalf("SPI.Init(): %v", err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		lsb   bool
+		data  []uint8
+		count uint
+		bits  uint64 // bits clocked on MOSI
+		recv  []uint8
+	}{
+		{"msb-9", false, []uint8{0xD2, 0x80}, 9, 0x1A5, []uint8{0x92, 0x00}},
+		{"msb-12", false, []uint8{0xAB, 0xCF}, 12, 0xABC, []uint8{0x92, 0x40}},
+		{"msb-3", false, []uint8{0xBF}, 3, 0x5, []uint8{0x80}},
+		{"lsb-9", true, []uint8{0xA5, 0x01}, 9, 0x14B, []uint8{0x49, 0x00}},
+		{"lsb-12", true, []uint8{0xBC, 0xFA}, 12, 0x3D5, []uint8{0x49, 0x02}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := ft.SPI.ChangeBitOrder(test.lsb); nil != err {
+				t.Fatalf("SPI.ChangeBitOrder(): %v", err)
+			}
+			n, err := ft.SPI.WriteBits(test.data, test.count, true, true)
+			if nil != err || test.count != n {
+				t.Fatalf("SPI.WriteBits(): %d, %v", n, err)
+			}
+			if n, bits := slave.stream(); int(test.count) != n || test.bits != bits {
+				t.Fatalf("MOSI={%d bits: 0x%X}, expected={%d bits: 0x%X}",
+					n, bits, test.count, test.bits)
+			}
+			recv, err := ft.SPI.SwapBits(test.data, test.count, true, true)
+			if nil != err {
+				t.Fatalf("SPI.SwapBits(): %v", err)
+			}
+			if !bytes.Equal(test.recv, recv) {
+				t.Fatalf("SPI.SwapBits()={% X}, expected={% X}", recv, test.recv)
+			}
+			recv, err = ft.SPI.ReadBits(test.count, true, true)
+			if nil != err {
+				t.Fatalf("SPI.ReadBits(): %v", err)
+			}
+			if !bytes.Equal(test.recv, recv) {
+				t.Fatalf("SPI.ReadBits()={% X}, expected={% X}", recv, test.recv)
+			}
+		})
+	}
+
+	t.Run("insufficient", func(t *testing.T) {
+		if _, err := ft.SPI.WriteBits([]uint8{0x00}, 9, true, true); nil == err {
+			t.Fatalf("SPI.WriteBits(): expected error")
+		}
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		if err := ft.SPI.ChangeBitOrder(false); nil != err {
+			t.Fatalf("SPI.ChangeBitOrder(): %v", err)
+		}
+		count := uint(2*maxTransferBytes*8 + 5)
+		recv, err := ft.SPI.ReadBits(count, true, true)
+		if nil != err {
+			t.Fatalf("SPI.ReadBits(): %v", err)
+		}
+		if n, _ := slave.stream(); int(count) != n {
+			t.Fatalf("MISO={%d bits}, expected={%d bits}", n, count)
+		}
+		// bit i is set IFF i is a multiple of 3
+		for _, i := range []uint{0, 1, 3, count - 6, count - 5, count - 3, count - 2, count - 1} {
+			bit := (recv[i/8] & (0x80 >> (i % 8))) > 0
+			if exp := 0 == i%3; exp != bit {
+				t.Fatalf("bit[%d]={%t}, expected={%t}", i, bit, exp)
+			}
+		}
+		if 0 != recv[len(recv)-1]&0x07 {
+			t.Fatalf("unused bits not cleared: 0x%02X", recv[len(recv)-1])
+		}
+	})
+
+	t.Run("commands", func(t *testing.T) {
+		tpt := &testTransport{port: &testPort{}}
+		ft, err := OpenMask(&Mask{Transport: tpt})
+		if nil != err {
+			t.Fatalf("OpenMask(): %v", err)
+		}
+		defer ft.Close()
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		tpt.port.cmd = nil
+		count := uint(2*maxTransferBytes*8 + 4)
+		n, err := ft.SPI.WriteBits(make([]uint8, (count+7)/8), count, true, true)
+		if nil != err || count != n {
+			t.Fatalf("SPI.WriteBits(): %d, %v", n, err)
+		}
+		// CS, 65536 bytes, 65536 bytes, 4 bits, CS
+		var seq []string
+		for _, c := range tpt.port.cmd {
+			switch c := c.(type) {
+			case mpsse.SetLow:
+				seq = append(seq, "cs")
+			case mpsse.ClockData:
+				if c.Bits {
+					seq = append(seq, fmt.Sprintf("%d-bits", c.Len))
+				} else {
+					seq = append(seq, fmt.Sprintf("%d-bytes", c.Len))
+				}
+			}
+		}
+		exp := fmt.Sprintf("[cs %d-bytes %d-bytes 4-bits cs]", maxTransferBytes, maxTransferBytes)
+		if got := fmt.Sprint(seq); exp != got {
+			t.Fatalf("commands={%s}, expected={%s}", got, exp)
+		}
+	})
+}
+
+func TestSPITx(t *testing.T) {
+
+	slave := &simRegSPI{}
+	gpio := &simLoopGPIO{}
+	var dev *SimDevice
+	ft, err := OpenSim("tx", func(d *SimDevice) error {
+		dev = d
+		d.AttachGPIO(gpio)
+		return d.AttachSPI(D(3), slave)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.SPI.Init(); nil != err {
+		t.Fatalf("SPI.Init(): %v", err)
+	}
+
+	rd, err := ft.SPI.Tx().
+		Pin(C(0), true).
+		Assert().Write([]uint8{0x10, 0xAA, 0xBB, 0xCC}).Deassert().
+		Pin(D(5), true).
+		Assert().Write([]uint8{0x91}).Read(2).Deassert().
+		Delay(time.Millisecond).
+		Assert().Swap([]uint8{0x90, 0x00}).Deassert().
+		Exec()
+	if nil != err {
+		t.Fatalf("Exec(): %v", err)
+	}
+	if 2 != len(rd) {
+		t.Fatalf("Exec()={%d segments}, expected={%d segments}", len(rd), 2)
+	}
+	if exp := []uint8{0xBB, 0xCC}; !bytes.Equal(exp, rd[0]) {
+		t.Fatalf("Read()={% X}, expected={% X}", rd[0], exp)
+	}
+	if exp := []uint8{0x00, 0xAA}; !bytes.Equal(exp, rd[1]) {
+		t.Fatalf("Swap()={% X}, expected={% X}", rd[1], exp)
+	}
+	if slave.active {
+		t.Fatalf("expected slave de-selected after transaction")
+	}
+	if gpio.val != C(0).Mask() || ft.GPIO.config.Val != C(0).Mask() {
+		t.Fatalf("GPIO val={%08b}, expected={%08b}", gpio.val, C(0).Mask())
+	}
+	if set, err := ft.DGPIO.Get(D(5)); nil != err || !set {
+		t.Fatalf("DGPIO.Get(): %t, %v", set, err)
+	}
+
+	if _, err := ft.SPI.Tx().Pin(D(1), true).Exec(); nil == err {
+		t.Fatalf("Exec(): expected error for reserved pin")
+	}
+	if _, err := ft.SPI.Tx().Pin(C(9), true).Exec(); nil == err {
+		t.Fatalf("Exec(): expected error for invalid pin")
+	}
+
+	t.Run("single-exchange", func(t *testing.T) {
+		tpt := &testTransport{port: &testPort{}}
+		ft, err := OpenMask(&Mask{Transport: tpt})
+		if nil != err {
+			t.Fatalf("OpenMask(): %v", err)
+		}
+		defer ft.Close()
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		tpt.port.nw = 0
+		rd, err := ft.SPI.Tx().
+			Pin(C(1), false).Assert().Write([]uint8{0x2A}).Deassert().
+			Pin(C(1), true).Assert().Write(make([]uint8, maxTransferBytes+1)).Read(4).Deassert().
+			Exec()
+		if nil != err {
+			t.Fatalf("Exec(): %v", err)
+		}
+		if 1 != tpt.port.nw {
+			t.Fatalf("USB writes={%d}, expected={%d}", tpt.port.nw, 1)
+		}
+		if 1 != len(rd) || 4 != len(rd[0]) {
+			t.Fatalf("Exec()={%v}, expected 1 segment of 4 bytes", rd)
+		}
+	})
+
+	t.Run("gpio-cs", func(t *testing.T) {
+		if err := ft.SPI.Change(C(7)); nil != err {
+			t.Fatalf("SPI.Change(): %v", err)
+		}
+		spiC := &simRegSPI{}
+		if err := dev.AttachSPI(C(7), spiC); nil != err {
+			t.Fatalf("AttachSPI(): %v", err)
+		}
+		// CS on C7 is de-asserted HIGH, so assert it to begin
+		if _, err := ft.SPI.Tx().Pin(C(7), true).Exec(); nil != err {
+			t.Fatalf("Exec(): %v", err)
+		}
+		if _, err := ft.SPI.Tx().Assert().Write([]uint8{0x05, 0x42}).Deassert().Exec(); nil != err {
+			t.Fatalf("Exec(): %v", err)
+		}
+		if 0x42 != spiC.reg[0x05] || spiC.active {
+			t.Fatalf("reg[0x05]={0x%02X}, active={%t}", spiC.reg[0x05], spiC.active)
+		}
+	})
+}
+
+func TestSPIReg(t *testing.T) {
+
+	slave := &simRegSPI{}
+	bits := &simBitSPI{}
+	ft, err := OpenSim("reg", func(d *SimDevice) error {
+		if err := d.AttachSPI(D(3), slave); nil != err {
+			return err
+		}
+		return d.AttachSPI(D(4), bits)
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if err := ft.SPI.Init(); nil != err {
+		t.Fatalf("SPI.Init(): %v", err)
+	}
+
+	reg := ft.SPI.Reg(0x10, SPIRegFormatRW7)
+	if err := reg.Write(2, 0x1234); nil != err {
+		t.Fatalf("Write(): %v", err)
+	}
+	if 0x12 != slave.reg[0x10] || 0x34 != slave.reg[0x11] {
+		t.Fatalf("Write()={% X}, expected={% X}", slave.reg[0x10:0x12], []uint8{0x12, 0x34})
+	}
+	if v, err := reg.Read(2); nil != err || 0x1234 != v {
+		t.Fatalf("Read()={%04X}, expected={%04X}: %v", v, 0x1234, err)
+	}
+
+	field := RegField{Name: "F", Shift: 4, Width: 4}
+	if err := reg.SetField(1, field, 0xA); nil != err {
+		t.Fatalf("SetField(): %v", err)
+	}
+	if v, err := reg.Field(1, field); nil != err || 0xA != v || 0xA2 != slave.reg[0x10] {
+		t.Fatalf("Field()={%X} (reg %02X), expected={%X} (reg %02X): %v",
+			v, slave.reg[0x10], 0xA, 0xA2, err)
+	}
+	if err := reg.SetField(1, field, 0x10); nil == err {
+		t.Fatalf("SetField(): expected error for value exceeding bitfield")
+	}
+
+	inc := ft.SPI.Reg(0x20, SPIRegFormatRW7Inc6)
+	if err := inc.Write(1, 0xAB); nil != err || 0xAB != slave.reg[0x20] {
+		t.Fatalf("Write()={%02X}, expected={%02X}: %v", slave.reg[0x20], 0xAB, err)
+	}
+	// the auto-increment bit is set for multi-byte transfers, which the
+	// simulated slave interprets as part of the address
+	if err := inc.Write(2, 0xCDEF); nil != err || 0xEF != slave.reg[0x60] || 0xCD != slave.reg[0x61] {
+		t.Fatalf("Write()={% X}, expected={% X}: %v", slave.reg[0x60:0x62], []uint8{0xEF, 0xCD}, err)
+	}
+
+	for _, tc := range []struct {
+		addr   uint
+		format SPIRegFormat
+	}{
+		{0x80, SPIRegFormatRW7},                             // overlaps R/W bit
+		{0x40, SPIRegFormatRW7Inc6},                         // overlaps auto-increment bit
+		{0x01, SPIRegFormat{Space: Addr8Bit, ReadBit: 8}},   // R/W bit outside command
+		{0x01, SPIRegFormat{Space: Addr8Bit, IncBit: 0}},    // bits overlap
+		{0x01, SPIRegFormat{Space: 3, IncBit: SPIRegNoBit}}, // invalid space
+		{0x100, SPIRegFormat{Space: Addr8Bit, ReadBit: 7, IncBit: SPIRegNoBit}},
+	} {
+		if _, err := ft.SPI.Reg(tc.addr, tc.format).Read(1); nil == err {
+			t.Fatalf("Read(): expected error for address 0x%02X, format %+v", tc.addr, tc.format)
+		}
+	}
+	if _, err := reg.Read(9); nil == err {
+		t.Fatalf("Read(): expected error for invalid size")
+	}
+
+	t.Run("dummy", func(t *testing.T) {
+		if err := ft.SPI.Change(D(4)); nil != err {
+			t.Fatalf("SPI.Change(): %v", err)
+		}
+		// 16-bit command: bit 15 = R/W (0 = read), bit 14 = auto-increment,
+		// followed by 4 dummy cycles
+		reg := ft.SPI.Reg(0x0123, SPIRegFormat{
+			Space: Addr16Bit, Order: MSB, ReadBit: 15, ReadClear: true, IncBit: 14, Dummy: 4,
+		})
+		rd, err := reg.ReadBytes(2)
+		if nil != err {
+			t.Fatalf("ReadBytes(): %v", err)
+		}
+		if n, v := bits.stream(); 36 != n || 0x412300000 != v {
+			t.Fatalf("stream={%d bits: %X}, expected={%d bits: %X}", n, v, 36, 0x412300000)
+		}
+		// data bits 20-35 of the stream are set IFF a multiple of 3
+		var exp uint64
+		for i := 20; i < 36; i++ {
+			exp <<= 1
+			if 0 == i%3 {
+				exp |= 1
+			}
+		}
+		if v := MSB.Uint(2, rd); exp != v {
+			t.Fatalf("ReadBytes()={%04X}, expected={%04X}", v, exp)
+		}
+		if err := reg.WriteBytes([]uint8{0x5A}); nil != err {
+			t.Fatalf("WriteBytes(): %v", err)
+		}
+		if n, v := bits.stream(); 24 != n || 0x81235A != v {
+			t.Fatalf("stream={%d bits: %X}, expected={%d bits: %X}", n, v, 24, 0x81235A)
+		}
+	})
+}
@@ -0,0 +1,349 @@
+package ft232h
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// SPITx is an SPI transaction builder. It queues a sequence of SPI writes,
+// reads, swaps, and dummy clock cycles, CS assertion, GPIO pin changes, and
+// delays, which are then executed by Exec as a single MPSSE command buffer in
+// one USB exchange, or, if the Port implements PortChannel, one segment at a
+// time.
+//
+// Construct an SPITx with SPI.Tx, and chain calls to its methods to queue each
+// segment of the transaction, e.g.:
+//
+//	rd, err := ft.SPI.Tx().Assert().Write([]uint8{0x9F}).Read(3).Deassert().Exec()
+//
+// The first error encountered while queueing segments is returned by Exec, and
+// no segments are executed.
+type SPITx struct {
+	spi *SPI
+	op  []spiTxOp
+	err error
+}
+
+// spiTxKind identifies the type of a queued SPI transaction segment.
+type spiTxKind int
+
+// Constants defining the types of SPI transaction segments.
+const (
+	spiTxAssert spiTxKind = iota
+	spiTxDeassert
+	spiTxWrite
+	spiTxRead
+	spiTxSwap
+	spiTxClock
+	spiTxPin
+	spiTxDelay
+)
+
+// spiTxOp is a queued SPI transaction segment.
+type spiTxOp struct {
+	kind  spiTxKind
+	data  []uint8
+	count uint
+	pin   Pin
+	val   bool
+	delay time.Duration
+}
+
+// Tx returns a new, empty SPI transaction builder using the receiver SPI
+// interface, which must be initialized before calling Exec.
+func (spi *SPI) Tx() *SPITx {
+	return &SPITx{spi: spi, op: []spiTxOp{}, err: nil}
+}
+
+// queue appends the given segment to the receiver transaction.
+func (tx *SPITx) queue(op spiTxOp) *SPITx {
+	tx.op = append(tx.op, op)
+	return tx
+}
+
+// Assert asserts the currently configured CS line (see SPIOption).
+func (tx *SPITx) Assert() *SPITx {
+	return tx.queue(spiTxOp{kind: spiTxAssert})
+}
+
+// Deassert de-asserts the currently configured CS line (see SPIOption).
+func (tx *SPITx) Deassert() *SPITx {
+	return tx.queue(spiTxOp{kind: spiTxDeassert})
+}
+
+// Write writes the given byte slice data. There is no maximum length for the
+// data slice. The data slice is not copied, and must not be modified until the
+// transaction has been executed.
+func (tx *SPITx) Write(data []uint8) *SPITx {
+	return tx.queue(spiTxOp{kind: spiTxWrite, data: data})
+}
+
+// Read reads the given count number of bytes, which are returned by Exec.
+func (tx *SPITx) Read(count uint) *SPITx {
+	return tx.queue(spiTxOp{kind: spiTxRead, count: count})
+}
+
+// Swap simultaneously reads and writes the given byte slice data. The bytes
+// read are returned by Exec. The data slice is not copied, and must not be
+// modified until the transaction has been executed.
+func (tx *SPITx) Swap(data []uint8) *SPITx {
+	return tx.queue(spiTxOp{kind: spiTxSwap, data: data})
+}
+
+// Clock clocks the given count number of dummy cycles, with MOSI held low and
+// MISO ignored, e.g. for the wait states some devices require between a command
+// and its response. The count need not be a multiple of 8.
+func (tx *SPITx) Clock(count uint) *SPITx {
+	return tx.queue(spiTxOp{kind: spiTxClock, count: count})
+}
+
+// Pin configures the given pin as output with the given val. The pin may be
+// either a CPin (GPIO) or a DPin not reserved by SPI (see DGPIO.Reserved).
+func (tx *SPITx) Pin(pin Pin, val bool) *SPITx {
+	if nil == tx.err && (nil == pin || !pin.Valid()) {
+		tx.err = fmt.Errorf("invalid pin: %v", pin)
+	}
+	return tx.queue(spiTxOp{kind: spiTxPin, pin: pin, val: val})
+}
+
+// Delay waits for the given duration before executing the next segment.
+// The MPSSE has no command for waiting a fixed duration, so a delay divides
+// the transaction into separate MPSSE command buffers: all segments queued
+// before the delay are flushed to the device, and the host then waits for the
+// given duration before continuing.
+func (tx *SPITx) Delay(d time.Duration) *SPITx {
+	return tx.queue(spiTxOp{kind: spiTxDelay, delay: d})
+}
+
+// Exec executes all segments of the transaction in order, returning the bytes
+// received by each Read and Swap segment, in the order they were queued, and a
+// non-nil error if unsuccessful.
+//
+// If the Port implements PortChannel, which cannot execute MPSSE commands, each
+// segment is instead performed with a separate call to the SPI and GPIO
+// interfaces (see SPI.Write, for example). The channel asserts and de-asserts a
+// CS pin on port "D" only as part of a transfer, so the Assert of such a pin is
+// performed by the next Write, Read, Swap, or Clock segment, and its Deassert
+// must immediately follow one of those segments.
+func (tx *SPITx) Exec() ([][]uint8, error) {
+
+	if nil != tx.err {
+		return nil, tx.err
+	}
+
+	spi := tx.spi
+	eng := spi.device.info.engine
+	if nil != eng.channel {
+		return tx.execChannel()
+	}
+	_, out, in := spi.config.options.clock()
+	lsb := spi.config.options.lsbFirst()
+
+	recv := [][]uint8{}
+	cmd := []mpsse.Command{}
+	pend := []int{} // indices of recv awaiting response
+
+	// flush executes all pending commands, and distributes the response to the
+	// pending Read and Swap segments.
+	flush := func() error {
+		if 0 == len(cmd) {
+			return nil
+		}
+		resp, err := eng.exec(cmd...)
+		if nil != err {
+			return err
+		}
+		for _, i := range pend {
+			n := copy(recv[i], resp)
+			resp = resp[n:]
+		}
+		cmd, pend = cmd[:0], pend[:0]
+		return nil
+	}
+
+	// shift appends the commands clocking count bytes, in chunks no larger than
+	// the MPSSE transfer limit.
+	shift := func(read bool, send []uint8, count uint) {
+		for beg := uint(0); beg < count; beg += maxTransferBytes {
+			end := beg + maxTransferBytes
+			if end > count {
+				end = count
+			}
+			var data []uint8
+			if nil != send {
+				data = send[beg:end]
+			}
+			cmd = append(cmd, mpsse.ClockData{
+				Out:     nil != send,
+				In:      read,
+				LSB:     lsb,
+				OutEdge: out,
+				InEdge:  in,
+				Len:     int(end - beg),
+				Data:    data,
+			})
+		}
+	}
+
+	for _, op := range tx.op {
+		switch op.kind {
+		case spiTxAssert, spiTxDeassert:
+			assert := spiTxAssert == op.kind
+			if cs := spi.config.chipSelect; cs.IsMPSSE() {
+				cmd = append(cmd, spi.cs(assert))
+			} else {
+				lvl := assert != spi.config.options.activeLow()
+				cmd = append(cmd, spi.device.GPIO.output(cs.(CPin), lvl))
+			}
+
+		case spiTxWrite:
+			shift(false, op.data, uint(len(op.data)))
+
+		case spiTxRead, spiTxSwap:
+			count := op.count
+			if spiTxSwap == op.kind {
+				count = uint(len(op.data))
+			}
+			pend = append(pend, len(recv))
+			recv = append(recv, make([]uint8, count))
+			shift(true, op.data, count)
+
+		case spiTxClock:
+			shift(false, make([]uint8, op.count/8), op.count/8)
+			if rem := op.count % 8; rem > 0 {
+				cmd = append(cmd, mpsse.ClockData{
+					Out:     true,
+					Bits:    true,
+					LSB:     lsb,
+					OutEdge: out,
+					InEdge:  in,
+					Len:     int(rem),
+					Data:    []uint8{0},
+				})
+			}
+
+		case spiTxPin:
+			if op.pin.IsMPSSE() {
+				c, err := spi.device.DGPIO.output(op.pin.(DPin), op.val)
+				if nil != err {
+					return recv, err
+				}
+				cmd = append(cmd, c)
+			} else {
+				cmd = append(cmd, spi.device.GPIO.output(op.pin.(CPin), op.val))
+			}
+
+		case spiTxDelay:
+			if err := flush(); nil != err {
+				return recv, err
+			}
+			time.Sleep(op.delay)
+		}
+	}
+
+	if err := flush(); nil != err {
+		return recv, err
+	}
+	return recv, nil
+}
+
+// execChannel executes all segments of the transaction in order, one at a time,
+// with the SPI and GPIO interfaces of a Port implementing PortChannel. See Exec
+// for the semantics of CS assertion.
+func (tx *SPITx) execChannel() ([][]uint8, error) {
+
+	spi := tx.spi
+	cs := spi.config.chipSelect
+	recv := [][]uint8{}
+
+	// size returns the number of bytes transferred by segment i.
+	size := func(i int) uint {
+		switch op := tx.op[i]; op.kind {
+		case spiTxWrite, spiTxSwap:
+			return uint(len(op.data))
+		case spiTxRead:
+			return op.count
+		case spiTxClock:
+			return (op.count + 7) / 8
+		}
+		return 0
+	}
+
+	// deassert returns true if the next segment following segment i, other than
+	// an empty transfer, de-asserts CS.
+	deassert := func(i int) bool {
+		for j := i + 1; j < len(tx.op); j++ {
+			switch tx.op[j].kind {
+			case spiTxDeassert:
+				return true
+			case spiTxWrite, spiTxRead, spiTxSwap, spiTxClock:
+				if 0 == size(j) {
+					continue
+				}
+			}
+			return false
+		}
+		return false
+	}
+
+	// CS on port "D" awaiting assertion by the next transfer, and de-asserted by
+	// the most recent transfer.
+	pend, done := false, false
+
+	for i, op := range tx.op {
+		var err error
+		switch op.kind {
+		case spiTxAssert, spiTxDeassert:
+			assert := spiTxAssert == op.kind
+			switch {
+			case !cs.IsMPSSE():
+				err = spi.device.GPIO.Set(cs.(CPin), assert != spi.config.options.activeLow())
+			case assert:
+				pend = true
+			case !done:
+				err = fmt.Errorf("CS de-asserted without transfer: %w", ErrNoMPSSE)
+			}
+			done = false
+
+		case spiTxWrite, spiTxRead, spiTxSwap, spiTxClock:
+			rd := []uint8{}
+			if n := size(i); n > 0 {
+				start, stop := pend, cs.IsMPSSE() && deassert(i)
+				pend, done = false, stop
+				switch op.kind {
+				case spiTxWrite:
+					_, err = spi.Write(op.data, start, stop)
+				case spiTxRead:
+					rd, err = spi.Read(op.count, start, stop)
+				case spiTxSwap:
+					rd, err = spi.Swap(op.data, start, stop)
+				case spiTxClock:
+					_, err = spi.WriteBits(make([]uint8, n), op.count, start, stop)
+				}
+			}
+			if spiTxRead == op.kind || spiTxSwap == op.kind {
+				recv = append(recv, rd)
+			}
+
+		case spiTxPin:
+			if op.pin.IsMPSSE() {
+				err = spi.device.DGPIO.Set(op.pin.(DPin), op.val)
+			} else {
+				err = spi.device.GPIO.Set(op.pin.(CPin), op.val)
+			}
+
+		case spiTxDelay:
+			time.Sleep(op.delay)
+		}
+		if nil != err {
+			return recv, err
+		}
+	}
+
+	if pend {
+		return recv, fmt.Errorf("CS asserted without transfer: %w", ErrNoMPSSE)
+	}
+	return recv, nil
+}
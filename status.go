@@ -0,0 +1,152 @@
+package ft232h
+
+// Types representing the status and chip identifiers reported by the D2XX
+// driver. These are defined natively in Go, with values equal to those of the
+// corresponding D2XX enumerations, so that they are available to every
+// Transport implementation – including those built without cgo.
+type (
+	Status uint32
+	Chip   uint32
+)
+
+// Constants related to device status
+const (
+	SOK Status = iota
+	SInvalidHandle
+	SDeviceNotFound
+	SDeviceNotOpened
+	SIOError
+	SInsufficientResources
+	SInvalidParameter
+	SInvalidBaudRate
+	SDeviceNotOpenedForErase
+	SDeviceNotOpenedForWrite
+	SFailedToWriteDevice
+	SEEPROMReadFailed
+	SEEPROMWriteFailed
+	SEEPROMEraseFailed
+	SEEPROMNotPresent
+	SEEPROMNotProgrammed
+	SInvalidArgs
+	SNotSupported
+	SOtherError
+	SDeviceListNotReady
+)
+
+// OK returns true if the status equals SOK, otherwise false.
+func (s Status) OK() bool {
+	return SOK == s
+}
+
+// Error implements the error interface. Returns the string "unknown error" if
+// the status is invalid.
+func (s Status) Error() string {
+	switch s {
+	case SOK:
+		return "OK"
+	case SInvalidHandle:
+		return "invalid handle"
+	case SDeviceNotFound:
+		return "device not found"
+	case SDeviceNotOpened:
+		return "device not opened"
+	case SIOError:
+		return "IO error"
+	case SInsufficientResources:
+		return "insufficient resources"
+	case SInvalidParameter:
+		return "invalid parameter"
+	case SInvalidBaudRate:
+		return "invalid baud rate"
+	case SDeviceNotOpenedForErase:
+		return "device not opened for erase"
+	case SDeviceNotOpenedForWrite:
+		return "device not opened for write"
+	case SFailedToWriteDevice:
+		return "failed to write device"
+	case SEEPROMReadFailed:
+		return "EEPROM read failed"
+	case SEEPROMWriteFailed:
+		return "EEPROM write failed"
+	case SEEPROMEraseFailed:
+		return "EEPROM erase failed"
+	case SEEPROMNotPresent:
+		return "EEPROM not present"
+	case SEEPROMNotProgrammed:
+		return "EEPROM not programmed"
+	case SInvalidArgs:
+		return "invalid args"
+	case SNotSupported:
+		return "not supported"
+	case SOtherError:
+		return "other error"
+	case SDeviceListNotReady:
+		return "device list not ready"
+	default:
+		return "(unknown error)"
+	}
+}
+
+// Constants defining the FTDI chip identifiers specified by FTDI.
+const (
+	CFTBM Chip = iota
+	CFTAM
+	CFT100AX
+	CFTUnknown
+	CFT2232C
+	CFT232R
+	CFT2232H
+	CFT4232H
+	CFT232H
+	CFTX
+	CFT4222H0
+	CFT4222H12
+	CFT4222H3
+	CFT4222P
+	CFT900
+	CFT930
+	CUMFTPD3A
+)
+
+// String returns the descriptive string representation of an FTDI chip.
+// Returns the string "invalid chip" if the chip is not defined.
+func (c Chip) String() string {
+	switch c {
+	case CFTBM:
+		return "FTBM"
+	case CFTAM:
+		return "FTAM"
+	case CFT100AX:
+		return "FT100AX"
+	case CFTUnknown:
+		return "FTUnknown"
+	case CFT2232C:
+		return "FT2232C"
+	case CFT232R:
+		return "FT232R"
+	case CFT2232H:
+		return "FT2232H"
+	case CFT4232H:
+		return "FT4232H"
+	case CFT232H:
+		return "FT232H"
+	case CFTX:
+		return "FTX"
+	case CFT4222H0:
+		return "FT4222H0"
+	case CFT4222H12:
+		return "FT4222H12"
+	case CFT4222H3:
+		return "FT4222H3"
+	case CFT4222P:
+		return "FT4222P"
+	case CFT900:
+		return "FT900"
+	case CFT930:
+		return "FT930"
+	case CUMFTPD3A:
+		return "UMFTPD3A"
+	default:
+		return "(invalid chip)"
+	}
+}
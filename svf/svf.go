@@ -0,0 +1,717 @@
+/*
+Package svf implements players for Serial Vector Format (SVF) and Xilinx
+Serial Vector Format (XSVF) files, used to program and test CPLDs, FPGAs, and
+other devices through the JTAG interface of an FT232H (see ft232h.JTAG).
+
+A file is parsed completely before any of it is executed, so a malformed file
+is rejected without modifying the state of the devices on the scan chain.
+Each scan whose TDO is specified is verified while the file executes, and the
+first mismatch stops the player with a *MismatchError identifying the
+statement (SVF line number) or instruction (XSVF byte offset), along with the
+expected and actual TDO bits.
+
+SVF is described in the Serial Vector Format Specification, revision E,
+published by ASSET InterTech. XSVF is described in Xilinx application note
+XAPP503, "SVF and XSVF File Formats for Xilinx Devices".
+*/
+package svf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ardnew/ft232h"
+)
+
+// Player executes SVF and XSVF files on the JTAG interface of an FT232H.
+//
+// The state of an SVF file that persists between statements (end states, the
+// RUNTEST run and end states, and the header and trailer patterns) is reset
+// at the start of each call to Play, but the state of the TAP controller is
+// not; an SVF file usually begins by moving the TAP controller to a known
+// state with a STATE statement.
+type Player struct {
+	jtag *ft232h.JTAG
+}
+
+// New returns a new Player on the given JTAG interface, which must already be
+// initialized (see ft232h.JTAG.Init).
+func New(jtag *ft232h.JTAG) (*Player, error) {
+	if nil == jtag {
+		return nil, fmt.Errorf("nil JTAG interface")
+	}
+	return &Player{jtag: jtag}, nil
+}
+
+// MismatchError is the error returned when the bits shifted out on TDO by a
+// scan do not match the expected bits.
+type MismatchError struct {
+	Line     int     // SVF line number of the statement, or 0 for XSVF
+	Offset   int64   // XSVF byte offset of the instruction, or 0 for SVF
+	Bits     uint    // length of the scan
+	Expected []uint8 // expected TDO bits, LSB first
+	Actual   []uint8 // actual TDO bits, LSB first
+	Mask     []uint8 // bits compared, LSB first
+}
+
+// Error returns a descriptive string of the mismatch, with each bit vector
+// formatted as an SVF hexadecimal value (most-significant digit first).
+func (e *MismatchError) Error() string {
+	at := fmt.Sprintf("line %d", e.Line)
+	if 0 == e.Line {
+		at = fmt.Sprintf("offset %d", e.Offset)
+	}
+	return fmt.Sprintf("%s: TDO mismatch: expected (%s), actual (%s), mask (%s)", at,
+		svfFormat(e.Bits, e.Expected), svfFormat(e.Bits, e.Actual), svfFormat(e.Bits, e.Mask))
+}
+
+// svfBits is a bit vector of the given length, LSB first.
+type svfBits struct {
+	len  uint
+	data []uint8
+}
+
+// svfScan holds the parameters of an SVF scan statement (HDR, HIR, SDR, SIR,
+// TDR, or TIR). The TDO and mask vectors are nil if TDO is not compared.
+type svfScan struct {
+	len  uint
+	tdi  []uint8
+	tdo  []uint8
+	mask []uint8
+}
+
+// update applies the parameters given by a scan statement to the receiver,
+// following the SVF rules: TDI and MASK persist while the length is
+// unchanged, TDI must be given when the length changes, MASK defaults to all
+// 1s when the length changes, and TDO applies only to the given statement
+// (excluding the header and trailer statements, for which it persists).
+func (s *svfScan) update(bits uint, tdi, tdo, mask []uint8, sticky bool) error {
+	if bits != s.len || nil == s.tdi {
+		if nil == tdi && bits > 0 {
+			return fmt.Errorf("TDI required for scan length %d", bits)
+		}
+		s.len, s.tdi = bits, make([]uint8, (bits+7)/8)
+		s.mask = svfOnes(bits)
+		s.tdo = nil
+	}
+	if nil != tdi {
+		s.tdi = tdi
+	}
+	if nil != mask {
+		s.mask = mask
+	}
+	if nil != tdo || !sticky {
+		s.tdo = tdo
+	}
+	return nil
+}
+
+// svfStmt is a parsed SVF statement ready for execution.
+type svfStmt struct {
+	line  int
+	cmd   string
+	state []ft232h.JTAGState // STATE path, or the state of ENDDR/ENDIR
+	ir    bool               // SIR (or SDR)
+	scan  svfScan            // SIR/SDR, including header and trailer
+	run   ft232h.JTAGState   // RUNTEST run state
+	end   ft232h.JTAGState   // RUNTEST end state
+	count uint64             // RUNTEST clock cycles
+	wait  time.Duration      // RUNTEST minimum time
+	hz    float64            // FREQUENCY (0 for maximum)
+}
+
+// svfStates maps the SVF state names to TAP controller states.
+var svfStates = map[string]ft232h.JTAGState{
+	"RESET":     ft232h.JTAGTestLogicReset,
+	"IDLE":      ft232h.JTAGRunTestIdle,
+	"DRSELECT":  ft232h.JTAGSelectDRScan,
+	"DRCAPTURE": ft232h.JTAGCaptureDR,
+	"DRSHIFT":   ft232h.JTAGShiftDR,
+	"DREXIT1":   ft232h.JTAGExit1DR,
+	"DRPAUSE":   ft232h.JTAGPauseDR,
+	"DREXIT2":   ft232h.JTAGExit2DR,
+	"DRUPDATE":  ft232h.JTAGUpdateDR,
+	"IRSELECT":  ft232h.JTAGSelectIRScan,
+	"IRCAPTURE": ft232h.JTAGCaptureIR,
+	"IRSHIFT":   ft232h.JTAGShiftIR,
+	"IREXIT1":   ft232h.JTAGExit1IR,
+	"IRPAUSE":   ft232h.JTAGPauseIR,
+	"IREXIT2":   ft232h.JTAGExit2IR,
+	"IRUPDATE":  ft232h.JTAGUpdateIR,
+}
+
+// Play parses and then executes the SVF file read from r.
+//
+// All SVF statements are supported except PIO and PIOMAP, and TRST ON (the
+// FT232H has no TRST signal). RUNTEST clock counts given in SCK cycles are
+// clocked on TCK, and its maximum time is ignored.
+func (p *Player) Play(r io.Reader) error {
+
+	stmt, err := svfParse(r)
+	if nil != err {
+		return err
+	}
+
+	endIR, endDR := ft232h.JTAGRunTestIdle, ft232h.JTAGRunTestIdle
+	for _, s := range stmt {
+		var err error
+		switch s.cmd {
+		case "ENDIR":
+			endIR = s.state[0]
+		case "ENDDR":
+			endDR = s.state[0]
+		case "FREQUENCY":
+			err = p.frequency(s.hz)
+		case "STATE":
+			for _, t := range s.state {
+				if err = p.goTo(t); nil != err {
+					break
+				}
+			}
+		case "RUNTEST":
+			err = p.runTest(s)
+		case "SIR":
+			err = p.scan(s, endIR)
+		case "SDR":
+			err = p.scan(s, endDR)
+		}
+		if nil != err {
+			if _, ok := err.(*MismatchError); ok {
+				return err
+			}
+			return fmt.Errorf("line %d: %s: %v", s.line, s.cmd, err)
+		}
+	}
+	return nil
+}
+
+// goTo moves the TAP controller to the given state, resetting it with TMS if
+// the given state is Test-Logic-Reset.
+func (p *Player) goTo(state ft232h.JTAGState) error {
+	if ft232h.JTAGTestLogicReset == state {
+		return p.jtag.Reset()
+	}
+	return p.jtag.GoTo(state)
+}
+
+// frequency sets the TCK rate to the given frequency, or to the maximum rate if
+// hz is 0 or exceeds the maximum.
+func (p *Player) frequency(hz float64) error {
+	if 0 == hz || hz > float64(ft232h.JTAGClockMaximum) {
+		return p.jtag.SetClock(ft232h.JTAGClockMaximum)
+	}
+	return p.jtag.SetClock(uint32(hz))
+}
+
+// cycles returns the number of TCK cycles clocked in the given duration.
+func (p *Player) cycles(d time.Duration) uint64 {
+	return uint64(math.Ceil(d.Seconds() * float64(p.jtag.GetConfig().Clock)))
+}
+
+// runTest executes an SVF RUNTEST statement.
+func (p *Player) runTest(s svfStmt) error {
+
+	if err := p.goTo(s.run); nil != err {
+		return err
+	}
+
+	count := s.count
+	if ft232h.JTAGRunTestIdle == s.run {
+		if c := p.cycles(s.wait); c > count {
+			count = c
+		}
+		for count > 0 {
+			n := count
+			if n > math.MaxUint32 {
+				n = math.MaxUint32
+			}
+			if err := p.jtag.Idle(uint(n)); nil != err {
+				return err
+			}
+			count -= n
+		}
+	} else {
+		// TMS holds the TAP controller in the other stable states
+		tms := uint64(0)
+		if ft232h.JTAGTestLogicReset == s.run {
+			tms = math.MaxUint64
+		}
+		start := time.Now()
+		for count > 0 {
+			n := count
+			if n > 64 {
+				n = 64
+			}
+			if err := p.jtag.TMS(tms, uint(n)); nil != err {
+				return err
+			}
+			count -= n
+		}
+		time.Sleep(s.wait - time.Since(start))
+	}
+
+	return p.goTo(s.end)
+}
+
+// scan executes an SVF SIR or SDR statement, and then moves the TAP controller
+// to the given end state.
+func (p *Player) scan(s svfStmt, end ft232h.JTAGState) error {
+	if 0 == s.scan.len {
+		return nil
+	}
+	if nil == s.scan.tdo {
+		if s.ir {
+			return p.jtag.WriteIR(s.scan.len, s.scan.tdi, end)
+		}
+		return p.jtag.WriteDR(s.scan.len, s.scan.tdi, end)
+	}
+	shift := p.jtag.ShiftDR
+	if s.ir {
+		shift = p.jtag.ShiftIR
+	}
+	tdo, err := shift(s.scan.len, s.scan.tdi, end)
+	if nil != err {
+		return err
+	}
+	if !svfMatch(tdo, s.scan.tdo, s.scan.mask) {
+		return &MismatchError{Line: s.line, Bits: s.scan.len,
+			Expected: s.scan.tdo, Actual: tdo, Mask: s.scan.mask}
+	}
+	return nil
+}
+
+// svfParse reads and parses all of the statements of an SVF file, returning
+// the statements to be executed.
+func svfParse(r io.Reader) ([]svfStmt, error) {
+
+	var hir, tir, hdr, tdr, sir, sdr svfScan
+
+	runState, runEnd := ft232h.JTAGRunTestIdle, ft232h.JTAGRunTestIdle
+
+	stmt := []svfStmt{}
+	lex := &svfLexer{r: bufio.NewReader(r), line: 1}
+	for {
+		line, field, err := lex.next()
+		if nil != err {
+			return nil, err
+		}
+		if nil == field {
+			return stmt, nil
+		}
+
+		s := svfStmt{line: line, cmd: field[0]}
+		arg := field[1:]
+		err = nil
+		switch s.cmd {
+		case "ENDIR", "ENDDR":
+			if 1 != len(arg) {
+				err = fmt.Errorf("expected 1 state")
+				break
+			}
+			var t ft232h.JTAGState
+			if t, err = svfStable(arg[0]); nil == err {
+				s.state = []ft232h.JTAGState{t}
+				stmt = append(stmt, s)
+			}
+
+		case "FREQUENCY":
+			switch {
+			case 0 == len(arg):
+			case 2 == len(arg) && "HZ" == arg[1]:
+				if s.hz, err = svfFloat(arg[0]); nil == err && 0 == s.hz {
+					err = fmt.Errorf("invalid frequency: %s", arg[0])
+				}
+			default:
+				err = fmt.Errorf("expected frequency in HZ")
+			}
+			if nil == err {
+				stmt = append(stmt, s)
+			}
+
+		case "STATE":
+			if 0 == len(arg) {
+				err = fmt.Errorf("expected state")
+				break
+			}
+			for _, a := range arg {
+				t, ok := svfStates[a]
+				if !ok {
+					err = fmt.Errorf("invalid state: %s", a)
+					break
+				}
+				s.state = append(s.state, t)
+			}
+			if nil == err {
+				_, err = svfStable(arg[len(arg)-1])
+			}
+			if nil == err {
+				stmt = append(stmt, s)
+			}
+
+		case "RUNTEST":
+			if err = svfRunTest(&s, arg, &runState, &runEnd); nil == err {
+				stmt = append(stmt, s)
+			}
+
+		case "HIR":
+			err = svfScanArgs(&hir, arg, true)
+		case "TIR":
+			err = svfScanArgs(&tir, arg, true)
+		case "HDR":
+			err = svfScanArgs(&hdr, arg, true)
+		case "TDR":
+			err = svfScanArgs(&tdr, arg, true)
+
+		case "SIR":
+			if err = svfScanArgs(&sir, arg, false); nil == err {
+				s.ir, s.scan = true, svfJoin(hir, sir, tir)
+				stmt = append(stmt, s)
+			}
+		case "SDR":
+			if err = svfScanArgs(&sdr, arg, false); nil == err {
+				s.scan = svfJoin(hdr, sdr, tdr)
+				stmt = append(stmt, s)
+			}
+
+		case "TRST":
+			switch {
+			case 1 != len(arg):
+				err = fmt.Errorf("expected TRST mode")
+			case "ON" == arg[0]:
+				err = fmt.Errorf("TRST signal not supported")
+			case "OFF" != arg[0] && "Z" != arg[0] && "ABSENT" != arg[0]:
+				err = fmt.Errorf("invalid TRST mode: %s", arg[0])
+			}
+
+		case "PIO", "PIOMAP":
+			err = fmt.Errorf("statement not supported")
+
+		default:
+			err = fmt.Errorf("unknown statement")
+		}
+		if nil != err {
+			return nil, fmt.Errorf("line %d: %s: %v", line, s.cmd, err)
+		}
+	}
+}
+
+// svfRunTest parses the arguments of an SVF RUNTEST statement into s, updating
+// the persistent run and end states.
+func svfRunTest(s *svfStmt, arg []string, runState, runEnd *ft232h.JTAGState) error {
+
+	// RUNTEST [run_state] [run_count run_clk] [min_time SEC [MAXIMUM max_time SEC]]
+	//         [ENDSTATE end_state]
+	if len(arg) > 0 {
+		if _, ok := svfStates[arg[0]]; ok {
+			t, err := svfStable(arg[0])
+			if nil != err {
+				return err
+			}
+			*runState, *runEnd = t, t
+			arg = arg[1:]
+		}
+	}
+	timed := false
+	if len(arg) >= 2 && ("TCK" == arg[1] || "SCK" == arg[1]) {
+		n, err := svfFloat(arg[0])
+		if nil != err {
+			return err
+		}
+		if n != math.Trunc(n) || n > math.MaxUint64 {
+			return fmt.Errorf("invalid clock count: %s", arg[0])
+		}
+		s.count = uint64(n)
+		timed, arg = true, arg[2:]
+	}
+	if len(arg) >= 2 && "SEC" == arg[1] {
+		t, err := svfFloat(arg[0])
+		if nil != err {
+			return err
+		}
+		s.wait = time.Duration(math.Ceil(t * float64(time.Second)))
+		timed, arg = true, arg[2:]
+		if len(arg) >= 3 && "MAXIMUM" == arg[0] && "SEC" == arg[2] {
+			if _, err := svfFloat(arg[1]); nil != err {
+				return err
+			}
+			arg = arg[3:]
+		}
+	}
+	if !timed {
+		return fmt.Errorf("expected clock count or minimum time")
+	}
+	if len(arg) >= 2 && "ENDSTATE" == arg[0] {
+		t, err := svfStable(arg[1])
+		if nil != err {
+			return err
+		}
+		*runEnd, arg = t, arg[2:]
+	}
+	if len(arg) > 0 {
+		return fmt.Errorf("unexpected argument: %s", arg[0])
+	}
+	s.run, s.end = *runState, *runEnd
+	return nil
+}
+
+// svfScanArgs parses the arguments of an SVF scan statement (length, and the
+// optional TDI, TDO, MASK, and SMASK values) and applies them to scan.
+func svfScanArgs(scan *svfScan, arg []string, sticky bool) error {
+
+	if 0 == len(arg) {
+		return fmt.Errorf("expected scan length")
+	}
+	n, err := strconv.ParseUint(arg[0], 10, 32)
+	if nil != err {
+		return fmt.Errorf("invalid scan length: %s", arg[0])
+	}
+	bits := uint(n)
+
+	var tdi, tdo, mask []uint8
+	for arg = arg[1:]; len(arg) > 0; arg = arg[2:] {
+		if len(arg) < 2 {
+			return fmt.Errorf("expected value of %s", arg[0])
+		}
+		v, err := svfHex(arg[1], bits)
+		if nil != err {
+			return fmt.Errorf("%s: %v", arg[0], err)
+		}
+		switch arg[0] {
+		case "TDI":
+			tdi = v
+		case "TDO":
+			tdo = v
+		case "MASK":
+			mask = v
+		case "SMASK":
+			// TDI is always driven
+		default:
+			return fmt.Errorf("unexpected argument: %s", arg[0])
+		}
+	}
+	return scan.update(bits, tdi, tdo, mask, sticky)
+}
+
+// svfJoin returns the scan formed by shifting the given header, data, and
+// trailer scans in that order. TDO is compared if given by any of the scans.
+func svfJoin(head, data, tail svfScan) svfScan {
+	part := []svfScan{head, data, tail}
+	tdi := []svfBits{}
+	tdo := []svfBits{}
+	mask := []svfBits{}
+	cmp := false
+	for _, s := range part {
+		tdi = append(tdi, svfBits{s.len, s.tdi})
+		if nil == s.tdo {
+			tdo = append(tdo, svfBits{s.len, nil})
+			mask = append(mask, svfBits{s.len, nil})
+		} else {
+			tdo = append(tdo, svfBits{s.len, s.tdo})
+			mask = append(mask, svfBits{s.len, s.mask})
+			cmp = true
+		}
+	}
+	scan := svfScan{len: head.len + data.len + tail.len, tdi: svfCat(tdi...)}
+	if cmp {
+		scan.tdo, scan.mask = svfCat(tdo...), svfCat(mask...)
+	}
+	return scan
+}
+
+// svfStable returns the TAP controller state with the given SVF name, which
+// must be one of the stable states of SVF: RESET, IDLE, DRPAUSE, or IRPAUSE.
+func svfStable(name string) (ft232h.JTAGState, error) {
+	t, ok := svfStates[name]
+	switch {
+	case !ok, ft232h.JTAGShiftDR == t, ft232h.JTAGShiftIR == t, !t.Stable():
+		return t, fmt.Errorf("invalid stable state: %s", name)
+	}
+	return t, nil
+}
+
+// svfFloat parses an SVF real number.
+func svfFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if nil != err || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, fmt.Errorf("invalid number: %s", s)
+	}
+	return f, nil
+}
+
+// svfHex parses a parenthesized SVF hexadecimal value of the given number of
+// bits, returning its bits LSB first.
+func svfHex(s string, bits uint) ([]uint8, error) {
+	if len(s) < 2 || '(' != s[0] || ')' != s[len(s)-1] {
+		return nil, fmt.Errorf("expected (hex) value: %s", s)
+	}
+	s = s[1 : len(s)-1]
+	v := make([]uint8, (bits+7)/8)
+	for i := 0; i < len(s); i++ {
+		d, err := strconv.ParseUint(s[len(s)-1-i:len(s)-i], 16, 8)
+		if nil != err {
+			return nil, fmt.Errorf("invalid hex digit: %q", s[len(s)-1-i])
+		}
+		for k := uint(0); k < 4; k++ {
+			if 0 != d&(1<<k) && uint(i)*4+k >= bits {
+				return nil, fmt.Errorf("value exceeds %d bits", bits)
+			}
+		}
+		if 0 == d {
+			continue
+		}
+		v[i/2] |= uint8(d) << (4 * uint(i%2))
+	}
+	return v, nil
+}
+
+// svfFormat returns the given bits as an SVF hexadecimal value.
+func svfFormat(bits uint, data []uint8) string {
+	var b strings.Builder
+	for i := int((bits+3)/4) - 1; i >= 0; i-- {
+		d := uint8(0)
+		if i/2 < len(data) {
+			d = data[i/2] >> (4 * uint(i%2)) & 0x0F
+		}
+		b.WriteString(strconv.FormatUint(uint64(d), 16))
+	}
+	return strings.ToUpper(b.String())
+}
+
+// svfOnes returns a vector of the given number of bits, all set to 1.
+func svfOnes(bits uint) []uint8 {
+	v := make([]uint8, (bits+7)/8)
+	for i := uint(0); i < bits; i++ {
+		v[i/8] |= 1 << (i % 8)
+	}
+	return v
+}
+
+// svfCat returns the concatenation of the given bit vectors, the first given
+// occupying the least-significant bits. Nil vectors contribute 0s.
+func svfCat(part ...svfBits) []uint8 {
+	n := uint(0)
+	for _, p := range part {
+		n += p.len
+	}
+	v := make([]uint8, (n+7)/8)
+	k := uint(0)
+	for _, p := range part {
+		for i := uint(0); i < p.len; i++ {
+			if nil != p.data && 0 != p.data[i/8]&(1<<(i%8)) {
+				v[k/8] |= 1 << (k % 8)
+			}
+			k++
+		}
+	}
+	return v
+}
+
+// svfMatch returns true if and only if the bits of actual and expected selected
+// by mask are all equal.
+func svfMatch(actual, expected, mask []uint8) bool {
+	for i := range expected {
+		if 0 != (actual[i]^expected[i])&mask[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// svfLexer splits an SVF file into statements of upper-case fields, removing
+// comments. Each parenthesized value is a single field with its whitespace
+// removed.
+type svfLexer struct {
+	r    *bufio.Reader
+	line int
+}
+
+// next returns the line number and fields of the next statement, or nil fields
+// at the end of the file.
+func (l *svfLexer) next() (int, []string, error) {
+
+	var (
+		field []string
+		tok   strings.Builder
+		paren bool
+		start int
+	)
+	flush := func() {
+		if tok.Len() > 0 {
+			if 0 == len(field) {
+				start = l.line
+			}
+			field = append(field, strings.ToUpper(tok.String()))
+			tok.Reset()
+		}
+	}
+
+	for {
+		c, err := l.r.ReadByte()
+		if io.EOF == err {
+			flush()
+			if paren {
+				return 0, nil, fmt.Errorf("line %d: unterminated value", l.line)
+			}
+			if len(field) > 0 {
+				return 0, nil, fmt.Errorf("line %d: missing ';'", start)
+			}
+			return 0, nil, nil
+		}
+		if nil != err {
+			return 0, nil, err
+		}
+
+		// comments
+		if '!' == c || ('/' == c && l.peek('/')) {
+			if _, err := l.r.ReadString('\n'); nil != err && io.EOF != err {
+				return 0, nil, err
+			}
+			c = '\n'
+		}
+		if '\n' == c {
+			l.line++
+		}
+
+		switch {
+		case paren:
+			switch {
+			case ')' == c:
+				tok.WriteByte(c)
+				paren = false
+				flush()
+			case ' ' == c || '\t' == c || '\r' == c || '\n' == c:
+			default:
+				tok.WriteByte(c)
+			}
+		case '(' == c:
+			flush()
+			tok.WriteByte(c)
+			paren = true
+		case ';' == c:
+			flush()
+			if 0 == len(field) {
+				continue // empty statement
+			}
+			return start, field, nil
+		case ' ' == c || '\t' == c || '\r' == c || '\n' == c:
+			flush()
+		default:
+			tok.WriteByte(c)
+		}
+	}
+}
+
+// peek returns true and consumes the next byte if it equals c.
+func (l *svfLexer) peek(c byte) bool {
+	b, err := l.r.Peek(1)
+	if nil != err || c != b[0] {
+		return false
+	}
+	l.r.ReadByte()
+	return true
+}
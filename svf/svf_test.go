@@ -0,0 +1,295 @@
+package svf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ardnew/ft232h"
+)
+
+// simValue returns the value of the given bits, LSB first.
+func simValue(b []bool) uint64 {
+	v := uint64(0)
+	for i, s := range b {
+		if s {
+			v |= 1 << uint(i)
+		}
+	}
+	return v
+}
+
+// openPlayer returns a Player on a simulated FT232H with a scan chain of a
+// target device (nearest TDI) and a device in BYPASS (nearest TDO).
+func openPlayer(t *testing.T) (*Player, *ft232h.SimTAP, *ft232h.SimTAP, func()) {
+	target := &ft232h.SimTAP{IRLen: 4, CapIR: 0x01, IDCode: 0x4BA00477, DRLen: 40}
+	other := &ft232h.SimTAP{IRLen: 6, CapIR: 0x01}
+
+	ft, err := ft232h.OpenSim("svf", func(d *ft232h.SimDevice) error {
+		d.AttachJTAG(ft232h.SimChain{target, other})
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	if err := ft.JTAG.Init(); nil != err {
+		t.Fatalf("JTAG.Init(): %v", err)
+	}
+	p, err := New(ft.JTAG)
+	if nil != err {
+		t.Fatalf("New(): %v", err)
+	}
+	return p, target, other, func() { ft.Close() }
+}
+
+func TestPlay(t *testing.T) {
+
+	p, target, other, done := openPlayer(t)
+	defer done()
+
+	const prog = `! test program
+TRST OFF;
+ENDIR IDLE;
+ENDDR IDLE;
+STATE RESET IDLE;
+FREQUENCY 1E6 HZ;
+HIR 6 TDI (3F);
+HDR 1 TDI (0);
+// read IDCODE
+SIR 4 TDI (1) TDO (1) MASK (3);
+sdr 32 tdi (00000000)
+	TDO (4BA00477) MASK (0FFFFFFF);
+SIR 4 TDI (2);
+SDR 40 TDI (12 3456 789A) SMASK (FFFFFFFFFF);
+SDR 40 TDI (0000000000) TDO (123456789A);
+SDR 40 TDO (0000000000); ! TDI and MASK persist
+RUNTEST 100 TCK;
+RUNTEST DRPAUSE 10 TCK ENDSTATE IDLE;
+RUNTEST IDLE 1.0E-4 SEC MAXIMUM 1 SEC ENDSTATE DRPAUSE;
+;
+`
+	if err := p.Play(strings.NewReader(prog)); nil != err {
+		t.Fatalf("Play(): %v", err)
+	}
+	if ft232h.JTAGPauseDR != target.State || ft232h.JTAGPauseDR != other.State {
+		t.Fatalf("Play()={%s, %s}, expected={%s}", target.State, other.State, ft232h.JTAGPauseDR)
+	}
+	if ft232h.SimTAPDATA != target.IR || 1<<6-1 != other.IR {
+		t.Fatalf("Play()={IR 0x%X, 0x%X}, expected={IR 0x%X, 0x%X}", target.IR, other.IR, ft232h.SimTAPDATA, 1<<6-1)
+	}
+	if v := simValue(target.DR); 0 != v {
+		t.Fatalf("Play()={DR 0x%X}, expected={DR 0x%X}", v, 0)
+	}
+	if target.Idle < 200 {
+		t.Fatalf("Play()={%d idle clocks}, expected={>= %d}", target.Idle, 200)
+	}
+	if 1000000 != p.jtag.GetConfig().Clock {
+		t.Fatalf("Play()={%d Hz}, expected={%d Hz}", p.jtag.GetConfig().Clock, 1000000)
+	}
+
+	// mismatch reports the line and the bits
+	const bad = `STATE RESET;
+HIR 6 TDI (3F);
+HDR 1 TDI (0);
+SIR 4 TDI (1);
+SDR 32 TDI (00000000)
+	TDO (4BA00476);
+`
+	err := p.Play(strings.NewReader(bad))
+	var me *MismatchError
+	if !errors.As(err, &me) {
+		t.Fatalf("Play(): %v, expected *MismatchError", err)
+	}
+	// the header (1 bit BYPASS) is shifted first, into the least-significant bit
+	if 5 != me.Line || 33 != me.Bits ||
+		!bytes.Equal(me.Expected, []uint8{0xEC, 0x08, 0x40, 0x97, 0x00}) ||
+		!bytes.Equal(me.Actual, []uint8{0xEE, 0x08, 0x40, 0x97, 0x00}) ||
+		!bytes.Equal(me.Mask, []uint8{0xFE, 0xFF, 0xFF, 0xFF, 0x01}) {
+		t.Fatalf("Play()={%v}", err)
+	}
+	if exp := "line 5: TDO mismatch: expected (0974008EC), actual (0974008EE), mask (1FFFFFFFE)"; exp != err.Error() {
+		t.Fatalf("Error()={%s}, expected={%s}", err, exp)
+	}
+
+	// nothing executes if the file is invalid
+	for _, tc := range []struct {
+		prog string
+		line int
+	}{
+		{"STATE RESET;\nSIR 4 TDI (1);\nFOO;\n", 3},
+		{"STATE IDLE DRSHIFT DREXIT1;", 1},
+		{"STATE IDLE DRSHIFT;", 1},
+		{"RUNTEST IRSHIFT 10 TCK;", 1},
+		{"\nENDDR DRSHIFT;", 2},
+		{"SIR 4;", 1},
+		{"SIR 4 TDI (10);", 1},
+		{"SIR 4 TDI (1) TDO 1;", 1},
+		{"SDR 8 TDI (X1);", 1},
+		{"RUNTEST IDLE ENDSTATE RESET;", 1},
+		{"RUNTEST 10 TCK FOO;", 1},
+		{"FREQUENCY 0 HZ;", 1},
+		{"TRST ON;", 1},
+		{"PIOMAP (IN A);", 1},
+		{"STATE RESET;\nSIR 4 TDI (1)", 2},
+		{"STATE RESET;\nSIR 4 TDI (1\n", 3},
+	} {
+		if err := p.jtag.GoTo(ft232h.JTAGRunTestIdle); nil != err {
+			t.Fatalf("GoTo(): %v", err)
+		}
+		target.IR = ft232h.SimTAPDATA
+		err := p.Play(strings.NewReader(tc.prog))
+		if nil == err || !strings.HasPrefix(err.Error(), "line "+string(rune('0'+tc.line))+":") {
+			t.Fatalf("Play(%q): %v, expected error at line %d", tc.prog, err, tc.line)
+		}
+		if ft232h.JTAGRunTestIdle != target.State || ft232h.SimTAPDATA != target.IR {
+			t.Fatalf("Play(%q): executed statements of invalid file", tc.prog)
+		}
+	}
+}
+
+// xsvf builds an XSVF file.
+type xsvf struct {
+	bytes.Buffer
+}
+
+func (x *xsvf) add(code uint8, arg ...[]uint8) int64 {
+	off := int64(x.Len())
+	x.WriteByte(code)
+	for _, a := range arg {
+		x.Write(a)
+	}
+	return off
+}
+
+// u8 and u32 encode XSVF integer arguments.
+func u8(v uint8) []uint8 { return []uint8{v} }
+
+func u32(v uint32) []uint8 {
+	b := make([]uint8, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// vec encodes the given bits of v as an XSVF bit vector.
+func vec(bits uint, v uint64) []uint8 {
+	b := make([]uint8, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b[8-(bits+7)/8:]
+}
+
+func TestPlayXSVF(t *testing.T) {
+
+	p, target, other, done := openPlayer(t)
+	defer done()
+
+	// the BYPASS device nearest TDO occupies the least-significant bits
+	const data = uint64(0x123456789A)
+	stream := data << 1
+	var x xsvf
+	x.add(XCOMMENT, []uint8("test program\x00"))
+	x.add(XREPEAT, u8(3))
+	x.add(XRUNTEST, u32(0))
+	x.add(XENDIR, u8(0))
+	x.add(XENDDR, u8(0))
+	x.add(XSTATE, u8(0))
+	x.add(XSTATE, u8(1))
+	x.add(XSIR, u8(10), vec(10, ft232h.SimTAPIDCODE<<6|0x3F))
+	x.add(XSDRSIZE, u32(33))
+	x.add(XTDOMASK, vec(33, 0x0FFFFFFF<<1))
+	x.add(XSDRTDO, vec(33, 0), vec(33, 0x4BA00477<<1))
+	x.add(XSIR2, []uint8{0, 10}, vec(10, ft232h.SimTAPDATA<<6|0x3F))
+	x.add(XSDRSIZE, u32(20))
+	x.add(XSDRB, vec(20, stream&(1<<20-1)))
+	x.add(XSDRSIZE, u32(21))
+	x.add(XSDRE, vec(21, stream>>20))
+	x.add(XSDRSIZE, u32(41))
+	x.add(XSDRTDO, vec(41, 0), vec(41, stream))
+	x.add(XSIR, u8(10), vec(10, ft232h.SimTAPSTATUS<<6|0x3F))
+	x.add(XSDRSIZE, u32(9))
+	x.add(XRUNTEST, u32(10))
+	// the retry shifts Shift-DR again without Capture-DR, so it reads back TDI
+	x.add(XSDRTDO, vec(9, 0x01<<1), vec(9, 0x01<<1))
+	x.add(XWAIT, u8(1), u8(13), u32(20))
+	x.add(XCOMPLETE)
+	x.add(0xFF) // ignored after XCOMPLETE
+
+	target.Busy = 1
+	if err := p.PlayXSVF(&x); nil != err {
+		t.Fatalf("PlayXSVF(): %v", err)
+	}
+	if 0 != target.Busy || ft232h.JTAGPauseIR != target.State || ft232h.JTAGPauseIR != other.State {
+		t.Fatalf("PlayXSVF()={%d, %s}, expected={%d, %s}", target.Busy, target.State, 0, ft232h.JTAGPauseIR)
+	}
+	if ft232h.SimTAPSTATUS != target.IR || 1<<6-1 != other.IR {
+		t.Fatalf("PlayXSVF()={IR 0x%X, 0x%X}, expected={IR 0x%X, 0x%X}", target.IR, other.IR, ft232h.SimTAPSTATUS, 1<<6-1)
+	}
+	if v := simValue(target.DR); 0 != v {
+		t.Fatalf("PlayXSVF()={DR 0x%X}, expected={DR 0x%X}", v, 0)
+	}
+	if target.Idle < 10+20 {
+		t.Fatalf("PlayXSVF()={%d idle clocks}, expected={>= %d}", target.Idle, 10+20)
+	}
+
+	// retries exhausted
+	x.Reset()
+	x.add(XREPEAT, u8(1))
+	x.add(XRUNTEST, u32(1))
+	x.add(XSIR, u8(10), vec(10, ft232h.SimTAPSTATUS<<6|0x3F))
+	x.add(XSDRSIZE, u32(9))
+	x.add(XTDOMASK, vec(9, 0x1FF))
+	off := x.add(XSDRTDO, vec(9, 0), vec(9, 0x01<<1))
+	target.Busy = 2
+	err := p.PlayXSVF(&x)
+	var me *MismatchError
+	if !errors.As(err, &me) || off != me.Offset || 0 != me.Line || 9 != me.Bits ||
+		0x02 != me.Expected[0] || 0x00 != me.Actual[0] || 1 != target.Busy {
+		t.Fatalf("PlayXSVF(): %v, expected mismatch at offset %d", err, off)
+	}
+	if exp := "offset 19: TDO mismatch: expected (002), actual (000), mask (1FF)"; exp != err.Error() {
+		t.Fatalf("Error()={%s}, expected={%s}", err, exp)
+	}
+
+	// TDO isn't compared by XSDR until a TDO mask is given
+	for _, tdoMask := range []bool{false, true} {
+		x.Reset()
+		x.add(XRUNTEST, u32(0))
+		x.add(XSIR, u8(10), vec(10, ft232h.SimTAPIDCODE<<6|0x3F))
+		x.add(XSDRSIZE, u32(33))
+		if tdoMask {
+			x.add(XTDOMASK, vec(33, 0x0FFFFFFF<<1))
+		}
+		off = x.add(XSDR, vec(33, 0))
+		err := p.PlayXSVF(&x)
+		if !tdoMask && nil != err {
+			t.Fatalf("PlayXSVF(): %v", err)
+		}
+		if tdoMask && (!errors.As(err, &me) || off != me.Offset || 0 != me.Expected[0]) {
+			t.Fatalf("PlayXSVF(): %v, expected mismatch at offset %d", err, off)
+		}
+	}
+
+	// nothing executes if the file is invalid
+	for _, b := range [][]uint8{
+		{XSTATE, 0, XSIR, 10, 0x00},
+		{XSTATE, 0, XSTATE, 16},
+		{XSTATE, 0, XSETSDRMASKS},
+		{XSTATE, 0, 0x30},
+	} {
+		if err := p.jtag.GoTo(ft232h.JTAGRunTestIdle); nil != err {
+			t.Fatalf("GoTo(): %v", err)
+		}
+		target.IR = ft232h.SimTAPDATA
+		if err := p.PlayXSVF(bytes.NewReader(b)); nil == err || !strings.HasPrefix(err.Error(), "offset 2:") {
+			t.Fatalf("PlayXSVF(% X): %v, expected error at offset 2", b, err)
+		}
+		if ft232h.JTAGRunTestIdle != target.State || ft232h.SimTAPDATA != target.IR {
+			t.Fatalf("PlayXSVF(% X): executed instructions of invalid file", b)
+		}
+	}
+
+	if _, err := New(nil); nil == err {
+		t.Fatalf("New(nil): expected error")
+	}
+}
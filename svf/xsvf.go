@@ -0,0 +1,329 @@
+package svf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ardnew/ft232h"
+)
+
+// Constants defining the XSVF instruction codes.
+const (
+	XCOMPLETE    uint8 = 0x00 // end of file
+	XTDOMASK     uint8 = 0x01 // set TDO mask
+	XSIR         uint8 = 0x02 // shift instruction register
+	XSDR         uint8 = 0x03 // shift data register, compare TDO with retry
+	XRUNTEST     uint8 = 0x04 // set run-test time (µs)
+	XREPEAT      uint8 = 0x07 // set number of retries of XSDR/XSDRTDO
+	XSDRSIZE     uint8 = 0x08 // set data register length (bits)
+	XSDRTDO      uint8 = 0x09 // shift data register with TDI and expected TDO
+	XSETSDRMASKS uint8 = 0x0A // obsolete
+	XSDRINC      uint8 = 0x0B // obsolete
+	XSDRB        uint8 = 0x0C // shift data register, begin
+	XSDRC        uint8 = 0x0D // shift data register, continue
+	XSDRE        uint8 = 0x0E // shift data register, end
+	XSDRTDOB     uint8 = 0x0F // shift data register with TDO compare, begin
+	XSDRTDOC     uint8 = 0x10 // shift data register with TDO compare, continue
+	XSDRTDOE     uint8 = 0x11 // shift data register with TDO compare, end
+	XSTATE       uint8 = 0x12 // move to TAP controller state
+	XENDIR       uint8 = 0x13 // set end state of XSIR
+	XENDDR       uint8 = 0x14 // set end state of XSDR
+	XSIR2        uint8 = 0x15 // shift instruction register (16-bit length)
+	XCOMMENT     uint8 = 0x16 // null-terminated comment
+	XWAIT        uint8 = 0x17 // wait in a TAP controller state
+)
+
+// XSVFRepeatDefault is the number of times a mismatched XSDR or XSDRTDO scan is
+// retried if the file does not specify it with XREPEAT.
+const XSVFRepeatDefault = 32
+
+// xsvfInstr is a decoded XSVF instruction.
+type xsvfInstr struct {
+	off  int64
+	code uint8
+	n    uint32  // length (bits), time (µs), count, or state
+	end  uint8   // XWAIT end state
+	tdi  []uint8 // LSB first
+	tdo  []uint8 // LSB first
+}
+
+// PlayXSVF parses and then executes the XSVF file read from r.
+//
+// All XSVF instructions of XAPP503 are supported except the obsolete
+// XSETSDRMASKS and XSDRINC. Run-test times are clocked on TCK in
+// Run-Test/Idle, and waits in other states use the host timer. A mismatched
+// XSDR or XSDRTDO scan followed by a non-zero run-test time is retried (up to
+// the XREPEAT count) by returning to Shift-DR through Pause-DR, without
+// updating the data register, and waiting an additional 25% each time.
+func (p *Player) PlayXSVF(r io.Reader) error {
+
+	instr, err := xsvfParse(r)
+	if nil != err {
+		return err
+	}
+
+	var (
+		size    uint32                   // XSDRSIZE
+		runTest uint32                   // XRUNTEST (µs)
+		repeat  = XSVFRepeatDefault      // XREPEAT
+		mask    []uint8                  // XTDOMASK, initially 0s
+		expect  []uint8                  // TDO of the last XSDRTDO, initially 0s
+		endIR   = ft232h.JTAGRunTestIdle // XENDIR
+		endDR   = ft232h.JTAGRunTestIdle // XENDDR
+	)
+	for _, x := range instr {
+		var err error
+		switch x.code {
+		case XTDOMASK:
+			mask = x.tdo
+		case XRUNTEST:
+			runTest = x.n
+		case XREPEAT:
+			repeat = int(x.n)
+		case XSDRSIZE:
+			size = x.n
+		case XSIR, XSIR2:
+			err = p.xsvfShift(x, true, x.n, nil, nil, endIR, runTest, 0)
+		case XSDR:
+			err = p.xsvfShift(x, false, size, xsvfBits(expect, size), mask, endDR, runTest, repeat)
+		case XSDRTDO:
+			expect = x.tdo
+			err = p.xsvfShift(x, false, size, expect, mask, endDR, runTest, repeat)
+		case XSDRB, XSDRC:
+			err = p.xsvfShift(x, false, size, nil, nil, ft232h.JTAGShiftDR, 0, 0)
+		case XSDRE:
+			err = p.xsvfShift(x, false, size, nil, nil, endDR, runTest, 0)
+		case XSDRTDOB, XSDRTDOC:
+			err = p.xsvfShift(x, false, size, x.tdo, mask, ft232h.JTAGShiftDR, 0, 0)
+		case XSDRTDOE:
+			err = p.xsvfShift(x, false, size, x.tdo, mask, endDR, runTest, 0)
+		case XSTATE:
+			err = p.goTo(ft232h.JTAGState(x.n))
+		case XENDIR:
+			endIR = xsvfEnd(x.n, ft232h.JTAGPauseIR)
+		case XENDDR:
+			endDR = xsvfEnd(x.n, ft232h.JTAGPauseDR)
+		case XWAIT:
+			err = p.xsvfWait(ft232h.JTAGState(x.end>>4), ft232h.JTAGState(x.end&0x0F), x.n)
+		}
+		if nil != err {
+			if _, ok := err.(*MismatchError); ok {
+				return err
+			}
+			return fmt.Errorf("offset %d: instruction 0x%02X: %v", x.off, x.code, err)
+		}
+	}
+	return nil
+}
+
+// xsvfEnd returns the end state encoded by XENDIR or XENDDR.
+func xsvfEnd(n uint32, pause ft232h.JTAGState) ft232h.JTAGState {
+	if 0 == n {
+		return ft232h.JTAGRunTestIdle
+	}
+	return pause
+}
+
+// xsvfShift shifts the given number of bits of the instruction register (if ir
+// is true) or data register, comparing TDO with expect if non-nil, and then
+// moves the TAP controller to the given end state. If runTest is non-zero, the
+// TAP controller instead moves to Run-Test/Idle, where it is clocked for the
+// given number of microseconds. A mismatch is retried up to repeat times if
+// runTest is non-zero. The bits of TDO compared are selected by mask, which is
+// extended with 0s if shorter than the shifted bits.
+func (p *Player) xsvfShift(x xsvfInstr, ir bool, bits uint32,
+	expect, mask []uint8, end ft232h.JTAGState, runTest uint32, repeat int) error {
+
+	if runTest > 0 {
+		end = ft232h.JTAGRunTestIdle
+	}
+	if 0 == bits {
+		return p.xsvfWait(end, end, runTest)
+	}
+	if nil == expect {
+		if ir {
+			if err := p.jtag.WriteIR(uint(bits), x.tdi, end); nil != err {
+				return err
+			}
+		} else if err := p.jtag.WriteDR(uint(bits), x.tdi, end); nil != err {
+			return err
+		}
+		return p.xsvfWait(end, end, runTest)
+	}
+
+	expect, mask = xsvfBits(expect, bits), xsvfBits(mask, bits)
+	wait := runTest
+	for try := 0; ; try++ {
+		// pause until the TDO comparison decides between retry and update
+		tdo, err := p.jtag.ShiftDR(uint(bits), x.tdi, ft232h.JTAGPauseDR)
+		if nil != err {
+			return err
+		}
+		if svfMatch(tdo, expect, mask) {
+			break
+		}
+		if 0 == runTest || try >= repeat {
+			return &MismatchError{Offset: x.off, Bits: uint(bits),
+				Expected: expect, Actual: tdo, Mask: mask}
+		}
+		time.Sleep(time.Duration(wait) * time.Microsecond)
+		wait += wait >> 2
+	}
+	if err := p.jtag.GoTo(end); nil != err {
+		return err
+	}
+	return p.xsvfWait(end, end, wait)
+}
+
+// xsvfWait moves the TAP controller to the given wait state for the given
+// number of microseconds, and then to the given end state. The wait is
+// clocked on TCK in Run-Test/Idle, and otherwise uses the host timer.
+func (p *Player) xsvfWait(state, end ft232h.JTAGState, us uint32) error {
+	if 0 == us && state == end {
+		return p.goTo(end)
+	}
+	if err := p.goTo(state); nil != err {
+		return err
+	}
+	d := time.Duration(us) * time.Microsecond
+	if ft232h.JTAGRunTestIdle == state {
+		if err := p.jtag.Idle(uint(p.cycles(d))); nil != err {
+			return err
+		}
+	} else {
+		time.Sleep(d)
+	}
+	return p.goTo(end)
+}
+
+// xsvfParse reads and decodes all of the instructions of an XSVF file up to
+// XCOMPLETE (or the end of the file), returning the instructions to be
+// executed.
+func xsvfParse(r io.Reader) ([]xsvfInstr, error) {
+
+	br := &xsvfReader{r: bufio.NewReader(r)}
+	instr := []xsvfInstr{}
+	size := uint32(0)
+	for {
+		x := xsvfInstr{off: br.off}
+		code, err := br.r.ReadByte()
+		if io.EOF == err {
+			return instr, nil
+		}
+		if nil != err {
+			return nil, err
+		}
+		br.off++
+		x.code = code
+
+		switch code {
+		case XCOMPLETE:
+			return instr, nil
+		case XTDOMASK:
+			x.tdo, err = br.bits(size)
+		case XSIR:
+			if x.n, err = br.uint(1); nil == err {
+				x.tdi, err = br.bits(x.n)
+			}
+		case XSIR2:
+			if x.n, err = br.uint(2); nil == err {
+				x.tdi, err = br.bits(x.n)
+			}
+		case XSDR, XSDRB, XSDRC, XSDRE:
+			x.tdi, err = br.bits(size)
+		case XSDRTDO, XSDRTDOB, XSDRTDOC, XSDRTDOE:
+			if x.tdi, err = br.bits(size); nil == err {
+				x.tdo, err = br.bits(size)
+			}
+		case XRUNTEST:
+			x.n, err = br.uint(4)
+		case XREPEAT:
+			x.n, err = br.uint(1)
+		case XSDRSIZE:
+			if x.n, err = br.uint(4); nil == err {
+				size = x.n
+			}
+		case XSTATE:
+			if x.n, err = br.uint(1); nil == err && x.n > uint32(ft232h.JTAGUpdateIR) {
+				err = fmt.Errorf("invalid TAP state: %d", x.n)
+			}
+		case XENDIR, XENDDR:
+			if x.n, err = br.uint(1); nil == err && x.n > 1 {
+				err = fmt.Errorf("invalid end state: %d", x.n)
+			}
+		case XCOMMENT:
+			var c string
+			c, err = br.r.ReadString(0)
+			br.off += int64(len(c))
+		case XWAIT:
+			var s uint32
+			if s, err = br.uint(2); nil == err {
+				if s>>8 > uint32(ft232h.JTAGUpdateIR) || s&0xFF > uint32(ft232h.JTAGUpdateIR) {
+					err = fmt.Errorf("invalid TAP state: %d, %d", s>>8, s&0xFF)
+				}
+				x.end = uint8(s>>8)<<4 | uint8(s&0xFF)
+				if nil == err {
+					x.n, err = br.uint(4)
+				}
+			}
+		case XSETSDRMASKS, XSDRINC:
+			err = fmt.Errorf("obsolete instruction not supported")
+		default:
+			err = fmt.Errorf("unknown instruction")
+		}
+		if io.EOF == err {
+			err = io.ErrUnexpectedEOF
+		}
+		if nil != err {
+			return nil, fmt.Errorf("offset %d: instruction 0x%02X: %v", x.off, code, err)
+		}
+		if XCOMMENT == code {
+			continue
+		}
+		instr = append(instr, x)
+	}
+}
+
+// xsvfReader reads the arguments of XSVF instructions, tracking the offset.
+type xsvfReader struct {
+	r   *bufio.Reader
+	off int64
+}
+
+// uint reads an unsigned big-endian integer of n (1, 2, or 4) bytes.
+func (x *xsvfReader) uint(n int) (uint32, error) {
+	b := make([]uint8, 4)
+	if _, err := io.ReadFull(x.r, b[4-n:]); nil != err {
+		return 0, err
+	}
+	x.off += int64(n)
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// bits reads a bit vector of the given length, stored most-significant byte
+// first in the file, and returns it LSB first.
+func (x *xsvfReader) bits(n uint32) ([]uint8, error) {
+	b := make([]uint8, (n+7)/8)
+	if _, err := io.ReadFull(x.r, b); nil != err {
+		return nil, err
+	}
+	x.off += int64(len(b))
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b, nil
+}
+
+// xsvfBits returns a copy of the given bit vector with the given number of
+// bits, extended with 0s or truncated.
+func xsvfBits(v []uint8, bits uint32) []uint8 {
+	b := make([]uint8, (bits+7)/8)
+	copy(b, v)
+	if r := bits % 8; r > 0 {
+		b[len(b)-1] &= 1<<r - 1
+	}
+	return b
+}
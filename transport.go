@@ -0,0 +1,185 @@
+package ft232h
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// Transport defines the methods required for enumerating and opening
+// MPSSE-capable USB devices. The primary implementation is the D2XX/libMPSSE
+// bridge (see native_bridge.go), which is only available when building with cgo
+// on a supported platform. The USB transport (see usb.go) communicates with
+// devices natively in Go over a raw USB endpoint interface. Alternative implementations
+// can be selected for an individual device via the Transport field of Mask, or
+// for all devices by assigning DefaultTransport.
+type Transport interface {
+	// Devices returns a slice of DeviceInfo pointers describing all of the
+	// MPSSE-capable USB devices available to the transport. Returns an empty
+	// slice and nil error if no devices were found.
+	Devices() ([]*DeviceInfo, error)
+	// Open opens the device enumerated at the given index, returning a Port
+	// used for all subsequent communication with the device.
+	Open(index int) (Port, error)
+}
+
+// TransportID is an optional interface implemented by a Transport that only
+// enumerates devices with a particular USB vendor and product ID. If the VID or
+// PID of a Mask is given, OpenMask enumerates devices with the Transport
+// returned by WithID.
+type TransportID interface {
+	// WithID returns a Transport enumerating the devices with the given USB
+	// vendor and product ID, or the receiver's defaults if zero.
+	WithID(vid uint32, pid uint32) Transport
+}
+
+// Port defines the methods required for communicating with an opened device.
+// A Port provides the device's control requests and raw byte stream. Unless the
+// Port also implements PortChannel, the GPIO, SPI, and I²C interfaces are all
+// implemented by encoding MPSSE commands (see package mpsse) written to and read
+// from the Port.
+type Port interface {
+	Close() error // close the device
+
+	// device configuration
+	Reset() error                              // reset the device
+	Purge() error                              // purge the receive and transmit buffers
+	SetLatency(ms uint8) error                 // set the latency timer
+	SetBitMode(mask uint8, mode BitMode) error // set the bit mode and pin mask
+
+	// Write writes all of the given bytes to the device, returning the number of
+	// bytes written and a non-nil error if unsuccessful.
+	Write(data []uint8) (int, error)
+	// Read reads exactly len(data) bytes from the device, returning the number
+	// of bytes read and a non-nil error if fewer bytes were received before the
+	// read timeout expired, which should wrap ErrReadTimeout.
+	Read(data []uint8) (int, error)
+}
+
+// PortChannel is an optional interface implemented by a Port whose GPIO, SPI,
+// and I²C channels are provided by a native driver, such as libMPSSE in the
+// D2XX/libMPSSE bridge. Option arguments are the 32-bit configuration and
+// transfer option bitmaps defined by libMPSSE, and are passed through
+// unmodified from the SPI and I²C interfaces.
+//
+// The GPIO, SPI, and I²C interfaces of a device opened with a PortChannel use it
+// in place of MPSSE commands, and the features that require MPSSE commands
+// return an error wrapping ErrNoMPSSE. These are the DGPIO and JTAG interfaces,
+// bit-granular and LSB-first SPI transfers, 10-bit I²C addresses, and I²C
+// clock stretching. SPI transactions (SPI.Tx and SPI.Reg) are performed one
+// segment at a time (see SPITx.Exec), multi-message I²C transfers
+// (I2C.Transfer) one message at a time, and I²C bus scans (I2C.Scan) one probe
+// at a time.
+//
+// The SPI and I²C transfer methods are not required to handle transfers larger
+// than the MPSSE limit of 65536 bytes; callers split larger transfers into
+// multiple requests.
+type PortChannel interface {
+	// GPIO ("C" port) pin direction and levels
+	WriteGPIO(dir uint8, val uint8) error
+	ReadGPIO() (uint8, error)
+
+	// SPI master channel
+	SPIInit(clock uint32, latency uint8, options uint32, pin uint32) error
+	SPIChange(options uint32) error
+	SPIRead(data []uint8, opt uint32) (uint, error)
+	SPIWrite(data []uint8, opt uint32) (uint, error)
+	SPISwap(recv []uint8, send []uint8, opt uint32) (uint, error)
+
+	// I²C master channel
+	I2CInit(clock uint32, latency uint8, options uint32) error
+	I2CRead(addr uint, data []uint8, opt uint32) (uint, error)
+	I2CWrite(addr uint, data []uint8, opt uint32) (uint, error)
+}
+
+// PortTimeout is an optional interface implemented by a Port whose read timeout
+// can be changed while the device is open.
+type PortTimeout interface {
+	// SetReadTimeout sets the maximum duration Read waits for all requested
+	// bytes to be received. A zero duration restores the Port's default.
+	SetReadTimeout(d time.Duration) error
+}
+
+// PortSerial is an optional interface implemented by a Port that supports the
+// asynchronous serial (UART) mode of the device, used with the MPSSE disabled
+// (bit mode BitModeReset).
+type PortSerial interface {
+	SetBaudRate(baud uint32) error                                  // set the baud rate
+	SetLine(bits uint8, parity UARTParity, stop UARTStopBits) error // set the data characteristics
+	SetFlowControl(flow UARTFlow) error                             // set the hardware flow control
+	SetBreak(on bool) error                                         // set or clear the break condition on TXD
+	SetDTR(on bool) error                                           // assert or de-assert DTR#
+	SetRTS(on bool) error                                           // assert or de-assert RTS#
+	ModemStatus() (UARTStatus, error)                               // read the modem and line status
+	Queue() (int, error)                                            // number of received bytes available to Read
+}
+
+// PortEEPROM is an optional interface implemented by a Port that can access the
+// configuration EEPROM of the device, one 16-bit word at a time.
+type PortEEPROM interface {
+	ReadEEPROM(addr uint8) (uint16, error)      // read the word at the given word address
+	WriteEEPROM(addr uint8, value uint16) error // write the word at the given word address
+	EraseEEPROM() error                         // erase the entire EEPROM
+}
+
+// ErrReadTimeout is the error wrapped by the errors returned from Port.Read
+// when fewer bytes were received than requested before the read timeout.
+var ErrReadTimeout = errors.New("read timeout")
+
+// ErrNoMPSSE is the error wrapped by the errors returned from operations that
+// require MPSSE commands on a device whose Port implements PortChannel.
+var ErrNoMPSSE = errors.New("MPSSE commands not supported by transport")
+
+// BitMode represents the operating mode of the device set with SetBitMode.
+type BitMode uint8
+
+// Constants defining the supported bit modes.
+const (
+	BitModeReset      BitMode = 0x00 // reset to configuration in EEPROM
+	BitModeAsyncBang  BitMode = 0x01 // asynchronous bit-bang
+	BitModeMPSSE      BitMode = 0x02 // MPSSE (SPI, I²C, JTAG)
+	BitModeSyncBang   BitMode = 0x04 // synchronous bit-bang
+	BitModeMCU        BitMode = 0x08 // MCU host bus emulation
+	BitModeFastSerial BitMode = 0x10 // fast opto-isolated serial
+	BitModeCBUSBang   BitMode = 0x20 // CBUS bit-bang
+	BitModeSyncFIFO   BitMode = 0x40 // single channel synchronous 245 FIFO
+)
+
+// DeviceInfo contains the USB device descriptor and attributes for a device
+// enumerated by a Transport.
+type DeviceInfo struct {
+	Index   int
+	Open    bool
+	HiSpeed bool
+	Chip    Chip
+	VID     uint32
+	PID     uint32
+	LocID   uint32
+	Serial  string
+	Desc    string
+}
+
+// DefaultTransport is the Transport used to enumerate and open devices when a
+// Mask does not specify one. It is initialized to the D2XX/libMPSSE bridge when
+// built with cgo, to the USB transport using usbfs when built without cgo on Linux,
+// and is otherwise nil.
+var DefaultTransport Transport
+
+// transport returns the Transport specified by the receiver mask, or
+// DefaultTransport if the mask is nil or does not specify one.
+// Returns a non-nil error if neither are available.
+func (mask *Mask) transport() (Transport, error) {
+	if nil != mask && nil != mask.Transport {
+		return mask.Transport, nil
+	}
+	if nil == DefaultTransport {
+		return nil, fmt.Errorf("no transport available (unsupported platform without cgo?)")
+	}
+	return DefaultTransport, nil
+}
+
+// maxTransferBytes is the MPSSE limit on the length of a single SPI or I²C
+// transfer, since the packet length has to fit into 16 bits.
+const maxTransferBytes = mpsse.MaxBytes
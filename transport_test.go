@@ -0,0 +1,366 @@
+package ft232h
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ardnew/ft232h/mpsse"
+)
+
+// testTransport implements Transport with a single device whose Port records
+// each of the MPSSE commands it receives.
+type testTransport struct {
+	port *testPort
+}
+
+type testPort struct {
+	open bool
+	mode BitMode
+	high uint8
+	low  uint8 // levels of port "D" pins read by GetLow
+	hold uint8 // bits held LOW when echoing data written and read
+	resp []uint8
+	cmd  []mpsse.Command
+	nw   int // number of calls to Write
+}
+
+func (t *testTransport) Devices() ([]*DeviceInfo, error) {
+	return []*DeviceInfo{
+		{Index: 0, Chip: CFT232H, VID: 0x0403, PID: 0x6014, Serial: "TEST0"},
+	}, nil
+}
+
+func (t *testTransport) Open(index int) (Port, error) {
+	if 0 != index {
+		return nil, SDeviceNotFound
+	}
+	t.port.open = true
+	return t.port, nil
+}
+
+func (p *testPort) Close() error                           { p.open = false; return nil }
+func (p *testPort) Reset() error                           { p.resp = nil; return nil }
+func (p *testPort) Purge() error                           { p.resp = nil; return nil }
+func (p *testPort) SetLatency(uint8) error                 { return nil }
+func (p *testPort) SetBitMode(_ uint8, mode BitMode) error { p.mode = mode; return nil }
+
+func (p *testPort) Write(data []uint8) (int, error) {
+	p.nw++
+	if 1 == len(data) && uint8(mpsse.OpBadCommandExample) == data[0] {
+		p.resp = append(p.resp, uint8(mpsse.OpBadCommandReply), data[0])
+		return 1, nil
+	}
+	cmd, err := mpsse.Decode(data)
+	if nil != err {
+		return 0, err
+	}
+	for _, c := range cmd {
+		switch c := c.(type) {
+		case mpsse.SetHigh:
+			p.high = c.Value
+		case mpsse.GetHigh:
+			p.resp = append(p.resp, p.high)
+			continue
+		case mpsse.GetLow:
+			p.resp = append(p.resp, p.low)
+			continue
+		case mpsse.ClockData:
+			// loop data written back, e.g. SDA sampled while writing I²C data
+			if c.Out && c.In && !c.Bits {
+				for _, b := range c.Data {
+					p.resp = append(p.resp, b & ^p.hold)
+				}
+				continue
+			}
+		}
+		p.resp = append(p.resp, make([]uint8, c.Response())...)
+	}
+	p.cmd = append(p.cmd, cmd...)
+	return len(data), nil
+}
+
+func (p *testPort) Read(data []uint8) (int, error) {
+	n := copy(data, p.resp)
+	p.resp = p.resp[n:]
+	if n < len(data) {
+		return n, SIOError
+	}
+	return n, nil
+}
+
+func TestTransport(t *testing.T) {
+
+	tpt := &testTransport{port: &testPort{}}
+
+	if _, err := OpenMask(&Mask{Serial: "NOPE", Transport: tpt}); SDeviceNotFound != err {
+		t.Fatalf("OpenMask(): unexpected error: %v", err)
+	}
+
+	ft, err := OpenMask(&Mask{Serial: "test0", Transport: tpt})
+	if nil != err {
+		t.Fatalf("OpenMask(): %v", err)
+	}
+
+	if !ft.IsOpen() || !tpt.port.open || BitModeMPSSE != tpt.port.mode {
+		t.Fatalf("expected device to be open in MPSSE mode")
+	}
+	if "TEST0" != ft.Serial() || 0x6014 != ft.PID() {
+		t.Fatalf("unexpected device info: %s", ft)
+	}
+
+	t.Run("GPIO", func(t *testing.T) {
+		tpt.port.cmd = nil
+		if err := ft.GPIO.Set(C(3), true); nil != err {
+			t.Fatalf("GPIO.Set(): %v", err)
+		}
+		exp := mpsse.SetHigh{Value: C(3).Mask(), Dir: C(3).Mask()}
+		if 1 != len(tpt.port.cmd) || exp != tpt.port.cmd[0] {
+			t.Fatalf("commands={%v}, expected={%v}", tpt.port.cmd, exp)
+		}
+		if set, err := ft.GPIO.Get(C(3)); nil != err || !set {
+			t.Fatalf("GPIO.Get(): %t, %v", set, err)
+		}
+	})
+
+	t.Run("SPI", func(t *testing.T) {
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		tpt.port.cmd = nil
+		n, err := ft.SPI.Write(make([]uint8, 2*maxTransferBytes+1), true, true)
+		if nil != err || n != 2*maxTransferBytes+1 {
+			t.Fatalf("SPI.Write(): %d, %v", n, err)
+		}
+		// CS asserted only before first chunk, de-asserted only after last chunk
+		var size []int
+		var cs []bool
+		for _, c := range tpt.port.cmd {
+			switch c := c.(type) {
+			case mpsse.ClockData:
+				size = append(size, c.Len)
+			case mpsse.SetLow:
+				cs = append(cs, 0 == (c.Value&D(3).Mask()))
+			}
+		}
+		if exp := []int{maxTransferBytes, maxTransferBytes, 1}; len(exp) != len(size) ||
+			exp[0] != size[0] || exp[1] != size[1] || exp[2] != size[2] {
+			t.Fatalf("transfers={%v}, expected={%v}", size, exp)
+		}
+		if 2 != len(cs) || !cs[0] || cs[1] {
+			t.Fatalf("CS asserted={%v}, expected={[true false]}", cs)
+		}
+		if _, ok := tpt.port.cmd[0].(mpsse.SetLow); !ok {
+			t.Fatalf("expected CS asserted before first transfer: %v", tpt.port.cmd[0])
+		}
+	})
+
+	if err := ft.Close(); nil != err {
+		t.Fatalf("Close(): %v", err)
+	}
+	if ft.IsOpen() || tpt.port.open {
+		t.Fatalf("expected device to be closed")
+	}
+}
+
+// channelTransport implements Transport with a single device whose Port also
+// implements PortChannel, recording each of the requests it receives.
+type channelTransport struct {
+	port *channelPort
+}
+
+type channelPort struct {
+	open bool
+	nw   int // number of calls to Write
+	dir  uint8
+	val  uint8
+	xfer []channelXfer
+	nack uint // number of bytes ACKed by the I²C slave before NACK
+	gone bool // I²C slave NACKs its address
+}
+
+type channelXfer struct {
+	size uint
+	opt  uint32
+}
+
+func (t *channelTransport) Devices() ([]*DeviceInfo, error) {
+	return []*DeviceInfo{
+		{Index: 0, Chip: CFT232H, VID: 0x0403, PID: 0x6014, Serial: "CHAN0"},
+	}, nil
+}
+
+func (t *channelTransport) Open(index int) (Port, error) {
+	if 0 != index {
+		return nil, SDeviceNotFound
+	}
+	t.port.open = true
+	return t.port, nil
+}
+
+func (p *channelPort) Close() error                                { p.open = false; return nil }
+func (p *channelPort) Reset() error                                { return nil }
+func (p *channelPort) Purge() error                                { return nil }
+func (p *channelPort) SetLatency(uint8) error                      { return nil }
+func (p *channelPort) SetBitMode(uint8, BitMode) error             { return nil }
+func (p *channelPort) Write(data []uint8) (int, error)             { p.nw++; return len(data), nil }
+func (p *channelPort) Read(data []uint8) (int, error)              { return 0, SIOError }
+func (p *channelPort) WriteGPIO(dir uint8, val uint8) error        { p.dir, p.val = dir, val; return nil }
+func (p *channelPort) ReadGPIO() (uint8, error)                    { return p.val, nil }
+func (p *channelPort) SPIInit(uint32, uint8, uint32, uint32) error { return nil }
+func (p *channelPort) SPIChange(uint32) error                      { return nil }
+func (p *channelPort) SPIRead(data []uint8, opt uint32) (uint, error) {
+	p.xfer = append(p.xfer, channelXfer{size: uint(len(data)), opt: opt})
+	return uint(len(data)), nil
+}
+func (p *channelPort) SPIWrite(data []uint8, opt uint32) (uint, error) {
+	p.xfer = append(p.xfer, channelXfer{size: uint(len(data)), opt: opt})
+	return uint(len(data)), nil
+}
+func (p *channelPort) SPISwap(recv []uint8, send []uint8, opt uint32) (uint, error) {
+	p.xfer = append(p.xfer, channelXfer{size: uint(len(send)), opt: opt})
+	return uint(len(send)), nil
+}
+func (p *channelPort) I2CInit(uint32, uint8, uint32) error { return nil }
+func (p *channelPort) I2CRead(addr uint, data []uint8, opt uint32) (uint, error) {
+	p.xfer = append(p.xfer, channelXfer{size: uint(len(data)), opt: opt})
+	return uint(len(data)), nil
+}
+func (p *channelPort) I2CWrite(addr uint, data []uint8, opt uint32) (uint, error) {
+	p.xfer = append(p.xfer, channelXfer{size: uint(len(data)), opt: opt})
+	if p.gone {
+		return 0, SDeviceNotFound
+	}
+	if p.nack < uint(len(data)) {
+		return p.nack, SFailedToWriteDevice
+	}
+	return uint(len(data)), nil
+}
+
+func TestTransportChannel(t *testing.T) {
+
+	tpt := &channelTransport{port: &channelPort{nack: ^uint(0)}}
+
+	ft, err := OpenMask(&Mask{Serial: "chan0", Transport: tpt})
+	if nil != err {
+		t.Fatalf("OpenMask(): %v", err)
+	}
+
+	t.Run("GPIO", func(t *testing.T) {
+		if err := ft.GPIO.Set(C(3), true); nil != err {
+			t.Fatalf("GPIO.Set(): %v", err)
+		}
+		if tpt.port.dir != C(3).Mask() || tpt.port.val != C(3).Mask() {
+			t.Fatalf("GPIO dir={%08b} val={%08b}, expected={%08b}",
+				tpt.port.dir, tpt.port.val, C(3).Mask())
+		}
+		if set, err := ft.GPIO.Get(C(3)); nil != err || !set {
+			t.Fatalf("GPIO.Get(): %t, %v", set, err)
+		}
+	})
+
+	t.Run("SPI", func(t *testing.T) {
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		tpt.port.xfer = nil
+		n, err := ft.SPI.Write(make([]uint8, 2*maxTransferBytes+1), true, true)
+		if nil != err || n != 2*maxTransferBytes+1 {
+			t.Fatalf("SPI.Write(): %d, %v", n, err)
+		}
+		// CS asserted only on first chunk, de-asserted only on last chunk
+		exp := []channelXfer{
+			{size: maxTransferBytes, opt: uint32(spiCSAssert)},
+			{size: maxTransferBytes, opt: 0},
+			{size: 1, opt: uint32(spiCSDeAssert)},
+		}
+		if len(exp) != len(tpt.port.xfer) {
+			t.Fatalf("transfers={%d}, expected={%d}", len(tpt.port.xfer), len(exp))
+		}
+		for i, x := range exp {
+			if x != tpt.port.xfer[i] {
+				t.Fatalf("transfer[%d]={%+v}, expected={%+v}", i, tpt.port.xfer[i], x)
+			}
+		}
+		// transactions are performed one segment at a time
+		tpt.port.xfer = nil
+		rd, err := ft.SPI.Tx().Assert().Write([]uint8{0x9F}).Read(3).Deassert().Exec()
+		if nil != err || 1 != len(rd) || 3 != len(rd[0]) {
+			t.Fatalf("SPI.Tx().Exec()={%v}: %v", rd, err)
+		}
+		exp = []channelXfer{
+			{size: 1, opt: uint32(spiCSAssert)},
+			{size: 3, opt: uint32(spiCSDeAssert)},
+		}
+		if len(exp) != len(tpt.port.xfer) || exp[0] != tpt.port.xfer[0] || exp[1] != tpt.port.xfer[1] {
+			t.Fatalf("transfers={%+v}, expected={%+v}", tpt.port.xfer, exp)
+		}
+		if _, err := ft.SPI.Tx().Assert().Deassert().Exec(); !errors.Is(err, ErrNoMPSSE) {
+			t.Fatalf("SPI.Tx().Exec()={%v}, expected={%v}", err, ErrNoMPSSE)
+		}
+		if _, err := ft.SPI.WriteBits([]uint8{0xFF, 0x80}, 9, true, true); !errors.Is(err, ErrNoMPSSE) {
+			t.Fatalf("SPI.WriteBits()={%v}, expected={%v}", err, ErrNoMPSSE)
+		}
+	})
+
+	t.Run("I2C", func(t *testing.T) {
+		if err := ft.I2C.Init(); nil != err {
+			t.Fatalf("I2C.Init(): %v", err)
+		}
+		tpt.port.xfer = nil
+		if _, err := ft.I2C.Tx(0x50, []uint8{0x10}, 2); nil != err {
+			t.Fatalf("I2C.Tx(): %v", err)
+		}
+		exp := []channelXfer{
+			{size: 1, opt: uint32(i2cStartBit | i2cFastTransfer)},
+			{size: 2, opt: uint32(i2cStartBit | i2cStopBit | i2cFastTransfer | i2cLastReadNACK)},
+		}
+		if len(exp) != len(tpt.port.xfer) {
+			t.Fatalf("transfers={%d}, expected={%d}", len(tpt.port.xfer), len(exp))
+		}
+		for i, x := range exp {
+			if x != tpt.port.xfer[i] {
+				t.Fatalf("transfer[%d]={%+v}, expected={%+v}", i, tpt.port.xfer[i], x)
+			}
+		}
+		// an empty transaction writes only the slave address
+		tpt.port.xfer = nil
+		if _, err := ft.I2C.Tx(0x50, nil, 0); nil != err {
+			t.Fatalf("I2C.Tx(): %v", err)
+		}
+		quick := channelXfer{size: 0, opt: uint32(i2cStartBit | i2cStopBit | i2cFastTransfer)}
+		if 1 != len(tpt.port.xfer) || quick != tpt.port.xfer[0] {
+			t.Fatalf("transfers={%+v}, expected={[%+v]}", tpt.port.xfer, quick)
+		}
+		tpt.port.gone = true
+		var addr *I2CAddrNACKError
+		if _, err := ft.I2C.Tx(0x50, nil, 0); !errors.As(err, &addr) {
+			t.Fatalf("I2C.Tx()={%v}, expected={%v}", err, &I2CAddrNACKError{Addr: 0x50})
+		}
+		tpt.port.gone = false
+		// clock stretching is rejected without changing the configuration
+		brk := ft.I2C.GetConfig().BreakOnNACK
+		if err := ft.I2C.Option(&I2COption{ClockStretch: true, BreakOnNACK: !brk}); !errors.Is(err, ErrNoMPSSE) {
+			t.Fatalf("I2C.Option()={%v}, expected={%v}", err, ErrNoMPSSE)
+		}
+		if cfg := ft.I2C.GetConfig(); cfg.ClockStretch || brk != cfg.BreakOnNACK {
+			t.Fatalf("GetConfig()={%t, %t}, expected={%t, %t}",
+				cfg.ClockStretch, cfg.BreakOnNACK, false, brk)
+		}
+		tpt.port.nack = 1
+		var nack *I2CDataNACKError
+		if _, err := ft.I2C.Write(0x50, []uint8{1, 2, 3}, true, true); !errors.As(err, &nack) || 1 != nack.Index {
+			t.Fatalf("I2C.Write()={%v}, expected={%v}", err, &I2CDataNACKError{Addr: 0x50, Index: 1})
+		}
+	})
+
+	if err := ft.JTAG.Init(); !errors.Is(err, ErrNoMPSSE) {
+		t.Fatalf("JTAG.Init()={%v}, expected={%v}", err, ErrNoMPSSE)
+	}
+	if 0 != tpt.port.nw {
+		t.Fatalf("MPSSE writes={%d}, expected={%d}", tpt.port.nw, 0)
+	}
+
+	if err := ft.Close(); nil != err {
+		t.Fatalf("Close(): %v", err)
+	}
+}
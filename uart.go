@@ -0,0 +1,393 @@
+package ft232h
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UART stores interface configuration settings for the asynchronous serial
+// (UART) mode of the FT232H, and implements io.ReadWriteCloser for
+// transferring data over its TXD and RXD lines.
+//
+// In UART mode, the MPSSE is disabled and the port "D" pins have fixed
+// functions: TXD (D0), RXD (D1), RTS# (D2), CTS# (D3), DTR# (D4), DSR# (D5),
+// DCD# (D6), and RI# (D7). The GPIO and DGPIO interfaces are unavailable until
+// the MPSSE is re-enabled by initializing either the SPI or I2C interface.
+// The interface must be initialized by calling either Init or Config (not both)
+// before use, and requires a Transport whose Port implements PortSerial.
+type UART struct {
+	device *FT232H
+	config *UARTConfig
+}
+
+// UARTParity represents the parity bit appended to each UART data word.
+type UARTParity uint8
+
+// Constants defining the supported parity bits.
+const (
+	UARTParityNone  UARTParity = 0
+	UARTParityOdd   UARTParity = 1
+	UARTParityEven  UARTParity = 2
+	UARTParityMark  UARTParity = 3 // parity bit always 1
+	UARTParitySpace UARTParity = 4 // parity bit always 0
+)
+
+// String returns a string describing the parity bit.
+func (p UARTParity) String() string {
+	switch p {
+	case UARTParityNone:
+		return "none"
+	case UARTParityOdd:
+		return "odd"
+	case UARTParityEven:
+		return "even"
+	case UARTParityMark:
+		return "mark"
+	case UARTParitySpace:
+		return "space"
+	default:
+		return "(invalid parity)"
+	}
+}
+
+// UARTStopBits represents the number of stop bits following each UART data
+// word.
+type UARTStopBits uint8
+
+// Constants defining the supported number of stop bits.
+const (
+	UARTStopBits1 UARTStopBits = 0
+	UARTStopBits2 UARTStopBits = 2
+)
+
+// String returns a string describing the number of stop bits.
+func (s UARTStopBits) String() string {
+	switch s {
+	case UARTStopBits1:
+		return "1"
+	case UARTStopBits2:
+		return "2"
+	default:
+		return "(invalid stop bits)"
+	}
+}
+
+// UARTFlow represents the hardware flow control used in UART mode.
+type UARTFlow uint16
+
+// Constants defining the supported hardware flow control. With RTS/CTS flow
+// control, the FT232H transmits only while CTS# is asserted, and de-asserts
+// RTS# when its receive buffer is full; likewise for DTR/DSR.
+const (
+	UARTFlowNone   UARTFlow = 0x0000
+	UARTFlowRTSCTS UARTFlow = 0x0100
+	UARTFlowDTRDSR UARTFlow = 0x0200
+)
+
+// String returns a string describing the hardware flow control.
+func (f UARTFlow) String() string {
+	switch f {
+	case UARTFlowNone:
+		return "none"
+	case UARTFlowRTSCTS:
+		return "RTS/CTS"
+	case UARTFlowDTRDSR:
+		return "DTR/DSR"
+	default:
+		return "(invalid flow control)"
+	}
+}
+
+// UARTStatus contains the modem status (low byte) and line status (high byte)
+// of the FT232H in UART mode. Each modem status bit is set if the corresponding
+// active-LOW input is asserted.
+type UARTStatus uint16
+
+// Constants defining the bits of UARTStatus.
+const (
+	UARTStatusCTS     UARTStatus = 0x0010 // clear to send
+	UARTStatusDSR     UARTStatus = 0x0020 // data set ready
+	UARTStatusRI      UARTStatus = 0x0040 // ring indicator
+	UARTStatusDCD     UARTStatus = 0x0080 // data carrier detect
+	UARTStatusDR      UARTStatus = 0x0100 // data ready
+	UARTStatusOE      UARTStatus = 0x0200 // overrun error
+	UARTStatusPE      UARTStatus = 0x0400 // parity error
+	UARTStatusFE      UARTStatus = 0x0800 // framing error
+	UARTStatusBI      UARTStatus = 0x1000 // break interrupt
+	UARTStatusTHRE    UARTStatus = 0x2000 // transmitter holding register empty
+	UARTStatusTEMT    UARTStatus = 0x4000 // transmitter empty
+	UARTStatusRCVRErr UARTStatus = 0x8000 // error in receiver FIFO
+)
+
+// String returns a string listing the names of all bits set in the status.
+func (s UARTStatus) String() string {
+	name := []string{
+		"CTS", "DSR", "RI", "DCD", "DR", "OE", "PE", "FE",
+		"BI", "THRE", "TEMT", "RCVRErr",
+	}
+	set := []string{}
+	for i, n := range name {
+		if 0 != s&(UARTStatusCTS<<uint(i)) {
+			set = append(set, n)
+		}
+	}
+	return fmt.Sprintf("{ %s }", strings.Join(set, " "))
+}
+
+// UARTConfig holds all of the configuration settings for initializing a UART
+// interface.
+type UARTConfig struct {
+	Baud     uint32        // baud rate, 183-12000000 (0 = default)
+	DataBits uint8         // number of data bits, 7 or 8 (0 = default)
+	Parity   UARTParity    // parity bit
+	StopBits UARTStopBits  // number of stop bits
+	Flow     UARTFlow      // hardware flow control
+	Timeout  time.Duration // maximum duration Read waits for data (0 = forever)
+	Latency  byte          // 1-255 USB HiSpeed, 2-255 USB FullSpeed
+}
+
+// Constants related to UART interface initialization.
+const (
+	UARTBaudDefault     uint32 = 115200
+	UARTDataBitsDefault uint8  = 8
+	UARTLatencyDefault  byte   = 16
+	uartPollInterval           = time.Millisecond // Read polling interval
+)
+
+// UARTConfigDefault returns the default configuration settings for a UART
+// interface: 115200 baud, 8 data bits, no parity, 1 stop bit (8N1), no flow
+// control, and Read waits indefinitely for data.
+func UARTConfigDefault() *UARTConfig {
+	return &UARTConfig{
+		Baud:     UARTBaudDefault,
+		DataBits: UARTDataBitsDefault,
+		Parity:   UARTParityNone,
+		StopBits: UARTStopBits1,
+		Flow:     UARTFlowNone,
+		Timeout:  0,
+		Latency:  UARTLatencyDefault,
+	}
+}
+
+// String returns a descriptive string of a UART configuration.
+func (c *UARTConfig) String() string {
+	return fmt.Sprintf("{ Baud: %d, DataBits: %d, Parity: %s, StopBits: %s, "+
+		"Flow: %s, Timeout: %s, Latency: \"%d ms\" }",
+		c.Baud, c.DataBits, c.Parity, c.StopBits, c.Flow, c.Timeout, c.Latency)
+}
+
+// String returns a descriptive string of a UART interface.
+func (uart *UART) String() string {
+	return fmt.Sprintf("{ FT232H: %p, Config: %s }", uart.device, uart.config)
+}
+
+// GetConfig returns the current configuration settings of the UART receiver.
+func (uart *UART) GetConfig() *UARTConfig {
+	cfg := *uart.config
+	return &cfg
+}
+
+// Config configures the UART interface with the given settings, and then
+// initializes the interface (see Init). Zero-valued Baud, DataBits, and
+// Latency use their default values. If cfg is nil, the default configuration is
+// used (see UARTConfigDefault).
+func (uart *UART) Config(cfg *UARTConfig) error {
+
+	if nil == cfg {
+		cfg = UARTConfigDefault()
+	}
+
+	c := *cfg
+	if 0 == c.Baud {
+		c.Baud = UARTBaudDefault
+	}
+	if 0 == c.DataBits {
+		c.DataBits = UARTDataBitsDefault
+	}
+	if 0 == c.Latency {
+		c.Latency = UARTLatencyDefault
+	}
+
+	if 7 != c.DataBits && 8 != c.DataBits {
+		return fmt.Errorf("invalid data bits: %d (7 or 8)", c.DataBits)
+	}
+	if c.Parity > UARTParitySpace {
+		return fmt.Errorf("invalid parity: %d", c.Parity)
+	}
+	if UARTStopBits1 != c.StopBits && UARTStopBits2 != c.StopBits {
+		return fmt.Errorf("invalid stop bits: %d", c.StopBits)
+	}
+	switch c.Flow {
+	case UARTFlowNone, UARTFlowRTSCTS, UARTFlowDTRDSR:
+	default:
+		return fmt.Errorf("invalid flow control: 0x%04X", uint16(c.Flow))
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid read timeout: %s", c.Timeout)
+	}
+
+	uart.config = &c
+
+	return uart.Init()
+}
+
+// serial returns the receiver's Port as a PortSerial, and a non-nil error if
+// the Port does not support UART mode.
+func (uart *UART) serial() (PortSerial, error) {
+	if s, ok := uart.device.info.port.(PortSerial); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("%s mode not supported by transport", ModeUART)
+}
+
+// ready returns the receiver's Port as a PortSerial, and a non-nil error if the
+// interface has not been initialized.
+func (uart *UART) ready() (PortSerial, error) {
+	if ModeUART != uart.device.mode {
+		return nil, fmt.Errorf("%s interface not initialized (mode: %s)",
+			ModeUART, uart.device.mode)
+	}
+	return uart.serial()
+}
+
+// Init initializes the UART interface to a state ready for read/write, with
+// DTR# and RTS# asserted. If Config has not been called, the default
+// configuration is used (see UARTConfigDefault).
+// The device is reset and the MPSSE is disabled, so any SPI or I²C interface
+// must be initialized again before use.
+func (uart *UART) Init() error {
+
+	s, err := uart.serial()
+	if nil != err {
+		return err
+	}
+
+	if err := uart.device.info.engine.initSerial(uart.config.Latency); nil != err {
+		return err
+	}
+
+	cfg := uart.config
+	for _, f := range []func() error{
+		func() error { return s.SetBaudRate(cfg.Baud) },
+		func() error { return s.SetLine(cfg.DataBits, cfg.Parity, cfg.StopBits) },
+		func() error { return s.SetFlowControl(cfg.Flow) },
+		func() error { return s.SetDTR(true) },
+		func() error { return s.SetRTS(true) },
+	} {
+		if err := f(); nil != err {
+			return err
+		}
+	}
+
+	uart.device.mode = ModeUART
+
+	return nil
+}
+
+// Close closes both the UART interface and the connection to the FT232H device.
+func (uart *UART) Close() error {
+	return uart.device.Close()
+}
+
+// SetReadTimeout sets the maximum duration Read waits for data to be received.
+// A zero duration waits indefinitely.
+func (uart *UART) SetReadTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("invalid read timeout: %s", d)
+	}
+	uart.config.Timeout = d
+	return nil
+}
+
+// Read reads up to len(p) bytes received on RXD into p, returning the number of
+// bytes read. If no data has been received, Read waits until data is available
+// or the configured read timeout expires, in which case it returns 0 and an
+// error wrapping ErrReadTimeout.
+func (uart *UART) Read(p []uint8) (int, error) {
+
+	s, err := uart.ready()
+	if nil != err {
+		return 0, err
+	}
+	if 0 == len(p) {
+		return 0, nil
+	}
+
+	timeout := uart.config.Timeout
+	deadline := time.Now().Add(timeout)
+	for {
+		n, err := s.Queue()
+		if nil != err {
+			return 0, err
+		}
+		if n > 0 {
+			if n > len(p) {
+				n = len(p)
+			}
+			return uart.device.info.port.Read(p[:n])
+		}
+		if 0 != timeout && !time.Now().Before(deadline) {
+			return 0, fmt.Errorf("%w: no data received in %s", ErrReadTimeout, timeout)
+		}
+		time.Sleep(uartPollInterval)
+	}
+}
+
+// Write transmits all of the bytes in p on TXD, returning the number of bytes
+// written and a non-nil error if unsuccessful.
+func (uart *UART) Write(p []uint8) (int, error) {
+	if _, err := uart.ready(); nil != err {
+		return 0, err
+	}
+	return uart.device.info.port.Write(p)
+}
+
+// SetDTR asserts (drives LOW, if on is true) or de-asserts the DTR# output.
+// The output is controlled by the FT232H while DTR/DSR flow control is enabled.
+func (uart *UART) SetDTR(on bool) error {
+	s, err := uart.ready()
+	if nil != err {
+		return err
+	}
+	return s.SetDTR(on)
+}
+
+// SetRTS asserts (drives LOW, if on is true) or de-asserts the RTS# output.
+// The output is controlled by the FT232H while RTS/CTS flow control is enabled.
+func (uart *UART) SetRTS(on bool) error {
+	s, err := uart.ready()
+	if nil != err {
+		return err
+	}
+	return s.SetRTS(on)
+}
+
+// ModemStatus returns the current state of the modem status inputs (CTS#, DSR#,
+// RI#, DCD#) and the line status of the FT232H.
+func (uart *UART) ModemStatus() (UARTStatus, error) {
+	s, err := uart.ready()
+	if nil != err {
+		return 0, err
+	}
+	return s.ModemStatus()
+}
+
+// SetBreak sets (if on is true) or clears the break condition, holding TXD LOW
+// until cleared.
+func (uart *UART) SetBreak(on bool) error {
+	s, err := uart.ready()
+	if nil != err {
+		return err
+	}
+	return s.SetBreak(on)
+}
+
+// Break generates a break condition on TXD for the given duration.
+func (uart *UART) Break(d time.Duration) error {
+	if err := uart.SetBreak(true); nil != err {
+		return err
+	}
+	time.Sleep(d)
+	return uart.SetBreak(false)
+}
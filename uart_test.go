@@ -0,0 +1,199 @@
+package ft232h
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// simEchoUART is a simulated serial device that echoes all received bytes,
+// with modem status inputs looped back from the modem control outputs.
+type simEchoUART struct {
+	dtr, rts bool
+	ring     bool
+	breaks   int
+}
+
+func (u *simEchoUART) Transmit(data []uint8) []uint8 { return append([]uint8{}, data...) }
+func (u *simEchoUART) Control(dtr bool, rts bool)    { u.dtr, u.rts = dtr, rts }
+
+func (u *simEchoUART) Status() UARTStatus {
+	var s UARTStatus
+	if u.rts {
+		s |= UARTStatusCTS
+	}
+	if u.dtr {
+		s |= UARTStatusDSR | UARTStatusDCD
+	}
+	if u.ring {
+		s |= UARTStatusRI
+	}
+	return s
+}
+
+func (u *simEchoUART) Break(on bool) {
+	if on {
+		u.breaks++
+	}
+}
+
+func TestUART(t *testing.T) {
+
+	echo := &simEchoUART{}
+	var dev *SimDevice
+	ft, err := OpenSim("uart", func(d *SimDevice) error {
+		dev = d
+		d.AttachUART(echo)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("OpenSim(): %v", err)
+	}
+	defer ft.Close()
+
+	if _, err := ft.UART.Write([]uint8{0x00}); nil == err {
+		t.Fatalf("Write(): expected error before Init")
+	}
+
+	cfg := &UARTConfig{
+		Baud:     9600,
+		DataBits: 7,
+		Parity:   UARTParityEven,
+		StopBits: UARTStopBits2,
+		Flow:     UARTFlowRTSCTS,
+		Timeout:  10 * time.Millisecond,
+	}
+	if err := ft.UART.Config(cfg); nil != err {
+		t.Fatalf("Config(): %v", err)
+	}
+	if ModeUART != ft.mode || BitModeReset != dev.mode {
+		t.Fatalf("Config()={%s, %d}, expected={%s, %d}", ft.mode, dev.mode, ModeUART, BitModeReset)
+	}
+	if 9600 != dev.baud || 7 != dev.dataBits || UARTParityEven != dev.parity ||
+		UARTStopBits2 != dev.stopBits || UARTFlowRTSCTS != dev.flow {
+		t.Fatalf("Config()={%d %d %s %s %s}, expected={%s}",
+			dev.baud, dev.dataBits, dev.parity, dev.stopBits, dev.flow, cfg)
+	}
+	exp := *cfg
+	exp.Latency = UARTLatencyDefault
+	if got := ft.UART.GetConfig(); exp != *got {
+		t.Fatalf("GetConfig()={%s}, expected={%s}", got, &exp)
+	}
+
+	t.Run("ReadWrite", func(t *testing.T) {
+		send := []uint8("hello, world")
+		if n, err := ft.UART.Write(send); nil != err || len(send) != n {
+			t.Fatalf("Write(): %d, %v", n, err)
+		}
+		recv := make([]uint8, 5)
+		if n, err := ft.UART.Read(recv); nil != err || 5 != n {
+			t.Fatalf("Read(): %d, %v", n, err)
+		}
+		rest := make([]uint8, 64)
+		n, err := ft.UART.Read(rest)
+		if nil != err {
+			t.Fatalf("Read(): %v", err)
+		}
+		if got := append(recv, rest[:n]...); !bytes.Equal(send, got) {
+			t.Fatalf("Read()={%q}, expected={%q}", got, send)
+		}
+		start := time.Now()
+		if n, err := ft.UART.Read(rest); !errors.Is(err, ErrReadTimeout) || 0 != n {
+			t.Fatalf("Read(): %d, %v, expected timeout", n, err)
+		}
+		if d := time.Since(start); d < cfg.Timeout {
+			t.Fatalf("Read(): returned after %s, expected >= %s", d, cfg.Timeout)
+		}
+	})
+
+	t.Run("Modem", func(t *testing.T) {
+		echo.ring = true
+		s, err := ft.UART.ModemStatus()
+		if nil != err {
+			t.Fatalf("ModemStatus(): %v", err)
+		}
+		exp := UARTStatusCTS | UARTStatusDSR | UARTStatusRI | UARTStatusDCD |
+			UARTStatusTHRE | UARTStatusTEMT
+		if exp != s {
+			t.Fatalf("ModemStatus()={%s}, expected={%s}", s, exp)
+		}
+		if err := ft.UART.SetRTS(false); nil != err {
+			t.Fatalf("SetRTS(): %v", err)
+		}
+		if err := ft.UART.SetDTR(false); nil != err {
+			t.Fatalf("SetDTR(): %v", err)
+		}
+		if s, err = ft.UART.ModemStatus(); nil != err {
+			t.Fatalf("ModemStatus(): %v", err)
+		}
+		if "{ RI THRE TEMT }" != s.String() {
+			t.Fatalf("String()={%s}, expected={%s}", s, "{ RI THRE TEMT }")
+		}
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		if err := ft.UART.Break(time.Millisecond); nil != err {
+			t.Fatalf("Break(): %v", err)
+		}
+		if 1 != echo.breaks || dev.brk {
+			t.Fatalf("Break()={%d, %t}, expected={%d, %t}", echo.breaks, dev.brk, 1, false)
+		}
+		if err := ft.UART.SetBreak(true); nil != err {
+			t.Fatalf("SetBreak(): %v", err)
+		}
+		if _, err := ft.UART.Write([]uint8{0x55}); nil != err {
+			t.Fatalf("Write(): %v", err)
+		}
+		if err := ft.UART.SetBreak(false); nil != err {
+			t.Fatalf("SetBreak(): %v", err)
+		}
+		if n, _ := dev.Queue(); 0 != n {
+			t.Fatalf("Queue()={%d}, expected={%d}", n, 0)
+		}
+	})
+
+	t.Run("Switch", func(t *testing.T) {
+		if 0xFF != ft.DGPIO.Reserved() {
+			t.Fatalf("Reserved()={0x%02X}, expected={0x%02X}", ft.DGPIO.Reserved(), 0xFF)
+		}
+		if err := ft.GPIO.Set(C(0), true); nil == err {
+			t.Fatalf("Set(): expected error in %s mode", ModeUART)
+		}
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		if err := ft.GPIO.Set(C(0), true); nil != err {
+			t.Fatalf("Set(): %v", err)
+		}
+		if _, err := ft.UART.Read(make([]uint8, 1)); nil == err {
+			t.Fatalf("Read(): expected error in %s mode", ModeSPI)
+		}
+		if err := ft.UART.Init(); nil != err || ModeUART != ft.mode {
+			t.Fatalf("Init(): %v", err)
+		}
+	})
+
+	for _, c := range []*UARTConfig{
+		{DataBits: 6},
+		{Parity: 5},
+		{StopBits: 1},
+		{Flow: 0x0400},
+		{Timeout: -time.Second},
+		{Baud: 13000000},
+	} {
+		if err := ft.UART.Config(c); nil == err {
+			t.Fatalf("Config(%s): expected error", c)
+		}
+	}
+
+	tp := &testTransport{port: &testPort{}}
+	ftp, err := OpenMask(&Mask{Transport: tp})
+	if nil != err {
+		t.Fatalf("OpenMask(): %v", err)
+	}
+	defer ftp.Close()
+	if err := ftp.UART.Init(); nil == err {
+		t.Fatalf("Init(): expected error for Port without PortSerial")
+	}
+}
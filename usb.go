@@ -0,0 +1,436 @@
+package ft232h
+
+import (
+	"fmt"
+	"time"
+)
+
+// Endpoint defines the raw USB operations required to communicate with an
+// FT232H without any vendor driver: control transfers on the default pipe and
+// bulk transfers on the device's IN and OUT endpoints. Implementations are
+// provided by a USBBus.
+type Endpoint interface {
+	// Control performs a control transfer with the given setup packet fields.
+	// Data is sent to the device if the direction bit (0x80) of reqType is
+	// clear, and is received from the device otherwise. Returns the number of
+	// data bytes transferred.
+	Control(reqType uint8, req uint8, value uint16, index uint16, data []uint8) (int, error)
+	// BulkOut writes data to the given OUT endpoint, returning the number of
+	// bytes written.
+	BulkOut(ep uint8, data []uint8) (int, error)
+	// BulkIn reads up to len(data) bytes from the given IN endpoint, waiting at
+	// most timeout for a packet to arrive. Returns 0 and nil error if no packet
+	// arrived before the timeout expired.
+	BulkIn(ep uint8, data []uint8, timeout time.Duration) (int, error)
+	// Close releases the device.
+	Close() error
+}
+
+// USBBus defines the methods required for enumerating and opening FT232H
+// devices as raw USB Endpoints.
+type USBBus interface {
+	// Devices returns a slice of DeviceInfo pointers describing all of the
+	// FT232H devices on the bus. Returns an empty slice and nil error if no
+	// devices were found.
+	Devices() ([]*DeviceInfo, error)
+	// Open opens the device enumerated at the given index.
+	Open(index int) (Endpoint, error)
+}
+
+// Constants defining the USB vendor and product ID of the FT232H.
+const (
+	USBVendorFTDI    uint32 = 0x0403 // FTDI vendor ID
+	USBProductFT232H uint32 = 0x6014 // FT232H product ID
+)
+
+// USB implements Transport natively in Go by issuing the FTDI vendor requests
+// and framing the bulk transfers of a device opened as a raw USB Endpoint,
+// without any dependency on FTDI's D2XX driver.
+type USB struct {
+	Bus         USBBus
+	ReadTimeout time.Duration
+}
+
+// Constants defining the default attributes of a USB transport.
+const (
+	USBReadTimeoutDefault = 5 * time.Second
+)
+
+// NewUSB constructs a new USB transport using the given bus to enumerate and
+// open devices.
+func NewUSB(bus USBBus) *USB {
+	return &USB{Bus: bus, ReadTimeout: USBReadTimeoutDefault}
+}
+
+// WithID implements TransportID, returning a copy of the receiver whose bus
+// enumerates the devices with the given USB vendor and product ID, if the bus
+// supports it (see USBFS.WithID). Otherwise, returns the receiver.
+func (u *USB) WithID(vid uint32, pid uint32) Transport {
+	if b, ok := u.Bus.(interface{ WithID(uint32, uint32) USBBus }); ok {
+		c := *u
+		c.Bus = b.WithID(vid, pid)
+		return &c
+	}
+	return u
+}
+
+// Devices returns a slice of DeviceInfo pointers describing all of the devices
+// on the receiver's bus.
+func (u *USB) Devices() ([]*DeviceInfo, error) {
+	return u.Bus.Devices()
+}
+
+// Open opens the device at the given index on the receiver's bus, returning a
+// nil Port and non-nil error if the device could not be opened.
+func (u *USB) Open(index int) (Port, error) {
+
+	info, err := u.Bus.Devices()
+	if nil != err {
+		return nil, err
+	}
+	if index < 0 || index >= len(info) {
+		return nil, SDeviceNotFound
+	}
+
+	ep, err := u.Bus.Open(index)
+	if nil != err {
+		return nil, err
+	}
+
+	packet := usbPacketSizeFull
+	if info[index].HiSpeed {
+		packet = usbPacketSizeHigh
+	}
+	return &usbPort{ep: ep, packet: packet, timeout: u.ReadTimeout, deflt: u.ReadTimeout}, nil
+}
+
+// Constants defining the FTDI USB protocol used by the FT232H.
+const (
+	usbRequestOut uint8 = 0x40 // vendor request, host to device
+	usbRequestIn  uint8 = 0xC0 // vendor request, device to host
+
+	usbEndpointOut uint8 = 0x02
+	usbEndpointIn  uint8 = 0x81
+
+	usbInterface uint16 = 1 // wIndex of the (only) channel A
+
+	usbPacketSizeHigh = 512 // bulk packet size of high-speed devices
+	usbPacketSizeFull = 64  // bulk packet size of full-speed devices
+	usbStatusSize     = 2   // modem status bytes at the start of each packet
+	usbReadPackets    = 32  // maximum packets requested per bulk read
+	usbWriteSize      = 16384
+)
+
+// Constants defining the FTDI vendor requests.
+const (
+	usbReqReset           uint8 = 0x00 // reset (wValue 0), purge RX (1), purge TX (2)
+	usbReqModemCtrl       uint8 = 0x01 // set DTR/RTS
+	usbReqFlowCtrl        uint8 = 0x02 // set flow control
+	usbReqBaudRate        uint8 = 0x03 // set baud rate divisor
+	usbReqData            uint8 = 0x04 // set data bits, parity, stop bits, break
+	usbReqPollModemStatus uint8 = 0x05 // read modem status
+	usbReqEventChar       uint8 = 0x06 // set event character
+	usbReqErrorChar       uint8 = 0x07 // set error character
+	usbReqSetLatency      uint8 = 0x09 // set latency timer
+	usbReqGetLatency      uint8 = 0x0A // get latency timer
+	usbReqSetBitMode      uint8 = 0x0B // set bit mode (wValue mode<<8 | mask)
+	usbReqReadPins        uint8 = 0x0C // read pins
+	usbReqReadEEPROM      uint8 = 0x90 // read EEPROM word
+	usbReqWriteEEPROM     uint8 = 0x91 // write EEPROM word
+	usbReqEraseEEPROM     uint8 = 0x92 // erase EEPROM
+
+	usbResetSIO     uint16 = 0
+	usbResetPurgeRX uint16 = 1
+	usbResetPurgeTX uint16 = 2
+)
+
+// usbPort implements Port for a device opened as a raw USB Endpoint.
+type usbPort struct {
+	ep      Endpoint
+	packet  int
+	timeout time.Duration
+	deflt   time.Duration // read timeout of the USB transport
+	buf     []uint8       // received data not yet read
+	status  [2]uint8      // modem status of the most recently received packet
+	line    uint16        // data characteristics (wValue of usbReqData)
+}
+
+// control issues the given FTDI vendor request to the receiver's device.
+func (p *usbPort) control(req uint8, value uint16) error {
+	_, err := p.ep.Control(usbRequestOut, req, value, usbInterface, nil)
+	return err
+}
+
+// controlIndex issues the given FTDI vendor request with the given wIndex,
+// whose low byte must be the interface number, to the receiver's device.
+func (p *usbPort) controlIndex(req uint8, value uint16, index uint16) error {
+	_, err := p.ep.Control(usbRequestOut, req, value, index, nil)
+	return err
+}
+
+// Close closes the device.
+func (p *usbPort) Close() error {
+	return p.ep.Close()
+}
+
+// Reset resets the device, discarding any data received but not yet read.
+func (p *usbPort) Reset() error {
+	p.buf = nil
+	return p.control(usbReqReset, usbResetSIO)
+}
+
+// Purge purges the device receive and transmit buffers, discarding any data
+// received but not yet read.
+func (p *usbPort) Purge() error {
+	p.buf = nil
+	if err := p.control(usbReqReset, usbResetPurgeRX); nil != err {
+		return err
+	}
+	return p.control(usbReqReset, usbResetPurgeTX)
+}
+
+// SetLatency sets the device latency timer in milliseconds.
+func (p *usbPort) SetLatency(ms uint8) error {
+	return p.control(usbReqSetLatency, uint16(ms))
+}
+
+// SetBitMode sets the device bit mode and pin mask.
+func (p *usbPort) SetBitMode(mask uint8, mode BitMode) error {
+	return p.control(usbReqSetBitMode, uint16(mode)<<8|uint16(mask))
+}
+
+// SetReadTimeout sets the read timeout, or restores the USB transport's
+// ReadTimeout if d is zero.
+func (p *usbPort) SetReadTimeout(d time.Duration) error {
+	if 0 == d {
+		d = p.deflt
+	}
+	p.timeout = d
+	return nil
+}
+
+// Write writes all of the given bytes to the bulk OUT endpoint.
+func (p *usbPort) Write(data []uint8) (int, error) {
+	var n int
+	for n < len(data) {
+		end := n + usbWriteSize
+		if end > len(data) {
+			end = len(data)
+		}
+		w, err := p.ep.BulkOut(usbEndpointOut, data[n:end])
+		n += w
+		if nil != err {
+			return n, err
+		}
+		if 0 == w {
+			return n, fmt.Errorf("write stalled: %d of %d bytes", n, len(data))
+		}
+	}
+	return n, nil
+}
+
+// Read reads exactly len(data) bytes from the bulk IN endpoint. The device
+// prefixes each packet with 2 modem status bytes, which are removed. Packets
+// containing only the status bytes carry no data, and the endpoint is polled
+// until enough data has been received or the read timeout expires.
+func (p *usbPort) Read(data []uint8) (int, error) {
+
+	deadline := time.Now().Add(p.timeout)
+	raw := make([]uint8, usbReadPackets*p.packet)
+
+	for len(p.buf) < len(data) {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			n := copy(data, p.buf)
+			p.buf = p.buf[n:]
+			return n, fmt.Errorf("%w: %d of %d bytes", ErrReadTimeout, n, len(data))
+		}
+		r, err := p.ep.BulkIn(usbEndpointIn, raw, wait)
+		if nil != err {
+			return 0, err
+		}
+		p.unframe(raw[:r])
+	}
+
+	n := copy(data, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+// unframe removes the modem status bytes from each packet in the given bulk
+// transfer, appending the remaining data to the receiver's buffer.
+func (p *usbPort) unframe(raw []uint8) {
+	for len(raw) > 0 {
+		n := p.packet
+		if n > len(raw) {
+			n = len(raw)
+		}
+		if n >= usbStatusSize {
+			copy(p.status[:], raw[:usbStatusSize])
+			p.buf = append(p.buf, raw[usbStatusSize:n]...)
+		}
+		raw = raw[n:]
+	}
+}
+
+// Constants related to the baud rate generator of the FT232H.
+const (
+	usbClockHigh   = 120000000 // 120 MHz base clock, divided by 10
+	usbClockCompat = 48000000  // 48 MHz base clock, divided by 16 (FT232R compatible)
+	usbBaudClock10 = 0x20000   // encoded divisor bit selecting the 120 MHz clock
+	usbBaudMaxDiv  = 0x1FFFF   // maximum 14.3 fixed-point divisor
+	usbBaudError   = 20        // maximum deviation (1/n) of the actual baud rate
+	usbQueueWait   = time.Millisecond
+)
+
+// usbBaudDivisor returns the wValue and wIndex of the usbReqBaudRate request
+// for the given baud rate, along with the actual baud rate produced. Returns a
+// non-nil error if the baud rate cannot be produced within 5%.
+func usbBaudDivisor(baud uint32) (value uint16, index uint16, actual uint32, err error) {
+
+	if 0 == baud {
+		return 0, 0, 0, fmt.Errorf("invalid baud rate: %d", baud)
+	}
+
+	var enc uint32
+	if uint64(baud)*10 > usbClockHigh/0x3FFF {
+		actual, enc = usbClockBits(baud, usbClockHigh, 10)
+		enc |= usbBaudClock10
+	} else {
+		actual, enc = usbClockBits(baud, usbClockCompat, 16)
+	}
+
+	if (actual < baud && uint64(actual)*usbBaudError < uint64(baud)*(usbBaudError-1)) ||
+		(actual > baud && uint64(baud)*(usbBaudError+1) < uint64(actual)*usbBaudError) {
+		return 0, 0, 0, fmt.Errorf("unsupported baud rate: %d (nearest %d)", baud, actual)
+	}
+
+	return uint16(enc), uint16(enc>>8)&0xFF00 | usbInterface, actual, nil
+}
+
+// usbClockBits returns the baud rate nearest the given baud rate that can be
+// derived from the given base clock and prescaler, along with its encoded
+// divisor. The divisor is a 14-bit integer with a 3-bit fractional part, whose
+// fraction is encoded in bits 14-16.
+func usbClockBits(baud uint32, clock uint64, prescale uint64) (uint32, uint32) {
+
+	frac := [8]uint32{0, 3, 2, 4, 1, 5, 6, 7}
+	base := clock / prescale
+
+	switch b := uint64(baud); {
+	case b >= base:
+		return uint32(base), 0
+	case b >= clock/(prescale+prescale/2):
+		return uint32(clock / (prescale + prescale/2)), 1
+	case b >= clock/(2*prescale):
+		return uint32(clock / (2 * prescale)), 2
+	}
+
+	// the divisor is computed with one extra fractional bit for rounding
+	div := (base*16/uint64(baud) + 1) / 2
+	if div > usbBaudMaxDiv {
+		div = usbBaudMaxDiv
+	}
+	actual := (base*16/div + 1) / 2
+
+	return uint32(actual), uint32(div>>3) | frac[div&7]<<14
+}
+
+// SetBaudRate sets the baud rate of the device in UART mode.
+func (p *usbPort) SetBaudRate(baud uint32) error {
+	value, index, _, err := usbBaudDivisor(baud)
+	if nil != err {
+		return err
+	}
+	return p.controlIndex(usbReqBaudRate, value, index)
+}
+
+// SetLine sets the number of data bits, parity, and number of stop bits of the
+// device in UART mode.
+func (p *usbPort) SetLine(bits uint8, parity UARTParity, stop UARTStopBits) error {
+	p.line = uint16(bits) | uint16(parity)<<8 | uint16(stop)<<11
+	return p.control(usbReqData, p.line)
+}
+
+// SetFlowControl sets the hardware flow control of the device in UART mode.
+func (p *usbPort) SetFlowControl(flow UARTFlow) error {
+	return p.controlIndex(usbReqFlowCtrl, 0, uint16(flow)|usbInterface)
+}
+
+// SetBreak sets (if on is true) or clears the break condition on TXD, retaining
+// the data characteristics set with SetLine.
+func (p *usbPort) SetBreak(on bool) error {
+	const brk = 1 << 14
+	value := p.line &^ brk
+	if on {
+		value |= brk
+	}
+	return p.control(usbReqData, value)
+}
+
+// SetDTR asserts (if on is true) or de-asserts the DTR# output.
+func (p *usbPort) SetDTR(on bool) error {
+	if on {
+		return p.control(usbReqModemCtrl, 0x0101)
+	}
+	return p.control(usbReqModemCtrl, 0x0100)
+}
+
+// SetRTS asserts (if on is true) or de-asserts the RTS# output.
+func (p *usbPort) SetRTS(on bool) error {
+	if on {
+		return p.control(usbReqModemCtrl, 0x0202)
+	}
+	return p.control(usbReqModemCtrl, 0x0200)
+}
+
+// ModemStatus polls the modem status (low byte) and line status (high byte) of
+// the device.
+func (p *usbPort) ModemStatus() (UARTStatus, error) {
+	var b [2]uint8
+	n, err := p.ep.Control(usbRequestIn, usbReqPollModemStatus, 0, usbInterface, b[:])
+	if nil != err {
+		return 0, err
+	}
+	if n < len(b) {
+		return 0, fmt.Errorf("short modem status: %d of %d bytes", n, len(b))
+	}
+	return UARTStatus(b[0]) | UARTStatus(b[1])<<8, nil
+}
+
+// ReadEEPROM reads the configuration EEPROM word at the given word address.
+func (p *usbPort) ReadEEPROM(addr uint8) (uint16, error) {
+	var b [2]uint8
+	n, err := p.ep.Control(usbRequestIn, usbReqReadEEPROM, 0, uint16(addr), b[:])
+	if nil != err {
+		return 0, err
+	}
+	if n < len(b) {
+		return 0, fmt.Errorf("short EEPROM read: %d of %d bytes", n, len(b))
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, nil
+}
+
+// WriteEEPROM writes the configuration EEPROM word at the given word address.
+func (p *usbPort) WriteEEPROM(addr uint8, value uint16) error {
+	return p.controlIndex(usbReqWriteEEPROM, value, uint16(addr))
+}
+
+// EraseEEPROM erases the entire configuration EEPROM.
+func (p *usbPort) EraseEEPROM() error {
+	return p.controlIndex(usbReqEraseEEPROM, 0, 0)
+}
+
+// Queue returns the number of received bytes that can be read without waiting.
+// If none have been received, the bulk IN endpoint is polled once.
+func (p *usbPort) Queue() (int, error) {
+	if 0 == len(p.buf) {
+		raw := make([]uint8, usbReadPackets*p.packet)
+		r, err := p.ep.BulkIn(usbEndpointIn, raw, usbQueueWait)
+		if nil != err {
+			return 0, err
+		}
+		p.unframe(raw[:r])
+	}
+	return len(p.buf), nil
+}
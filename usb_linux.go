@@ -0,0 +1,303 @@
+package ft232h
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// USBFS implements USBBus using the Linux usbfs interface (/dev/bus/usb), which
+// requires no cgo and no vendor driver. Devices are enumerated from sysfs, and
+// the ftdi_sio kernel driver is detached from a device when it is opened. The
+// user must have read/write permission on the device node (e.g., via a udev
+// rule).
+// Only the devices with the given USB vendor and product ID are enumerated, or
+// USBVendorFTDI and USBProductFT232H if zero, respectively.
+type USBFS struct {
+	VID uint32
+	PID uint32
+}
+
+func init() {
+	// prefer the D2XX bridge if it is available (i.e., built with cgo)
+	if nil == DefaultTransport {
+		DefaultTransport = NewUSB(USBFS{})
+	}
+}
+
+// Constants defining the sysfs and usbfs locations of USB devices.
+const (
+	usbfsSysPath = "/sys/bus/usb/devices"
+	usbfsDevPath = "/dev/bus/usb"
+)
+
+// usbfsDevice is a USB device enumerated from sysfs.
+type usbfsDevice struct {
+	info *DeviceInfo
+	bus  int
+	dev  int
+}
+
+// Variables defining the usbfs ioctl request codes (see linux/usbdevice_fs.h).
+var (
+	usbdevfsControl          = usbfsIOWR('U', 0, unsafe.Sizeof(usbfsCtrlTransfer{}))
+	usbdevfsBulk             = usbfsIOWR('U', 2, unsafe.Sizeof(usbfsBulkTransfer{}))
+	usbdevfsClaimInterface   = usbfsIOR('U', 15, unsafe.Sizeof(uint32(0)))
+	usbdevfsReleaseInterface = usbfsIOR('U', 16, unsafe.Sizeof(uint32(0)))
+	usbdevfsIoctl            = usbfsIOWR('U', 18, unsafe.Sizeof(usbfsIoctl{}))
+	usbdevfsDisconnect       = usbfsIO('U', 22)
+)
+
+// usbfsCtrlTransfer is struct usbdevfs_ctrltransfer.
+type usbfsCtrlTransfer struct {
+	reqType uint8
+	req     uint8
+	value   uint16
+	index   uint16
+	length  uint16
+	timeout uint32 // milliseconds
+	data    unsafe.Pointer
+}
+
+// usbfsBulkTransfer is struct usbdevfs_bulktransfer.
+type usbfsBulkTransfer struct {
+	ep      uint32
+	length  uint32
+	timeout uint32 // milliseconds
+	data    unsafe.Pointer
+}
+
+// usbfsIoctl is struct usbdevfs_ioctl.
+type usbfsIoctl struct {
+	ifno int32
+	code int32
+	data unsafe.Pointer
+}
+
+// usbfsIOC returns the ioctl request code with the given direction, type,
+// number, and argument size (see asm-generic/ioctl.h).
+func usbfsIOC(dir uintptr, typ uintptr, nr uintptr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | typ<<8 | nr
+}
+func usbfsIO(typ uintptr, nr uintptr) uintptr { return usbfsIOC(0, typ, nr, 0) }
+func usbfsIOR(typ uintptr, nr uintptr, size uintptr) uintptr {
+	return usbfsIOC(2, typ, nr, size)
+}
+func usbfsIOWR(typ uintptr, nr uintptr, size uintptr) uintptr {
+	return usbfsIOC(3, typ, nr, size)
+}
+
+// WithID returns a USBFS enumerating the devices with the given USB vendor and
+// product ID, or USBVendorFTDI and USBProductFT232H if zero, respectively.
+func (USBFS) WithID(vid uint32, pid uint32) USBBus {
+	return USBFS{VID: vid, PID: pid}
+}
+
+// id returns the USB vendor and product ID of the devices enumerated by the
+// receiver.
+func (u USBFS) id() (uint32, uint32) {
+	vid, pid := u.VID, u.PID
+	if 0 == vid {
+		vid = USBVendorFTDI
+	}
+	if 0 == pid {
+		pid = USBProductFT232H
+	}
+	return vid, pid
+}
+
+// scan returns all FT232H devices enumerated in sysfs with the receiver's USB
+// vendor and product ID, sorted by path.
+func (u USBFS) scan() ([]*usbfsDevice, error) {
+
+	path, err := filepath.Glob(filepath.Join(usbfsSysPath, "*", "idVendor"))
+	if nil != err {
+		return nil, err
+	}
+
+	attr := func(dir string, name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if nil != err {
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+	hex := func(dir string, name string) uint32 {
+		v, _ := strconv.ParseUint(attr(dir, name), 16, 16)
+		return uint32(v)
+	}
+	dec := func(dir string, name string) int {
+		v, _ := strconv.Atoi(attr(dir, name))
+		return v
+	}
+
+	id, product := u.id()
+
+	dev := []*usbfsDevice{}
+	for _, p := range path {
+		dir := filepath.Dir(p)
+		vid, pid := hex(dir, "idVendor"), hex(dir, "idProduct")
+		if id != vid || product != pid {
+			continue
+		}
+		d := &usbfsDevice{bus: dec(dir, "busnum"), dev: dec(dir, "devnum")}
+		d.info = &DeviceInfo{
+			Index:   len(dev),
+			HiSpeed: "480" == attr(dir, "speed"),
+			Chip:    CFT232H,
+			VID:     vid,
+			PID:     pid,
+			LocID:   uint32(d.bus<<8 | d.dev),
+			Serial:  attr(dir, "serial"),
+			Desc:    attr(dir, "product"),
+		}
+		dev = append(dev, d)
+	}
+	return dev, nil
+}
+
+// Devices returns a slice of DeviceInfo pointers describing all of the FT232H
+// devices enumerated in sysfs. The LocID of each device is its bus number
+// (bits 15:8) and device number (bits 7:0).
+func (u USBFS) Devices() ([]*DeviceInfo, error) {
+	dev, err := u.scan()
+	if nil != err {
+		return nil, err
+	}
+	info := make([]*DeviceInfo, len(dev))
+	for i, d := range dev {
+		info[i] = d.info
+	}
+	return info, nil
+}
+
+// Open opens the usbfs device node of the FT232H at the given index, detaches
+// any kernel driver, and claims its interface.
+func (u USBFS) Open(index int) (Endpoint, error) {
+
+	dev, err := u.scan()
+	if nil != err {
+		return nil, err
+	}
+	if index < 0 || index >= len(dev) {
+		return nil, SDeviceNotFound
+	}
+
+	name := filepath.Join(usbfsDevPath,
+		fmt.Sprintf("%03d", dev[index].bus), fmt.Sprintf("%03d", dev[index].dev))
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if nil != err {
+		return nil, err
+	}
+
+	ep := &usbfsEndpoint{file: f}
+
+	// detaching fails with ENODATA if no driver is bound, which is ignored
+	ctl := usbfsIoctl{ifno: 0, code: int32(usbdevfsDisconnect)}
+	_ = ep.ioctl(usbdevfsIoctl, unsafe.Pointer(&ctl))
+
+	ifno := uint32(0)
+	if err := ep.ioctl(usbdevfsClaimInterface, unsafe.Pointer(&ifno)); nil != err {
+		f.Close()
+		return nil, fmt.Errorf("failed to claim interface: %v", err)
+	}
+	return ep, nil
+}
+
+// usbfsEndpoint implements Endpoint for an opened usbfs device node.
+type usbfsEndpoint struct {
+	file *os.File
+}
+
+// ioctlN issues the given usbfs request with the given argument, returning the
+// (non-negative) result and a non-nil error if unsuccessful.
+func (e *usbfsEndpoint) ioctlN(req uintptr, arg unsafe.Pointer) (int, error) {
+	for {
+		r, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
+			e.file.Fd(), req, uintptr(arg))
+		if syscall.EINTR == errno {
+			continue
+		}
+		if 0 != errno {
+			return 0, errno
+		}
+		return int(r), nil
+	}
+}
+
+// ioctl issues the given usbfs request with the given argument, returning a
+// non-nil error if unsuccessful.
+func (e *usbfsEndpoint) ioctl(req uintptr, arg unsafe.Pointer) error {
+	_, err := e.ioctlN(req, arg)
+	return err
+}
+
+// usbfsTimeout is the timeout (ms) used for control and bulk OUT transfers.
+const usbfsTimeout = 5000
+
+// Control performs a control transfer on the default pipe.
+func (e *usbfsEndpoint) Control(reqType uint8, req uint8, value uint16, index uint16, data []uint8) (int, error) {
+	xfer := usbfsCtrlTransfer{
+		reqType: reqType,
+		req:     req,
+		value:   value,
+		index:   index,
+		length:  uint16(len(data)),
+		timeout: usbfsTimeout,
+	}
+	if len(data) > 0 {
+		xfer.data = unsafe.Pointer(&data[0])
+	}
+	n, err := e.ioctlN(usbdevfsControl, unsafe.Pointer(&xfer))
+	runtime.KeepAlive(data)
+	return n, err
+}
+
+// bulk performs a bulk transfer on the given endpoint.
+func (e *usbfsEndpoint) bulk(ep uint8, data []uint8, timeout uint32) (int, error) {
+	if 0 == len(data) {
+		return 0, nil
+	}
+	xfer := usbfsBulkTransfer{
+		ep:      uint32(ep),
+		length:  uint32(len(data)),
+		timeout: timeout,
+		data:    unsafe.Pointer(&data[0]),
+	}
+	n, err := e.ioctlN(usbdevfsBulk, unsafe.Pointer(&xfer))
+	runtime.KeepAlive(data)
+	return n, err
+}
+
+// BulkOut writes data to the given OUT endpoint.
+func (e *usbfsEndpoint) BulkOut(ep uint8, data []uint8) (int, error) {
+	return e.bulk(ep, data, usbfsTimeout)
+}
+
+// BulkIn reads up to len(data) bytes from the given IN endpoint, returning 0
+// and nil error if the timeout expires.
+func (e *usbfsEndpoint) BulkIn(ep uint8, data []uint8, timeout time.Duration) (int, error) {
+	ms := uint32(timeout / time.Millisecond)
+	if 0 == ms {
+		ms = 1
+	}
+	n, err := e.bulk(ep, data, ms)
+	if syscall.ETIMEDOUT == err {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Close releases the claimed interface and closes the device node.
+func (e *usbfsEndpoint) Close() error {
+	ifno := uint32(0)
+	_ = e.ioctl(usbdevfsReleaseInterface, unsafe.Pointer(&ifno))
+	return e.file.Close()
+}
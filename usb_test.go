@@ -0,0 +1,405 @@
+package ft232h
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testControl is a control request received by a test Endpoint.
+type testControl struct {
+	reqType uint8
+	req     uint8
+	value   uint16
+	index   uint16
+}
+
+// loopEndpoint implements Endpoint by returning all bytes written to its OUT
+// endpoint on its IN endpoint, framed into packets prefixed with modem status.
+// Every other bulk IN transfer returns a packet containing only modem status.
+type loopEndpoint struct {
+	packet int
+	open   bool
+	ctrl   []testControl
+	data   []uint8
+	empty  bool
+}
+
+func (e *loopEndpoint) Control(reqType uint8, req uint8, value uint16, index uint16, data []uint8) (int, error) {
+	e.ctrl = append(e.ctrl, testControl{reqType, req, value, index})
+	return len(data), nil
+}
+
+func (e *loopEndpoint) BulkOut(ep uint8, data []uint8) (int, error) {
+	e.data = append(e.data, data...)
+	return len(data), nil
+}
+
+func (e *loopEndpoint) BulkIn(ep uint8, data []uint8, timeout time.Duration) (int, error) {
+	if e.empty = !e.empty; e.empty {
+		return copy(data, []uint8{0x32, 0x60}), nil
+	}
+	return frame(data, &e.data, e.packet), nil
+}
+
+func (e *loopEndpoint) Close() error { e.open = false; return nil }
+
+// frame moves bytes from src into packets of the given size in dst, each
+// prefixed with 2 modem status bytes, returning the number of bytes in dst.
+func frame(dst []uint8, src *[]uint8, packet int) int {
+	var n int
+	for len(dst)-n >= packet && len(*src) > 0 {
+		p := copy(dst[n+2:n+packet], *src)
+		dst[n], dst[n+1] = 0x32, 0x60
+		*src = (*src)[p:]
+		n += p + 2
+		if p < packet-2 {
+			break // short packet ends the transfer
+		}
+	}
+	return n
+}
+
+// testUSBBus implements USBBus with a single device, enumerated only if it has
+// the bus's USB vendor and product ID (see WithID).
+type testUSBBus struct {
+	ep      Endpoint
+	hiSpeed bool
+	vid     uint32 // USB IDs of the device, or 0 for USBVendorFTDI
+	pid     uint32 // and USBProductFT232H
+	match   bool   // enumerate devices with the device's USB IDs
+}
+
+func (b *testUSBBus) WithID(vid uint32, pid uint32) USBBus {
+	c := *b
+	c.match = (0 == vid || vid == b.vid) && (0 == pid || pid == b.pid)
+	return &c
+}
+
+func (b *testUSBBus) Devices() ([]*DeviceInfo, error) {
+	if 0 != b.vid && !b.match {
+		return []*DeviceInfo{}, nil
+	}
+	vid, pid := b.vid, b.pid
+	if 0 == vid {
+		vid, pid = USBVendorFTDI, USBProductFT232H
+	}
+	return []*DeviceInfo{
+		{Index: 0, HiSpeed: b.hiSpeed, Chip: CFT232H, VID: vid, PID: pid},
+	}, nil
+}
+
+func (b *testUSBBus) Open(index int) (Endpoint, error) {
+	if 0 != index {
+		return nil, SDeviceNotFound
+	}
+	return b.ep, nil
+}
+
+// simEndpoint implements Endpoint by translating FTDI vendor requests and bulk
+// transfers to the Port methods of a simulated FT232H.
+type simEndpoint struct {
+	dev    *SimDevice
+	packet int
+}
+
+func (e *simEndpoint) Control(reqType uint8, req uint8, value uint16, index uint16, data []uint8) (int, error) {
+	switch req {
+	case usbReqReset:
+		if usbResetSIO == value {
+			return 0, e.dev.Reset()
+		}
+		return 0, e.dev.Purge()
+	case usbReqSetLatency:
+		return 0, e.dev.SetLatency(uint8(value))
+	case usbReqSetBitMode:
+		return 0, e.dev.SetBitMode(uint8(value), BitMode(value>>8))
+	case usbReqReadEEPROM:
+		w, err := e.dev.ReadEEPROM(uint8(index))
+		return copy(data, []uint8{uint8(w), uint8(w >> 8)}), err
+	case usbReqWriteEEPROM:
+		return 0, e.dev.WriteEEPROM(uint8(index), value)
+	case usbReqEraseEEPROM:
+		return 0, e.dev.EraseEEPROM()
+	}
+	return 0, SNotSupported
+}
+
+func (e *simEndpoint) BulkOut(ep uint8, data []uint8) (int, error) {
+	return e.dev.Write(data)
+}
+
+func (e *simEndpoint) BulkIn(ep uint8, data []uint8, timeout time.Duration) (int, error) {
+	return frame(data, &e.dev.resp, e.packet), nil
+}
+
+func (e *simEndpoint) Close() error { return e.dev.Close() }
+
+func TestUSB(t *testing.T) {
+
+	t.Run("Control", func(t *testing.T) {
+		ep := &loopEndpoint{packet: usbPacketSizeHigh, open: true}
+		port, err := NewUSB(&testUSBBus{ep: ep, hiSpeed: true}).Open(0)
+		if nil != err {
+			t.Fatalf("Open(): %v", err)
+		}
+		for _, err := range []error{
+			port.Reset(),
+			port.Purge(),
+			port.SetLatency(16),
+			port.SetBitMode(0x0B, BitModeMPSSE),
+		} {
+			if nil != err {
+				t.Fatalf("control: %v", err)
+			}
+		}
+		exp := []testControl{
+			{0x40, 0x00, 0x0000, 1},
+			{0x40, 0x00, 0x0001, 1},
+			{0x40, 0x00, 0x0002, 1},
+			{0x40, 0x09, 0x0010, 1},
+			{0x40, 0x0B, 0x020B, 1},
+		}
+		if len(exp) != len(ep.ctrl) {
+			t.Fatalf("requests={%d}, expected={%d}", len(ep.ctrl), len(exp))
+		}
+		for i, c := range exp {
+			if c != ep.ctrl[i] {
+				t.Fatalf("request[%d]={%+v}, expected={%+v}", i, ep.ctrl[i], c)
+			}
+		}
+		if err := port.Close(); nil != err || ep.open {
+			t.Fatalf("Close(): %v", err)
+		}
+	})
+
+	for _, test := range []struct {
+		name    string
+		packet  int
+		hiSpeed bool
+		size    int
+	}{
+		{"high-speed-short", usbPacketSizeHigh, true, 3},
+		{"high-speed-packets", usbPacketSizeHigh, true, 3 * (usbPacketSizeHigh - 2)},
+		{"high-speed-long", usbPacketSizeHigh, true, 40000},
+		{"full-speed", usbPacketSizeFull, false, 1000},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ep := &loopEndpoint{packet: test.packet}
+			port, err := NewUSB(&testUSBBus{ep: ep, hiSpeed: test.hiSpeed}).Open(0)
+			if nil != err {
+				t.Fatalf("Open(): %v", err)
+			}
+			send := make([]uint8, test.size)
+			for i := range send {
+				send[i] = uint8(i * 7)
+			}
+			if n, err := port.Write(send); nil != err || n != len(send) {
+				t.Fatalf("Write(): %d, %v", n, err)
+			}
+			recv := make([]uint8, test.size)
+			if n, err := port.Read(recv); nil != err || n != len(recv) {
+				t.Fatalf("Read(): %d, %v", n, err)
+			}
+			if !bytes.Equal(send, recv) {
+				t.Fatalf("Read()={% X}, expected={% X}", recv, send)
+			}
+		})
+	}
+
+	t.Run("Timeout", func(t *testing.T) {
+		ep := &loopEndpoint{packet: usbPacketSizeHigh}
+		usb := NewUSB(&testUSBBus{ep: ep, hiSpeed: true})
+		usb.ReadTimeout = 10 * time.Millisecond
+		port, err := usb.Open(0)
+		if nil != err {
+			t.Fatalf("Open(): %v", err)
+		}
+		if _, err := port.Write([]uint8{0x01}); nil != err {
+			t.Fatalf("Write(): %v", err)
+		}
+		recv := make([]uint8, 2)
+		if n, err := port.Read(recv); !errors.Is(err, ErrReadTimeout) || 1 != n {
+			t.Fatalf("Read(): %d, %v, expected timeout", n, err)
+		}
+		pt, ok := port.(PortTimeout)
+		if !ok {
+			t.Fatalf("Open(): expected Port to implement PortTimeout")
+		}
+		for _, d := range []time.Duration{time.Millisecond, 0} {
+			if err := pt.SetReadTimeout(d); nil != err {
+				t.Fatalf("SetReadTimeout(): %v", err)
+			}
+			exp := d
+			if 0 == d {
+				exp = usb.ReadTimeout
+			}
+			if got := port.(*usbPort).timeout; exp != got {
+				t.Fatalf("SetReadTimeout(%v)={%v}, expected={%v}", d, got, exp)
+			}
+		}
+	})
+
+	t.Run("Serial", func(t *testing.T) {
+		ep := &loopEndpoint{packet: usbPacketSizeHigh, open: true}
+		port, err := NewUSB(&testUSBBus{ep: ep, hiSpeed: true}).Open(0)
+		if nil != err {
+			t.Fatalf("Open(): %v", err)
+		}
+		ser, ok := port.(PortSerial)
+		if !ok {
+			t.Fatalf("Open(): expected Port to implement PortSerial")
+		}
+		for _, baud := range []uint32{12000000, 115200, 9600, 300} {
+			if err := ser.SetBaudRate(baud); nil != err {
+				t.Fatalf("SetBaudRate(%d): %v", baud, err)
+			}
+		}
+		for _, baud := range []uint32{0, 13000000, 100} {
+			if err := ser.SetBaudRate(baud); nil == err {
+				t.Fatalf("SetBaudRate(%d): expected error", baud)
+			}
+		}
+		for _, err := range []error{
+			ser.SetLine(7, UARTParityEven, UARTStopBits2),
+			ser.SetBreak(true),
+			ser.SetBreak(false),
+			ser.SetFlowControl(UARTFlowRTSCTS),
+			ser.SetDTR(true),
+			ser.SetRTS(false),
+		} {
+			if nil != err {
+				t.Fatalf("control: %v", err)
+			}
+		}
+		if _, err := ser.ModemStatus(); nil != err {
+			t.Fatalf("ModemStatus(): %v", err)
+		}
+		exp := []testControl{
+			{0x40, 0x03, 0x0000, 0x0201},
+			{0x40, 0x03, 0xC068, 0x0201},
+			{0x40, 0x03, 0x04E2, 0x0201},
+			{0x40, 0x03, 0x2710, 0x0001},
+			{0x40, 0x04, 0x1207, 1},
+			{0x40, 0x04, 0x5207, 1},
+			{0x40, 0x04, 0x1207, 1},
+			{0x40, 0x02, 0x0000, 0x0101},
+			{0x40, 0x01, 0x0101, 1},
+			{0x40, 0x01, 0x0200, 1},
+			{0xC0, 0x05, 0x0000, 1},
+		}
+		if len(exp) != len(ep.ctrl) {
+			t.Fatalf("requests={%d}, expected={%d}", len(ep.ctrl), len(exp))
+		}
+		for i, c := range exp {
+			if c != ep.ctrl[i] {
+				t.Fatalf("request[%d]={%+v}, expected={%+v}", i, ep.ctrl[i], c)
+			}
+		}
+		if _, err := port.Write([]uint8{0x55, 0xAA}); nil != err {
+			t.Fatalf("Write(): %v", err)
+		}
+		var n int
+		for i := 0; i < 2 && 0 == n; i++ { // first poll returns status only
+			if n, err = ser.Queue(); nil != err {
+				t.Fatalf("Queue(): %v", err)
+			}
+		}
+		if 2 != n {
+			t.Fatalf("Queue()={%d}, expected={%d}", n, 2)
+		}
+	})
+
+	t.Run("EEPROM", func(t *testing.T) {
+		ep := &loopEndpoint{packet: usbPacketSizeHigh, open: true}
+		port, err := NewUSB(&testUSBBus{ep: ep, hiSpeed: true}).Open(0)
+		if nil != err {
+			t.Fatalf("Open(): %v", err)
+		}
+		ee, ok := port.(PortEEPROM)
+		if !ok {
+			t.Fatalf("Open(): expected Port to implement PortEEPROM")
+		}
+		if _, err := ee.ReadEEPROM(0x7F); nil != err {
+			t.Fatalf("ReadEEPROM(): %v", err)
+		}
+		if err := ee.WriteEEPROM(0x12, 0xBEEF); nil != err {
+			t.Fatalf("WriteEEPROM(): %v", err)
+		}
+		if err := ee.EraseEEPROM(); nil != err {
+			t.Fatalf("EraseEEPROM(): %v", err)
+		}
+		exp := []testControl{
+			{0xC0, 0x90, 0x0000, 0x007F},
+			{0x40, 0x91, 0xBEEF, 0x0012},
+			{0x40, 0x92, 0x0000, 0x0000},
+		}
+		if len(exp) != len(ep.ctrl) {
+			t.Fatalf("requests={%d}, expected={%d}", len(ep.ctrl), len(exp))
+		}
+		for i, c := range exp {
+			if c != ep.ctrl[i] {
+				t.Fatalf("request[%d]={%+v}, expected={%+v}", i, ep.ctrl[i], c)
+			}
+		}
+	})
+
+	t.Run("Sim", func(t *testing.T) {
+		sim := NewSim()
+		dev := sim.Add(0, 0, "USB0", "usb")
+		slave := &simRegSPI{}
+		if err := dev.AttachSPI(D(3), slave); nil != err {
+			t.Fatalf("AttachSPI(): %v", err)
+		}
+		if _, err := sim.Open(0); nil != err {
+			t.Fatalf("Sim.Open(): %v", err)
+		}
+		ep := &simEndpoint{dev: dev, packet: usbPacketSizeFull}
+
+		// devices with custom USB IDs are only enumerated when given in the mask
+		bus := &testUSBBus{ep: ep, vid: 0x1209, pid: 0x0001}
+		if _, err := OpenMask(&Mask{Transport: NewUSB(bus)}); SDeviceNotFound != err {
+			t.Fatalf("OpenMask()={%v}, expected={%v}", err, SDeviceNotFound)
+		}
+		ft, err := OpenMask(&Mask{VID: "0x1209", PID: "0x0001", Transport: NewUSB(bus)})
+		if nil != err {
+			t.Fatalf("OpenMask(): %v", err)
+		}
+		defer ft.Close()
+		if 0x1209 != ft.VID() || 0x0001 != ft.PID() {
+			t.Fatalf("OpenMask()={%04X:%04X}, expected={%04X:%04X}", ft.VID(), ft.PID(), 0x1209, 0x0001)
+		}
+
+		if err := ft.SPI.Init(); nil != err {
+			t.Fatalf("SPI.Init(): %v", err)
+		}
+		data := make([]uint8, 100)
+		for i := range data {
+			data[i] = uint8(0xFF - i)
+		}
+		if _, err := ft.SPI.Write(append([]uint8{0x00}, data...), true, true); nil != err {
+			t.Fatalf("SPI.Write(): %v", err)
+		}
+		rd, err := ft.SPI.Swap(append([]uint8{0x80}, make([]uint8, len(data))...), true, true)
+		if nil != err {
+			t.Fatalf("SPI.Swap(): %v", err)
+		}
+		if !bytes.Equal(data, rd[1:]) {
+			t.Fatalf("SPI.Swap()={% X}, expected={% X}", rd[1:], data)
+		}
+
+		cfg := EEPROMConfigDefault()
+		cfg.Serial, cfg.SerialEnable = "USB0", true
+		if err := ft.SetEEPROMConfig(cfg); nil != err {
+			t.Fatalf("SetEEPROMConfig(): %v", err)
+		}
+		if 0x0403 != dev.eeprom[1] || 0x6014 != dev.eeprom[2] {
+			t.Fatalf("eeprom={%04X %04X}, expected={%04X %04X}",
+				dev.eeprom[1], dev.eeprom[2], 0x0403, 0x6014)
+		}
+		if c, err := ft.EEPROMConfig(); nil != err || "USB0" != c.Serial {
+			t.Fatalf("EEPROMConfig()={%v}, expected Serial={%q}: %v", c, "USB0", err)
+		}
+	})
+}
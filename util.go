@@ -159,7 +159,7 @@ func (o ByteOrder) Bytes(count uint, value uint64) []uint8 {
 		case MSB:
 			b[i] = uint8((value >> ((count - uint(i) - 1) * 8)) & 0xFF)
 		case LSB:
-			b[i] = uint8((value >> (count * 8)) & 0xFF)
+			b[i] = uint8((value >> (uint(i) * 8)) & 0xFF)
 		}
 	}
 	return b
@@ -193,3 +193,42 @@ func (o ByteOrder) Uint(count uint, bytes []uint8) uint64 {
 	}
 	return n
 }
+
+// RegField describes a named bitfield of an I²C or SPI slave device register
+// (see I2CReg and SPIReg), e.g. the averaging mode of the INA260 configuration
+// register, bits 11-9:
+//
+//	RegField{Name: "AVG", Shift: 9, Width: 3}
+type RegField struct {
+	Name  string // name of the bitfield
+	Shift uint   // bit position of the least significant bit
+	Width uint   // number of bits
+}
+
+// String returns a descriptive string of an RegField.
+func (f RegField) String() string {
+	if f.Width > 1 {
+		return fmt.Sprintf("%s[%d:%d]", f.Name, f.Shift+f.Width-1, f.Shift)
+	}
+	return fmt.Sprintf("%s[%d]", f.Name, f.Shift)
+}
+
+// Max returns the maximum value of the bitfield.
+func (f RegField) Max() uint64 {
+	if f.Width >= 64 {
+		return ^uint64(0)
+	}
+	return 1<<f.Width - 1
+}
+
+// Mask returns the mask of the bits of the bitfield in its register.
+func (f RegField) Mask() uint64 { return f.Max() << f.Shift }
+
+// Get returns the value of the bitfield in the given register value.
+func (f RegField) Get(reg uint64) uint64 { return (reg & f.Mask()) >> f.Shift }
+
+// Set returns the given register value with the bitfield replaced by the given
+// value, truncated to the width of the bitfield.
+func (f RegField) Set(reg uint64, value uint64) uint64 {
+	return (reg & ^f.Mask()) | ((value << f.Shift) & f.Mask())
+}
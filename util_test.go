@@ -167,3 +167,72 @@ func TestAddrSpace(t *testing.T) {
 	}
 
 }
+
+func TestByteOrderBytes(t *testing.T) {
+
+	for _, test := range []struct {
+		order ByteOrder
+		count uint
+		value uint64
+		exp   []uint8
+	}{
+		{
+			order: MSB,
+			count: 1,
+			value: 0x12,
+			exp:   []uint8{0x12},
+		},
+		{
+			order: LSB,
+			count: 1,
+			value: 0x12,
+			exp:   []uint8{0x12},
+		},
+		{
+			order: MSB,
+			count: 2,
+			value: 0x1234,
+			exp:   []uint8{0x12, 0x34},
+		},
+		{
+			order: LSB,
+			count: 2,
+			value: 0x1234,
+			exp:   []uint8{0x34, 0x12},
+		},
+		{
+			order: MSB,
+			count: 3,
+			value: 0xAB123456,
+			exp:   []uint8{0x12, 0x34, 0x56},
+		},
+		{
+			order: LSB,
+			count: 3,
+			value: 0xAB123456,
+			exp:   []uint8{0x56, 0x34, 0x12},
+		},
+		{
+			order: MSB,
+			count: 9,
+			value: 0x0102030405060708,
+			exp:   []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		},
+		{
+			order: LSB,
+			count: 9,
+			value: 0x0102030405060708,
+			exp:   []uint8{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+		},
+	} {
+		t.Run(fmt.Sprintf("%s/%d/0x%X", test.order, test.count, test.value),
+			func(s *testing.T) {
+
+				got := test.order.Bytes(test.count, test.value)
+				if fmt.Sprint(got) != fmt.Sprint(test.exp) {
+					s.Fatalf("Bytes(%d, 0x%X)={%v}, expected={%v}",
+						test.count, test.value, got, test.exp)
+				}
+			})
+	}
+}